@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	. "FinalProject/stores"
+	. "FinalProject/utils"
+)
+
+func ListSnapshotsHandler(w http.ResponseWriter, r *http.Request, snapshots *SnapshotManager) {
+	log.Println("ListSnapshotsHandler: Received request to list snapshot generations.")
+	generations, err := snapshots.ListSnapshots(r.Context())
+	if err != nil {
+		log.Printf("ListSnapshotsHandler: Failed to list snapshots. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusInternalServerError, "Failed to list snapshots")
+		return
+	}
+	log.Printf("ListSnapshotsHandler: Snapshots retrieved successfully. Count: %d\n", len(generations))
+	e.RespondWithJSON(w, http.StatusOK, generations)
+}
+
+func CreateSnapshotHandler(w http.ResponseWriter, r *http.Request, snapshots *SnapshotManager, stores *Stores) {
+	log.Println("CreateSnapshotHandler: Received request to create a snapshot generation.")
+	generation, err := snapshots.CreateSnapshot(r.Context(), stores)
+	if err != nil {
+		log.Printf("CreateSnapshotHandler: Failed to create snapshot. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("CreateSnapshotHandler: Snapshot created successfully. Generation: %s\n", generation.Generation)
+	e.RespondWithJSON(w, http.StatusCreated, generation)
+}
+
+func RestoreSnapshotHandler(w http.ResponseWriter, r *http.Request, snapshots *SnapshotManager, stores *Stores) {
+	log.Println("RestoreSnapshotHandler: Received request to restore a snapshot generation.")
+	generation, err := ExtractPathParam(r, 3)
+	if err != nil {
+		log.Printf("RestoreSnapshotHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := snapshots.RestoreSnapshot(r.Context(), generation, stores); err != nil {
+		log.Printf("RestoreSnapshotHandler: Failed to restore snapshot %s. Error: %v\n", generation, err)
+		e.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("RestoreSnapshotHandler: Snapshot restored successfully. Generation: %s\n", generation)
+	e.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success", "generation": generation})
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	. "FinalProject/models"
+	. "FinalProject/stores"
+)
+
+// ListStockAlertsHandler answers the open stock alerts, ?status=resolved or ?status=all for the others.
+func ListStockAlertsHandler(w http.ResponseWriter, r *http.Request, alertStore AlertStore) {
+	log.Println("ListStockAlertsHandler: Received request to list the stock alerts.")
+	status := AlertOpen
+	switch param := r.URL.Query().Get("status"); param {
+	case "", string(AlertOpen):
+	case string(AlertResolved):
+		status = AlertResolved
+	case "all":
+		status = ""
+	default:
+		log.Printf("ListStockAlertsHandler: Invalid status %q.\n", param)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid status '"+param+"', expected open, resolved or all")
+		return
+	}
+	alerts, err := alertStore.ListStockAlerts(r.Context(), status)
+	if err != nil {
+		log.Printf("ListStockAlertsHandler: Failed to retrieve the stock alerts. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusInternalServerError, "Failed to get the stock alerts")
+		return
+	}
+	log.Printf("ListStockAlertsHandler: %d stock alerts retrieved.\n", len(alerts))
+	e.RespondWithJSON(w, http.StatusOK, alerts)
+}
@@ -10,7 +10,7 @@ import (
 	. "FinalProject/utils"
 )
 
-func CreateAuthorHandler(w http.ResponseWriter, r *http.Request, auth *InMemoryAuthorStore) {
+func CreateAuthorHandler(w http.ResponseWriter, r *http.Request, auth AuthorStore) {
 	log.Println("CreateAuthorHandler: Received request to create an author.")
 	var author Author
 	if err := json.NewDecoder(r.Body).Decode(&author); err != nil {
@@ -33,7 +33,7 @@ func CreateAuthorHandler(w http.ResponseWriter, r *http.Request, auth *InMemoryA
 	e.RespondWithError(w, http.StatusBadRequest, "All fields should have a value")
 }
 
-func GetAuthorByIdHandler(w http.ResponseWriter, r *http.Request, auth *InMemoryAuthorStore) {
+func GetAuthorByIdHandler(w http.ResponseWriter, r *http.Request, auth AuthorStore) {
 	log.Println("GetAuthorByIdHandler: Received request to retrieve an author by ID.")
 	authorID, err := ExtractPathParamInt(r)
 	if err != nil {
@@ -51,7 +51,7 @@ func GetAuthorByIdHandler(w http.ResponseWriter, r *http.Request, auth *InMemory
 	e.RespondWithJSON(w, http.StatusOK, author)
 }
 
-func UpdateAuthorHandler(w http.ResponseWriter, r *http.Request, auth *InMemoryAuthorStore) {
+func UpdateAuthorHandler(w http.ResponseWriter, r *http.Request, auth AuthorStore) {
 	log.Println("UpdateAuthorHandler: Received request to update an author.")
 	authorID, err := ExtractPathParamInt(r)
 	if err != nil {
@@ -92,7 +92,7 @@ func UpdateAuthorHandler(w http.ResponseWriter, r *http.Request, auth *InMemoryA
 	e.RespondWithJSON(w, http.StatusOK, updatedAuthor)
 }
 
-func DeleteAuthorHandler(w http.ResponseWriter, r *http.Request, auth *InMemoryAuthorStore, s *InMemoryBookStore) {
+func DeleteAuthorHandler(w http.ResponseWriter, r *http.Request, auth AuthorStore) {
 	log.Println("DeleteAuthorHandler: Received request to delete an author.")
 	authorID, err := ExtractPathParamInt(r)
 	if err != nil {
@@ -100,7 +100,7 @@ func DeleteAuthorHandler(w http.ResponseWriter, r *http.Request, auth *InMemoryA
 		e.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	err = auth.DeleteAuthor(r.Context(), authorID, s)
+	err = auth.DeleteAuthor(r.Context(), authorID)
 	if err != nil {
 		log.Printf("DeleteAuthorHandler: Failed to delete author. ID: %d. Error: %v\n", authorID, err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -110,7 +110,7 @@ func DeleteAuthorHandler(w http.ResponseWriter, r *http.Request, auth *InMemoryA
 	e.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 }
 
-func ListAllHandler(w http.ResponseWriter, r *http.Request, auth *InMemoryAuthorStore) {
+func ListAllHandler(w http.ResponseWriter, r *http.Request, auth AuthorStore) {
 	log.Println("ListAllHandler: Received request to list all authors.")
 	authors, err := auth.ListAuthors(r.Context())
 	if err != nil {
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -11,7 +12,7 @@ import (
 	. "FinalProject/utils"
 )
 
-func CreateBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookStore, auth *InMemoryAuthorStore) {
+func CreateBookHandler(w http.ResponseWriter, r *http.Request, s BookStore) {
 	log.Println("CreateBookHandler: Received request to create a book.")
 	var book Book
 	if err := json.NewDecoder(r.Body).Decode(&book); err != nil {
@@ -19,10 +20,38 @@ func CreateBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookSt
 		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for creating a new book")
 		return
 	}
-	if book.Author.ID != 0 && book.Title != "" && book.Genres != nil && book.PublishedAt != (time.Time{}) && book.Price > 0 && book.Stock > 0 {
-		createdBook, err := s.CreateBook(r.Context(), book, auth)
+	prices, err := NormalizePrices(book.Prices)
+	if err != nil {
+		log.Printf("CreateBookHandler: Invalid price list. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	book.Prices = prices
+	if book.Weight < 0 {
+		log.Println("CreateBookHandler: Negative weight.")
+		e.RespondWithError(w, http.StatusBadRequest, "The weight of a book can't be negative")
+		return
+	}
+	if book.ReorderPoint < 0 || book.ReorderQuantity < 0 {
+		log.Println("CreateBookHandler: Negative reorder point or quantity.")
+		e.RespondWithError(w, http.StatusBadRequest, "The reorder point and quantity of a book can't be negative")
+		return
+	}
+	if book.UnitCost < 0 {
+		log.Println("CreateBookHandler: Negative unit cost.")
+		e.RespondWithError(w, http.StatusBadRequest, "The unit cost of a book can't be negative")
+		return
+	}
+	// a book not published yet can be created without stock, it is pre-ordered
+	stocked := book.Stock > 0 || (book.Stock == 0 && !book.Released(time.Now()))
+	if len(book.Contributors) > 0 && book.Title != "" && book.Genres != nil && book.PublishedAt != (time.Time{}) && book.Price > 0 && stocked {
+		createdBook, err := s.CreateBook(r.Context(), book)
 		if err != nil {
 			log.Printf("CreateBookHandler: Failed to create book. Error: %v\n", err)
+			if errors.Is(err, ErrInvalidContributors) {
+				e.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
 			e.RespondWithError(w, http.StatusInternalServerError, "Failed to create book")
 			return
 		}
@@ -35,7 +64,7 @@ func CreateBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookSt
 	return
 }
 
-func GetBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookStore, auths *InMemoryAuthorStore) {
+func GetBookHandler(w http.ResponseWriter, r *http.Request, s BookStore) {
 	log.Println("GetBookHandler: Received request to retrieve a book by ID.")
 	bookID, err := ExtractPathParamInt(r)
 	if err != nil {
@@ -43,7 +72,7 @@ func GetBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookStore
 		e.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	book, err := s.GetBook(r.Context(), bookID, auths)
+	book, err := s.GetBook(r.Context(), bookID)
 	if err != nil {
 		log.Printf("GetBookHandler: Book not found. ID: %d. Error: %v\n", bookID, err)
 		e.RespondWithError(w, http.StatusNotFound, err.Error())
@@ -53,7 +82,7 @@ func GetBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookStore
 	e.RespondWithJSON(w, http.StatusOK, book)
 }
 
-func UpdateBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookStore, auth *InMemoryAuthorStore) {
+func UpdateBookHandler(w http.ResponseWriter, r *http.Request, s BookStore) {
 	log.Println("UpdateBookHandler: Received request to update a book.")
 	bookID, err1 := ExtractPathParamInt(r)
 	if err1 != nil {
@@ -61,7 +90,7 @@ func UpdateBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookSt
 		e.RespondWithError(w, http.StatusBadRequest, err1.Error())
 		return
 	}
-	existingBook, err := s.GetBook(r.Context(), bookID, auth)
+	existingBook, err := s.GetBook(r.Context(), bookID)
 	if err != nil {
 		log.Printf("UpdateBookHandler: Book not found. ID: %d. Error: %v\n", bookID, err)
 		e.RespondWithError(w, http.StatusNotFound, err.Error())
@@ -77,8 +106,8 @@ func UpdateBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookSt
 	if updatedBook.Title == "" {
 		updatedBook.Title = existingBook.Title
 	}
-	if updatedBook.Author == (Author{}) {
-		updatedBook.Author = existingBook.Author
+	if updatedBook.Contributors == nil {
+		updatedBook.Contributors = existingBook.Contributors
 	}
 	if len(updatedBook.Genres) == 0 {
 		updatedBook.Genres = existingBook.Genres
@@ -92,10 +121,42 @@ func UpdateBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookSt
 	if updatedBook.Stock == 0 {
 		updatedBook.Stock = existingBook.Stock
 	}
+	if updatedBook.Weight == 0 {
+		updatedBook.Weight = existingBook.Weight
+	}
+	if updatedBook.Prices == nil {
+		updatedBook.Prices = existingBook.Prices
+	}
+	if updatedBook.ReorderPoint == 0 {
+		updatedBook.ReorderPoint = existingBook.ReorderPoint
+	}
+	if updatedBook.ReorderQuantity == 0 {
+		updatedBook.ReorderQuantity = existingBook.ReorderQuantity
+	}
+	if updatedBook.ReorderPoint < 0 || updatedBook.ReorderQuantity < 0 {
+		log.Println("UpdateBookHandler: Negative reorder point or quantity.")
+		e.RespondWithError(w, http.StatusBadRequest, "The reorder point and quantity of a book can't be negative")
+		return
+	}
+	prices, err := NormalizePrices(updatedBook.Prices)
+	if err != nil {
+		log.Printf("UpdateBookHandler: Invalid price list. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	updatedBook.Prices = prices
 
-	b, err := s.UpdateBook(r.Context(), bookID, updatedBook, auth)
+	b, err := s.UpdateBook(r.Context(), bookID, updatedBook)
 	if err != nil {
 		log.Printf("UpdateBookHandler: Failed to update book. ID: %d. Error: %v\n", bookID, err)
+		if errors.Is(err, ErrStockBelowReserved) {
+			e.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, ErrInvalidContributors) {
+			e.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		e.RespondWithError(w, http.StatusInternalServerError, "Failed to update book")
 		return
 	}
@@ -103,7 +164,7 @@ func UpdateBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookSt
 	e.RespondWithJSON(w, http.StatusOK, b)
 }
 
-func DeleteBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookStore) {
+func DeleteBookHandler(w http.ResponseWriter, r *http.Request, s BookStore) {
 	log.Println("DeleteBookHandler: Received request to delete a book.")
 	bookID, err1 := ExtractPathParamInt(r)
 	if err1 != nil {
@@ -114,6 +175,10 @@ func DeleteBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookSt
 	err := s.DeleteBook(r.Context(), bookID)
 	if err != nil {
 		log.Printf("DeleteBookHandler: Failed to delete book. ID: %d. Error: %v\n", bookID, err)
+		if errors.Is(err, ErrBookInUse) {
+			e.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -121,7 +186,7 @@ func DeleteBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookSt
 	e.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 }
 
-func SearchBookHandler(w http.ResponseWriter, r *http.Request, s *InMemoryBookStore) {
+func SearchBookHandler(w http.ResponseWriter, r *http.Request, s BookStore) {
 	log.Println("SearchBookHandler: Received request to search books.")
 	titleParam := r.URL.Query().Get("Title")
 	authorParam := r.URL.Query().Get("Author")
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	. "FinalProject/models"
+	. "FinalProject/stores"
+	. "FinalProject/utils"
+)
+
+const defaultAbandonedCartIdle = 24 * time.Hour
+
+func GetCartHandler(w http.ResponseWriter, r *http.Request, cartStore CartStore) {
+	log.Println("GetCartHandler: Received request to retrieve a cart.")
+	customerID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("GetCartHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cart, err := cartStore.GetCart(r.Context(), customerID)
+	if err != nil {
+		log.Printf("GetCartHandler: Failed to retrieve the cart of customer %d. Error: %v\n", customerID, err)
+		respondWithCartError(w, err)
+		return
+	}
+	log.Printf("GetCartHandler: Cart %d retrieved successfully, %d problems.\n", cart.ID, len(cart.Problems))
+	e.RespondWithJSON(w, http.StatusOK, cart)
+}
+
+// UpdateCartHandler sets the coupon codes, the currency and the shipping method of the cart.
+func UpdateCartHandler(w http.ResponseWriter, r *http.Request, cartStore CartStore) {
+	log.Println("UpdateCartHandler: Received request to update the options of a cart.")
+	customerID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("UpdateCartHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var options Cart
+	if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+		log.Printf("UpdateCartHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for updating a cart")
+		return
+	}
+	cart, err := cartStore.UpdateCartOptions(r.Context(), customerID, options)
+	if err != nil {
+		log.Printf("UpdateCartHandler: Failed to update the cart of customer %d. Error: %v\n", customerID, err)
+		respondWithCartError(w, err)
+		return
+	}
+	log.Printf("UpdateCartHandler: Cart %d updated successfully.\n", cart.ID)
+	e.RespondWithJSON(w, http.StatusOK, cart)
+}
+
+func ClearCartHandler(w http.ResponseWriter, r *http.Request, cartStore CartStore) {
+	log.Println("ClearCartHandler: Received request to clear a cart.")
+	customerID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("ClearCartHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := cartStore.ClearCart(r.Context(), customerID); err != nil {
+		log.Printf("ClearCartHandler: Failed to clear the cart of customer %d. Error: %v\n", customerID, err)
+		respondWithCartError(w, err)
+		return
+	}
+	log.Printf("ClearCartHandler: Cart of customer %d cleared.\n", customerID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func AddCartItemHandler(w http.ResponseWriter, r *http.Request, cartStore CartStore) {
+	log.Println("AddCartItemHandler: Received request to add a book to a cart.")
+	customerID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("AddCartItemHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var item CartItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		log.Printf("AddCartItemHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for adding a book to a cart")
+		return
+	}
+	cart, err := cartStore.AddCartItem(r.Context(), customerID, item)
+	if err != nil {
+		log.Printf("AddCartItemHandler: Failed to add book %d to the cart of customer %d. Error: %v\n", item.BookID, customerID, err)
+		respondWithCartError(w, err)
+		return
+	}
+	log.Printf("AddCartItemHandler: Book %d added to cart %d.\n", item.BookID, cart.ID)
+	e.RespondWithJSON(w, http.StatusOK, cart)
+}
+
+func UpdateCartItemHandler(w http.ResponseWriter, r *http.Request, cartStore CartStore) {
+	log.Println("UpdateCartItemHandler: Received request to change a quantity in a cart.")
+	customerID, bookID, err := extractCartItemPath(r)
+	if err != nil {
+		log.Printf("UpdateCartItemHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var item CartItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		log.Printf("UpdateCartItemHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for updating a cart item")
+		return
+	}
+	cart, err := cartStore.UpdateCartItem(r.Context(), customerID, bookID, item.Quantity)
+	if err != nil {
+		log.Printf("UpdateCartItemHandler: Failed to update book %d in the cart of customer %d. Error: %v\n", bookID, customerID, err)
+		respondWithCartError(w, err)
+		return
+	}
+	log.Printf("UpdateCartItemHandler: Book %d of cart %d now has quantity %d.\n", bookID, cart.ID, item.Quantity)
+	e.RespondWithJSON(w, http.StatusOK, cart)
+}
+
+func RemoveCartItemHandler(w http.ResponseWriter, r *http.Request, cartStore CartStore) {
+	log.Println("RemoveCartItemHandler: Received request to remove a book from a cart.")
+	customerID, bookID, err := extractCartItemPath(r)
+	if err != nil {
+		log.Printf("RemoveCartItemHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cart, err := cartStore.RemoveCartItem(r.Context(), customerID, bookID)
+	if err != nil {
+		log.Printf("RemoveCartItemHandler: Failed to remove book %d from the cart of customer %d. Error: %v\n", bookID, customerID, err)
+		respondWithCartError(w, err)
+		return
+	}
+	log.Printf("RemoveCartItemHandler: Book %d removed from cart %d.\n", bookID, cart.ID)
+	e.RespondWithJSON(w, http.StatusOK, cart)
+}
+
+// CheckoutCartHandler places the order of the cart, it answers the order like POST /orders.
+func CheckoutCartHandler(w http.ResponseWriter, r *http.Request, cartStore CartStore) {
+	log.Println("CheckoutCartHandler: Received request to check out a cart.")
+	customerID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("CheckoutCartHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	order, err := cartStore.CheckoutCart(r.Context(), customerID)
+	if err != nil {
+		log.Printf("CheckoutCartHandler: Failed to check out the cart of customer %d. Error: %v\n", customerID, err)
+		respondWithCartError(w, err)
+		return
+	}
+	log.Printf("CheckoutCartHandler: Cart of customer %d checked out into order %d.\n", customerID, order.ID)
+	e.RespondWithJSON(w, http.StatusCreated, order)
+}
+
+// ListAbandonedCartsHandler lists the carts left with books for longer than ?idle=, 24h by default.
+func ListAbandonedCartsHandler(w http.ResponseWriter, r *http.Request, cartStore CartStore) {
+	log.Println("ListAbandonedCartsHandler: Received request to list the abandoned carts.")
+	idle := defaultAbandonedCartIdle
+	if param := r.URL.Query().Get("idle"); param != "" {
+		parsed, err := time.ParseDuration(param)
+		if err != nil || parsed < 0 {
+			log.Printf("ListAbandonedCartsHandler: Invalid idle duration %q.\n", param)
+			e.RespondWithError(w, http.StatusBadRequest, "Invalid idle duration '"+param+"', expected a duration like 48h")
+			return
+		}
+		idle = parsed
+	}
+	carts, err := cartStore.ListAbandonedCarts(r.Context(), idle)
+	if err != nil {
+		log.Printf("ListAbandonedCartsHandler: Failed to retrieve the abandoned carts. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusInternalServerError, "Failed to get the abandoned carts")
+		return
+	}
+	log.Printf("ListAbandonedCartsHandler: %d abandoned carts retrieved.\n", len(carts))
+	e.RespondWithJSON(w, http.StatusOK, carts)
+}
+
+func respondWithCartError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrCartNotFound) || errors.Is(err, ErrRecordNotFound) {
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	e.RespondWithError(w, http.StatusBadRequest, err.Error())
+}
+
+// extractCartItemPath reads /customers/{id}/cart/items/{bookId}.
+func extractCartItemPath(r *http.Request) (int, int, error) {
+	customerID, err := ExtractPathParamInt(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	param, err := ExtractPathParam(r, 5)
+	if err != nil {
+		return 0, 0, err
+	}
+	bookID, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, 0, errors.New("Invalid book ID " + param)
+	}
+	return customerID, bookID, nil
+}
@@ -10,7 +10,7 @@ import (
 	. "FinalProject/utils"
 )
 
-func CreateCustomerHandler(w http.ResponseWriter, r *http.Request, c *InMemoryCustomerStore) {
+func CreateCustomerHandler(w http.ResponseWriter, r *http.Request, c CustomerStore) {
 	log.Println("CreateCustomerHandler: Received request to create a customer.")
 	var customer Customer
 	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
@@ -33,7 +33,7 @@ func CreateCustomerHandler(w http.ResponseWriter, r *http.Request, c *InMemoryCu
 	e.RespondWithError(w, http.StatusBadRequest, "All fields should have a value")
 }
 
-func GetCustomerByIDHandler(w http.ResponseWriter, r *http.Request, c *InMemoryCustomerStore) {
+func GetCustomerByIDHandler(w http.ResponseWriter, r *http.Request, c CustomerStore) {
 	log.Println("GetCustomerByIDHandler: Received request to retrieve a customer by ID.")
 	customerID, err := ExtractPathParamInt(r)
 	if err != nil {
@@ -51,7 +51,7 @@ func GetCustomerByIDHandler(w http.ResponseWriter, r *http.Request, c *InMemoryC
 	e.RespondWithJSON(w, http.StatusOK, customer)
 }
 
-func GetAllCustomersHandler(w http.ResponseWriter, r *http.Request, c *InMemoryCustomerStore) {
+func GetAllCustomersHandler(w http.ResponseWriter, r *http.Request, c CustomerStore) {
 	log.Println("GetAllCustomersHandler: Received request to list all customers.")
 	customers, err := c.ListCustomers(r.Context())
 	if err != nil {
@@ -63,7 +63,7 @@ func GetAllCustomersHandler(w http.ResponseWriter, r *http.Request, c *InMemoryC
 	e.RespondWithJSON(w, http.StatusOK, customers)
 }
 
-func UpdateCustomerHandler(w http.ResponseWriter, r *http.Request, c *InMemoryCustomerStore) {
+func UpdateCustomerHandler(w http.ResponseWriter, r *http.Request, c CustomerStore) {
 	log.Println("UpdateCustomerHandler: Received request to update a customer.")
 	customerID, err := ExtractPathParamInt(r)
 	if err != nil {
@@ -104,7 +104,7 @@ func UpdateCustomerHandler(w http.ResponseWriter, r *http.Request, c *InMemoryCu
 	e.RespondWithJSON(w, http.StatusOK, updatedCustomer)
 }
 
-func DeleteCustomerHandler(w http.ResponseWriter, r *http.Request, c *InMemoryCustomerStore, o *InMemoryOrderStore) {
+func DeleteCustomerHandler(w http.ResponseWriter, r *http.Request, c CustomerStore) {
 	log.Println("DeleteCustomerHandler: Received request to delete a customer.")
 	customerID, err := ExtractPathParamInt(r)
 	if err != nil {
@@ -112,7 +112,7 @@ func DeleteCustomerHandler(w http.ResponseWriter, r *http.Request, c *InMemoryCu
 		e.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	err = c.DeleteCustomer(r.Context(), customerID, o)
+	err = c.DeleteCustomer(r.Context(), customerID)
 	if err != nil {
 		log.Printf("DeleteCustomerHandler: Failed to delete customer. ID: %d. Error: %v\n", customerID, err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	. "FinalProject/models"
+	. "FinalProject/stores"
+)
+
+// WithExpansion resolves the references named in ?expand=author,customer,items.book on every endpoint.
+// The handlers answer with IDs, their JSON response is held back and expanded before it is sent.
+// Errors are sent as they are.
+func WithExpansion(next http.Handler, stores *Stores) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		param := r.URL.Query().Get("expand")
+		if param == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		expansion, err := ParseExpansion(param)
+		if err != nil {
+			log.Printf("WithExpansion: Invalid expand parameter %q. Error: %v\n", param, err)
+			e.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !expansion.Any() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		response := &heldResponse{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(response, r)
+		body := response.body.Bytes()
+		if response.status < 300 && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err == nil {
+				stores.ExpandReferences(value, expansion)
+				if expanded, err := json.Marshal(value); err == nil {
+					body = append(expanded, '\n')
+				}
+			} else {
+				log.Printf("WithExpansion: Response of %s is not valid JSON, sent as it is. Error: %v\n", r.URL.Path, err)
+			}
+		}
+		w.WriteHeader(response.status)
+		w.Write(body)
+	})
+}
+
+// heldResponse keeps the response of the handler instead of sending it, only the headers go through.
+type heldResponse struct {
+	http.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (r *heldResponse) WriteHeader(status int) {
+	if !r.written {
+		r.status = status
+		r.written = true
+	}
+}
+
+func (r *heldResponse) Write(data []byte) (int, error) {
+	r.written = true
+	return r.body.Write(data)
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	. "FinalProject/models"
+	. "FinalProject/stores"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// WithIdempotencyKey runs a create handler at most once per Idempotency-Key header. A retry with the
+// same key and body gets the stored response back, with the Idempotent-Replayed header set, a retry
+// with another body gets a 422. Requests without the header are handled as usual.
+func WithIdempotencyKey(w http.ResponseWriter, r *http.Request, idempotencyStore IdempotencyStore, handler http.HandlerFunc) {
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if key == "" {
+		handler(w, r)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		log.Printf("WithIdempotencyKey: Idempotency key too long (%d characters).\n", len(key))
+		e.RespondWithError(w, http.StatusBadRequest, "The Idempotency-Key header can't be longer than 255 characters")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("WithIdempotencyKey: Failed to read the request body. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	record, replay, err := idempotencyStore.BeginRequest(r.Context(), key, r.Method, r.URL.Path, requestFingerprint(r, body))
+	if err != nil {
+		log.Printf("WithIdempotencyKey: Idempotency key %s refused. Error: %v\n", key, err)
+		switch {
+		case errors.Is(err, ErrIdempotencyKeyMismatch):
+			e.RespondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		case errors.Is(err, ErrIdempotencyKeyInUse):
+			e.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			e.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if replay {
+		log.Printf("WithIdempotencyKey: Replaying the response of idempotency key %s, status %d\n", key, record.Status)
+		if record.ContentType != "" {
+			w.Header().Set("Content-Type", record.ContentType)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(record.Status)
+		io.WriteString(w, record.Body)
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: w}
+	// the key is released even if the handler panics, the response is stored once the handler returns
+	defer func() {
+		if err := idempotencyStore.FinishRequest(context.WithoutCancel(r.Context()), record); err != nil {
+			log.Printf("WithIdempotencyKey: Failed to store the response of idempotency key %s. Error: %v\n", key, err)
+		}
+	}()
+	handler(recorder, r)
+	record.Status = recorder.status
+	record.ContentType = recorder.Header().Get("Content-Type")
+	record.Body = recorder.body.String()
+}
+
+// requestFingerprint hashes the method, the path and the body. A JSON body is compacted with its keys
+// sorted first, so the same request sent with another layout still matches.
+func requestFingerprint(r *http.Request, body []byte) string {
+	canonical := body
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err == nil {
+		if encoded, err := json.Marshal(value); err == nil {
+			canonical = encoded
+		}
+	}
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.Path+"\n")
+	hash.Write(canonical)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder passes the response on to the client and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	. "FinalProject/models"
+	. "FinalProject/stores"
+	. "FinalProject/utils"
+)
+
+// GetStockMovementsHandler answers the ledger of a book, with whether it adds up to the stock of the book.
+func GetStockMovementsHandler(w http.ResponseWriter, r *http.Request, inventoryStore InventoryStore) {
+	log.Println("GetStockMovementsHandler: Received request to retrieve the stock movements of a book.")
+	bookID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("GetStockMovementsHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ledger, err := inventoryStore.GetStockLedger(r.Context(), bookID)
+	if err != nil {
+		log.Printf("GetStockMovementsHandler: Failed to retrieve the ledger of book %d. Error: %v\n", bookID, err)
+		respondWithInventoryError(w, err)
+		return
+	}
+	if !ledger.Reconciled {
+		log.Printf("GetStockMovementsHandler: Book %d has %d in stock but its ledger adds up to %d.\n", bookID, ledger.Stock, ledger.LedgerStock)
+	}
+	log.Printf("GetStockMovementsHandler: %d stock movements retrieved for book %d.\n", len(ledger.Movements), bookID)
+	e.RespondWithJSON(w, http.StatusOK, ledger)
+}
+
+// RecordStockMovementHandler records a receipt or an adjustment of the stock of a book.
+func RecordStockMovementHandler(w http.ResponseWriter, r *http.Request, inventoryStore InventoryStore) {
+	log.Println("RecordStockMovementHandler: Received request to record a stock movement.")
+	bookID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("RecordStockMovementHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var movement StockMovement
+	if err := json.NewDecoder(r.Body).Decode(&movement); err != nil {
+		log.Printf("RecordStockMovementHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for recording a stock movement")
+		return
+	}
+	recorded, err := inventoryStore.RecordStockMovement(r.Context(), bookID, movement)
+	if err != nil {
+		log.Printf("RecordStockMovementHandler: Failed to record the movement of book %d. Error: %v\n", bookID, err)
+		respondWithInventoryError(w, err)
+		return
+	}
+	log.Printf("RecordStockMovementHandler: Stock movement %d recorded for book %d.\n", recorded.ID, bookID)
+	e.RespondWithJSON(w, http.StatusCreated, recorded)
+}
+
+func InventoryValuationHandler(w http.ResponseWriter, r *http.Request, inventoryStore InventoryStore) {
+	log.Println("InventoryValuationHandler: Received request to value the inventory.")
+	valuation, err := inventoryStore.ValueInventory(r.Context())
+	if err != nil {
+		log.Printf("InventoryValuationHandler: Failed to value the inventory. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusInternalServerError, "Failed to value the inventory")
+		return
+	}
+	log.Printf("InventoryValuationHandler: %d books valued, %d units worth %.2f %s at cost.\n", len(valuation.Books), valuation.TotalUnits, valuation.TotalCostValue, valuation.Currency)
+	e.RespondWithJSON(w, http.StatusOK, valuation)
+}
+
+func respondWithInventoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrRecordNotFound):
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrStockBelowReserved):
+		e.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -16,7 +17,7 @@ import (
 
 var e ErrorResponse
 
-func CreateOrderHandler(w http.ResponseWriter, r *http.Request, customerStore *InMemoryCustomerStore, bookStore *InMemoryBookStore, orderStore *InMemoryOrderStore) {
+func CreateOrderHandler(w http.ResponseWriter, r *http.Request, orderStore OrderStore) {
 	log.Println("CreateOrderHandler: Received request to create an order.")
 	var order Order
 	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
@@ -25,7 +26,7 @@ func CreateOrderHandler(w http.ResponseWriter, r *http.Request, customerStore *I
 		return
 	}
 	ctx := r.Context()
-	createdOrder, err := orderStore.CreateOrder(ctx, order, customerStore, bookStore)
+	createdOrder, err := orderStore.CreateOrder(ctx, order)
 	if err != nil {
 		log.Printf("CreateOrderHandler: Failed to create order. Error: %v\n", err)
 		e.RespondWithError(w, http.StatusBadRequest, err.Error())
@@ -35,7 +36,7 @@ func CreateOrderHandler(w http.ResponseWriter, r *http.Request, customerStore *I
 	e.RespondWithJSON(w, http.StatusCreated, createdOrder)
 }
 
-func GetOrderHandler(w http.ResponseWriter, r *http.Request, orderStore *InMemoryOrderStore) {
+func GetOrderHandler(w http.ResponseWriter, r *http.Request, orderStore OrderStore) {
 	log.Println("GetOrderHandler: Received request to retrieve an order by ID.")
 	orderID, err := ExtractPathParamInt(r)
 	if err != nil {
@@ -53,7 +54,7 @@ func GetOrderHandler(w http.ResponseWriter, r *http.Request, orderStore *InMemor
 	e.RespondWithJSON(w, http.StatusOK, order)
 }
 
-func GetAllOrdersHandler(w http.ResponseWriter, r *http.Request, orderStore *InMemoryOrderStore) {
+func GetAllOrdersHandler(w http.ResponseWriter, r *http.Request, orderStore OrderStore) {
 	log.Println("GetAllOrdersHandler: Received request to list all orders.")
 	orders, err := orderStore.ListOrders(r.Context())
 	if err != nil {
@@ -65,7 +66,7 @@ func GetAllOrdersHandler(w http.ResponseWriter, r *http.Request, orderStore *InM
 	e.RespondWithJSON(w, http.StatusOK, orders)
 }
 
-func UpdateOrderHandler(w http.ResponseWriter, r *http.Request, orderStore *InMemoryOrderStore, customerStore *InMemoryCustomerStore, bookStore *InMemoryBookStore) {
+func UpdateOrderHandler(w http.ResponseWriter, r *http.Request, orderStore OrderStore) {
 	log.Println("UpdateOrderHandler: Received request to update an order.")
 	orderID, err := ExtractPathParamInt(r)
 	if err != nil {
@@ -81,9 +82,13 @@ func UpdateOrderHandler(w http.ResponseWriter, r *http.Request, orderStore *InMe
 	}
 
 	ctx := r.Context()
-	err = orderStore.UpdateOrder(ctx, orderID, updatedOrder, customerStore, bookStore)
+	updatedOrder, err = orderStore.UpdateOrder(ctx, orderID, updatedOrder)
 	if err != nil {
 		log.Printf("UpdateOrderHandler: Failed to update order. ID: %d. Error: %v\n", orderID, err)
+		if errors.Is(err, ErrInvalidTransition) {
+			e.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
 		e.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -91,7 +96,52 @@ func UpdateOrderHandler(w http.ResponseWriter, r *http.Request, orderStore *InMe
 	e.RespondWithJSON(w, http.StatusOK, updatedOrder)
 }
 
-func DeleteOrderHandler(w http.ResponseWriter, r *http.Request, orderStore *InMemoryOrderStore) {
+// pay, cancel and refund go through the payment store, see PayOrderHandler and RefundOrderHandler
+var orderActions = map[string]OrderStatus{
+	"pack":    OrderPacked,
+	"ship":    OrderShipped,
+	"deliver": OrderDelivered,
+}
+
+func TransitionOrderHandler(w http.ResponseWriter, r *http.Request, orderStore OrderStore) {
+	log.Println("TransitionOrderHandler: Received request to change the status of an order.")
+	orderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("TransitionOrderHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	action, err := ExtractPathParam(r, 3)
+	if err != nil {
+		log.Printf("TransitionOrderHandler: Missing action. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status, ok := orderActions[action]
+	if !ok {
+		log.Printf("TransitionOrderHandler: Unknown action %s\n", action)
+		e.RespondWithError(w, http.StatusNotFound, "Unknown order action "+action)
+		return
+	}
+
+	order, err := orderStore.TransitionOrder(r.Context(), orderID, status)
+	if err != nil {
+		log.Printf("TransitionOrderHandler: Failed to %s order. ID: %d. Error: %v\n", action, orderID, err)
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			e.RespondWithError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, ErrInvalidTransition):
+			e.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			e.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	log.Printf("TransitionOrderHandler: Order %d is now %s\n", orderID, order.Status)
+	e.RespondWithJSON(w, http.StatusOK, order)
+}
+
+func DeleteOrderHandler(w http.ResponseWriter, r *http.Request, orderStore OrderStore) {
 	log.Println("DeleteOrderHandler: Received request to delete an order.")
 	orderID, err := ExtractPathParamInt(r)
 	if err != nil {
@@ -102,6 +152,10 @@ func DeleteOrderHandler(w http.ResponseWriter, r *http.Request, orderStore *InMe
 	err = orderStore.DeleteOrder(r.Context(), orderID)
 	if err != nil {
 		log.Printf("DeleteOrderHandler: Failed to delete order. ID: %d. Error: %v\n", orderID, err)
+		if errors.Is(err, ErrOrderPaid) {
+			e.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
 		e.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -109,7 +163,7 @@ func DeleteOrderHandler(w http.ResponseWriter, r *http.Request, orderStore *InMe
 	e.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 }
 
-func FetchOrdersWithinTimeHandler(w http.ResponseWriter, r *http.Request, store *InMemoryOrderStore) {
+func FetchOrdersWithinTimeHandler(w http.ResponseWriter, r *http.Request, store OrderStore) {
 	log.Println("FetchOrdersWithinTimeHandler: Received request to fetch orders within a time range.")
 	startTimeStr := r.URL.Query().Get("startTime")
 	endTimeStr := r.URL.Query().Get("endTime")
@@ -211,7 +265,7 @@ func ListReportsHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func ViewOrderHistoryHandler(w http.ResponseWriter, r *http.Request, store *InMemoryOrderStore) {
+func ViewOrderHistoryHandler(w http.ResponseWriter, r *http.Request, store OrderStore) {
 	log.Println("ViewOrderHistoryHandler: Received request to view order history.")
 
 	ctx := r.Context()
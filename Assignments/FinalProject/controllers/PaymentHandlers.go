@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	. "FinalProject/models"
+	. "FinalProject/payments"
+	. "FinalProject/stores"
+	. "FinalProject/utils"
+)
+
+// PayOrderHandler charges the card of the body and moves the order to paid.
+func PayOrderHandler(w http.ResponseWriter, r *http.Request, paymentStore PaymentStore) {
+	log.Println("PayOrderHandler: Received request to pay an order.")
+	orderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("PayOrderHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := paymentStore.ListPayments(r.Context(), orderID); err != nil {
+		log.Printf("PayOrderHandler: Order not found. ID: %d. Error: %v\n", orderID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	var card Card
+	if err := json.NewDecoder(r.Body).Decode(&card); err != nil || card.Number == "" {
+		log.Printf("PayOrderHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "A card with a number is needed to pay an order")
+		return
+	}
+	order, err := paymentStore.PayOrder(r.Context(), orderID, card)
+	if err != nil {
+		log.Printf("PayOrderHandler: Failed to pay order %d. Error: %v\n", orderID, err)
+		respondWithPaymentError(w, err)
+		return
+	}
+	log.Printf("PayOrderHandler: Order %d is now %s\n", orderID, order.Status)
+	e.RespondWithJSON(w, http.StatusOK, order)
+}
+
+// RefundOrderHandler serves the cancel and refund actions, the money of a paid order goes back first.
+func RefundOrderHandler(w http.ResponseWriter, r *http.Request, paymentStore PaymentStore) {
+	log.Println("RefundOrderHandler: Received request to cancel or refund an order.")
+	orderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("RefundOrderHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	action, err := ExtractPathParam(r, 3)
+	if err != nil {
+		log.Printf("RefundOrderHandler: Missing action. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := OrderRefunded
+	if action == "cancel" {
+		status = OrderCancelled
+	}
+	if _, err := paymentStore.ListPayments(r.Context(), orderID); err != nil {
+		log.Printf("RefundOrderHandler: Order not found. ID: %d. Error: %v\n", orderID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	order, err := paymentStore.RefundOrder(r.Context(), orderID, status)
+	if err != nil {
+		log.Printf("RefundOrderHandler: Failed to %s order %d. Error: %v\n", action, orderID, err)
+		respondWithPaymentError(w, err)
+		return
+	}
+	log.Printf("RefundOrderHandler: Order %d is now %s\n", orderID, order.Status)
+	e.RespondWithJSON(w, http.StatusOK, order)
+}
+
+func ListPaymentsHandler(w http.ResponseWriter, r *http.Request, paymentStore PaymentStore) {
+	log.Println("ListPaymentsHandler: Received request to list the payment attempts of an order.")
+	orderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("ListPaymentsHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	attempts, err := paymentStore.ListPayments(r.Context(), orderID)
+	if err != nil {
+		log.Printf("ListPaymentsHandler: Failed to retrieve the payments of order %d. Error: %v\n", orderID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.Printf("ListPaymentsHandler: %d payment attempts retrieved for order %d.\n", len(attempts), orderID)
+	e.RespondWithJSON(w, http.StatusOK, attempts)
+}
+
+func respondWithPaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidTransition):
+		e.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrDeclined):
+		e.RespondWithError(w, http.StatusPaymentRequired, err.Error())
+	case errors.Is(err, ErrTimeout):
+		e.RespondWithError(w, http.StatusGatewayTimeout, err.Error())
+	default:
+		e.RespondWithError(w, http.StatusBadGateway, err.Error())
+	}
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	. "FinalProject/stores"
+	. "FinalProject/utils"
+)
+
+// ListCustomerPreOrdersHandler answers the pre-orders of a customer with their position in the queue.
+func ListCustomerPreOrdersHandler(w http.ResponseWriter, r *http.Request, orderStore OrderStore) {
+	log.Println("ListCustomerPreOrdersHandler: Received request to list the pre-orders of a customer.")
+	customerID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("ListCustomerPreOrdersHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	preOrders, err := orderStore.ListPreOrders(r.Context(), customerID)
+	if err != nil {
+		log.Printf("ListCustomerPreOrdersHandler: Failed to retrieve the pre-orders of customer %d. Error: %v\n", customerID, err)
+		if errors.Is(err, ErrRecordNotFound) {
+			e.RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		e.RespondWithError(w, http.StatusInternalServerError, "Failed to get pre-orders")
+		return
+	}
+	log.Printf("ListCustomerPreOrdersHandler: %d pre-orders retrieved for customer %d.\n", len(preOrders), customerID)
+	e.RespondWithJSON(w, http.StatusOK, preOrders)
+}
+
+func PreOrderDemandHandler(w http.ResponseWriter, r *http.Request, orderStore OrderStore) {
+	log.Println("PreOrderDemandHandler: Received request for the pre-order demand report.")
+	report, err := orderStore.PreOrderDemand(r.Context())
+	if err != nil {
+		log.Printf("PreOrderDemandHandler: Failed to build the report. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusInternalServerError, "Failed to get the pre-order demand")
+		return
+	}
+	log.Printf("PreOrderDemandHandler: %d books pre-ordered by %d orders.\n", len(report.Books), report.TotalOrders)
+	e.RespondWithJSON(w, http.StatusOK, report)
+}
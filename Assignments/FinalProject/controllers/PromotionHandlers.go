@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	. "FinalProject/models"
+	. "FinalProject/stores"
+	. "FinalProject/utils"
+)
+
+func CreatePromotionHandler(w http.ResponseWriter, r *http.Request, promotionStore PromotionStore) {
+	log.Println("CreatePromotionHandler: Received request to create a promotion.")
+	var promotion Promotion
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		log.Printf("CreatePromotionHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for creating a new promotion")
+		return
+	}
+	createdPromotion, err := promotionStore.CreatePromotion(r.Context(), promotion)
+	if err != nil {
+		log.Printf("CreatePromotionHandler: Failed to create promotion. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("CreatePromotionHandler: Promotion created successfully. ID: %d\n", createdPromotion.ID)
+	e.RespondWithJSON(w, http.StatusCreated, createdPromotion)
+}
+
+func GetPromotionHandler(w http.ResponseWriter, r *http.Request, promotionStore PromotionStore) {
+	log.Println("GetPromotionHandler: Received request to retrieve a promotion by ID.")
+	promotionID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("GetPromotionHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	promotion, err := promotionStore.GetPromotion(r.Context(), promotionID)
+	if err != nil {
+		log.Printf("GetPromotionHandler: Promotion not found. ID: %d. Error: %v\n", promotionID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.Printf("GetPromotionHandler: Promotion retrieved successfully. ID: %d\n", promotionID)
+	e.RespondWithJSON(w, http.StatusOK, promotion)
+}
+
+func ListPromotionsHandler(w http.ResponseWriter, r *http.Request, promotionStore PromotionStore) {
+	log.Println("ListPromotionsHandler: Received request to list all promotions.")
+	promotions, err := promotionStore.ListPromotions(r.Context())
+	if err != nil {
+		log.Printf("ListPromotionsHandler: Failed to retrieve promotions. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusInternalServerError, "Failed to get promotions")
+		return
+	}
+	log.Println("ListPromotionsHandler: Promotions retrieved successfully.")
+	e.RespondWithJSON(w, http.StatusOK, promotions)
+}
+
+// UpdatePromotionHandler replaces the whole promotion, a field left out is cleared.
+func UpdatePromotionHandler(w http.ResponseWriter, r *http.Request, promotionStore PromotionStore) {
+	log.Println("UpdatePromotionHandler: Received request to update a promotion.")
+	promotionID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("UpdatePromotionHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := promotionStore.GetPromotion(r.Context(), promotionID); err != nil {
+		log.Printf("UpdatePromotionHandler: Promotion not found. ID: %d. Error: %v\n", promotionID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	var promotion Promotion
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		log.Printf("UpdatePromotionHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for promotion Update")
+		return
+	}
+	updatedPromotion, err := promotionStore.UpdatePromotion(r.Context(), promotionID, promotion)
+	if err != nil {
+		log.Printf("UpdatePromotionHandler: Failed to update promotion. ID: %d. Error: %v\n", promotionID, err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("UpdatePromotionHandler: Promotion updated successfully. ID: %d\n", promotionID)
+	e.RespondWithJSON(w, http.StatusOK, updatedPromotion)
+}
+
+func DeletePromotionHandler(w http.ResponseWriter, r *http.Request, promotionStore PromotionStore) {
+	log.Println("DeletePromotionHandler: Received request to delete a promotion.")
+	promotionID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("DeletePromotionHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := promotionStore.DeletePromotion(r.Context(), promotionID); err != nil {
+		log.Printf("DeletePromotionHandler: Failed to delete promotion. ID: %d. Error: %v\n", promotionID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.Printf("DeletePromotionHandler: Promotion deleted successfully. ID: %d\n", promotionID)
+	e.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+func ListRedemptionsHandler(w http.ResponseWriter, r *http.Request, promotionStore PromotionStore) {
+	log.Println("ListRedemptionsHandler: Received request to list the redemptions of a promotion.")
+	promotionID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("ListRedemptionsHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	redemptions, err := promotionStore.ListRedemptions(r.Context(), promotionID)
+	if err != nil {
+		log.Printf("ListRedemptionsHandler: Failed to list redemptions. ID: %d. Error: %v\n", promotionID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.Printf("ListRedemptionsHandler: %d redemptions found for promotion %d\n", len(redemptions), promotionID)
+	e.RespondWithJSON(w, http.StatusOK, redemptions)
+}
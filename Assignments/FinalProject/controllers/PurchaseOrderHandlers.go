@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	. "FinalProject/models"
+	. "FinalProject/stores"
+	. "FinalProject/utils"
+)
+
+func CreatePurchaseOrderHandler(w http.ResponseWriter, r *http.Request, purchaseOrderStore PurchaseOrderStore) {
+	log.Println("CreatePurchaseOrderHandler: Received request to create a purchase order.")
+	var purchaseOrder PurchaseOrder
+	if err := json.NewDecoder(r.Body).Decode(&purchaseOrder); err != nil {
+		log.Printf("CreatePurchaseOrderHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for creating a new purchase order")
+		return
+	}
+	createdPurchaseOrder, err := purchaseOrderStore.CreatePurchaseOrder(r.Context(), purchaseOrder)
+	if err != nil {
+		log.Printf("CreatePurchaseOrderHandler: Failed to create purchase order. Error: %v\n", err)
+		respondWithPurchaseOrderError(w, err)
+		return
+	}
+	log.Printf("CreatePurchaseOrderHandler: Purchase order created successfully. ID: %d\n", createdPurchaseOrder.ID)
+	e.RespondWithJSON(w, http.StatusCreated, createdPurchaseOrder)
+}
+
+func GetPurchaseOrderHandler(w http.ResponseWriter, r *http.Request, purchaseOrderStore PurchaseOrderStore) {
+	log.Println("GetPurchaseOrderHandler: Received request to retrieve a purchase order by ID.")
+	purchaseOrderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("GetPurchaseOrderHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	purchaseOrder, err := purchaseOrderStore.GetPurchaseOrder(r.Context(), purchaseOrderID)
+	if err != nil {
+		log.Printf("GetPurchaseOrderHandler: Purchase order not found. ID: %d. Error: %v\n", purchaseOrderID, err)
+		respondWithPurchaseOrderError(w, err)
+		return
+	}
+	log.Printf("GetPurchaseOrderHandler: Purchase order retrieved successfully. ID: %d\n", purchaseOrderID)
+	e.RespondWithJSON(w, http.StatusOK, purchaseOrder)
+}
+
+// ListPurchaseOrdersHandler answers the purchase orders, ?supplier_id= and ?status= narrow them down.
+func ListPurchaseOrdersHandler(w http.ResponseWriter, r *http.Request, purchaseOrderStore PurchaseOrderStore) {
+	log.Println("ListPurchaseOrdersHandler: Received request to list the purchase orders.")
+	supplierID := 0
+	if param := r.URL.Query().Get("supplier_id"); param != "" {
+		id, err := strconv.Atoi(param)
+		if err != nil || id <= 0 {
+			log.Printf("ListPurchaseOrdersHandler: Invalid supplier ID %q.\n", param)
+			e.RespondWithError(w, http.StatusBadRequest, "Invalid supplier_id '"+param+"'")
+			return
+		}
+		supplierID = id
+	}
+	status := PurchaseOrderStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", PurchaseOrderOpen, PurchaseOrderPartiallyReceived, PurchaseOrderReceived, PurchaseOrderCancelled:
+	default:
+		log.Printf("ListPurchaseOrdersHandler: Invalid status %q.\n", status)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid status '"+string(status)+"', expected open, partially_received, received or cancelled")
+		return
+	}
+	purchaseOrders, err := purchaseOrderStore.ListPurchaseOrders(r.Context(), supplierID, status)
+	if err != nil {
+		log.Printf("ListPurchaseOrdersHandler: Failed to retrieve purchase orders. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusInternalServerError, "Failed to get purchase orders")
+		return
+	}
+	log.Printf("ListPurchaseOrdersHandler: %d purchase orders retrieved.\n", len(purchaseOrders))
+	e.RespondWithJSON(w, http.StatusOK, purchaseOrders)
+}
+
+func UpdatePurchaseOrderHandler(w http.ResponseWriter, r *http.Request, purchaseOrderStore PurchaseOrderStore) {
+	log.Println("UpdatePurchaseOrderHandler: Received request to update a purchase order.")
+	purchaseOrderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("UpdatePurchaseOrderHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var purchaseOrder PurchaseOrder
+	if err := json.NewDecoder(r.Body).Decode(&purchaseOrder); err != nil {
+		log.Printf("UpdatePurchaseOrderHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for purchase order Update")
+		return
+	}
+	updatedPurchaseOrder, err := purchaseOrderStore.UpdatePurchaseOrder(r.Context(), purchaseOrderID, purchaseOrder)
+	if err != nil {
+		log.Printf("UpdatePurchaseOrderHandler: Failed to update purchase order. ID: %d. Error: %v\n", purchaseOrderID, err)
+		respondWithPurchaseOrderError(w, err)
+		return
+	}
+	log.Printf("UpdatePurchaseOrderHandler: Purchase order updated successfully. ID: %d\n", purchaseOrderID)
+	e.RespondWithJSON(w, http.StatusOK, updatedPurchaseOrder)
+}
+
+// ReceivePurchaseOrderHandler puts a delivery in stock, an empty body receives all that is still expected.
+func ReceivePurchaseOrderHandler(w http.ResponseWriter, r *http.Request, purchaseOrderStore PurchaseOrderStore) {
+	log.Println("ReceivePurchaseOrderHandler: Received request to receive a purchase order.")
+	purchaseOrderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("ReceivePurchaseOrderHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var receipt PurchaseReceipt
+	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("ReceivePurchaseOrderHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for receiving a purchase order")
+		return
+	}
+	purchaseOrder, err := purchaseOrderStore.ReceivePurchaseOrder(r.Context(), purchaseOrderID, receipt)
+	if err != nil {
+		log.Printf("ReceivePurchaseOrderHandler: Failed to receive purchase order %d. Error: %v\n", purchaseOrderID, err)
+		respondWithPurchaseOrderError(w, err)
+		return
+	}
+	log.Printf("ReceivePurchaseOrderHandler: Purchase order %d is now %s.\n", purchaseOrderID, purchaseOrder.Status)
+	e.RespondWithJSON(w, http.StatusOK, purchaseOrder)
+}
+
+func CancelPurchaseOrderHandler(w http.ResponseWriter, r *http.Request, purchaseOrderStore PurchaseOrderStore) {
+	log.Println("CancelPurchaseOrderHandler: Received request to cancel a purchase order.")
+	purchaseOrderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("CancelPurchaseOrderHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	purchaseOrder, err := purchaseOrderStore.CancelPurchaseOrder(r.Context(), purchaseOrderID)
+	if err != nil {
+		log.Printf("CancelPurchaseOrderHandler: Failed to cancel purchase order %d. Error: %v\n", purchaseOrderID, err)
+		respondWithPurchaseOrderError(w, err)
+		return
+	}
+	log.Printf("CancelPurchaseOrderHandler: Purchase order %d cancelled.\n", purchaseOrderID)
+	e.RespondWithJSON(w, http.StatusOK, purchaseOrder)
+}
+
+func respondWithPurchaseOrderError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrRecordNotFound):
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrPurchaseOrderClosed):
+		e.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+	}
+}
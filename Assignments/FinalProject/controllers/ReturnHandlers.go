@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	. "FinalProject/models"
+	. "FinalProject/payments"
+	. "FinalProject/stores"
+	. "FinalProject/utils"
+)
+
+func RequestReturnHandler(w http.ResponseWriter, r *http.Request, returnStore ReturnStore) {
+	log.Println("RequestReturnHandler: Received request to return books.")
+	var request ReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Printf("RequestReturnHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for requesting a return")
+		return
+	}
+	createdReturn, err := returnStore.RequestReturn(r.Context(), request)
+	if err != nil {
+		log.Printf("RequestReturnHandler: Failed to request the return of order %d. Error: %v\n", request.OrderID, err)
+		respondWithReturnError(w, err)
+		return
+	}
+	log.Printf("RequestReturnHandler: Return %d requested for order %d.\n", createdReturn.ID, createdReturn.OrderID)
+	e.RespondWithJSON(w, http.StatusCreated, createdReturn)
+}
+
+func GetReturnHandler(w http.ResponseWriter, r *http.Request, returnStore ReturnStore) {
+	log.Println("GetReturnHandler: Received request to retrieve a return by ID.")
+	returnID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("GetReturnHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	request, err := returnStore.GetReturn(r.Context(), returnID)
+	if err != nil {
+		log.Printf("GetReturnHandler: Return not found. ID: %d. Error: %v\n", returnID, err)
+		respondWithReturnError(w, err)
+		return
+	}
+	log.Printf("GetReturnHandler: Return retrieved successfully. ID: %d\n", returnID)
+	e.RespondWithJSON(w, http.StatusOK, request)
+}
+
+// ListReturnsHandler answers the returns, ?order_id= and ?status= narrow them down.
+func ListReturnsHandler(w http.ResponseWriter, r *http.Request, returnStore ReturnStore) {
+	log.Println("ListReturnsHandler: Received request to list the returns.")
+	orderID := 0
+	if param := r.URL.Query().Get("order_id"); param != "" {
+		id, err := strconv.Atoi(param)
+		if err != nil || id <= 0 {
+			log.Printf("ListReturnsHandler: Invalid order ID %q.\n", param)
+			e.RespondWithError(w, http.StatusBadRequest, "Invalid order_id '"+param+"'")
+			return
+		}
+		orderID = id
+	}
+	status := ReturnStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", ReturnRequested, ReturnApproved, ReturnRejected:
+	default:
+		log.Printf("ListReturnsHandler: Invalid status %q.\n", status)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid status '"+string(status)+"', expected requested, approved or rejected")
+		return
+	}
+	returns, err := returnStore.ListReturns(r.Context(), orderID, status)
+	if err != nil {
+		log.Printf("ListReturnsHandler: Failed to retrieve returns. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusInternalServerError, "Failed to get returns")
+		return
+	}
+	log.Printf("ListReturnsHandler: %d returns retrieved.\n", len(returns))
+	e.RespondWithJSON(w, http.StatusOK, returns)
+}
+
+// ApproveReturnHandler refunds a return, the body can give the disposition of each book.
+func ApproveReturnHandler(w http.ResponseWriter, r *http.Request, returnStore ReturnStore) {
+	decideReturn(w, r, "ApproveReturnHandler", returnStore.ApproveReturn)
+}
+
+func RejectReturnHandler(w http.ResponseWriter, r *http.Request, returnStore ReturnStore) {
+	decideReturn(w, r, "RejectReturnHandler", returnStore.RejectReturn)
+}
+
+func decideReturn(w http.ResponseWriter, r *http.Request, handler string, decide func(ctx context.Context, id int, decision ReturnDecision) (ReturnRequest, error)) {
+	log.Printf("%s: Received request to decide a return.\n", handler)
+	returnID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("%s: Invalid path parameter. Error: %v\n", handler, err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var decision ReturnDecision
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("%s: Invalid request payload. Error: %v\n", handler, err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for deciding a return")
+		return
+	}
+	request, err := decide(r.Context(), returnID, decision)
+	if err != nil {
+		log.Printf("%s: Failed to decide return %d. Error: %v\n", handler, returnID, err)
+		respondWithReturnError(w, err)
+		return
+	}
+	log.Printf("%s: Return %d is now %s.\n", handler, returnID, request.Status)
+	e.RespondWithJSON(w, http.StatusOK, request)
+}
+
+func respondWithReturnError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrRecordNotFound):
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrReturnNotAllowed), errors.Is(err, ErrReturnDecided):
+		e.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrDeclined):
+		e.RespondWithError(w, http.StatusPaymentRequired, err.Error())
+	case errors.Is(err, ErrTimeout):
+		e.RespondWithError(w, http.StatusGatewayTimeout, err.Error())
+	default:
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	. "FinalProject/models"
+	. "FinalProject/stores"
+	. "FinalProject/utils"
+)
+
+func CreateShipmentHandler(w http.ResponseWriter, r *http.Request, shipmentStore ShipmentStore) {
+	log.Println("CreateShipmentHandler: Received request to create a shipment.")
+	orderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("CreateShipmentHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := shipmentStore.ListShipments(r.Context(), orderID); err != nil {
+		log.Printf("CreateShipmentHandler: Order not found. ID: %d. Error: %v\n", orderID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	var shipment Shipment
+	if err := json.NewDecoder(r.Body).Decode(&shipment); err != nil {
+		log.Printf("CreateShipmentHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for creating a new shipment")
+		return
+	}
+	createdShipment, err := shipmentStore.CreateShipment(r.Context(), orderID, shipment)
+	if err != nil {
+		log.Printf("CreateShipmentHandler: Failed to create shipment for order %d. Error: %v\n", orderID, err)
+		if errors.Is(err, ErrInvalidTransition) {
+			e.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("CreateShipmentHandler: Shipment created successfully. ID: %d\n", createdShipment.ID)
+	e.RespondWithJSON(w, http.StatusCreated, createdShipment)
+}
+
+func ListShipmentsHandler(w http.ResponseWriter, r *http.Request, shipmentStore ShipmentStore) {
+	log.Println("ListShipmentsHandler: Received request to list the shipments of an order.")
+	orderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("ListShipmentsHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	shipments, err := shipmentStore.ListShipments(r.Context(), orderID)
+	if err != nil {
+		log.Printf("ListShipmentsHandler: Failed to retrieve shipments of order %d. Error: %v\n", orderID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.Printf("ListShipmentsHandler: %d shipments retrieved for order %d.\n", len(shipments), orderID)
+	e.RespondWithJSON(w, http.StatusOK, shipments)
+}
+
+func GetShipmentHandler(w http.ResponseWriter, r *http.Request, shipmentStore ShipmentStore) {
+	log.Println("GetShipmentHandler: Received request to retrieve a shipment.")
+	orderID, shipmentID, err := extractShipmentPath(r)
+	if err != nil {
+		log.Printf("GetShipmentHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	shipment, err := shipmentStore.GetShipment(r.Context(), orderID, shipmentID)
+	if err != nil {
+		log.Printf("GetShipmentHandler: Shipment not found. ID: %d. Error: %v\n", shipmentID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.Printf("GetShipmentHandler: Shipment retrieved successfully. ID: %d\n", shipmentID)
+	e.RespondWithJSON(w, http.StatusOK, shipment)
+}
+
+// AddTrackingEventHandler takes the status updates of the carrier, the order follows them.
+func AddTrackingEventHandler(w http.ResponseWriter, r *http.Request, shipmentStore ShipmentStore) {
+	log.Println("AddTrackingEventHandler: Received a tracking update.")
+	orderID, shipmentID, err := extractShipmentPath(r)
+	if err != nil {
+		log.Printf("AddTrackingEventHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := shipmentStore.GetShipment(r.Context(), orderID, shipmentID); err != nil {
+		log.Printf("AddTrackingEventHandler: Shipment not found. ID: %d. Error: %v\n", shipmentID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	var event TrackingEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		log.Printf("AddTrackingEventHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for a tracking event")
+		return
+	}
+	shipment, err := shipmentStore.AddTrackingEvent(r.Context(), orderID, shipmentID, event)
+	if err != nil {
+		log.Printf("AddTrackingEventHandler: Failed to record the event of shipment %d. Error: %v\n", shipmentID, err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("AddTrackingEventHandler: Shipment %d is now %s\n", shipmentID, shipment.Status)
+	e.RespondWithJSON(w, http.StatusOK, shipment)
+}
+
+// extractShipmentPath reads /orders/{id}/shipments/{shipmentId}.
+func extractShipmentPath(r *http.Request) (int, int, error) {
+	orderID, err := ExtractPathParamInt(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	param, err := ExtractPathParam(r, 4)
+	if err != nil {
+		return 0, 0, err
+	}
+	shipmentID, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, 0, errors.New("Invalid shipment ID " + param)
+	}
+	return orderID, shipmentID, nil
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	. "FinalProject/models"
+	. "FinalProject/stores"
+	. "FinalProject/utils"
+)
+
+func CreateSupplierHandler(w http.ResponseWriter, r *http.Request, supplierStore SupplierStore) {
+	log.Println("CreateSupplierHandler: Received request to create a supplier.")
+	var supplier Supplier
+	if err := json.NewDecoder(r.Body).Decode(&supplier); err != nil {
+		log.Printf("CreateSupplierHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for creating a new supplier")
+		return
+	}
+	createdSupplier, err := supplierStore.CreateSupplier(r.Context(), supplier)
+	if err != nil {
+		log.Printf("CreateSupplierHandler: Failed to create supplier. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("CreateSupplierHandler: Supplier created successfully. ID: %d\n", createdSupplier.ID)
+	e.RespondWithJSON(w, http.StatusCreated, createdSupplier)
+}
+
+func GetSupplierHandler(w http.ResponseWriter, r *http.Request, supplierStore SupplierStore) {
+	log.Println("GetSupplierHandler: Received request to retrieve a supplier by ID.")
+	supplierID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("GetSupplierHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	supplier, err := supplierStore.GetSupplier(r.Context(), supplierID)
+	if err != nil {
+		log.Printf("GetSupplierHandler: Supplier not found. ID: %d. Error: %v\n", supplierID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.Printf("GetSupplierHandler: Supplier retrieved successfully. ID: %d\n", supplierID)
+	e.RespondWithJSON(w, http.StatusOK, supplier)
+}
+
+func ListSuppliersHandler(w http.ResponseWriter, r *http.Request, supplierStore SupplierStore) {
+	log.Println("ListSuppliersHandler: Received request to list all suppliers.")
+	suppliers, err := supplierStore.ListSuppliers(r.Context())
+	if err != nil {
+		log.Printf("ListSuppliersHandler: Failed to retrieve suppliers. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusInternalServerError, "Failed to get suppliers")
+		return
+	}
+	log.Println("ListSuppliersHandler: Suppliers retrieved successfully.")
+	e.RespondWithJSON(w, http.StatusOK, suppliers)
+}
+
+func UpdateSupplierHandler(w http.ResponseWriter, r *http.Request, supplierStore SupplierStore) {
+	log.Println("UpdateSupplierHandler: Received request to update a supplier.")
+	supplierID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("UpdateSupplierHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := supplierStore.GetSupplier(r.Context(), supplierID); err != nil {
+		log.Printf("UpdateSupplierHandler: Supplier not found. ID: %d. Error: %v\n", supplierID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	var supplier Supplier
+	if err := json.NewDecoder(r.Body).Decode(&supplier); err != nil {
+		log.Printf("UpdateSupplierHandler: Invalid request payload. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, "Invalid request payload for supplier Update")
+		return
+	}
+	updatedSupplier, err := supplierStore.UpdateSupplier(r.Context(), supplierID, supplier)
+	if err != nil {
+		log.Printf("UpdateSupplierHandler: Failed to update supplier. ID: %d. Error: %v\n", supplierID, err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("UpdateSupplierHandler: Supplier updated successfully. ID: %d\n", supplierID)
+	e.RespondWithJSON(w, http.StatusOK, updatedSupplier)
+}
+
+// DeleteSupplierHandler only deletes the suppliers without purchase orders, the orders keep pointing at them.
+func DeleteSupplierHandler(w http.ResponseWriter, r *http.Request, supplierStore SupplierStore) {
+	log.Println("DeleteSupplierHandler: Received request to delete a supplier.")
+	supplierID, err := ExtractPathParamInt(r)
+	if err != nil {
+		log.Printf("DeleteSupplierHandler: Invalid path parameter. Error: %v\n", err)
+		e.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := supplierStore.GetSupplier(r.Context(), supplierID); err != nil {
+		log.Printf("DeleteSupplierHandler: Supplier not found. ID: %d. Error: %v\n", supplierID, err)
+		e.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err := supplierStore.DeleteSupplier(r.Context(), supplierID); err != nil {
+		log.Printf("DeleteSupplierHandler: Failed to delete supplier. ID: %d. Error: %v\n", supplierID, err)
+		e.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	log.Printf("DeleteSupplierHandler: Supplier deleted successfully. ID: %d\n", supplierID)
+	e.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
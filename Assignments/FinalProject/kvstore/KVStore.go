@@ -0,0 +1,415 @@
+package kvstore
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ----------------------------------------------Definition of the KV store--------------------------------
+// DB is an embedded log-structured key-value store. Writes are appended to the active segment file,
+// and an in-memory index keeps, for every live key, where its latest value is. Keys are also kept
+// sorted so they can be scanned in order. Compact rewrites the live values into fresh segments and
+// drops the old ones, and Open rebuilds the index by reading the segments back, cutting off a torn
+// last record left by a crash.
+type Options struct {
+	MaxSegmentSize int64
+	SyncWrites     bool
+	// CompactRatio triggers MaybeCompact once the segments are this many times larger than the live data.
+	CompactRatio   float64
+	MinCompactSize int64
+}
+
+var DefaultOptions = Options{
+	MaxSegmentSize: 16 << 20,
+	SyncWrites:     true,
+	CompactRatio:   2,
+	MinCompactSize: 1 << 20,
+}
+
+var ErrClosed = errors.New("kvstore is closed")
+
+type location struct {
+	segment int
+	offset  int64
+	size    int
+}
+
+type Stats struct {
+	Keys       int   `json:"keys"`
+	Segments   int   `json:"segments"`
+	LiveBytes  int64 `json:"live_bytes"`
+	TotalBytes int64 `json:"total_bytes"`
+}
+
+type DB struct {
+	mu        sync.RWMutex
+	dir       string
+	opts      Options
+	segments  map[int]*segment
+	active    *segment
+	index     map[string]location
+	keys      []string
+	liveBytes int64
+	closed    bool
+}
+
+type Batch struct {
+	ops []operation
+}
+
+func (b *Batch) Put(key string, value []byte) {
+	b.ops = append(b.ops, operation{kind: opPut, key: key, value: append([]byte(nil), value...)})
+}
+
+func (b *Batch) Delete(key string) {
+	b.ops = append(b.ops, operation{kind: opDelete, key: key})
+}
+
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+func Open(dir string, opts Options) (*DB, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.Printf("Failed to create directory %s: %v\n", dir, err)
+		return nil, err
+	}
+	db := &DB{dir: dir, opts: opts, segments: make(map[int]*segment), index: make(map[string]location)}
+
+	ids, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		seg, err := openSegment(dir, id)
+		if err != nil {
+			db.closeSegments()
+			return nil, err
+		}
+		db.segments[id] = seg
+		if err := db.recover(seg, i == len(ids)-1); err != nil {
+			db.closeSegments()
+			return nil, err
+		}
+		db.active = seg
+	}
+	if db.active == nil {
+		if err := db.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	db.keys = make([]string, 0, len(db.index))
+	for key := range db.index {
+		db.keys = append(db.keys, key)
+	}
+	sort.Strings(db.keys)
+	log.Printf("KV store opened at %s, %d keys in %d segments\n", dir, len(db.keys), len(db.segments))
+	return db, nil
+}
+
+// recover replays one segment into the index. Only the last segment may end with a torn record,
+// it is truncated so the next append starts on a clean boundary.
+func (db *DB) recover(seg *segment, last bool) error {
+	var offset int64
+	for offset < seg.size {
+		ops, offsets, size, err := readRecord(seg.file, offset, seg.size)
+		if errors.Is(err, errTornRecord) {
+			if !last {
+				return errors.New("segment " + seg.path + " is corrupted")
+			}
+			log.Printf("Ignoring incomplete record at the end of segment %s\n", seg.path)
+			if err := seg.file.Truncate(offset); err != nil {
+				return err
+			}
+			seg.size = offset
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for i, op := range ops {
+			db.applyToIndex(op, location{segment: seg.id, offset: offsets[i], size: len(op.value)}, false)
+		}
+		offset += size
+	}
+	return nil
+}
+
+// applyToIndex expects the caller to hold the write lock. The sorted keys are only maintained once open.
+func (db *DB) applyToIndex(op operation, loc location, sorted bool) {
+	old, existed := db.index[op.key]
+	if existed {
+		db.liveBytes -= int64(len(op.key) + old.size)
+	}
+	switch op.kind {
+	case opPut:
+		db.index[op.key] = loc
+		db.liveBytes += int64(len(op.key) + loc.size)
+		if sorted && !existed {
+			i := sort.SearchStrings(db.keys, op.key)
+			db.keys = append(db.keys, "")
+			copy(db.keys[i+1:], db.keys[i:])
+			db.keys[i] = op.key
+		}
+	case opDelete:
+		delete(db.index, op.key)
+		if sorted && existed {
+			i := sort.SearchStrings(db.keys, op.key)
+			db.keys = append(db.keys[:i], db.keys[i+1:]...)
+		}
+	}
+}
+
+func (db *DB) rotate() error {
+	id := 1
+	if db.active != nil {
+		id = db.active.id + 1
+	}
+	seg, err := openSegment(db.dir, id)
+	if err != nil {
+		return err
+	}
+	db.segments[id] = seg
+	db.active = seg
+	return syncDir(db.dir)
+}
+
+// Write applies every operation of the batch or none of them, even across a crash.
+func (db *DB) Write(b *Batch) error {
+	if b == nil || len(b.ops) == 0 {
+		return nil
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+	return db.write(b.ops)
+}
+
+func (db *DB) write(ops []operation) error {
+	record, offsets := encodeRecord(ops)
+	if db.active.size > 0 && db.active.size+int64(len(record)) > db.opts.MaxSegmentSize {
+		if err := db.rotate(); err != nil {
+			return err
+		}
+	}
+	start := db.active.size
+	if _, err := db.active.file.WriteAt(record, start); err != nil {
+		// whatever part of the record reached the file is cut off on the next open
+		return err
+	}
+	if db.opts.SyncWrites {
+		if err := db.active.file.Sync(); err != nil {
+			return err
+		}
+	}
+	db.active.size += int64(len(record))
+	for i, op := range ops {
+		db.applyToIndex(op, location{segment: db.active.id, offset: start + offsets[i], size: len(op.value)}, true)
+	}
+	return nil
+}
+
+func (db *DB) Put(key string, value []byte) error {
+	b := &Batch{}
+	b.Put(key, value)
+	return db.Write(b)
+}
+
+func (db *DB) Delete(key string) error {
+	b := &Batch{}
+	b.Delete(key)
+	return db.Write(b)
+}
+
+func (db *DB) Get(key string) ([]byte, bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return nil, false, ErrClosed
+	}
+	loc, ok := db.index[key]
+	if !ok {
+		return nil, false, nil
+	}
+	value, err := db.read(loc)
+	return value, err == nil, err
+}
+
+func (db *DB) read(loc location) ([]byte, error) {
+	value := make([]byte, loc.size)
+	if loc.size == 0 {
+		return value, nil
+	}
+	_, err := db.segments[loc.segment].file.ReadAt(value, loc.offset)
+	return value, err
+}
+
+// ScanRange calls fn for every key in [start, end) in key order, an empty end means no upper bound.
+// The store is read-locked during the scan, fn must not write to it.
+func (db *DB) ScanRange(start, end string, fn func(key string, value []byte) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	for i := sort.SearchStrings(db.keys, start); i < len(db.keys); i++ {
+		key := db.keys[i]
+		if end != "" && key >= end {
+			break
+		}
+		value, err := db.read(db.index[key])
+		if err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanKeys is ScanRange without reading the values.
+func (db *DB) ScanKeys(start, end string, fn func(key string) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	for i := sort.SearchStrings(db.keys, start); i < len(db.keys); i++ {
+		if end != "" && db.keys[i] >= end {
+			break
+		}
+		if err := fn(db.keys[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) ScanPrefix(prefix string, fn func(key string, value []byte) error) error {
+	return db.ScanRange(prefix, PrefixEnd(prefix), fn)
+}
+
+// PrefixEnd returns the first key after every key starting with prefix.
+func PrefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+func (db *DB) Stats() Stats {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	stats := Stats{Keys: len(db.keys), Segments: len(db.segments), LiveBytes: db.liveBytes}
+	for _, seg := range db.segments {
+		stats.TotalBytes += seg.size
+	}
+	return stats
+}
+
+// MaybeCompact compacts the store once enough of the segments is taken by overwritten or deleted values.
+func (db *DB) MaybeCompact() error {
+	stats := db.Stats()
+	if stats.TotalBytes < db.opts.MinCompactSize || float64(stats.TotalBytes) < db.opts.CompactRatio*float64(stats.LiveBytes) {
+		return nil
+	}
+	return db.Compact()
+}
+
+// Compact copies the live values into new segments and removes the old ones. The new segments have
+// higher ids, so if the process stops halfway the old segments are replayed first and the result is the same.
+func (db *DB) Compact() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+
+	old := make([]int, 0, len(db.segments))
+	for id := range db.segments {
+		old = append(old, id)
+	}
+	sort.Ints(old)
+	before := int64(0)
+	for _, id := range old {
+		before += db.segments[id].size
+	}
+
+	if err := db.rotate(); err != nil {
+		return err
+	}
+	newIndex := make(map[string]location, len(db.index))
+	for _, key := range db.keys {
+		value, err := db.read(db.index[key])
+		if err != nil {
+			return err
+		}
+		record, offsets := encodeRecord([]operation{{kind: opPut, key: key, value: value}})
+		if db.active.size > 0 && db.active.size+int64(len(record)) > db.opts.MaxSegmentSize {
+			if err := db.active.file.Sync(); err != nil {
+				return err
+			}
+			if err := db.rotate(); err != nil {
+				return err
+			}
+		}
+		if _, err := db.active.file.WriteAt(record, db.active.size); err != nil {
+			return err
+		}
+		newIndex[key] = location{segment: db.active.id, offset: db.active.size + offsets[0], size: len(value)}
+		db.active.size += int64(len(record))
+	}
+	if err := db.active.file.Sync(); err != nil {
+		return err
+	}
+	db.index = newIndex
+
+	for _, id := range old {
+		seg := db.segments[id]
+		seg.file.Close()
+		delete(db.segments, id)
+		if err := os.Remove(seg.path); err != nil {
+			log.Printf("Failed to remove compacted segment %s: %v\n", seg.path, err)
+		}
+	}
+	if err := syncDir(db.dir); err != nil {
+		return err
+	}
+	after := int64(0)
+	for _, seg := range db.segments {
+		after += seg.size
+	}
+	log.Printf("KV store %s compacted from %d to %d bytes\n", db.dir, before, after)
+	return nil
+}
+
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	return db.closeSegments()
+}
+
+func (db *DB) closeSegments() error {
+	var errs []error
+	for _, seg := range db.segments {
+		if err := seg.file.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,221 @@
+package kvstore
+
+import (
+	"os"
+	"testing"
+)
+
+var testOptions = Options{MaxSegmentSize: 256, SyncWrites: false, CompactRatio: 2, MinCompactSize: 0}
+
+func openTestDB(t *testing.T, dir string) *DB {
+	t.Helper()
+	db, err := Open(dir, testOptions)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// contents returns every live key with its value, in key order.
+func contents(t *testing.T, db *DB) map[string]string {
+	t.Helper()
+	got := make(map[string]string)
+	var previous string
+	if err := db.ScanRange("", "", func(key string, value []byte) error {
+		if key <= previous && previous != "" {
+			t.Fatalf("scan returned %q after %q", key, previous)
+		}
+		previous = key
+		got[key] = string(value)
+		return nil
+	}); err != nil {
+		t.Fatalf("ScanRange: %v", err)
+	}
+	return got
+}
+
+func sameContents(got, want map[string]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for key, value := range want {
+		if v, ok := got[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+type step struct {
+	put    string
+	delete string
+	value  string
+}
+
+func TestPutDeleteReopen(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+		want  map[string]string
+	}{
+		{"puts", []step{{put: "a", value: "1"}, {put: "b", value: "2"}}, map[string]string{"a": "1", "b": "2"}},
+		{"overwrite", []step{{put: "a", value: "1"}, {put: "a", value: "2"}}, map[string]string{"a": "2"}},
+		{"delete", []step{{put: "a", value: "1"}, {put: "b", value: "2"}, {delete: "a"}}, map[string]string{"b": "2"}},
+		{"delete a missing key", []step{{put: "a", value: "1"}, {delete: "z"}}, map[string]string{"a": "1"}},
+		{"put after delete", []step{{put: "a", value: "1"}, {delete: "a"}, {put: "a", value: "3"}}, map[string]string{"a": "3"}},
+		{"empty value", []step{{put: "a", value: ""}}, map[string]string{"a": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			db := openTestDB(t, dir)
+			for _, s := range tt.steps {
+				var err error
+				if s.delete != "" {
+					err = db.Delete(s.delete)
+				} else {
+					err = db.Put(s.put, []byte(s.value))
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := contents(t, db); !sameContents(got, tt.want) {
+				t.Fatalf("contents %v, want %v", got, tt.want)
+			}
+			db.Close()
+
+			db = openTestDB(t, dir)
+			if got := contents(t, db); !sameContents(got, tt.want) {
+				t.Fatalf("contents after reopen %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got, ok, err := db.Get(key); err != nil || !ok || string(got) != value {
+					t.Fatalf("Get(%q) = %q, %v, %v", key, got, ok, err)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchIsAtomicAcrossTornWrites(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestDB(t, dir)
+	if err := db.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	b := &Batch{}
+	b.Put("b", []byte("2"))
+	b.Delete("a")
+	if err := db.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// cut the last record in half, as if the process stopped while writing it
+	path := segmentPath(dir, 1)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openTestDB(t, dir)
+	if got := contents(t, db); !sameContents(got, map[string]string{"a": "1"}) {
+		t.Fatalf("contents %v, the torn batch was partly applied", got)
+	}
+	if err := db.Put("c", []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	db = openTestDB(t, dir)
+	if got := contents(t, db); !sameContents(got, map[string]string{"a": "1", "c": "3"}) {
+		t.Fatalf("contents %v after writing past the torn record", got)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestDB(t, dir)
+	want := map[string]string{}
+	for round := 0; round < 10; round++ {
+		for _, key := range []string{"book/1", "book/2", "book/3", "order/1"} {
+			value := key + " version " + string(rune('0'+round))
+			if err := db.Put(key, []byte(value)); err != nil {
+				t.Fatal(err)
+			}
+			want[key] = value
+		}
+	}
+	if err := db.Delete("book/2"); err != nil {
+		t.Fatal(err)
+	}
+	delete(want, "book/2")
+
+	before := db.Stats()
+	if before.Segments < 2 {
+		t.Fatalf("expected the writes to rotate segments, got %d", before.Segments)
+	}
+	if err := db.MaybeCompact(); err != nil {
+		t.Fatalf("MaybeCompact: %v", err)
+	}
+	after := db.Stats()
+	if after.TotalBytes >= before.TotalBytes {
+		t.Fatalf("compaction grew the store from %d to %d bytes", before.TotalBytes, after.TotalBytes)
+	}
+	if after.Keys != len(want) {
+		t.Fatalf("%d keys after compaction, want %d", after.Keys, len(want))
+	}
+	if got := contents(t, db); !sameContents(got, want) {
+		t.Fatalf("contents after compaction %v, want %v", got, want)
+	}
+
+	if err := db.Put("order/2", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	want["order/2"] = "new"
+	db.Close()
+	db = openTestDB(t, dir)
+	if got := contents(t, db); !sameContents(got, want) {
+		t.Fatalf("contents after compaction and reopen %v, want %v", got, want)
+	}
+}
+
+func TestScanPrefix(t *testing.T) {
+	db := openTestDB(t, t.TempDir())
+	for _, key := range []string{"order/2", "book/10", "book/2", "author/1", "book/1", "books"} {
+		if err := db.Put(key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	if err := db.ScanPrefix("book/", func(key string, value []byte) error {
+		got = append(got, key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"book/1", "book/10", "book/2"}
+	if len(got) != len(want) {
+		t.Fatalf("scanned %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("scanned %v, want %v", got, want)
+		}
+	}
+}
+
+func TestClosedStore(t *testing.T) {
+	db := openTestDB(t, t.TempDir())
+	db.Close()
+	if err := db.Put("a", []byte("1")); err != ErrClosed {
+		t.Fatalf("Put on a closed store = %v, want ErrClosed", err)
+	}
+	if _, _, err := db.Get("a"); err != ErrClosed {
+		t.Fatalf("Get on a closed store = %v, want ErrClosed", err)
+	}
+}
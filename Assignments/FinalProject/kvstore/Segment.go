@@ -0,0 +1,158 @@
+package kvstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------Definition of Segment files--------------------------------
+// A segment is an append-only file of records. A record is one atomic batch of operations:
+//
+//	crc32 (4 bytes) | payload length (4 bytes) | payload
+//
+// and the payload holds the operations one after the other:
+//
+//	op (1 byte) | key length (uvarint) | key | value length (uvarint) | value
+const (
+	segmentPrefix = "segment-"
+	segmentSuffix = ".log"
+	headerSize    = 8
+
+	opPut    byte = 1
+	opDelete byte = 2
+)
+
+var errTornRecord = errors.New("torn or corrupted record")
+
+type segment struct {
+	id   int
+	path string
+	file *os.File
+	size int64
+}
+
+func segmentPath(dir string, id int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%06d%s", segmentPrefix, id, segmentSuffix))
+}
+
+func listSegments(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func openSegment(dir string, id int) (*segment, error) {
+	path := segmentPath(dir, id)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &segment{id: id, path: path, file: file, size: info.Size()}, nil
+}
+
+type operation struct {
+	kind  byte
+	key   string
+	value []byte
+}
+
+// encodeRecord returns the record and, for every operation, the offset of its value inside the record.
+func encodeRecord(ops []operation) ([]byte, []int64) {
+	payload := make([]byte, 0, 64)
+	offsets := make([]int64, len(ops))
+	for i, op := range ops {
+		payload = append(payload, op.kind)
+		payload = binary.AppendUvarint(payload, uint64(len(op.key)))
+		payload = append(payload, op.key...)
+		payload = binary.AppendUvarint(payload, uint64(len(op.value)))
+		offsets[i] = int64(headerSize + len(payload))
+		payload = append(payload, op.value...)
+	}
+	record := make([]byte, headerSize, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], crc32.ChecksumIEEE(payload))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(len(payload)))
+	return append(record, payload...), offsets
+}
+
+// readRecord reads the record starting at offset. The operations carry the offset of their value in the file.
+func readRecord(r io.ReaderAt, offset int64, fileSize int64) ([]operation, []int64, int64, error) {
+	if offset+headerSize > fileSize {
+		return nil, nil, 0, errTornRecord
+	}
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, nil, 0, err
+	}
+	length := int64(binary.LittleEndian.Uint32(header[4:8]))
+	if offset+headerSize+length > fileSize {
+		return nil, nil, 0, errTornRecord
+	}
+	payload := make([]byte, length)
+	if _, err := r.ReadAt(payload, offset+headerSize); err != nil {
+		return nil, nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[0:4]) {
+		return nil, nil, 0, errTornRecord
+	}
+
+	var ops []operation
+	var offsets []int64
+	pos := 0
+	for pos < len(payload) {
+		kind := payload[pos]
+		pos++
+		keyLen, n := binary.Uvarint(payload[pos:])
+		if n <= 0 || pos+n+int(keyLen) > len(payload) {
+			return nil, nil, 0, errTornRecord
+		}
+		pos += n
+		key := string(payload[pos : pos+int(keyLen)])
+		pos += int(keyLen)
+		valueLen, n := binary.Uvarint(payload[pos:])
+		if n <= 0 || pos+n+int(valueLen) > len(payload) {
+			return nil, nil, 0, errTornRecord
+		}
+		pos += n
+		offsets = append(offsets, offset+headerSize+int64(pos))
+		ops = append(ops, operation{kind: kind, key: key, value: payload[pos : pos+int(valueLen)]})
+		pos += int(valueLen)
+	}
+	return ops, offsets, headerSize + length, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
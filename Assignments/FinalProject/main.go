@@ -2,10 +2,15 @@ package main
 
 import (
 	. "FinalProject/logging"
+	. "FinalProject/models"
+	. "FinalProject/pricing"
 	. "FinalProject/reports"
 	. "FinalProject/routes"
+	. "FinalProject/stores"
 	. "FinalProject/utils"
 	"context"
+	"encoding/json"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -14,6 +19,25 @@ import (
 )
 
 func main() {
+	restoreGeneration := flag.String("restore", "", "snapshot generation to restore before the server starts")
+	snapshotRetention := flag.Int("snapshot-retention", 10, "number of snapshot generations to keep")
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "report the data migrations that loading would apply, then exit")
+	storage := flag.String("storage", "json", "where the data is kept: json, sql, kv or memory")
+	importJSON := flag.Bool("import-json", false, "copy the database/*.json files into the selected storage, then exit")
+	taxRate := flag.Float64("tax-rate", 0, "tax rate applied to the discounted subtotal of every order, 0.2 for 20%")
+	taxRates := flag.String("tax-rates", "", "JSON file of tax rules by customer address, replaces -tax-rate when set")
+	currency := flag.String("currency", DefaultCurrency, "base currency of the book prices, the fees and the sales reports")
+	exchangeRates := flag.String("exchange-rates", "", "JSON file of exchange rates to the base currency, needed for orders in other currencies")
+	shippingFee := flag.Float64("shipping-fee", 0, "shipping fee charged per order")
+	shippingMethods := flag.String("shipping-methods", "", "JSON file of carriers and shipping rates, replaces the flat shipping fee when set")
+	freeShippingFrom := flag.Float64("free-shipping-from", 0, "subtotal from which shipping is free, 0 to always charge it")
+	cartTTL := flag.Duration("cart-ttl", DefaultCartTTL, "how long a cart left untouched stays active before it expires")
+	reservationTTL := flag.Duration("reservation-ttl", DefaultReservationTTL, "how long an unpaid order holds its books before it is cancelled")
+	lowStockInterval := flag.Duration("low-stock-interval", time.Hour, "how often the stock levels are checked for the stock alerts")
+	velocityWindow := flag.Duration("sales-velocity-window", 30*24*time.Hour, "how far back the sales are counted for the reorder suggestions")
+	preOrderInterval := flag.Duration("pre-order-interval", time.Hour, "how often the pre-orders of the books released are allocated")
+	idempotencyTTL := flag.Duration("idempotency-ttl", DefaultIdempotencyTTL, "how long the responses of requests sent with an Idempotency-Key are kept for retries")
+	flag.Parse()
 
 	logFile, err := SetupLogging()
 	if err != nil {
@@ -21,12 +45,109 @@ func main() {
 	}
 	defer logFile.Close()
 
-	router, bookStore, authorStore, customerStore, orderStore := InitializeRoutes()
+	if *migrateDryRun {
+		reports, err := DryRunMigrations(context.Background(), "journal.log")
+		if err != nil {
+			log.Fatalf("Migration dry run failed: %v", err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(reports)
+		return
+	}
+
+	journal, err := OpenJournal("journal.log")
+	if err != nil {
+		log.Fatalf("Failed to open journal: %v", err)
+	}
+	drivers, err := OpenStorageDrivers(*storage)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", *storage, err)
+	}
+	defer drivers.Close()
+
+	if *importJSON {
+		if err := ImportJSONDatabase(context.Background(), journal, drivers); err != nil {
+			log.Fatalf("Import of the JSON files failed: %v", err)
+		}
+		log.Printf("JSON files imported into the %s storage\n", *storage)
+		journal.Close()
+		return
+	}
+
+	router, stores, snapshots := InitializeRoutes(journal, drivers)
+	snapshots.Retain = *snapshotRetention
+	if *idempotencyTTL <= 0 {
+		log.Fatalf("Invalid idempotency TTL %s", *idempotencyTTL)
+	}
+	stores.Idempotency.TTL = *idempotencyTTL
+	if *cartTTL <= 0 {
+		log.Fatalf("Invalid cart TTL %s", *cartTTL)
+	}
+	stores.Carts.TTL = *cartTTL
+	if *reservationTTL <= 0 {
+		log.Fatalf("Invalid reservation TTL %s", *reservationTTL)
+	}
+	stores.Reservations.TTL = *reservationTTL
+	if *lowStockInterval <= 0 || *velocityWindow < 24*time.Hour {
+		log.Fatalf("Invalid low stock check every %s over %s of sales, the window is at least a day", *lowStockInterval, *velocityWindow)
+	}
+	if *preOrderInterval <= 0 {
+		log.Fatalf("Invalid pre-order release interval %s", *preOrderInterval)
+	}
+	rates := &ExchangeRates{Base: NormalizeCurrency(*currency)}
+	if !ValidCurrency(rates.Base) {
+		log.Fatalf("Invalid base currency %q", *currency)
+	}
+	if *exchangeRates != "" {
+		rates, err = LoadExchangeRates(*exchangeRates, rates.Base)
+		if err != nil {
+			log.Fatalf("Failed to load the exchange rates: %v", err)
+		}
+	}
+	stores.Inventory.Currency = rates.Base
+	stores.Orders.Pricing = &PricingEngine{
+		Tax:      FlatRateTax{Rate: *taxRate},
+		Shipping: FlatShipping{Fee: *shippingFee, FreeFrom: *freeShippingFrom, Rates: rates},
+		Rates:    rates,
+	}
+	if *taxRates != "" {
+		table, err := LoadTaxTable(*taxRates)
+		if err != nil {
+			log.Fatalf("Failed to load the tax rates: %v", err)
+		}
+		stores.Orders.Pricing.Tax = table
+	}
+	if *shippingMethods != "" {
+		table, err := LoadShippingTable(*shippingMethods, rates)
+		if err != nil {
+			log.Fatalf("Failed to load the shipping methods: %v", err)
+		}
+		stores.Orders.Pricing.Shipping = table
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go StartSalesReportBackgroundJob(ctx, orderStore, bookStore, 3*time.Hour) //24*time.Hour
+	if *restoreGeneration != "" {
+		if err := snapshots.RestoreSnapshot(ctx, *restoreGeneration, stores); err != nil {
+			log.Fatalf("Failed to restore snapshot %s: %v", *restoreGeneration, err)
+		}
+	}
+
+	go StartSalesReportBackgroundJob(ctx, stores.Orders, stores.Books, rates, 3*time.Hour) //24*time.Hour
+	go StartLowStockBackgroundJob(ctx, stores.Orders, stores.Alerts, *velocityWindow, *lowStockInterval)
+	go StartJournalCompactionBackgroundJob(ctx, snapshots, stores, 15*time.Minute)
+	go StartIdempotencyKeyCleanupBackgroundJob(ctx, stores.Idempotency, time.Hour)
+	go StartCartExpiryBackgroundJob(ctx, stores.Carts, 15*time.Minute)
+	go StartPreOrderReleaseBackgroundJob(ctx, stores.Orders, *preOrderInterval)
+	// the holds are swept at least once per TTL, so a short TTL doesn't keep the books much longer
+	sweepEvery := time.Minute
+	if *reservationTTL < sweepEvery {
+		sweepEvery = *reservationTTL
+	}
+	go StartReservationSweeperBackgroundJob(ctx, stores.Reservations, sweepEvery)
 
 	server := &http.Server{
 		Addr:    ":8080",
@@ -50,7 +171,10 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
-	SaveAllData(ctx, bookStore, authorStore, customerStore, orderStore)
+	if err := CompactJournal(ctx, snapshots, stores); err != nil {
+		log.Printf("Data could not be fully saved, the journal is kept for the next start: %v", err)
+	}
+	snapshots.Journal.Close()
 
 	log.Println("Server exited cleanly")
 }
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ----------------------------------------------Definition of Carts--------------------------------
+// A customer fills one active cart before checking it out into an order. A cart left untouched past
+// ExpiresAt expires, the customer starts a new one next time, and the old one stays for the
+// abandoned-cart queries.
+type CartStatus string
+
+const (
+	CartActive     CartStatus = "active"
+	CartCheckedOut CartStatus = "checked_out"
+	CartExpired    CartStatus = "expired"
+)
+
+var ErrCartNotFound = errors.New("no active cart")
+
+type CartItem struct {
+	BookID    int    `json:"book_id"`
+	Title     string `json:"title,omitempty"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Amount `json:"unit_price"`
+	LineTotal Amount `json:"line_total"`
+	// Available is the stock of the book not held by other orders when the cart was priced.
+	Available int `json:"available"`
+}
+
+type Cart struct {
+	ID             int                `json:"id"`
+	CustomerID     int                `json:"customer_id"`
+	Status         CartStatus         `json:"status"`
+	Items          []CartItem         `json:"items"`
+	CouponCodes    []string           `json:"coupon_codes,omitempty"`
+	Currency       string             `json:"currency,omitempty"`
+	ShippingMethod string             `json:"shipping_method,omitempty"`
+	Promotions     []AppliedPromotion `json:"promotions,omitempty"`
+	Pricing        PriceBreakdown     `json:"pricing"`
+	// Problems tells what would stop the checkout now, like a book out of stock.
+	Problems  []string  `json:"problems,omitempty"`
+	OrderID   int       `json:"order_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AbandonedCart is a cart left with books in it, with what marketing needs to reach the customer.
+type AbandonedCart struct {
+	Cart          Cart   `json:"cart"`
+	CustomerName  string `json:"customer_name"`
+	CustomerEmail string `json:"customer_email"`
+}
+
+func (c Cart) Expired(at time.Time) bool {
+	return c.Status == CartExpired || (c.Status == CartActive && !c.ExpiresAt.After(at))
+}
+
+func (c Cart) GetID() int { return c.ID }
+
+func (c Cart) WithID(id int) Cart {
+	c.ID = id
+	return c
+}
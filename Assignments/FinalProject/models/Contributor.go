@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"sort"
+)
+
+// ----------------------------------------------Definition of Contributors--------------------------------
+// A book points at its authors by ID, each with the part they took in it. The author itself is only
+// looked up when the book is read, so an author updated later is never out of date in its books.
+type ContributorRole string
+
+const (
+	RoleAuthor      ContributorRole = "author"
+	RoleEditor      ContributorRole = "editor"
+	RoleTranslator  ContributorRole = "translator"
+	RoleIllustrator ContributorRole = "illustrator"
+)
+
+var ErrInvalidContributors = errors.New("invalid contributors")
+
+// Position orders the contributors on the cover, 1 comes first. Author is filled in on reads only.
+type Contributor struct {
+	AuthorID int             `json:"author_id"`
+	Role     ContributorRole `json:"role"`
+	Position int             `json:"position"`
+	Author   *Author         `json:"author,omitempty"`
+}
+
+func (r ContributorRole) Valid() bool {
+	switch r {
+	case RoleAuthor, RoleEditor, RoleTranslator, RoleIllustrator:
+		return true
+	}
+	return false
+}
+
+// SortedContributors gives a copy of the contributors in their order, an empty role is an author.
+// The contributors without a position keep their place after the others and every position is
+// numbered again from 1.
+func (b Book) SortedContributors() []Contributor {
+	contributors := make([]Contributor, len(b.Contributors))
+	copy(contributors, b.Contributors)
+	sort.SliceStable(contributors, func(i, j int) bool {
+		if contributors[i].Position == 0 || contributors[j].Position == 0 {
+			return contributors[j].Position == 0 && contributors[i].Position != 0
+		}
+		return contributors[i].Position < contributors[j].Position
+	})
+	for i := range contributors {
+		if contributors[i].Role == "" {
+			contributors[i].Role = RoleAuthor
+		}
+		contributors[i].Position = i + 1
+		contributors[i].Author = nil
+	}
+	return contributors
+}
+
+// HasContributor tells whether the author took any part in the book.
+func (b Book) HasContributor(authorId int) bool {
+	for _, contributor := range b.Contributors {
+		if contributor.AuthorID == authorId {
+			return true
+		}
+	}
+	return false
+}
+
+// PrimaryAuthorID is the first contributor with the author role, or the first contributor of a book
+// that only has editors, translators or illustrators.
+func (b Book) PrimaryAuthorID() int {
+	for _, contributor := range b.Contributors {
+		if contributor.Role == RoleAuthor {
+			return contributor.AuthorID
+		}
+	}
+	if len(b.Contributors) > 0 {
+		return b.Contributors[0].AuthorID
+	}
+	return 0
+}
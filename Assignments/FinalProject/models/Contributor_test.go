@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestSortedContributors(t *testing.T) {
+	book := Book{Contributors: []Contributor{
+		{AuthorID: 3, Role: RoleTranslator},
+		{AuthorID: 1, Position: 2},
+		{AuthorID: 2, Role: RoleEditor, Position: 1},
+	}}
+	want := []Contributor{
+		{AuthorID: 2, Role: RoleEditor, Position: 1},
+		{AuthorID: 1, Role: RoleAuthor, Position: 2},
+		{AuthorID: 3, Role: RoleTranslator, Position: 3},
+	}
+	got := book.SortedContributors()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("contributor %d is %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if book.Contributors[0].AuthorID != 3 {
+		t.Fatal("the contributors of the book were sorted in place")
+	}
+}
+
+func TestPrimaryAuthorID(t *testing.T) {
+	tests := []struct {
+		name         string
+		contributors []Contributor
+		want         int
+	}{
+		{"no contributors", nil, 0},
+		{"first author", []Contributor{{AuthorID: 4, Role: RoleEditor}, {AuthorID: 5, Role: RoleAuthor}, {AuthorID: 6, Role: RoleAuthor}}, 5},
+		{"no author role", []Contributor{{AuthorID: 4, Role: RoleEditor}, {AuthorID: 7, Role: RoleIllustrator}}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Book{Contributors: tt.contributors}).PrimaryAuthorID(); got != tt.want {
+				t.Fatalf("PrimaryAuthorID = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,46 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
 	"time"
 )
 
 // ----------------------------------------------Definition of structs--------------------------------
 type OrderItem struct {
-	Book     Book `json:"book"`
-	Quantity int  `json:"quantity"`
+	BookID int `json:"book_id"`
+	// Book is only filled in while the order is priced, and on reads with ?expand=items.book.
+	Book *Book `json:"book,omitempty"`
+	// Title, UnitPrice and UnitCost are taken when the order is placed, the item keeps what was sold
+	// whatever becomes of the book.
+	Title     string `json:"title"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Amount `json:"unit_price"`
+	LineTotal Amount `json:"line_total"`
+	UnitCost  Amount `json:"unit_cost,omitempty"`
+	// Backordered is the part of the quantity still waiting for stock, Shipped the part handed to a carrier.
+	Backordered int `json:"backordered,omitempty"`
+	Shipped     int `json:"shipped,omitempty"`
+	// PreOrder is set when the book wasn't published yet, all its copies waited for the release.
+	PreOrder bool `json:"pre_order,omitempty"`
+}
+
+// Tax is added to the total, TaxIncluded is the part of the prices that already was tax.
+type PriceBreakdown struct {
+	Subtotal    Amount `json:"subtotal"`
+	Discount    Amount `json:"discount"`
+	Tax         Amount `json:"tax"`
+	TaxIncluded Amount `json:"tax_included,omitempty"`
+	Shipping    Amount `json:"shipping"`
+	Total       Amount `json:"total"`
+}
+
+type TaxLine struct {
+	Jurisdiction string  `json:"jurisdiction"`
+	Rate         float64 `json:"rate"`
+	Taxable      float64 `json:"taxable"`
+	Amount       float64 `json:"amount"`
+	Inclusive    bool    `json:"inclusive,omitempty"`
 }
 
 type Address struct {
@@ -22,9 +55,17 @@ type BookSales struct {
 	Quantity int  `json:"quantity_sold"`
 }
 
+// GrossMargin is the revenue less its tax and the cost of the books sold, NetRevenue the revenue less
+// what was refunded for the orders, returns included.
 type SalesReport struct {
 	Timestamp       time.Time   `json:"timestamp"`
 	TotalRevenue    float64     `json:"total_revenue"`
+	TotalRefunds    float64     `json:"total_refunds"`
+	NetRevenue      float64     `json:"net_revenue"`
+	TotalTax        float64     `json:"total_tax"`
+	TotalCost       float64     `json:"total_cost"`
+	GrossMargin     float64     `json:"gross_margin"`
+	Currency        string      `json:"currency"`
 	TotalOrders     int         `json:"total_orders"`
 	TopSellingBooks []BookSales `json:"top_selling_books"`
 }
@@ -37,13 +78,27 @@ type Author struct {
 }
 
 type Book struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Author      Author    `json:"author"`
-	Genres      []string  `json:"genres"`
-	PublishedAt time.Time `json:"published_at"`
-	Price       float64   `json:"price"`
-	Stock       int       `json:"stock"`
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	// Contributors are the authors, editors, translators and illustrators of the book, by ID.
+	Contributors []Contributor `json:"contributors"`
+	Genres       []string      `json:"genres"`
+	PublishedAt  time.Time     `json:"published_at"`
+	// Price is in the base currency, Prices holds the prices set for the other currencies.
+	Price  Amount  `json:"price"`
+	Prices []Money `json:"prices,omitempty"`
+	// Stock is on hand in the warehouse, Reserved is the part of it held by unpaid orders.
+	Stock    int `json:"stock"`
+	Reserved int `json:"reserved"`
+	// Weight in kilograms, used by the shipping rates.
+	Weight float64 `json:"weight,omitempty"`
+	// A stock alert is raised once what is available falls to ReorderPoint, ReorderQuantity is the
+	// least that is ordered at once.
+	ReorderPoint    int `json:"reorder_point,omitempty"`
+	ReorderQuantity int `json:"reorder_quantity,omitempty"`
+	// UnitCost is the moving average cost of the copies received, in the base currency. The ledger
+	// keeps it, a sale costs what its copies cost on average.
+	UnitCost Amount `json:"unit_cost,omitempty"`
 }
 type Customer struct {
 	ID        int       `json:"id"`
@@ -54,12 +109,31 @@ type Customer struct {
 }
 
 type Order struct {
-	ID         int         `json:"id"`
-	Customer   Customer    `json:"customer"`
-	Items      []OrderItem `json:"items"`
-	TotalPrice float64     `json:"total_price"`
-	CreatedAt  time.Time   `json:"created_at"`
-	Status     string      `json:"status"`
+	ID         int `json:"id"`
+	CustomerID int `json:"customer_id"`
+	// Customer is only filled in on reads with ?expand=customer.
+	Customer *Customer `json:"customer,omitempty"`
+	// ShippingAddress is the address of the customer when the order was placed, the order was taxed
+	// and its shipping priced for it.
+	ShippingAddress Address            `json:"shipping_address"`
+	Items           []OrderItem        `json:"items"`
+	CouponCodes     []string           `json:"coupon_codes,omitempty"`
+	Promotions      []AppliedPromotion `json:"promotions,omitempty"`
+	TaxLines        []TaxLine          `json:"tax_lines,omitempty"`
+	// ShippingMethod is the code of the method the order ships with, the cheapest one when not chosen.
+	ShippingMethod string         `json:"shipping_method,omitempty"`
+	Pricing        PriceBreakdown `json:"pricing"`
+	TotalPrice     Amount         `json:"total_price"`
+	Currency       string         `json:"currency,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	Status         OrderStatus    `json:"status"`
+	StatusHistory  []StatusChange `json:"status_history"`
+	// ReservedUntil is when an unpaid order loses its books and gets cancelled.
+	ReservedUntil *time.Time `json:"reserved_until,omitempty"`
+	// RefundedAmount is what was given back to the customer, in the currency of the order.
+	RefundedAmount Amount `json:"refunded_amount,omitempty"`
+	// AllowBackorder accepts the order when a book is short, what is missing waits for the next receipts.
+	AllowBackorder bool `json:"allow_backorder,omitempty"`
 }
 
 type SearchCriteria struct {
@@ -67,3 +141,62 @@ type SearchCriteria struct {
 	Author string
 	Genre  string
 }
+
+// ListPrice returns the price of the book in a currency other than the base one, when it has one.
+func (b Book) ListPrice(currency string) (Money, bool) {
+	for _, price := range b.Prices {
+		if NormalizeCurrency(price.Currency) == NormalizeCurrency(currency) {
+			return price, true
+		}
+	}
+	return Money{}, false
+}
+
+// ErrBookInUse is returned when a book that was ordered, or that carts or purchase orders still wait for, is deleted.
+var ErrBookInUse = errors.New("book still in use")
+
+// Available is what can still be ordered, the stock on hand less the reservations.
+func (b Book) Available() int {
+	if b.Reserved >= b.Stock {
+		return 0
+	}
+	return b.Stock - b.Reserved
+}
+
+// MarshalJSON adds the available quantity, so it is never stored apart from the stock it comes from.
+func (b Book) MarshalJSON() ([]byte, error) {
+	type plainBook Book
+	return json.Marshal(struct {
+		plainBook
+		Available int `json:"available"`
+	}{plainBook(b), b.Available()})
+}
+
+// ----------------------------------------------Entity methods used by the repositories--------------------------------
+func (a Author) GetID() int { return a.ID }
+
+func (a Author) WithID(id int) Author {
+	a.ID = id
+	return a
+}
+
+func (b Book) GetID() int { return b.ID }
+
+func (b Book) WithID(id int) Book {
+	b.ID = id
+	return b
+}
+
+func (c Customer) GetID() int { return c.ID }
+
+func (c Customer) WithID(id int) Customer {
+	c.ID = id
+	return c
+}
+
+func (o Order) GetID() int { return o.ID }
+
+func (o Order) WithID(id int) Order {
+	o.ID = id
+	return o
+}
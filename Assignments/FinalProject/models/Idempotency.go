@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ----------------------------------------------Definition of Idempotency keys--------------------------------
+// A create request sent with an Idempotency-Key header keeps its response under that key, so a client
+// retrying after a timeout gets the first response back instead of creating the record a second time.
+// The fingerprint is a hash of the method, the path and the body of the first request.
+var (
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key reused with a different request")
+	ErrIdempotencyKeyInUse    = errors.New("a request with this idempotency key is still being processed")
+)
+
+type IdempotencyRecord struct {
+	ID          int       `json:"id"`
+	Key         string    `json:"key"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Fingerprint string    `json:"fingerprint"`
+	Status      int       `json:"status"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (r IdempotencyRecord) Expired(at time.Time) bool {
+	return !r.ExpiresAt.After(at)
+}
+
+func (r IdempotencyRecord) GetID() int { return r.ID }
+
+func (r IdempotencyRecord) WithID(id int) IdempotencyRecord {
+	r.ID = id
+	return r
+}
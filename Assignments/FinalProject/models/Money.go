@@ -0,0 +1,180 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------Definition of Money--------------------------------
+// Money is an amount in the minor units of its currency (cents for USD, yen for JPY), so adding up
+// amounts never drifts the way floats do. In JSON the amount is in major units like every other price
+// of the API, {"amount": 17.99, "currency": "EUR"}, and is rounded to the minor unit when read.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+type moneyJSON struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount := strconv.FormatFloat(m.Float(), 'f', -1, 64)
+	return json.Marshal(moneyJSON{Amount: json.Number(amount), Currency: m.Currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	amount := 0.0
+	if raw.Amount != "" {
+		var err error
+		if amount, err = raw.Amount.Float64(); err != nil {
+			return errors.New("Invalid amount '" + string(raw.Amount) + "'")
+		}
+	}
+	*m = NewMoney(amount, raw.Currency)
+	return nil
+}
+
+// DefaultCurrency is the base currency when none is configured.
+const DefaultCurrency = "USD"
+
+// currencies without two decimals, every other ISO 4217 code has cents
+var currencyDecimals = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+func NormalizeCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// ValidCurrency checks the shape of an ISO 4217 code, three letters.
+func ValidCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func CurrencyDecimals(currency string) int {
+	if decimals, ok := currencyDecimals[NormalizeCurrency(currency)]; ok {
+		return decimals
+	}
+	return 2
+}
+
+// NewMoney rounds a decimal amount to the minor unit of the currency, half away from zero.
+func NewMoney(amount float64, currency string) Money {
+	currency = NormalizeCurrency(currency)
+	return Money{Amount: int64(math.Round(amount * math.Pow10(CurrencyDecimals(currency)))), Currency: currency}
+}
+
+// Float gives the amount back in major units, for the JSON fields that are plain numbers.
+func (m Money) Float() float64 {
+	return float64(m.Amount) / math.Pow10(CurrencyDecimals(m.Currency))
+}
+
+// ----------------------------------------------Definition of Amount--------------------------------
+// Amount is a price, a cost or a total of a record whose currency is kept elsewhere, the currency of the
+// order or the base one. It counts thousandths of the major unit, which holds the minor unit of every
+// currency exactly (yen, cents, the fils of a dinar), so the sums never drift. In JSON it is a plain
+// number in major units, 12.99 and not 12990.
+type Amount int64
+
+const amountScale = 1000
+
+// NewAmount rounds a decimal amount to the thousandth, half away from zero.
+func NewAmount(amount float64) Amount {
+	return Amount(math.Round(amount * amountScale))
+}
+
+// AmountOf gives the amount of money without its currency.
+func AmountOf(m Money) Amount {
+	return Amount(m.Amount * int64(math.Pow10(3-CurrencyDecimals(m.Currency))))
+}
+
+func (a Amount) Float() float64 {
+	return float64(a) / amountScale
+}
+
+// In gives the amount as money of the currency, rounded to its minor unit.
+func (a Amount) In(currency string) Money {
+	currency = NormalizeCurrency(currency)
+	step := int64(math.Pow10(3 - CurrencyDecimals(currency)))
+	minor := int64(a) / step
+	if rest := int64(a) % step; rest*2 >= step {
+		minor++
+	} else if rest*2 <= -step {
+		minor--
+	}
+	return Money{Amount: minor, Currency: currency}
+}
+
+// Times is the amount for quantity copies.
+func (a Amount) Times(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+func (a Amount) String() string {
+	return strconv.FormatFloat(a.Float(), 'f', -1, 64)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	amount, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return errors.New("Invalid amount '" + string(data) + "'")
+	}
+	*a = NewAmount(amount)
+	return nil
+}
+
+// NormalizePrices upper-cases the currencies of a price list and rejects invalid or repeated ones.
+func NormalizePrices(prices []Money) ([]Money, error) {
+	seen := make(map[string]bool)
+	for i := range prices {
+		prices[i].Currency = NormalizeCurrency(prices[i].Currency)
+		if !ValidCurrency(prices[i].Currency) {
+			return nil, errors.New("Invalid currency '" + prices[i].Currency + "', expected an ISO 4217 code like EUR")
+		}
+		if prices[i].Amount <= 0 {
+			return nil, errors.New("The " + prices[i].Currency + " price must be positive")
+		}
+		if seen[prices[i].Currency] {
+			return nil, errors.New("The book has two " + prices[i].Currency + " prices")
+		}
+		seen[prices[i].Currency] = true
+	}
+	return prices, nil
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, errors.New("Can't add " + other.Currency + " to " + m.Currency)
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) String() string {
+	return strconv.FormatFloat(m.Float(), 'f', CurrencyDecimals(m.Currency), 64) + " " + m.Currency
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   string
+		money Money
+		out   string
+	}{
+		{"cents", `{"amount": 17.99, "currency": "eur"}`, Money{Amount: 1799, Currency: "EUR"}, `{"amount":17.99,"currency":"EUR"}`},
+		{"no decimals", `{"amount": 1500, "currency": "JPY"}`, Money{Amount: 1500, Currency: "JPY"}, `{"amount":1500,"currency":"JPY"}`},
+		{"three decimals", `{"amount": 1.234, "currency": "KWD"}`, Money{Amount: 1234, Currency: "KWD"}, `{"amount":1.234,"currency":"KWD"}`},
+		{"rounded to the minor unit", `{"amount": 0.125, "currency": "USD"}`, Money{Amount: 13, Currency: "USD"}, `{"amount":0.13,"currency":"USD"}`},
+		{"negative", `{"amount": -2.5, "currency": "USD"}`, Money{Amount: -250, Currency: "USD"}, `{"amount":-2.5,"currency":"USD"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var money Money
+			if err := json.Unmarshal([]byte(tt.raw), &money); err != nil {
+				t.Fatal(err)
+			}
+			if money != tt.money {
+				t.Fatalf("decoded %+v, want %+v", money, tt.money)
+			}
+			out, err := json.Marshal(money)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.out {
+				t.Fatalf("encoded %s, want %s", out, tt.out)
+			}
+		})
+	}
+}
+
+func TestMoneyRejectsBadAmounts(t *testing.T) {
+	var money Money
+	if err := json.Unmarshal([]byte(`{"amount": "ten", "currency": "USD"}`), &money); err == nil {
+		t.Fatal("a text amount was accepted")
+	}
+}
+
+func TestMoneyAddNeedsTheSameCurrency(t *testing.T) {
+	sum, err := Money{Amount: 150, Currency: "USD"}.Add(Money{Amount: 275, Currency: "USD"})
+	if err != nil || sum != (Money{Amount: 425, Currency: "USD"}) {
+		t.Fatalf("sum %+v, %v", sum, err)
+	}
+	if _, err := (Money{Amount: 1, Currency: "USD"}).Add(Money{Amount: 1, Currency: "EUR"}); err == nil {
+		t.Fatal("dollars and euros were added")
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	tests := []struct {
+		raw    string
+		amount Amount
+		out    string
+	}{
+		{`17.99`, 17990, `17.99`},
+		{`1500`, 1500000, `1500`},
+		{`1.234`, 1234, `1.234`},
+		{`0.1`, 100, `0.1`},
+		{`-2.5`, -2500, `-2.5`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			var amount Amount
+			if err := json.Unmarshal([]byte(tt.raw), &amount); err != nil {
+				t.Fatal(err)
+			}
+			if amount != tt.amount {
+				t.Fatalf("decoded %d, want %d", amount, tt.amount)
+			}
+			out, err := json.Marshal(amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.out {
+				t.Fatalf("encoded %s, want %s", out, tt.out)
+			}
+		})
+	}
+	var amount Amount
+	if err := json.Unmarshal([]byte(`"ten"`), &amount); err == nil {
+		t.Fatal("a text amount was accepted")
+	}
+}
+
+func TestAmountIn(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		currency string
+		want     Money
+	}{
+		{NewAmount(17.99), "usd", Money{Amount: 1799, Currency: "USD"}},
+		{NewAmount(0.125), "USD", Money{Amount: 13, Currency: "USD"}},
+		{NewAmount(-0.125), "USD", Money{Amount: -13, Currency: "USD"}},
+		{NewAmount(1499.5), "JPY", Money{Amount: 1500, Currency: "JPY"}},
+		{NewAmount(1.234), "KWD", Money{Amount: 1234, Currency: "KWD"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount.String()+" "+tt.currency, func(t *testing.T) {
+			got := tt.amount.In(tt.currency)
+			if got != tt.want {
+				t.Fatalf("In = %+v, want %+v", got, tt.want)
+			}
+			if back := AmountOf(got); back != NewAmount(got.Float()) {
+				t.Fatalf("AmountOf(%+v) = %v", got, back)
+			}
+		})
+	}
+}
+
+func TestAmountsAddUpExactly(t *testing.T) {
+	var total Amount
+	for i := 0; i < 10; i++ {
+		total += NewAmount(0.1)
+	}
+	if total != NewAmount(1) || total.Times(3) != NewAmount(3) {
+		t.Fatalf("ten times 0.1 is %v", total)
+	}
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ----------------------------------------------Definition of the order lifecycle--------------------------------
+type OrderStatus string
+
+const (
+	OrderPending OrderStatus = "pending"
+	OrderPaid    OrderStatus = "paid"
+	OrderPacked  OrderStatus = "packed"
+	// an order is partially shipped while some parcels are on their way and books are left to ship
+	OrderPartiallyShipped OrderStatus = "partially_shipped"
+	OrderShipped          OrderStatus = "shipped"
+	OrderDelivered        OrderStatus = "delivered"
+	OrderCancelled        OrderStatus = "cancelled"
+	OrderRefunded         OrderStatus = "refunded"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	ErrOrderPaid         = errors.New("order already paid")
+)
+
+type StatusChange struct {
+	From OrderStatus `json:"from,omitempty"`
+	To   OrderStatus `json:"to"`
+	At   time.Time   `json:"at"`
+}
+
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:          {OrderPaid, OrderCancelled},
+	OrderPaid:             {OrderPacked, OrderCancelled, OrderRefunded},
+	OrderPacked:           {OrderPartiallyShipped, OrderShipped, OrderCancelled},
+	OrderPartiallyShipped: {OrderShipped},
+	OrderShipped:          {OrderDelivered},
+	OrderDelivered:        {OrderRefunded},
+}
+
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderPending, OrderPaid, OrderPacked, OrderPartiallyShipped, OrderShipped, OrderDelivered, OrderCancelled, OrderRefunded:
+		return true
+	}
+	return false
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// HoldsStock tells whether the books of an order in this status are still in the warehouse,
+// reserved for it. Leaving such a status without shipping gives the stock back.
+func (s OrderStatus) HoldsStock() bool {
+	return s == OrderPending || s == OrderPaid || s == OrderPacked
+}
+
+// Transition moves the order to next and records when it happened.
+func (o *Order) Transition(next OrderStatus, at time.Time) error {
+	if !o.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: a %s order can't become %s", ErrInvalidTransition, o.Status, next)
+	}
+	o.StatusHistory = append(o.StatusHistory, StatusChange{From: o.Status, To: next, At: at})
+	o.Status = next
+	return nil
+}
+
+// fulfillmentFlow is the way a paid order goes forward, one step at a time.
+var fulfillmentFlow = []OrderStatus{OrderPaid, OrderPacked, OrderPartiallyShipped, OrderShipped, OrderDelivered}
+
+// AdvanceTo walks the order forward through every step up to target, an order already there or further
+// doesn't move. Only paid orders and the ones after them can be moved that way. An order shipped in one
+// go skips the partially shipped step.
+func (o *Order) AdvanceTo(target OrderStatus, at time.Time) error {
+	current, goal := -1, -1
+	for i, status := range fulfillmentFlow {
+		if status == o.Status {
+			current = i
+		}
+		if status == target {
+			goal = i
+		}
+	}
+	if current < 0 || goal < 0 {
+		return fmt.Errorf("%w: a %s order can't be moved to %s", ErrInvalidTransition, o.Status, target)
+	}
+	for i := current + 1; i <= goal; i++ {
+		if fulfillmentFlow[i] == OrderPartiallyShipped && target != OrderPartiallyShipped {
+			continue
+		}
+		if err := o.Transition(fulfillmentFlow[i], at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StatusAt returns when the order entered the given status, if it did.
+func (o Order) StatusAt(status OrderStatus) (time.Time, bool) {
+	for i := len(o.StatusHistory) - 1; i >= 0; i-- {
+		if o.StatusHistory[i].To == status {
+			return o.StatusHistory[i].At, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// WasPaid tells whether the order was paid at some point. The orders migrated from the free-form statuses
+// have no paid step in their history, a status past it is enough for them.
+func (o Order) WasPaid() bool {
+	if _, paid := o.StatusAt(OrderPaid); paid {
+		return true
+	}
+	switch o.Status {
+	case OrderPaid, OrderPacked, OrderPartiallyShipped, OrderShipped, OrderDelivered, OrderRefunded:
+		return true
+	}
+	return false
+}
+
+// RefundedTotal is what was given back for the order, all of it once it is refunded or cancelled after
+// being paid. The orders refunded before the amount was kept have no RefundedAmount.
+func (o Order) RefundedTotal() Amount {
+	if _, paid := o.StatusAt(OrderPaid); o.Status == OrderRefunded || (o.Status == OrderCancelled && paid) {
+		return o.TotalPrice
+	}
+	return o.RefundedAmount
+}
+
+// Allocated is the part of the item taken from the stock, the rest is on backorder.
+func (i OrderItem) Allocated() int {
+	return i.Quantity - i.Backordered
+}
+
+// ToShip is what was allocated to the item and isn't in a parcel yet.
+func (i OrderItem) ToShip() int {
+	return i.Allocated() - i.Shipped
+}
+
+// Backordered is the number of copies of the order still waiting for stock.
+func (o Order) Backordered() int {
+	backordered := 0
+	for _, item := range o.Items {
+		backordered += item.Backordered
+	}
+	return backordered
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// ----------------------------------------------Definition of Payments--------------------------------
+// Every call made to the payment provider for an order is kept as an attempt, whatever its result, so
+// the log tells what was charged, voided and refunded and why a payment failed.
+type PaymentOperation string
+
+const (
+	PaymentAuthorize PaymentOperation = "authorize"
+	PaymentCapture   PaymentOperation = "capture"
+	PaymentVoid      PaymentOperation = "void"
+	PaymentRefund    PaymentOperation = "refund"
+)
+
+type PaymentResult string
+
+const (
+	PaymentSucceeded PaymentResult = "succeeded"
+	PaymentDeclined  PaymentResult = "declined"
+	PaymentFailed    PaymentResult = "failed"
+)
+
+type PaymentAttempt struct {
+	ID        int              `json:"id"`
+	OrderID   int              `json:"order_id"`
+	Provider  string           `json:"provider"`
+	Operation PaymentOperation `json:"operation"`
+	Result    PaymentResult    `json:"result"`
+	Amount    Money            `json:"amount"`
+	// TransactionID is given by the provider, ParentID is the transaction a capture, void or refund applies to.
+	TransactionID string `json:"transaction_id,omitempty"`
+	ParentID      string `json:"parent_id,omitempty"`
+	// Reference is what the refund was named at the provider, a retried refund keeps it.
+	Reference string    `json:"reference,omitempty"`
+	CardBrand string    `json:"card_brand,omitempty"`
+	CardLast4 string    `json:"card_last4,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (p PaymentAttempt) GetID() int { return p.ID }
+
+func (p PaymentAttempt) WithID(id int) PaymentAttempt {
+	p.ID = id
+	return p
+}
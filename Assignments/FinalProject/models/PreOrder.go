@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+// ----------------------------------------------Definition of Pre-orders--------------------------------
+// A book whose PublishedAt is still ahead can be ordered without stock. Its copies wait on backorder,
+// queued in the order the orders were placed, and are only allocated once the book is released.
+
+// PreOrder is the place of an item of an order in the queue of its book. Position 1 is served first,
+// an item whose copies were all allocated has no position anymore.
+type PreOrder struct {
+	OrderID     int         `json:"order_id"`
+	OrderStatus OrderStatus `json:"order_status"`
+	BookID      int         `json:"book_id"`
+	Title       string      `json:"title"`
+	Quantity    int         `json:"quantity"`
+	Waiting     int         `json:"waiting"`
+	Position    int         `json:"position,omitempty"`
+	CopiesAhead int         `json:"copies_ahead"`
+	PublishedAt time.Time   `json:"published_at"`
+	OrderedAt   time.Time   `json:"ordered_at"`
+}
+
+// PreOrderDemand is what was pre-ordered of a book. Shortfall is what the stock on hand can't cover
+// of the copies still waiting.
+type PreOrderDemand struct {
+	BookID      int       `json:"book_id"`
+	Title       string    `json:"title"`
+	PublishedAt time.Time `json:"published_at"`
+	Released    bool      `json:"released"`
+	Orders      int       `json:"orders"`
+	Copies      int       `json:"copies"`
+	Waiting     int       `json:"waiting"`
+	Available   int       `json:"available"`
+	Shortfall   int       `json:"shortfall"`
+}
+
+type PreOrderReport struct {
+	Timestamp   time.Time        `json:"timestamp"`
+	TotalOrders int              `json:"total_orders"`
+	TotalCopies int              `json:"total_copies"`
+	Books       []PreOrderDemand `json:"books"`
+}
+
+// Released tells whether the book is published at the given time, a book without a publication date is.
+func (b Book) Released(at time.Time) bool {
+	return !b.PublishedAt.After(at)
+}
+
+// Waiting tells whether the order still waits for stock, an order cancelled or shipped doesn't.
+func (s OrderStatus) Waiting() bool {
+	return s.HoldsStock() || s == OrderPartiallyShipped
+}
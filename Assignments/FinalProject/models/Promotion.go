@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+// ----------------------------------------------Definition of Promotions--------------------------------
+// A promotion with a code is a coupon, it only applies to the orders that name it. A promotion
+// without a code applies by itself to every order it is eligible for.
+type PromotionKind string
+
+const (
+	PromotionPercentage PromotionKind = "percentage"
+	PromotionFixed      PromotionKind = "fixed"
+	PromotionBuyXGetY   PromotionKind = "buy_x_get_y"
+)
+
+type Promotion struct {
+	ID   int           `json:"id"`
+	Code string        `json:"code,omitempty"`
+	Name string        `json:"name"`
+	Kind PromotionKind `json:"kind"`
+	// Percent is used by percentage promotions, Amount by fixed ones.
+	Percent float64 `json:"percent,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+	// buy_x_get_y: for every BuyQuantity eligible books, FreeQuantity more are free, the cheapest ones.
+	BuyQuantity  int `json:"buy_quantity,omitempty"`
+	FreeQuantity int `json:"free_quantity,omitempty"`
+	// With genres or authors, only the matching books count, for the discount and for the minimum.
+	Genres        []string   `json:"genres,omitempty"`
+	AuthorIDs     []int      `json:"author_ids,omitempty"`
+	MinOrderValue float64    `json:"min_order_value,omitempty"`
+	StartsAt      *time.Time `json:"starts_at,omitempty"`
+	EndsAt        *time.Time `json:"ends_at,omitempty"`
+	// Zero means unlimited.
+	MaxUses            int       `json:"max_uses,omitempty"`
+	MaxUsesPerCustomer int       `json:"max_uses_per_customer,omitempty"`
+	Stackable          bool      `json:"stackable"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// AppliedPromotion is what an order keeps of the promotions it got, even if they are deleted later.
+type AppliedPromotion struct {
+	PromotionID int    `json:"promotion_id"`
+	Code        string `json:"code,omitempty"`
+	Name        string `json:"name"`
+	Discount    Amount `json:"discount"`
+}
+
+type Redemption struct {
+	OrderID     int         `json:"order_id"`
+	CustomerID  int         `json:"customer_id"`
+	OrderStatus OrderStatus `json:"order_status"`
+	Code        string      `json:"code,omitempty"`
+	Discount    Amount      `json:"discount"`
+	Currency    string      `json:"currency,omitempty"`
+	RedeemedAt  time.Time   `json:"redeemed_at"`
+}
+
+// ActiveAt tells whether the validity window of the promotion contains t, a missing bound is open.
+func (p Promotion) ActiveAt(t time.Time) bool {
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && t.After(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+func (p Promotion) GetID() int { return p.ID }
+
+func (p Promotion) WithID(id int) Promotion {
+	p.ID = id
+	return p
+}
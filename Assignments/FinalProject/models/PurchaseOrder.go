@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ----------------------------------------------Definition of Suppliers--------------------------------
+type Supplier struct {
+	ID      int     `json:"id"`
+	Name    string  `json:"name"`
+	Email   string  `json:"email,omitempty"`
+	Phone   string  `json:"phone,omitempty"`
+	Address Address `json:"address"`
+	// LeadTimeDays is how long the supplier usually takes to deliver, it sets the expected date of
+	// the purchase orders that don't give one.
+	LeadTimeDays int       `json:"lead_time_days,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ----------------------------------------------Definition of Purchase Orders--------------------------------
+// A purchase order is open until all its lines are received or it is cancelled. Every receipt puts the
+// copies delivered in stock at their cost, a delivery can come in several receipts.
+type PurchaseOrderStatus string
+
+const (
+	PurchaseOrderOpen              PurchaseOrderStatus = "open"
+	PurchaseOrderPartiallyReceived PurchaseOrderStatus = "partially_received"
+	PurchaseOrderReceived          PurchaseOrderStatus = "received"
+	PurchaseOrderCancelled         PurchaseOrderStatus = "cancelled"
+)
+
+var ErrPurchaseOrderClosed = errors.New("purchase order closed")
+
+type PurchaseOrderLine struct {
+	BookID   int    `json:"book_id"`
+	Title    string `json:"title"`
+	Quantity int    `json:"quantity"`
+	Received int    `json:"received"`
+	// UnitCost is the price agreed with the supplier for a copy, in the base currency.
+	UnitCost float64 `json:"unit_cost"`
+}
+
+// ReceiptLine is what came in for a book, at the agreed cost unless UnitCost says otherwise.
+type ReceiptLine struct {
+	BookID   int     `json:"book_id"`
+	Quantity int     `json:"quantity"`
+	UnitCost float64 `json:"unit_cost,omitempty"`
+}
+
+type PurchaseReceipt struct {
+	Lines      []ReceiptLine `json:"lines"`
+	Note       string        `json:"note,omitempty"`
+	Actor      string        `json:"actor,omitempty"`
+	ReceivedAt time.Time     `json:"received_at"`
+}
+
+type PurchaseOrder struct {
+	ID           int                 `json:"id"`
+	SupplierID   int                 `json:"supplier_id"`
+	SupplierName string              `json:"supplier_name"`
+	Status       PurchaseOrderStatus `json:"status"`
+	Lines        []PurchaseOrderLine `json:"lines"`
+	// TotalCost is what the whole order costs at the agreed prices, in Currency, the base one.
+	TotalCost  float64           `json:"total_cost"`
+	Currency   string            `json:"currency"`
+	ExpectedAt time.Time         `json:"expected_at"`
+	Notes      string            `json:"notes,omitempty"`
+	Receipts   []PurchaseReceipt `json:"receipts,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// Outstanding is what is still to be received of the line.
+func (l PurchaseOrderLine) Outstanding() int {
+	if l.Received >= l.Quantity {
+		return 0
+	}
+	return l.Quantity - l.Received
+}
+
+// Outstanding is what is still to be received of the whole order.
+func (p PurchaseOrder) Outstanding() int {
+	outstanding := 0
+	for _, line := range p.Lines {
+		outstanding += line.Outstanding()
+	}
+	return outstanding
+}
+
+// IsOpen tells whether the order still waits for deliveries.
+func (p PurchaseOrder) IsOpen() bool {
+	return p.Status == PurchaseOrderOpen || p.Status == PurchaseOrderPartiallyReceived
+}
+
+func (s Supplier) GetID() int { return s.ID }
+
+func (s Supplier) WithID(id int) Supplier {
+	s.ID = id
+	return s
+}
+
+func (p PurchaseOrder) GetID() int { return p.ID }
+
+func (p PurchaseOrder) WithID(id int) PurchaseOrder {
+	p.ID = id
+	return p
+}
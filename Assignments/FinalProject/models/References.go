@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------Definition of References--------------------------------
+// The entities point at each other by ID: a book at its authors, an order at its customer and its books.
+// What has to stay as it was when the order was placed, like the price of an item or the address it
+// ships to, is copied on purpose. The references are only resolved on the reads that ask for them
+// with ?expand=author,customer,items.book.
+const (
+	ExpandAuthor    = "author"
+	ExpandCustomer  = "customer"
+	ExpandItemsBook = "items.book"
+)
+
+var ErrInvalidExpansion = errors.New("invalid expand")
+
+// Expansion tells which references a response resolves.
+type Expansion struct {
+	Author    bool
+	Customer  bool
+	ItemsBook bool
+}
+
+// ParseExpansion reads the comma-separated value of the expand query parameter.
+func ParseExpansion(param string) (Expansion, error) {
+	var expansion Expansion
+	for _, name := range strings.Split(param, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case ExpandAuthor:
+			expansion.Author = true
+		case ExpandCustomer:
+			expansion.Customer = true
+		case ExpandItemsBook:
+			expansion.ItemsBook = true
+		default:
+			return Expansion{}, fmt.Errorf("%w '%s', expected author, customer or items.book", ErrInvalidExpansion, strings.TrimSpace(name))
+		}
+	}
+	return expansion, nil
+}
+
+func (e Expansion) Any() bool {
+	return e.Author || e.Customer || e.ItemsBook
+}
+
+// Detached keeps the references of the order as IDs, the customer and the books sent or resolved with
+// it are dropped. A reference only sent as an object, like {"customer": {"id": 1}}, gives its ID.
+func (o Order) Detached() Order {
+	if o.CustomerID == 0 && o.Customer != nil {
+		o.CustomerID = o.Customer.ID
+	}
+	o.Customer = nil
+	if o.Items == nil {
+		return o
+	}
+	items := make([]OrderItem, len(o.Items))
+	for i, item := range o.Items {
+		if item.BookID == 0 && item.Book != nil {
+			item.BookID = item.Book.ID
+		}
+		item.Book = nil
+		items[i] = item
+	}
+	o.Items = items
+	return o
+}
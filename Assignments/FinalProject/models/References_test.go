@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseExpansion(t *testing.T) {
+	tests := []struct {
+		param   string
+		want    Expansion
+		wantErr error
+	}{
+		{"", Expansion{}, nil},
+		{"customer", Expansion{Customer: true}, nil},
+		{"author, items.book", Expansion{Author: true, ItemsBook: true}, nil},
+		{"customer,,author", Expansion{Author: true, Customer: true}, nil},
+		{"items", Expansion{}, ErrInvalidExpansion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			got, err := ParseExpansion(tt.param)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseExpansion error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseExpansion = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetached(t *testing.T) {
+	order := Order{
+		Customer: &Customer{ID: 2, Name: "Bob"},
+		Items:    []OrderItem{{Book: &Book{ID: 3, Title: "Dune"}, Quantity: 1}, {BookID: 4, Book: &Book{ID: 9}, Quantity: 2}},
+	}
+	detached := order.Detached()
+	if detached.CustomerID != 2 || detached.Customer != nil {
+		t.Fatalf("customer is %d and %+v, want only the ID 2", detached.CustomerID, detached.Customer)
+	}
+	if detached.Items[0].BookID != 3 || detached.Items[1].BookID != 4 {
+		t.Fatalf("items point at books %d and %d, want 3 and 4", detached.Items[0].BookID, detached.Items[1].BookID)
+	}
+	for _, item := range detached.Items {
+		if item.Book != nil {
+			t.Fatalf("item of book %d kept its book", item.BookID)
+		}
+	}
+	if order.Items[0].Book == nil {
+		t.Fatal("the items of the order were detached in place")
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ----------------------------------------------Definition of Stock Reservations--------------------------------
+// An unpaid order holds its books until ExpiresAt. Paying commits the hold, the books then leave the
+// stock for good, while cancelling the order or letting the hold expire puts them back on sale.
+type ReservationStatus string
+
+const (
+	ReservationHeld      ReservationStatus = "held"
+	ReservationCommitted ReservationStatus = "committed"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationExpired   ReservationStatus = "expired"
+)
+
+var ErrStockBelowReserved = errors.New("stock below the reserved quantity")
+
+type StockReservation struct {
+	ID         int               `json:"id"`
+	OrderID    int               `json:"order_id"`
+	BookID     int               `json:"book_id"`
+	Quantity   int               `json:"quantity"`
+	Status     ReservationStatus `json:"status"`
+	CreatedAt  time.Time         `json:"created_at"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	ResolvedAt *time.Time        `json:"resolved_at,omitempty"`
+}
+
+func (r StockReservation) Expired(at time.Time) bool {
+	return r.Status == ReservationHeld && !r.ExpiresAt.After(at)
+}
+
+func (r StockReservation) GetID() int { return r.ID }
+
+func (r StockReservation) WithID(id int) StockReservation {
+	r.ID = id
+	return r
+}
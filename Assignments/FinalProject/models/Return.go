@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ----------------------------------------------Definition of Returns--------------------------------
+// A customer asks to return some books of a delivered order. Approving the request refunds them on the
+// original payment and decides what becomes of each book: back on the shelves, or written off when it
+// can't be sold again. A rejected request changes nothing.
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+)
+
+type ReturnDisposition string
+
+const (
+	DispositionRestock  ReturnDisposition = "restock"
+	DispositionWriteOff ReturnDisposition = "write_off"
+)
+
+var (
+	ErrReturnNotAllowed = errors.New("return not allowed")
+	ErrReturnDecided    = errors.New("return already decided")
+)
+
+type ReturnItem struct {
+	BookID   int    `json:"book_id"`
+	Title    string `json:"title"`
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+	// UnitPrice is what the book was sold for, in the currency of the order. A book on several lines of
+	// the order at different prices gets the average price of the copies returned.
+	UnitPrice   Amount            `json:"unit_price"`
+	Disposition ReturnDisposition `json:"disposition,omitempty"`
+}
+
+type ReturnRequest struct {
+	ID         int          `json:"id"`
+	OrderID    int          `json:"order_id"`
+	CustomerID int          `json:"customer_id"`
+	Status     ReturnStatus `json:"status"`
+	Items      []ReturnItem `json:"items"`
+	Note       string       `json:"note,omitempty"`
+	// RefundAmount is what the books are worth in the order, with its discount and tax, in Currency.
+	// It is what gets refunded once the return is approved.
+	RefundAmount        Amount     `json:"refund_amount"`
+	Currency            string     `json:"currency"`
+	RefundTransactionID string     `json:"refund_transaction_id,omitempty"`
+	DecisionNote        string     `json:"decision_note,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	DecidedAt           *time.Time `json:"decided_at,omitempty"`
+}
+
+// ReturnDecision approves or rejects a return, Items gives the disposition of the books on approval,
+// the ones left out are restocked.
+type ReturnDecision struct {
+	Items []ReturnItem `json:"items,omitempty"`
+	Note  string       `json:"note,omitempty"`
+}
+
+func (r ReturnRequest) GetID() int { return r.ID }
+
+func (r ReturnRequest) WithID(id int) ReturnRequest {
+	r.ID = id
+	return r
+}
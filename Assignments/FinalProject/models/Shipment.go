@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+)
+
+// ----------------------------------------------Definition of Shipments--------------------------------
+// A shipment is one parcel of an order handed to a carrier. Its tracking events come from the carrier,
+// the last one gives the status of the shipment.
+type ShipmentStatus string
+
+const (
+	ShipmentLabelCreated   ShipmentStatus = "label_created"
+	ShipmentInTransit      ShipmentStatus = "in_transit"
+	ShipmentOutForDelivery ShipmentStatus = "out_for_delivery"
+	ShipmentDelivered      ShipmentStatus = "delivered"
+	ShipmentException      ShipmentStatus = "exception"
+	ShipmentReturned       ShipmentStatus = "returned"
+)
+
+type TrackingEvent struct {
+	Status      ShipmentStatus `json:"status"`
+	Location    string         `json:"location,omitempty"`
+	Description string         `json:"description,omitempty"`
+	At          time.Time      `json:"at"`
+}
+
+// ShipmentItem is what a parcel carries of one book of the order.
+type ShipmentItem struct {
+	BookID   int `json:"book_id"`
+	Quantity int `json:"quantity"`
+}
+
+type Shipment struct {
+	ID             int            `json:"id"`
+	OrderID        int            `json:"order_id"`
+	Carrier        string         `json:"carrier"`
+	Method         string         `json:"method,omitempty"`
+	TrackingNumber string         `json:"tracking_number"`
+	Status         ShipmentStatus `json:"status"`
+	// Items are the books in the parcel, the shipments made before the orders could be split have none
+	// and carried the whole order.
+	Items     []ShipmentItem  `json:"items,omitempty"`
+	Events    []TrackingEvent `json:"events"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func (s ShipmentStatus) Valid() bool {
+	switch s {
+	case ShipmentLabelCreated, ShipmentInTransit, ShipmentOutForDelivery, ShipmentDelivered, ShipmentException, ShipmentReturned:
+		return true
+	}
+	return false
+}
+
+// OrderStatus is how far the order has gone once the parcel reached this status, an exception or a
+// return doesn't move the order.
+func (s ShipmentStatus) OrderStatus() (OrderStatus, bool) {
+	switch s {
+	case ShipmentLabelCreated:
+		return OrderPacked, true
+	case ShipmentInTransit, ShipmentOutForDelivery:
+		return OrderShipped, true
+	case ShipmentDelivered:
+		return OrderDelivered, true
+	}
+	return "", false
+}
+
+func (s Shipment) GetID() int { return s.ID }
+
+func (s Shipment) WithID(id int) Shipment {
+	s.ID = id
+	return s
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// ----------------------------------------------Definition of Stock Alerts--------------------------------
+// A book gets an open alert once what is available falls to its reorder point, or runs out for the
+// books without one. The alert is kept up to date while the stock stays low and resolved once it is
+// back above the point.
+type AlertStatus string
+
+const (
+	AlertOpen     AlertStatus = "open"
+	AlertResolved AlertStatus = "resolved"
+)
+
+type StockAlert struct {
+	ID              int         `json:"id"`
+	BookID          int         `json:"book_id"`
+	Title           string      `json:"title"`
+	Status          AlertStatus `json:"status"`
+	Stock           int         `json:"stock"`
+	Available       int         `json:"available"`
+	ReorderPoint    int         `json:"reorder_point"`
+	ReorderQuantity int         `json:"reorder_quantity"`
+	// SalesPerDay is the number of copies sold per day lately, SuggestedQuantity what to order so the
+	// stock covers the sales to come on top of the reorder point.
+	SalesPerDay       float64    `json:"sales_per_day"`
+	SuggestedQuantity int        `json:"suggested_quantity"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	ResolvedAt        *time.Time `json:"resolved_at,omitempty"`
+}
+
+func (a StockAlert) GetID() int { return a.ID }
+
+func (a StockAlert) WithID(id int) StockAlert {
+	a.ID = id
+	return a
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ----------------------------------------------Definition of the Inventory Ledger--------------------------------
+// Every change to the stock or the reservations of a book is a movement of the ledger. The Stock and
+// Reserved of a book are the sums of its movements, kept on the book so reading them stays cheap.
+type MovementKind string
+
+const (
+	MovementReceipt     MovementKind = "receipt"
+	MovementSale        MovementKind = "sale"
+	MovementReturn      MovementKind = "return"
+	MovementAdjustment  MovementKind = "adjustment"
+	MovementReservation MovementKind = "reservation"
+	MovementRelease     MovementKind = "release"
+)
+
+var ErrInvalidMovement = errors.New("invalid stock movement")
+
+type StockMovement struct {
+	ID     int          `json:"id"`
+	BookID int          `json:"book_id"`
+	Kind   MovementKind `json:"kind"`
+	// Quantity changes the stock on hand and Reserved the part of it held by orders, both can be negative.
+	Quantity int `json:"quantity"`
+	Reserved int `json:"reserved,omitempty"`
+	// UnitCost is what a received copy cost, in the base currency. On a sale it is the average cost
+	// of the copies sold.
+	UnitCost float64 `json:"unit_cost,omitempty"`
+	Reason   string  `json:"reason,omitempty"`
+	Actor    string  `json:"actor"`
+	OrderID  int     `json:"order_id,omitempty"`
+	// PurchaseOrderID is set on the receipts of a purchase order.
+	PurchaseOrderID int       `json:"purchase_order_id,omitempty"`
+	StockAfter      int       `json:"stock_after"`
+	ReservedAfter   int       `json:"reserved_after"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// StockLedger is the history of a book, with the stock it adds up to next to the one on the book.
+type StockLedger struct {
+	BookID         int             `json:"book_id"`
+	Title          string          `json:"title"`
+	Stock          int             `json:"stock"`
+	Reserved       int             `json:"reserved"`
+	LedgerStock    int             `json:"ledger_stock"`
+	LedgerReserved int             `json:"ledger_reserved"`
+	Reconciled     bool            `json:"reconciled"`
+	Movements      []StockMovement `json:"movements"`
+}
+
+// BookValuation values the stock on hand at the average cost of the receipts, and at the list price.
+type BookValuation struct {
+	BookID      int     `json:"book_id"`
+	Title       string  `json:"title"`
+	Stock       int     `json:"stock"`
+	Reserved    int     `json:"reserved"`
+	UnitCost    float64 `json:"unit_cost"`
+	CostValue   float64 `json:"cost_value"`
+	RetailValue float64 `json:"retail_value"`
+	Reconciled  bool    `json:"reconciled"`
+}
+
+type InventoryValuation struct {
+	Timestamp        time.Time       `json:"timestamp"`
+	Currency         string          `json:"currency"`
+	TotalUnits       int             `json:"total_units"`
+	TotalCostValue   float64         `json:"total_cost_value"`
+	TotalRetailValue float64         `json:"total_retail_value"`
+	Books            []BookValuation `json:"books"`
+}
+
+func (m StockMovement) GetID() int { return m.ID }
+
+func (m StockMovement) WithID(id int) StockMovement {
+	m.ID = id
+	return m
+}
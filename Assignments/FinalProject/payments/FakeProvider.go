@@ -0,0 +1,99 @@
+package payments
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------Definition of the fake provider--------------------------------
+// FakeProvider answers like a real gateway without leaving the machine. The outcome only depends on the
+// card number, so every scenario can be played again offline:
+//
+//	4000 0000 0000 0002  declined
+//	4000 0000 0000 9995  declined, insufficient funds
+//	4000 0000 0000 0069  declined, expired card
+//	4000 0000 0000 0119  the authorization times out
+//	4000 0000 0000 0341  authorized, but the capture is declined
+//	4000 0000 0000 5126  charged, but the refunds time out
+//
+// Any other number passing the Luhn check is approved, one that doesn't is declined. The transaction ids
+// are made of the references and the last digits of the card, nothing is kept in memory, so the later
+// steps behave the same after a restart.
+type FakeProvider struct{}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Authorize(ctx context.Context, reference string, amount Money, card Card) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if !card.ValidNumber() {
+		return "", fmt.Errorf("%w: invalid card number", ErrDeclined)
+	}
+	if amount.Amount <= 0 {
+		return "", errors.New("Nothing to authorize")
+	}
+	if card.ExpYear != 0 && !time.Date(card.ExpYear, time.Month(card.ExpMonth)+1, 1, 0, 0, 0, 0, time.UTC).After(time.Now()) {
+		return "", fmt.Errorf("%w: expired card", ErrDeclined)
+	}
+	switch card.Last4() {
+	case "0002":
+		return "", fmt.Errorf("%w: card declined", ErrDeclined)
+	case "9995":
+		return "", fmt.Errorf("%w: insufficient funds", ErrDeclined)
+	case "0069":
+		return "", fmt.Errorf("%w: expired card", ErrDeclined)
+	case "0119":
+		return "", ErrTimeout
+	}
+	return "fake_auth_" + reference + "_" + card.Last4(), nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, authorizationID string, amount Money) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	suffix, ok := strings.CutPrefix(authorizationID, "fake_auth_")
+	if !ok {
+		return "", errors.New("Unknown authorization " + authorizationID)
+	}
+	if strings.HasSuffix(suffix, "_0341") {
+		return "", fmt.Errorf("%w: the capture was refused", ErrDeclined)
+	}
+	return "fake_cap_" + suffix, nil
+}
+
+func (p *FakeProvider) Void(ctx context.Context, authorizationID string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	suffix, ok := strings.CutPrefix(authorizationID, "fake_auth_")
+	if !ok {
+		return "", errors.New("Unknown authorization " + authorizationID)
+	}
+	return "fake_void_" + suffix, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, captureID string, reference string, amount Money) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	suffix, ok := strings.CutPrefix(captureID, "fake_cap_")
+	if !ok {
+		return "", errors.New("Unknown capture " + captureID)
+	}
+	if strings.HasSuffix(suffix, "_5126") {
+		return "", ErrTimeout
+	}
+	return "fake_refund_" + suffix + "_" + reference, nil
+}
@@ -0,0 +1,72 @@
+package payments
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFakeProviderCards(t *testing.T) {
+	amount := Money{Amount: 2500, Currency: "USD"}
+	tests := []struct {
+		name      string
+		number    string
+		authorize error
+		capture   error
+		refund    error
+	}{
+		{name: "approved", number: "4242 4242 4242 4242"},
+		{name: "approved with dashes", number: "5555-5555-5555-4444"},
+		{name: "declined", number: "4000 0000 0000 0002", authorize: ErrDeclined},
+		{name: "insufficient funds", number: "4000 0000 0000 9995", authorize: ErrDeclined},
+		{name: "expired card", number: "4000 0000 0000 0069", authorize: ErrDeclined},
+		{name: "authorization timeout", number: "4000 0000 0000 0119", authorize: ErrTimeout},
+		{name: "failed Luhn check", number: "4242 4242 4242 4241", authorize: ErrDeclined},
+		{name: "capture refused", number: "4000 0000 0000 0341", capture: ErrDeclined},
+		{name: "refund timeout", number: "4000 0000 0000 5126", refund: ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			provider := NewFakeProvider()
+			card := Card{Number: tt.number, ExpMonth: 12, ExpYear: 2099}
+
+			authorizationID, err := provider.Authorize(ctx, "order_1_1", amount, card)
+			if !errors.Is(err, tt.authorize) {
+				t.Fatalf("Authorize error = %v, want %v", err, tt.authorize)
+			}
+			if err != nil {
+				return
+			}
+			captureID, err := provider.Capture(ctx, authorizationID, amount)
+			if !errors.Is(err, tt.capture) {
+				t.Fatalf("Capture error = %v, want %v", err, tt.capture)
+			}
+			if err != nil {
+				if _, err := provider.Void(ctx, authorizationID); err != nil {
+					t.Fatalf("Void: %v", err)
+				}
+				return
+			}
+			first, err := provider.Refund(ctx, captureID, "refund_1", amount)
+			if !errors.Is(err, tt.refund) {
+				t.Fatalf("Refund error = %v, want %v", err, tt.refund)
+			}
+			if err != nil {
+				return
+			}
+			second, _ := provider.Refund(ctx, captureID, "refund_2", amount)
+			if first == second {
+				t.Fatalf("two refunds got the same id %s", first)
+			}
+		})
+	}
+}
+
+func TestFakeProviderExpiryDate(t *testing.T) {
+	card := Card{Number: "4242 4242 4242 4242", ExpMonth: 1, ExpYear: 2000}
+	if _, err := NewFakeProvider().Authorize(context.Background(), "order_1_1", Money{Amount: 100, Currency: "USD"}, card); !errors.Is(err, ErrDeclined) {
+		t.Fatalf("Authorize error = %v, want %v", err, ErrDeclined)
+	}
+}
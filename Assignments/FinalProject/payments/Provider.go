@@ -0,0 +1,85 @@
+package payments
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"strings"
+)
+
+// ----------------------------------------------Definition of the payment providers--------------------------------
+// A provider takes a payment in two steps: the authorization holds the amount on the card, the capture
+// charges it. An authorization that won't be captured is voided, a capture is given back with a refund,
+// in full or in part. Every call returns the transaction id of the provider. The references are chosen by
+// the caller, a provider tells two refunds of the same capture apart by them and takes a refund retried
+// with the same reference for the same refund.
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, reference string, amount Money, card Card) (string, error)
+	Capture(ctx context.Context, authorizationID string, amount Money) (string, error)
+	Void(ctx context.Context, authorizationID string) (string, error)
+	Refund(ctx context.Context, captureID string, reference string, amount Money) (string, error)
+}
+
+// ErrDeclined is returned when the bank refuses the operation, ErrTimeout when the provider didn't
+// answer, in which case the outcome is unknown and the operation may be tried again.
+var (
+	ErrDeclined = errors.New("payment declined")
+	ErrTimeout  = errors.New("payment provider timed out")
+)
+
+type Card struct {
+	Number   string `json:"number"`
+	ExpMonth int    `json:"exp_month"`
+	ExpYear  int    `json:"exp_year"`
+	CVC      string `json:"cvc"`
+}
+
+// Last4 and Brand are all that is kept of a card, the number itself is never stored.
+func (c Card) Last4() string {
+	number := c.digits()
+	if len(number) < 4 {
+		return number
+	}
+	return number[len(number)-4:]
+}
+
+func (c Card) Brand() string {
+	number := c.digits()
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case strings.HasPrefix(number, "5"):
+		return "mastercard"
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return "amex"
+	}
+	return "unknown"
+}
+
+func (c Card) digits() string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(c.Number)
+}
+
+// ValidNumber runs the Luhn check every card number passes.
+func (c Card) ValidNumber() bool {
+	number := c.digits()
+	if len(number) < 12 || len(number) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range number {
+		digit := int(number[len(number)-1-i] - '0')
+		if digit < 0 || digit > 9 {
+			return false
+		}
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
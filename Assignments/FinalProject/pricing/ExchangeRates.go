@@ -0,0 +1,106 @@
+package pricing
+
+import (
+	. "FinalProject/models"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ----------------------------------------------Definition of the exchange rates--------------------------------
+// ExchangeRates converts between the base currency of the store and the others. Every rate says how many
+// units of the base currency one unit of Currency is worth from EffectiveFrom on, so a conversion uses the
+// rate that was in effect at the time it is given, and old orders keep the rate of their day.
+type ExchangeRate struct {
+	Currency      string    `json:"currency"`
+	Rate          float64   `json:"rate"`
+	EffectiveFrom time.Time `json:"effective_from"`
+}
+
+type ExchangeRates struct {
+	Base  string
+	Rates []ExchangeRate
+}
+
+// LoadExchangeRates reads a file like {"rates": [{"currency": "EUR", "rate": 1.08, "effective_from": "2026-01-01T00:00:00Z"}]}.
+func LoadExchangeRates(path string, base string) (*ExchangeRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rates []ExchangeRate `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, errors.New("Invalid exchange rates " + path + ": " + err.Error())
+	}
+	rates := &ExchangeRates{Base: NormalizeCurrency(base)}
+	for i, rate := range file.Rates {
+		rate.Currency = NormalizeCurrency(rate.Currency)
+		if !ValidCurrency(rate.Currency) {
+			return nil, errors.New("Exchange rate " + strconv.Itoa(i) + " of " + path + " has an invalid currency '" + rate.Currency + "'")
+		}
+		if rate.Rate <= 0 {
+			return nil, errors.New("Exchange rate " + strconv.Itoa(i) + " of " + path + " must be positive")
+		}
+		rates.Rates = append(rates.Rates, rate)
+	}
+	sort.SliceStable(rates.Rates, func(i, j int) bool { return rates.Rates[i].EffectiveFrom.Before(rates.Rates[j].EffectiveFrom) })
+	return rates, nil
+}
+
+// BaseCurrency works on a nil table too, it then only knows the default currency.
+func (r *ExchangeRates) BaseCurrency() string {
+	if r == nil || r.Base == "" {
+		return DefaultCurrency
+	}
+	return r.Base
+}
+
+// RateAt returns the value of one unit of the currency in the base currency at the given time.
+func (r *ExchangeRates) RateAt(currency string, at time.Time) (float64, error) {
+	currency = NormalizeCurrency(currency)
+	if currency == r.BaseCurrency() {
+		return 1, nil
+	}
+	rate := 0.0
+	if r != nil {
+		for _, candidate := range r.Rates {
+			if candidate.Currency == currency && !candidate.EffectiveFrom.After(at) {
+				rate = candidate.Rate
+			}
+		}
+	}
+	if rate == 0 {
+		return 0, errors.New("No exchange rate from " + currency + " to " + r.BaseCurrency() + " on " + at.Format("2006-01-02"))
+	}
+	return rate, nil
+}
+
+func (r *ExchangeRates) Convert(amount Money, to string, at time.Time) (Money, error) {
+	to = NormalizeCurrency(to)
+	if amount.Currency == to {
+		return amount, nil
+	}
+	fromRate, err := r.RateAt(amount.Currency, at)
+	if err != nil {
+		return Money{}, err
+	}
+	toRate, err := r.RateAt(to, at)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(amount.Float()*fromRate/toRate, to), nil
+}
+
+// FromBase converts an amount configured in the base currency, like a shipping fee, into the currency of an order.
+func (r *ExchangeRates) FromBase(amount float64, currency string, at time.Time) (float64, error) {
+	converted, err := r.Convert(NewMoney(amount, r.BaseCurrency()), currency, at)
+	if err != nil {
+		return 0, err
+	}
+	return converted.Float(), nil
+}
@@ -0,0 +1,49 @@
+package pricing
+
+import (
+	. "FinalProject/models"
+	"testing"
+	"time"
+)
+
+func TestExchangeRatesConvert(t *testing.T) {
+	january := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	rates := &ExchangeRates{Base: "USD", Rates: []ExchangeRate{
+		{Currency: "EUR", Rate: 1.1, EffectiveFrom: january},
+		{Currency: "EUR", Rate: 1.2, EffectiveFrom: june},
+		{Currency: "JPY", Rate: 0.01, EffectiveFrom: january},
+	}}
+	tests := []struct {
+		name    string
+		amount  Money
+		to      string
+		at      time.Time
+		want    Money
+		wantErr bool
+	}{
+		{"rate of the day", Money{Amount: 1000, Currency: "EUR"}, "USD", january.AddDate(0, 1, 0), Money{Amount: 1100, Currency: "USD"}, false},
+		{"newer rate", Money{Amount: 1000, Currency: "EUR"}, "USD", june, Money{Amount: 1200, Currency: "USD"}, false},
+		{"through the base currency", Money{Amount: 1100, Currency: "EUR"}, "JPY", january, Money{Amount: 1210, Currency: "JPY"}, false},
+		{"same currency", Money{Amount: 5, Currency: "EUR"}, "eur", january, Money{Amount: 5, Currency: "EUR"}, false},
+		{"before the first rate", Money{Amount: 1000, Currency: "EUR"}, "USD", january.AddDate(0, 0, -1), Money{}, true},
+		{"unknown currency", Money{Amount: 1000, Currency: "GBP"}, "USD", june, Money{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rates.Convert(tt.amount, tt.to, tt.at)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("converted to %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("converted to %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package pricing
+
+import (
+	. "FinalProject/models"
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ----------------------------------------------Definition of the pricing engine--------------------------------
+// The engine prices an order on the server: every line is priced from the current price of its book,
+// then the discounts, the tax and the shipping are applied in that order. Whatever totals the client
+// sent are overwritten. The stages are interfaces so new rules plug in without touching the order store.
+type DiscountPolicy interface {
+	Discount(order *Order, subtotal Amount) (Amount, error)
+}
+
+// TaxPolicy returns the tax lines of an order whose items are priced, discount is the discount of the
+// whole order, it lowers the taxable amounts.
+type TaxPolicy interface {
+	Tax(order Order, discount Amount) ([]TaxLine, error)
+}
+
+// ShippingPolicy may record on the order the method it priced.
+type ShippingPolicy interface {
+	Shipping(order *Order, subtotal Amount) (Amount, error)
+}
+
+type PricingEngine struct {
+	Discounts []DiscountPolicy
+	Tax       TaxPolicy
+	Shipping  ShippingPolicy
+	Rates     *ExchangeRates
+}
+
+func NewPricingEngine() *PricingEngine {
+	return &PricingEngine{Tax: FlatRateTax{}, Shipping: FlatShipping{}, Rates: &ExchangeRates{Base: DefaultCurrency}}
+}
+
+// PriceOrder expects the items to carry the books looked up as they are now, with their current price. The
+// discounts given here apply to this order only, after the ones of the engine. A discount policy may
+// record on the order what it applied. The order is priced in its currency, the base one when it has none.
+func (e *PricingEngine) PriceOrder(order *Order, discounts ...DiscountPolicy) error {
+	order.Currency = NormalizeCurrency(order.Currency)
+	if order.Currency == "" {
+		order.Currency = e.Rates.BaseCurrency()
+	}
+	if !ValidCurrency(order.Currency) {
+		return errors.New("Invalid currency '" + order.Currency + "', expected an ISO 4217 code like EUR")
+	}
+	currency := order.Currency
+	for _, item := range order.Items {
+		if item.Book == nil {
+			return errors.New("Book " + strconv.Itoa(item.BookID) + " of the order wasn't looked up before pricing")
+		}
+	}
+
+	var breakdown PriceBreakdown
+	order.Promotions = nil
+	order.TaxLines = nil
+	subtotal := Money{Currency: currency}
+	for i, item := range order.Items {
+		unitPrice, err := e.unitPrice(*item.Book, currency, order.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if unitPrice.Amount < 0 {
+			return errors.New("Book " + strconv.Itoa(item.BookID) + " has a negative price")
+		}
+		lineTotal := Money{Amount: unitPrice.Amount * int64(item.Quantity), Currency: currency}
+		order.Items[i].UnitPrice = AmountOf(unitPrice)
+		order.Items[i].LineTotal = AmountOf(lineTotal)
+		subtotal.Amount += lineTotal.Amount
+	}
+	breakdown.Subtotal = AmountOf(subtotal)
+
+	// every discount is rounded to the minor unit before they are added up
+	discount := Money{Currency: currency}
+	for _, policy := range append(append([]DiscountPolicy{}, e.Discounts...), discounts...) {
+		amount, err := policy.Discount(order, breakdown.Subtotal)
+		if err != nil {
+			return err
+		}
+		discount.Amount += amount.In(currency).Amount
+	}
+	// a discount never makes the books cost less than nothing
+	discount.Amount = min(max(discount.Amount, 0), subtotal.Amount)
+	breakdown.Discount = AmountOf(discount)
+
+	tax, taxIncluded := Money{Currency: currency}, Money{Currency: currency}
+	if e.Tax != nil {
+		lines, err := e.Tax.Tax(*order, breakdown.Discount)
+		if err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].Taxable = RoundAmount(lines[i].Taxable, currency)
+			amount := NewMoney(lines[i].Amount, currency)
+			lines[i].Amount = amount.Float()
+			if lines[i].Inclusive {
+				taxIncluded.Amount += amount.Amount
+			} else {
+				tax.Amount += amount.Amount
+			}
+		}
+		breakdown.Tax = AmountOf(tax)
+		breakdown.TaxIncluded = AmountOf(taxIncluded)
+		order.TaxLines = lines
+	}
+	shipping := Money{Currency: currency}
+	if e.Shipping != nil {
+		amount, err := e.Shipping.Shipping(order, breakdown.Subtotal)
+		if err != nil {
+			return err
+		}
+		shipping = amount.In(currency)
+		breakdown.Shipping = AmountOf(shipping)
+	}
+	total := Money{Amount: subtotal.Amount - discount.Amount + tax.Amount + shipping.Amount, Currency: currency}
+	breakdown.Total = AmountOf(total)
+
+	if order.TotalPrice != 0 && order.TotalPrice != breakdown.Total {
+		log.Printf("Ignoring the client total %.2f, the order costs %s\n", order.TotalPrice.Float(), total)
+	}
+	order.Pricing = breakdown
+	order.TotalPrice = breakdown.Total
+	return nil
+}
+
+// unitPrice takes the price the book has in the currency, or converts its base price at the rate of the day.
+func (e *PricingEngine) unitPrice(book Book, currency string, at time.Time) (Money, error) {
+	if price, ok := book.ListPrice(currency); ok {
+		return Money{Amount: price.Amount, Currency: currency}, nil
+	}
+	if currency == e.Rates.BaseCurrency() {
+		return book.Price.In(currency), nil
+	}
+	price, err := e.Rates.FromBase(book.Price.Float(), currency, at)
+	if err != nil {
+		return Money{}, errors.New("Book " + strconv.Itoa(book.ID) + " has no " + currency + " price: " + err.Error())
+	}
+	return NewMoney(price, currency), nil
+}
+
+// RoundPrice rounds to the cent, half away from zero.
+func RoundPrice(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// RoundAmount rounds to the minor unit of the currency.
+func RoundAmount(amount float64, currency string) float64 {
+	return NewMoney(amount, currency).Float()
+}
+
+// ----------------------------------------------Definition of the default policies--------------------------------
+type FlatRateTax struct {
+	Rate float64
+}
+
+func (t FlatRateTax) Tax(order Order, discount Amount) ([]TaxLine, error) {
+	if t.Rate == 0 {
+		return nil, nil
+	}
+	taxable := -discount
+	for _, item := range order.Items {
+		taxable += item.LineTotal
+	}
+	return []TaxLine{{Jurisdiction: "default", Rate: t.Rate, Taxable: taxable.Float(), Amount: taxable.Float() * t.Rate}}, nil
+}
+
+// FlatShipping charges Fee per order, nothing once the subtotal reaches FreeFrom (when it is set). Both
+// are in the base currency of Rates.
+type FlatShipping struct {
+	Fee      float64
+	FreeFrom float64
+	Rates    *ExchangeRates
+}
+
+func (s FlatShipping) Shipping(order *Order, subtotal Amount) (Amount, error) {
+	if len(order.Items) == 0 || s.Fee == 0 {
+		return 0, nil
+	}
+	if s.FreeFrom > 0 {
+		freeFrom, err := s.Rates.FromBase(s.FreeFrom, order.Currency, order.CreatedAt)
+		if err != nil {
+			return 0, err
+		}
+		if subtotal >= NewAmount(freeFrom) {
+			return 0, nil
+		}
+	}
+	fee, err := s.Rates.FromBase(s.Fee, order.Currency, order.CreatedAt)
+	return NewAmount(fee), err
+}
@@ -0,0 +1,68 @@
+package pricing
+
+import (
+	. "FinalProject/models"
+	"testing"
+)
+
+type fixedDiscount float64
+
+func (d fixedDiscount) Discount(order *Order, subtotal Amount) (Amount, error) {
+	return NewAmount(float64(d)), nil
+}
+
+func TestPriceOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		engine PricingEngine
+		items  []OrderItem
+		want   PriceBreakdown
+	}{
+		{
+			name:   "lines priced from the books",
+			engine: PricingEngine{},
+			items:  []OrderItem{{Book: &Book{Price: NewAmount(10.5)}, Quantity: 2}, {Book: &Book{Price: NewAmount(0.1)}, Quantity: 3}},
+			want:   PriceBreakdown{Subtotal: NewAmount(21.3), Total: NewAmount(21.3)},
+		},
+		{
+			name:   "discount, tax on the discounted amount and shipping",
+			engine: PricingEngine{Discounts: []DiscountPolicy{fixedDiscount(5)}, Tax: FlatRateTax{Rate: 0.1}, Shipping: FlatShipping{Fee: 4}},
+			items:  []OrderItem{{Book: &Book{Price: NewAmount(20)}, Quantity: 1}},
+			want:   PriceBreakdown{Subtotal: NewAmount(20), Discount: NewAmount(5), Tax: NewAmount(1.5), Shipping: NewAmount(4), Total: NewAmount(20.5)},
+		},
+		{
+			name:   "discount capped at the subtotal",
+			engine: PricingEngine{Discounts: []DiscountPolicy{fixedDiscount(8), fixedDiscount(8)}},
+			items:  []OrderItem{{Book: &Book{Price: NewAmount(12)}, Quantity: 1}},
+			want:   PriceBreakdown{Subtotal: NewAmount(12), Discount: NewAmount(12), Total: NewAmount(0)},
+		},
+		{
+			name:   "free shipping from a subtotal",
+			engine: PricingEngine{Shipping: FlatShipping{Fee: 4, FreeFrom: 50}},
+			items:  []OrderItem{{Book: &Book{Price: NewAmount(25)}, Quantity: 2}},
+			want:   PriceBreakdown{Subtotal: NewAmount(50), Total: NewAmount(50)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the client total is always replaced
+			order := Order{Items: tt.items, TotalPrice: NewAmount(1)}
+			if err := tt.engine.PriceOrder(&order); err != nil {
+				t.Fatal(err)
+			}
+			if order.Pricing != tt.want {
+				t.Fatalf("breakdown %+v, want %+v", order.Pricing, tt.want)
+			}
+			if order.TotalPrice != tt.want.Total {
+				t.Fatalf("total %v, want %v", order.TotalPrice, tt.want.Total)
+			}
+		})
+	}
+}
+
+func TestPriceOrderRefusesNegativePrices(t *testing.T) {
+	order := Order{Items: []OrderItem{{Book: &Book{ID: 1, Price: NewAmount(-1)}, Quantity: 1}}}
+	if err := NewPricingEngine().PriceOrder(&order); err == nil {
+		t.Fatal("a negative price was accepted")
+	}
+}
@@ -0,0 +1,207 @@
+package pricing
+
+import (
+	. "FinalProject/models"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------Definition of the promotion discount--------------------------------
+// PromotionDiscount prices the coupons named by an order together with the automatic promotions it
+// is eligible for. A coupon that can't be used fails the order, an automatic promotion that doesn't
+// apply is just skipped. Stackable promotions add up, a non-stackable one is used alone, and the
+// order gets whichever of the two gives the bigger discount. The amounts of the promotions are in the
+// base currency of Rates and converted to the currency of the order.
+type PromotionDiscount struct {
+	Promotions []Promotion
+	Usage      PromotionUsage
+	At         time.Time
+	Rates      *ExchangeRates
+}
+
+// PromotionUsage counts the orders that already used each promotion, in total and for the customer of
+// the order being priced.
+type PromotionUsage struct {
+	Total      map[int]int
+	ByCustomer map[int]int
+}
+
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (d PromotionDiscount) Discount(order *Order, subtotal Amount) (Amount, error) {
+	var codes []string
+	seen := make(map[string]bool)
+	for _, code := range order.CouponCodes {
+		code = NormalizeCouponCode(code)
+		if code != "" && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	order.CouponCodes = codes
+
+	var candidates []AppliedPromotion
+	stackable := make(map[int]bool)
+	for _, code := range codes {
+		promotion, ok := d.findCode(code)
+		if !ok {
+			return 0, errors.New("Coupon code " + code + " doesn't exist")
+		}
+		discount, err := d.promotionDiscount(promotion, *order)
+		if err != nil {
+			return 0, errors.New("Coupon code " + code + " can't be used: " + err.Error())
+		}
+		candidates = append(candidates, AppliedPromotion{PromotionID: promotion.ID, Code: promotion.Code, Name: promotion.Name, Discount: discount})
+		stackable[promotion.ID] = promotion.Stackable
+	}
+	for _, promotion := range d.Promotions {
+		if promotion.Code != "" {
+			continue
+		}
+		discount, err := d.promotionDiscount(promotion, *order)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, AppliedPromotion{PromotionID: promotion.ID, Name: promotion.Name, Discount: discount})
+		stackable[promotion.ID] = promotion.Stackable
+	}
+
+	var stacked []AppliedPromotion
+	var stackedTotal Amount
+	var best *AppliedPromotion
+	for i, candidate := range candidates {
+		if stackable[candidate.PromotionID] {
+			stacked = append(stacked, candidate)
+			stackedTotal += candidate.Discount
+		} else if best == nil || candidate.Discount > best.Discount {
+			best = &candidates[i]
+		}
+	}
+	applied := stacked
+	if best != nil && best.Discount > stackedTotal {
+		applied = []AppliedPromotion{*best}
+	}
+	if len(applied) < len(candidates) {
+		log.Printf("Only %d of the %d promotions of the order can be combined\n", len(applied), len(candidates))
+	}
+	sort.Slice(applied, func(i, j int) bool { return applied[i].PromotionID < applied[j].PromotionID })
+
+	var total Amount
+	for _, promotion := range applied {
+		total += promotion.Discount
+	}
+	order.Promotions = applied
+	return total, nil
+}
+
+func (d PromotionDiscount) findCode(code string) (Promotion, bool) {
+	for _, promotion := range d.Promotions {
+		if promotion.Code != "" && NormalizeCouponCode(promotion.Code) == code {
+			return promotion, true
+		}
+	}
+	return Promotion{}, false
+}
+
+// promotionDiscount checks every condition of the promotion and returns the discount it gives the order.
+func (d PromotionDiscount) promotionDiscount(promotion Promotion, order Order) (Amount, error) {
+	if !promotion.ActiveAt(d.At) {
+		return 0, errors.New("the promotion is not valid at this time")
+	}
+	if promotion.MaxUses > 0 && d.Usage.Total[promotion.ID] >= promotion.MaxUses {
+		return 0, errors.New("the promotion has been used the maximum number of times")
+	}
+	if promotion.MaxUsesPerCustomer > 0 && d.Usage.ByCustomer[promotion.ID] >= promotion.MaxUsesPerCustomer {
+		return 0, errors.New("the customer already used it the maximum number of times (" + strconv.Itoa(promotion.MaxUsesPerCustomer) + ")")
+	}
+
+	var eligible []OrderItem
+	var eligibleTotal Amount
+	for _, item := range order.Items {
+		if promotionCovers(promotion, *item.Book) {
+			eligible = append(eligible, item)
+			eligibleTotal += item.LineTotal
+		}
+	}
+	minOrderValue, err := d.Rates.FromBase(promotion.MinOrderValue, order.Currency, d.At)
+	if err != nil {
+		return 0, err
+	}
+	if eligibleTotal < NewAmount(minOrderValue) {
+		return 0, errors.New("the promotion needs at least " + NewMoney(minOrderValue, order.Currency).String() + " of eligible books")
+	}
+
+	discount := 0.0
+	switch promotion.Kind {
+	case PromotionPercentage:
+		discount = eligibleTotal.Float() * promotion.Percent / 100
+	case PromotionFixed:
+		discount, err = d.Rates.FromBase(promotion.Amount, order.Currency, d.At)
+		if err != nil {
+			return 0, err
+		}
+		discount = min(discount, eligibleTotal.Float())
+	case PromotionBuyXGetY:
+		discount = freeUnitsValue(eligible, promotion.BuyQuantity, promotion.FreeQuantity).Float()
+	default:
+		return 0, errors.New("unknown promotion kind " + string(promotion.Kind))
+	}
+	amount := AmountOf(NewMoney(discount, order.Currency))
+	if amount <= 0 {
+		return 0, errors.New("the promotion doesn't apply to the books of this order")
+	}
+	return amount, nil
+}
+
+func promotionCovers(promotion Promotion, book Book) bool {
+	if len(promotion.Genres) > 0 {
+		found := false
+		for _, genre := range promotion.Genres {
+			for _, bookGenre := range book.Genres {
+				if strings.EqualFold(strings.TrimSpace(genre), strings.TrimSpace(bookGenre)) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(promotion.AuthorIDs) > 0 {
+		for _, id := range promotion.AuthorIDs {
+			if book.HasContributor(id) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// freeUnitsValue lines the books up from the most to the least expensive and, in every group of
+// buy+free books, gives the last free ones away.
+func freeUnitsValue(items []OrderItem, buy int, free int) Amount {
+	if buy <= 0 || free <= 0 {
+		return 0
+	}
+	var prices []Amount
+	for _, item := range items {
+		for i := 0; i < item.Quantity; i++ {
+			prices = append(prices, item.UnitPrice)
+		}
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] > prices[j] })
+	var value Amount
+	for i, price := range prices {
+		if i%(buy+free) >= buy {
+			value += price
+		}
+	}
+	return value
+}
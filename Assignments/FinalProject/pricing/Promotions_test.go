@@ -0,0 +1,108 @@
+package pricing
+
+import (
+	. "FinalProject/models"
+	"testing"
+	"time"
+)
+
+func TestPromotionDiscount(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	items := []OrderItem{
+		{Book: &Book{ID: 1, Contributors: []Contributor{{AuthorID: 7, Role: RoleAuthor, Position: 1}}, Genres: []string{"Fantasy"}}, Quantity: 2, UnitPrice: NewAmount(10), LineTotal: NewAmount(20)},
+		{Book: &Book{ID: 2, Contributors: []Contributor{{AuthorID: 8, Role: RoleAuthor, Position: 1}}, Genres: []string{"History"}}, Quantity: 1, UnitPrice: NewAmount(30), LineTotal: NewAmount(30)},
+	}
+	tests := []struct {
+		name       string
+		promotions []Promotion
+		usage      PromotionUsage
+		codes      []string
+		want       float64
+		applied    int
+		wantErr    bool
+	}{
+		{
+			name:       "automatic percentage",
+			promotions: []Promotion{{ID: 1, Kind: PromotionPercentage, Percent: 10}},
+			want:       5, applied: 1,
+		},
+		{
+			name:       "fixed coupon limited to a genre",
+			promotions: []Promotion{{ID: 1, Code: "FANTASY", Kind: PromotionFixed, Amount: 25, Genres: []string{" fantasy"}}},
+			codes:      []string{" fantasy "},
+			want:       20, applied: 1,
+		},
+		{
+			name:       "buy one get one gives the cheaper book",
+			promotions: []Promotion{{ID: 1, Kind: PromotionBuyXGetY, BuyQuantity: 1, FreeQuantity: 1}},
+			want:       10, applied: 1,
+		},
+		{
+			name: "stackable promotions add up",
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionFixed, Amount: 5, Stackable: true},
+				{ID: 2, Kind: PromotionFixed, Amount: 4, Stackable: true},
+			},
+			want: 9, applied: 2,
+		},
+		{
+			name: "a bigger non-stackable promotion wins alone",
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionFixed, Amount: 5, Stackable: true},
+				{ID: 2, Kind: PromotionFixed, Amount: 4, Stackable: true},
+				{ID: 3, Kind: PromotionPercentage, Percent: 20},
+			},
+			want: 10, applied: 1,
+		},
+		{
+			name:       "automatic promotion below its minimum is skipped",
+			promotions: []Promotion{{ID: 1, Kind: PromotionFixed, Amount: 5, AuthorIDs: []int{7}, MinOrderValue: 25}},
+			want:       0, applied: 0,
+		},
+		{
+			name:       "expired automatic promotion is skipped",
+			promotions: []Promotion{{ID: 1, Kind: PromotionFixed, Amount: 5, EndsAt: &past}},
+			want:       0, applied: 0,
+		},
+		{
+			name:       "unknown coupon",
+			promotions: []Promotion{{ID: 1, Code: "SPRING", Kind: PromotionFixed, Amount: 5}},
+			codes:      []string{"WINTER"},
+			wantErr:    true,
+		},
+		{
+			name:       "coupon used up",
+			promotions: []Promotion{{ID: 1, Code: "ONCE", Kind: PromotionFixed, Amount: 5, MaxUses: 1}},
+			usage:      PromotionUsage{Total: map[int]int{1: 1}},
+			codes:      []string{"ONCE"},
+			wantErr:    true,
+		},
+		{
+			name:       "coupon used up by the customer",
+			promotions: []Promotion{{ID: 1, Code: "ONCE", Kind: PromotionFixed, Amount: 5, MaxUsesPerCustomer: 1}},
+			usage:      PromotionUsage{Total: map[int]int{1: 1}, ByCustomer: map[int]int{1: 1}},
+			codes:      []string{"ONCE"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := Order{Items: append([]OrderItem(nil), items...), CouponCodes: tt.codes, Currency: "USD"}
+			discount := PromotionDiscount{Promotions: tt.promotions, Usage: tt.usage, At: now}
+			got, err := discount.Discount(&order, NewAmount(50))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("the coupon was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != NewAmount(tt.want) || len(order.Promotions) != tt.applied {
+				t.Fatalf("discount %v with %d promotions, want %v with %d", got, len(order.Promotions), tt.want, tt.applied)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package pricing
+
+import (
+	. "FinalProject/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------Definition of the shipping methods--------------------------------
+// ShippingTable prices an order with the methods of the carriers. A method has rate rules by destination
+// country, weight and order value, the first matching rule gives the price, and the method can be free
+// from some order value. The order names its method, or gets the cheapest one delivering to its address.
+// Prices and order values are in the base currency of Rates.
+type ShippingRateRule struct {
+	// Countries the rule delivers to, every country when empty.
+	Countries []string `json:"countries,omitempty"`
+	// Weights in kilograms and order values, a zero maximum is open.
+	MinWeight     float64 `json:"min_weight,omitempty"`
+	MaxWeight     float64 `json:"max_weight,omitempty"`
+	MinOrderValue float64 `json:"min_order_value,omitempty"`
+	MaxOrderValue float64 `json:"max_order_value,omitempty"`
+	Price         float64 `json:"price"`
+	PerKg         float64 `json:"per_kg,omitempty"`
+}
+
+type ShippingMethod struct {
+	Code     string             `json:"code"`
+	Carrier  string             `json:"carrier"`
+	Name     string             `json:"name"`
+	FreeFrom float64            `json:"free_from,omitempty"`
+	Rules    []ShippingRateRule `json:"rules"`
+}
+
+type ShippingTable struct {
+	Methods []ShippingMethod `json:"methods"`
+	Rates   *ExchangeRates   `json:"-"`
+}
+
+func LoadShippingTable(path string, rates *ExchangeRates) (*ShippingTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var table ShippingTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, errors.New("Invalid shipping methods " + path + ": " + err.Error())
+	}
+	seen := make(map[string]bool)
+	for i, method := range table.Methods {
+		if method.Code == "" || method.Carrier == "" {
+			return nil, errors.New("Shipping method " + strconv.Itoa(i) + " of " + path + " needs a code and a carrier")
+		}
+		if seen[method.Code] {
+			return nil, errors.New("Shipping method " + method.Code + " is defined twice in " + path)
+		}
+		seen[method.Code] = true
+		for _, rule := range method.Rules {
+			if rule.Price < 0 || rule.PerKg < 0 {
+				return nil, errors.New("Shipping method " + method.Code + " of " + path + " has a negative price")
+			}
+		}
+	}
+	table.Rates = rates
+	return &table, nil
+}
+
+func (t *ShippingTable) Shipping(order *Order, subtotal Amount) (Amount, error) {
+	if len(order.Items) == 0 {
+		return 0, nil
+	}
+	weight := OrderWeight(*order)
+	value, err := t.toBase(subtotal, *order)
+	if err != nil {
+		return 0, err
+	}
+	country := order.ShippingAddress.Country
+
+	if order.ShippingMethod != "" {
+		method, ok := t.Method(order.ShippingMethod)
+		if !ok {
+			return 0, errors.New("Unknown shipping method " + order.ShippingMethod)
+		}
+		order.ShippingMethod = method.Code
+		price, ok := method.Quote(country, weight, value)
+		if !ok {
+			return 0, errors.New("Shipping method " + method.Code + " doesn't deliver this order to " + country)
+		}
+		return t.fromBase(price, *order)
+	}
+
+	found := false
+	cheapest := 0.0
+	for _, method := range t.Methods {
+		if price, ok := method.Quote(country, weight, value); ok && (!found || price < cheapest) {
+			found = true
+			cheapest = price
+			order.ShippingMethod = method.Code
+		}
+	}
+	if !found {
+		return 0, errors.New("No shipping method delivers this order to " + country)
+	}
+	log.Printf("Shipping with %s, the cheapest method to %s\n", order.ShippingMethod, country)
+	return t.fromBase(cheapest, *order)
+}
+
+func (t *ShippingTable) Method(code string) (ShippingMethod, bool) {
+	for _, method := range t.Methods {
+		if strings.EqualFold(method.Code, strings.TrimSpace(code)) {
+			return method, true
+		}
+	}
+	return ShippingMethod{}, false
+}
+
+// toBase gives the order value in the base currency, the rules are written in it.
+func (t *ShippingTable) toBase(amount Amount, order Order) (float64, error) {
+	converted, err := t.Rates.Convert(amount.In(order.Currency), t.Rates.BaseCurrency(), order.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return converted.Float(), nil
+}
+
+// fromBase gives a price of the rules in the currency of the order.
+func (t *ShippingTable) fromBase(price float64, order Order) (Amount, error) {
+	converted, err := t.Rates.FromBase(price, order.Currency, order.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return NewAmount(converted), nil
+}
+
+// Quote returns the price of the method for a parcel, in the base currency, and whether the method takes it.
+func (m ShippingMethod) Quote(country string, weight float64, orderValue float64) (float64, bool) {
+	for _, rule := range m.Rules {
+		if !rule.matches(country, weight, orderValue) {
+			continue
+		}
+		if m.FreeFrom > 0 && orderValue >= m.FreeFrom {
+			return 0, true
+		}
+		return rule.Price + rule.PerKg*weight, true
+	}
+	return 0, false
+}
+
+func (r ShippingRateRule) matches(country string, weight float64, orderValue float64) bool {
+	if len(r.Countries) > 0 {
+		found := false
+		for _, candidate := range r.Countries {
+			if strings.EqualFold(strings.TrimSpace(candidate), strings.TrimSpace(country)) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if weight < r.MinWeight || (r.MaxWeight > 0 && weight > r.MaxWeight) {
+		return false
+	}
+	return orderValue >= r.MinOrderValue && (r.MaxOrderValue == 0 || orderValue < r.MaxOrderValue)
+}
+
+// OrderWeight adds up the weight of every book of the order.
+func OrderWeight(order Order) float64 {
+	weight := 0.0
+	for _, item := range order.Items {
+		weight += item.Book.Weight * float64(item.Quantity)
+	}
+	return weight
+}
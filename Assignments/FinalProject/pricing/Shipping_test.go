@@ -0,0 +1,65 @@
+package pricing
+
+import (
+	. "FinalProject/models"
+	"testing"
+)
+
+var testShippingTable = &ShippingTable{Methods: []ShippingMethod{
+	{Code: "standard", Carrier: "Post", FreeFrom: 100, Rules: []ShippingRateRule{
+		{Countries: []string{"US"}, MaxWeight: 2, Price: 5},
+		{Countries: []string{"US"}, MinWeight: 2, Price: 5, PerKg: 2},
+	}},
+	{Code: "express", Carrier: "UPS", Rules: []ShippingRateRule{
+		{Price: 20},
+	}},
+	{Code: "bulk", Carrier: "Freight", Rules: []ShippingRateRule{
+		{MinWeight: 10, Price: 15},
+	}},
+}}
+
+func TestShippingTable(t *testing.T) {
+	tests := []struct {
+		name       string
+		country    string
+		weight     float64
+		quantity   int
+		method     string
+		subtotal   float64
+		want       float64
+		wantMethod string
+		wantErr    bool
+	}{
+		{"cheapest method", "US", 0.5, 2, "", 30, 5, "standard", false},
+		{"heavy parcel priced per kg", "US", 1.5, 2, "", 30, 11, "standard", false},
+		{"free from an order value", "US", 0.5, 2, "", 100, 0, "standard", false},
+		{"only one method delivers abroad", "FR", 0.5, 2, "", 30, 20, "express", false},
+		{"bulk is cheaper for heavy orders", "FR", 5, 2, "", 30, 15, "bulk", false},
+		{"named method", "US", 0.5, 2, "EXPRESS", 30, 20, "express", false},
+		{"named method that doesn't deliver", "FR", 0.5, 1, "standard", 30, 0, "", true},
+		{"unknown method", "US", 0.5, 1, "pigeon", 30, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := Order{
+				ShippingAddress: Address{Country: tt.country},
+				Items:           []OrderItem{{Book: &Book{Weight: tt.weight}, Quantity: tt.quantity}},
+				Currency:        "USD",
+				ShippingMethod:  tt.method,
+			}
+			got, err := testShippingTable.Shipping(&order, NewAmount(tt.subtotal))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("shipped with %s for %v", order.ShippingMethod, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != NewAmount(tt.want) || order.ShippingMethod != tt.wantMethod {
+				t.Fatalf("shipping %v with %s, want %v with %s", got, order.ShippingMethod, tt.want, tt.wantMethod)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package pricing
+
+import (
+	. "FinalProject/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------Definition of the tax table--------------------------------
+// TaxTable taxes an order with the rule of the customer's address. A rule names a country, and can be
+// narrowed to a state and to the postal codes starting with a prefix, the most specific matching rule
+// wins. Within a rule, a genre can have its own rate (reduced rate for books, full rate for ebooks) or be
+// exempt. An inclusive rule means the book prices already contain the tax, it is reported but not added.
+type TaxRule struct {
+	Name         string             `json:"name,omitempty"`
+	Country      string             `json:"country"`
+	State        string             `json:"state,omitempty"`
+	PostalPrefix string             `json:"postal_prefix,omitempty"`
+	Rate         float64            `json:"rate"`
+	GenreRates   map[string]float64 `json:"genre_rates,omitempty"`
+	ExemptGenres []string           `json:"exempt_genres,omitempty"`
+	Inclusive    bool               `json:"inclusive,omitempty"`
+}
+
+type TaxTable struct {
+	Rules []TaxRule `json:"rules"`
+}
+
+func LoadTaxTable(path string) (*TaxTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var table TaxTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, errors.New("Invalid tax table " + path + ": " + err.Error())
+	}
+	for i, rule := range table.Rules {
+		if strings.TrimSpace(rule.Country) == "" {
+			return nil, errors.New("Tax rule " + strconv.Itoa(i) + " of " + path + " has no country")
+		}
+		if rule.Rate < 0 {
+			return nil, errors.New("Tax rule " + strconv.Itoa(i) + " of " + path + " has a negative rate")
+		}
+		for genre, rate := range rule.GenreRates {
+			if rate < 0 {
+				return nil, errors.New("Tax rule " + strconv.Itoa(i) + " of " + path + " has a negative rate for " + genre)
+			}
+		}
+	}
+	return &table, nil
+}
+
+// Match returns the most specific rule for the address: a postal prefix beats a state, which beats a
+// country alone, and a longer prefix beats a shorter one.
+func (t *TaxTable) Match(address Address) (TaxRule, bool) {
+	var best TaxRule
+	bestScore := 0
+	postalCode := strings.ToUpper(strings.ReplaceAll(address.PostalCode, " ", ""))
+	for _, rule := range t.Rules {
+		if !strings.EqualFold(strings.TrimSpace(rule.Country), strings.TrimSpace(address.Country)) {
+			continue
+		}
+		score := 1
+		if rule.State != "" {
+			if !strings.EqualFold(strings.TrimSpace(rule.State), strings.TrimSpace(address.State)) {
+				continue
+			}
+			score += 1
+		}
+		if rule.PostalPrefix != "" {
+			prefix := strings.ToUpper(strings.ReplaceAll(rule.PostalPrefix, " ", ""))
+			if !strings.HasPrefix(postalCode, prefix) {
+				continue
+			}
+			score += 2 + len(prefix)
+		}
+		if score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+	return best, bestScore > 0
+}
+
+func (t *TaxTable) Tax(order Order, discount Amount) ([]TaxLine, error) {
+	rule, ok := t.Match(order.ShippingAddress)
+	if !ok {
+		log.Printf("No tax rule for the address of customer %d (%s), the order is not taxed\n", order.CustomerID, order.ShippingAddress.Country)
+		return nil, nil
+	}
+
+	var subtotal Amount
+	for _, item := range order.Items {
+		subtotal += item.LineTotal
+	}
+	var lines []TaxLine
+	for _, item := range order.Items {
+		rate := rule.rateFor(*item.Book)
+		if rate == 0 || item.LineTotal == 0 {
+			continue
+		}
+		// the order discount lowers every line in proportion to its price
+		taxable := item.LineTotal.Float()
+		if subtotal > 0 {
+			taxable -= discount.Float() * item.LineTotal.Float() / subtotal.Float()
+		}
+		found := false
+		for i := range lines {
+			if lines[i].Rate == rate {
+				lines[i].Taxable += taxable
+				found = true
+			}
+		}
+		if !found {
+			lines = append(lines, TaxLine{Jurisdiction: rule.jurisdiction(), Rate: rate, Taxable: taxable, Inclusive: rule.Inclusive})
+		}
+	}
+	for i := range lines {
+		if lines[i].Inclusive {
+			lines[i].Amount = lines[i].Taxable - lines[i].Taxable/(1+lines[i].Rate)
+		} else {
+			lines[i].Amount = lines[i].Taxable * lines[i].Rate
+		}
+	}
+	return lines, nil
+}
+
+// rateFor gives an exempt genre no tax, and a book with several taxed genres the lowest of their rates.
+func (r TaxRule) rateFor(book Book) float64 {
+	rate := r.Rate
+	reduced := false
+	for _, genre := range book.Genres {
+		genre = strings.TrimSpace(genre)
+		for _, exempt := range r.ExemptGenres {
+			if strings.EqualFold(strings.TrimSpace(exempt), genre) {
+				return 0
+			}
+		}
+		for name, genreRate := range r.GenreRates {
+			if strings.EqualFold(strings.TrimSpace(name), genre) && (!reduced || genreRate < rate) {
+				rate = genreRate
+				reduced = true
+			}
+		}
+	}
+	return rate
+}
+
+func (r TaxRule) jurisdiction() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	jurisdiction := strings.ToUpper(strings.TrimSpace(r.Country))
+	if r.State != "" {
+		jurisdiction += "-" + strings.ToUpper(strings.TrimSpace(r.State))
+	}
+	if r.PostalPrefix != "" {
+		jurisdiction += "-" + strings.ToUpper(strings.TrimSpace(r.PostalPrefix))
+	}
+	return jurisdiction
+}
@@ -0,0 +1,113 @@
+package pricing
+
+import (
+	. "FinalProject/models"
+	"math"
+	"testing"
+)
+
+var testTaxTable = &TaxTable{Rules: []TaxRule{
+	{Name: "FR VAT", Country: "FR", Rate: 0.2, GenreRates: map[string]float64{"Fiction": 0.055}, Inclusive: true},
+	{Name: "US-CA", Country: "US", State: "CA", Rate: 0.0725, ExemptGenres: []string{"Textbook"}},
+	{Name: "US-CA-900", Country: "US", State: "CA", PostalPrefix: "900", Rate: 0.095},
+	{Name: "US-CA-9001", Country: "US", State: "CA", PostalPrefix: "9001", Rate: 0.1},
+}}
+
+func TestTaxTableMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		address Address
+		want    string
+	}{
+		{"country only", Address{Country: "fr", PostalCode: "75001"}, "FR VAT"},
+		{"state", Address{Country: "US", State: "ca", PostalCode: "94105"}, "US-CA"},
+		{"postal prefix beats the state", Address{Country: "US", State: "CA", PostalCode: "90005"}, "US-CA-900"},
+		{"longer prefix wins", Address{Country: "US", State: "CA", PostalCode: "90012"}, "US-CA-9001"},
+		{"no rule", Address{Country: "US", State: "NY"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := testTaxTable.Match(tt.address)
+			if ok != (tt.want != "") || rule.Name != tt.want {
+				t.Fatalf("matched %q (%v), want %q", rule.Name, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaxTableLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  Address
+		items    []OrderItem
+		discount float64
+		want     []TaxLine
+	}{
+		{
+			name:    "exempt genre isn't taxed",
+			address: Address{Country: "US", State: "CA", PostalCode: "94105"},
+			items: []OrderItem{
+				{Book: &Book{Genres: []string{"Fiction"}}, LineTotal: NewAmount(100)},
+				{Book: &Book{Genres: []string{"Textbook"}}, LineTotal: NewAmount(50)},
+			},
+			want: []TaxLine{{Jurisdiction: "US-CA", Rate: 0.0725, Taxable: 100, Amount: 7.25}},
+		},
+		{
+			name:     "discount spread over the lines",
+			address:  Address{Country: "US", State: "CA", PostalCode: "94105"},
+			items:    []OrderItem{{Book: &Book{}, LineTotal: NewAmount(60)}, {Book: &Book{}, LineTotal: NewAmount(40)}},
+			discount: 10,
+			want:     []TaxLine{{Jurisdiction: "US-CA", Rate: 0.0725, Taxable: 90, Amount: 6.525}},
+		},
+		{
+			name:    "inclusive reduced rate",
+			address: Address{Country: "FR"},
+			items: []OrderItem{
+				{Book: &Book{Genres: []string{"Fiction"}}, LineTotal: NewAmount(105.5)},
+				{Book: &Book{Genres: []string{"Comics"}}, LineTotal: NewAmount(120)},
+			},
+			want: []TaxLine{
+				{Jurisdiction: "FR VAT", Rate: 0.055, Taxable: 105.5, Amount: 5.5, Inclusive: true},
+				{Jurisdiction: "FR VAT", Rate: 0.2, Taxable: 120, Amount: 20, Inclusive: true},
+			},
+		},
+		{
+			name:    "address without a rule",
+			address: Address{Country: "DE"},
+			items:   []OrderItem{{Book: &Book{}, LineTotal: NewAmount(10)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := Order{ShippingAddress: tt.address, Items: tt.items}
+			lines, err := testTaxTable.Tax(order, NewAmount(tt.discount))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != len(tt.want) {
+				t.Fatalf("tax lines %+v, want %+v", lines, tt.want)
+			}
+			for i := range lines {
+				got, want := lines[i], tt.want[i]
+				if got.Jurisdiction != want.Jurisdiction || got.Rate != want.Rate || got.Inclusive != want.Inclusive ||
+					math.Abs(got.Taxable-want.Taxable) > 1e-9 || math.Abs(got.Amount-want.Amount) > 1e-9 {
+					t.Fatalf("tax line %d is %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPriceOrderInclusiveTaxIsNotAdded(t *testing.T) {
+	engine := PricingEngine{Tax: testTaxTable}
+	order := Order{
+		ShippingAddress: Address{Country: "FR"},
+		Items:           []OrderItem{{Book: &Book{Price: NewAmount(12), Genres: []string{"Comics"}}, Quantity: 1}},
+	}
+	if err := engine.PriceOrder(&order); err != nil {
+		t.Fatal(err)
+	}
+	if order.Pricing.Tax != 0 || order.Pricing.TaxIncluded != NewAmount(2) || order.TotalPrice != NewAmount(12) {
+		t.Fatalf("breakdown %+v", order.Pricing)
+	}
+}
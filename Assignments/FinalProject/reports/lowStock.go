@@ -0,0 +1,72 @@
+package reports
+
+import (
+	. "FinalProject/models"
+	. "FinalProject/stores"
+	"context"
+	"log"
+	"time"
+)
+
+// SalesVelocity returns the copies sold per day of every book over the window, from the orders placed
+// in it. The cancelled and refunded orders don't count, the pending ones do since they are demand.
+func SalesVelocity(ctx context.Context, orderStore OrderStore, window time.Duration) (map[int]float64, error) {
+	endTime := time.Now()
+	orders, err := orderStore.FetchOrderWithinTimeLimit(ctx, endTime.Add(-window), endTime)
+	if err != nil {
+		log.Printf("Failed to fetch the orders of the sales velocity: %v\n", err)
+		return nil, err
+	}
+	days := window.Hours() / 24
+	velocity := make(map[int]float64)
+	for _, order := range orders {
+		if order.Status == OrderCancelled || order.Status == OrderRefunded {
+			continue
+		}
+		for _, item := range order.Items {
+			velocity[item.BookID] += float64(item.Quantity) / days
+		}
+	}
+	return velocity, nil
+}
+
+// CheckLowStock updates the stock alerts with the sales velocity of the books over the window.
+func CheckLowStock(ctx context.Context, orderStore OrderStore, alertStore AlertStore, window time.Duration) error {
+	velocity, err := SalesVelocity(ctx, orderStore, window)
+	if err != nil {
+		return err
+	}
+	opened, err := alertStore.CheckStockLevels(ctx, velocity)
+	if err != nil {
+		log.Printf("Failed to check the stock levels: %v\n", err)
+		return err
+	}
+	if opened > 0 {
+		log.Printf("%d books are low on stock\n", opened)
+	}
+	return nil
+}
+
+// StartLowStockBackgroundJob checks the stock levels when it starts, then at every interval.
+func StartLowStockBackgroundJob(ctx context.Context, orderStore OrderStore, alertStore AlertStore, window time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Starting periodic low stock background job...")
+	if err := CheckLowStock(ctx, orderStore, alertStore, window); err != nil {
+		log.Printf("Error checking the stock levels: %v\n", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping low stock background job.")
+			return
+		case <-ticker.C:
+			log.Println("Triggering stock level check...")
+			if err := CheckLowStock(ctx, orderStore, alertStore, window); err != nil {
+				log.Printf("Error checking the stock levels: %v\n", err)
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package reports
 
 import (
 	. "FinalProject/models"
+	. "FinalProject/pricing"
 	. "FinalProject/stores"
 	"context"
 	"encoding/json"
@@ -12,43 +13,74 @@ import (
 	"time"
 )
 
-func GenerateSalesReport(ctx context.Context, orderStore *InMemoryOrderStore, bookStore *InMemoryBookStore, interval time.Duration) error {
+// GenerateSalesReport sums the paid orders in the base currency of rates, each one converted at the rate of the
+// day it was placed. The pending orders and the ones cancelled before their payment were never sales. The books sold cost their average cost when the order was placed, and what was refunded
+// for the orders, returns or refunds, is taken off the net revenue.
+func GenerateSalesReport(ctx context.Context, orderStore OrderStore, bookStore BookStore, rates *ExchangeRates, interval time.Duration) error {
 	log.Println("Starting GenerateSalesReport function...")
 
 	startTime := time.Now().Add(-interval).Truncate(0)
 	endTime := time.Now().Truncate(0)
 	log.Printf("Generating sales report for orders between %s and %s\n", startTime, endTime)
 
-	var orders []Order
-	orderStore.Mu.RLock()
-	for _, order := range orderStore.Orders {
-		log.Printf("Checking order ID %d with CreatedAt %s\n", order.ID, order.CreatedAt)
-		if !order.CreatedAt.Before(startTime) && !order.CreatedAt.After(endTime) {
-			log.Printf("Order ID %d is within the time range.\n", order.ID)
-			orders = append(orders, order)
-		} else {
-			log.Printf("Order ID %d is outside the time range.\n", order.ID)
-		}
+	orders, err := orderStore.FetchOrderWithinTimeLimit(ctx, startTime, endTime)
+	if err != nil {
+		log.Printf("Failed to fetch the orders of the report: %v\n", err)
+		return err
 	}
-	orderStore.Mu.RUnlock()
 
 	if len(orders) == 0 {
 		log.Println("No orders found for the specified time range.")
 		return nil
 	}
 
-	var totalRevenue float64
+	base := rates.BaseCurrency()
+	totalRevenue := Money{Currency: base}
+	totalTax := Money{Currency: base}
+	totalRefunds := Money{Currency: base}
+	totalCost := Money{Currency: base}
 	var totalOrders int
 	bookSalesMap := make(map[int]int)
 
 	for _, order := range orders {
-		totalRevenue += order.TotalPrice
+		if !order.WasPaid() {
+			continue
+		}
+		currency := order.Currency
+		if currency == "" {
+			currency = base
+		}
+		revenue, err := rates.Convert(order.TotalPrice.In(currency), base, order.CreatedAt)
+		if err != nil {
+			log.Printf("Failed to convert order %d to %s: %v\n", order.ID, base, err)
+			return err
+		}
+		tax, err := rates.Convert((order.Pricing.Tax + order.Pricing.TaxIncluded).In(currency), base, order.CreatedAt)
+		if err != nil {
+			log.Printf("Failed to convert the tax of order %d to %s: %v\n", order.ID, base, err)
+			return err
+		}
+		refunds, err := rates.Convert(order.RefundedTotal().In(currency), base, order.CreatedAt)
+		if err != nil {
+			log.Printf("Failed to convert the refunds of order %d to %s: %v\n", order.ID, base, err)
+			return err
+		}
+		totalRevenue.Amount += revenue.Amount
+		totalRefunds.Amount += refunds.Amount
+		totalTax.Amount += tax.Amount
 		totalOrders++
 		for _, item := range order.Items {
-			bookSalesMap[item.Book.ID] += item.Quantity
+			bookSalesMap[item.BookID] += item.Quantity
+			// the cost is in the base currency, each copy rounded to the cent like the prices
+			totalCost.Amount += item.UnitCost.In(base).Amount * int64(item.Quantity)
 		}
 	}
 
+	if totalOrders == 0 {
+		log.Println("No paid orders found for the specified time range.")
+		return nil
+	}
+
 	var topSellingBooks []BookSales
 	var maxQuantity int
 	for _, quantity := range bookSalesMap {
@@ -57,10 +89,9 @@ func GenerateSalesReport(ctx context.Context, orderStore *InMemoryOrderStore, bo
 		}
 	}
 
-	bookStore.Mu.RLock()
 	for bookID, quantity := range bookSalesMap {
 		if quantity == maxQuantity {
-			if book, exists := bookStore.Books[bookID]; exists {
+			if book, err := bookStore.GetBook(ctx, bookID); err == nil {
 				topSellingBooks = append(topSellingBooks, BookSales{
 					Book:     book,
 					Quantity: quantity,
@@ -68,11 +99,16 @@ func GenerateSalesReport(ctx context.Context, orderStore *InMemoryOrderStore, bo
 			}
 		}
 	}
-	bookStore.Mu.RUnlock()
 
 	report := SalesReport{
 		Timestamp:       time.Now(),
-		TotalRevenue:    totalRevenue,
+		TotalRevenue:    totalRevenue.Float(),
+		TotalRefunds:    totalRefunds.Float(),
+		NetRevenue:      Money{Amount: totalRevenue.Amount - totalRefunds.Amount, Currency: base}.Float(),
+		TotalTax:        totalTax.Float(),
+		TotalCost:       totalCost.Float(),
+		GrossMargin:     Money{Amount: totalRevenue.Amount - totalTax.Amount - totalCost.Amount, Currency: base}.Float(),
+		Currency:        base,
 		TotalOrders:     totalOrders,
 		TopSellingBooks: topSellingBooks,
 	}
@@ -103,7 +139,7 @@ func GenerateSalesReport(ctx context.Context, orderStore *InMemoryOrderStore, bo
 	return nil
 }
 
-func StartSalesReportBackgroundJob(ctx context.Context, orderStore *InMemoryOrderStore, bookStore *InMemoryBookStore, reportGenerationInterval time.Duration) {
+func StartSalesReportBackgroundJob(ctx context.Context, orderStore OrderStore, bookStore BookStore, rates *ExchangeRates, reportGenerationInterval time.Duration) {
 	ticker := time.NewTicker(1 * time.Minute) //24*time.Hour
 	defer ticker.Stop()
 
@@ -116,7 +152,7 @@ func StartSalesReportBackgroundJob(ctx context.Context, orderStore *InMemoryOrde
 			return
 		case <-ticker.C:
 			log.Println("Triggering sales report generation...")
-			err := GenerateSalesReport(ctx, orderStore, bookStore, reportGenerationInterval)
+			err := GenerateSalesReport(ctx, orderStore, bookStore, rates, reportGenerationInterval)
 			if err != nil {
 				log.Printf("Error generating sales report: %v\n", err)
 			}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	. "FinalProject/controllers"
+	. "FinalProject/stores"
+	"net/http"
+	"strings"
+)
+
+func RegisterAdminRoutes(mux *http.ServeMux, snapshots *SnapshotManager, stores *Stores) {
+	mux.HandleFunc("/admin/snapshots", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			ListSnapshotsHandler(w, r, snapshots)
+		case "POST":
+			CreateSnapshotHandler(w, r, snapshots, stores)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/admin/snapshots/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/restore") {
+			RestoreSnapshotHandler(w, r, snapshots, stores)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	. "FinalProject/controllers"
+	. "FinalProject/stores"
+	"net/http"
+)
+
+func RegisterAlertRoutes(mux *http.ServeMux, alertStore AlertStore) {
+	mux.HandleFunc("/alerts/stock", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			ListStockAlertsHandler(w, r, alertStore)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
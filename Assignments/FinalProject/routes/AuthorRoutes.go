@@ -6,11 +6,13 @@ import (
 	"net/http"
 )
 
-func RegisterAuthorRoutes(mux *http.ServeMux, authorStore *InMemoryAuthorStore, bookStore *InMemoryBookStore) {
+func RegisterAuthorRoutes(mux *http.ServeMux, authorStore AuthorStore, idempotencyStore IdempotencyStore) {
 	mux.HandleFunc("/authors", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "POST":
-			CreateAuthorHandler(w, r, authorStore)
+			WithIdempotencyKey(w, r, idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
+				CreateAuthorHandler(w, r, authorStore)
+			})
 		case "GET":
 			ListAllHandler(w, r, authorStore)
 		default:
@@ -24,7 +26,7 @@ func RegisterAuthorRoutes(mux *http.ServeMux, authorStore *InMemoryAuthorStore,
 		case "PUT":
 			UpdateAuthorHandler(w, r, authorStore)
 		case "DELETE":
-			DeleteAuthorHandler(w, r, authorStore, bookStore)
+			DeleteAuthorHandler(w, r, authorStore)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
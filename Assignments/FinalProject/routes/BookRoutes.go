@@ -4,13 +4,16 @@ import (
 	. "FinalProject/controllers"
 	. "FinalProject/stores"
 	"net/http"
+	"strings"
 )
 
-func RegisterBookRoutes(mux *http.ServeMux, bookStore *InMemoryBookStore, authorStore *InMemoryAuthorStore) {
+func RegisterBookRoutes(mux *http.ServeMux, bookStore BookStore, inventoryStore InventoryStore, idempotencyStore IdempotencyStore) {
 	mux.HandleFunc("/books", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "POST":
-			CreateBookHandler(w, r, bookStore, authorStore)
+			WithIdempotencyKey(w, r, idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
+				CreateBookHandler(w, r, bookStore)
+			})
 		case "GET":
 			SearchBookHandler(w, r, bookStore)
 		default:
@@ -18,11 +21,15 @@ func RegisterBookRoutes(mux *http.ServeMux, bookStore *InMemoryBookStore, author
 		}
 	})
 	mux.HandleFunc("/books/", func(w http.ResponseWriter, r *http.Request) {
+		if parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/"); len(parts) == 4 && parts[3] == "stock-movements" {
+			serveStockMovements(w, r, inventoryStore)
+			return
+		}
 		switch r.Method {
 		case "GET":
-			GetBookHandler(w, r, bookStore, authorStore)
+			GetBookHandler(w, r, bookStore)
 		case "PUT":
-			UpdateBookHandler(w, r, bookStore, authorStore)
+			UpdateBookHandler(w, r, bookStore)
 		case "DELETE":
 			DeleteBookHandler(w, r, bookStore)
 		default:
@@ -0,0 +1,63 @@
+package routes
+
+import (
+	. "FinalProject/controllers"
+	. "FinalProject/stores"
+	"net/http"
+	"strings"
+)
+
+func RegisterCartRoutes(mux *http.ServeMux, cartStore CartStore) {
+	mux.HandleFunc("/carts/abandoned", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			ListAbandonedCartsHandler(w, r, cartStore)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// serveCart handles /customers/{id}/cart, /customers/{id}/cart/items, /customers/{id}/cart/items/{bookId}
+// and /customers/{id}/cart/checkout, which share the /customers/ route with the customers.
+func serveCart(w http.ResponseWriter, r *http.Request, cartStore CartStore, idempotencyStore IdempotencyStore) {
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 4:
+		switch r.Method {
+		case "GET":
+			GetCartHandler(w, r, cartStore)
+		case "PUT":
+			UpdateCartHandler(w, r, cartStore)
+		case "DELETE":
+			ClearCartHandler(w, r, cartStore)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 5 && parts[4] == "items":
+		if r.Method == "POST" {
+			AddCartItemHandler(w, r, cartStore)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 6 && parts[4] == "items":
+		switch r.Method {
+		case "PUT":
+			UpdateCartItemHandler(w, r, cartStore)
+		case "DELETE":
+			RemoveCartItemHandler(w, r, cartStore)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 5 && parts[4] == "checkout":
+		// the checkout creates an order, it is as safe to retry as POST /orders
+		if r.Method == "POST" {
+			WithIdempotencyKey(w, r, idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
+				CheckoutCartHandler(w, r, cartStore)
+			})
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -4,13 +4,16 @@ import (
 	. "FinalProject/controllers"
 	. "FinalProject/stores"
 	"net/http"
+	"strings"
 )
 
-func RegisterCustomerRoutes(mux *http.ServeMux, customerStore *InMemoryCustomerStore, orderStore *InMemoryOrderStore) {
+func RegisterCustomerRoutes(mux *http.ServeMux, customerStore CustomerStore, cartStore CartStore, orderStore OrderStore, idempotencyStore IdempotencyStore) {
 	mux.HandleFunc("/customers", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "POST":
-			CreateCustomerHandler(w, r, customerStore)
+			WithIdempotencyKey(w, r, idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
+				CreateCustomerHandler(w, r, customerStore)
+			})
 		case "GET":
 			GetAllCustomersHandler(w, r, customerStore)
 		default:
@@ -19,13 +22,26 @@ func RegisterCustomerRoutes(mux *http.ServeMux, customerStore *InMemoryCustomerS
 	})
 
 	mux.HandleFunc("/customers/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) > 3 && parts[3] == "cart" {
+			serveCart(w, r, cartStore, idempotencyStore)
+			return
+		}
+		if len(parts) > 3 && parts[3] == "pre-orders" {
+			if r.Method == "GET" {
+				ListCustomerPreOrdersHandler(w, r, orderStore)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
 		switch r.Method {
 		case "GET":
 			GetCustomerByIDHandler(w, r, customerStore)
 		case "PUT":
 			UpdateCustomerHandler(w, r, customerStore)
 		case "DELETE":
-			DeleteCustomerHandler(w, r, customerStore, orderStore)
+			DeleteCustomerHandler(w, r, customerStore)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
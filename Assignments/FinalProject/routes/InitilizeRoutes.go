@@ -1,56 +1,38 @@
 package routes
 
 import (
-	. "FinalProject/models"
+	. "FinalProject/controllers"
 	. "FinalProject/stores"
 	"context"
 	"log"
 	"net/http"
-	"sync"
 )
 
-func InitializeRoutes() (*http.ServeMux, *InMemoryBookStore, *InMemoryAuthorStore, *InMemoryCustomerStore, *InMemoryOrderStore) {
-	bookStore := &InMemoryBookStore{
-		Mu:     sync.RWMutex{},
-		Books:  make(map[int]Book),
-		NextID: 1,
-	}
-	authorStore := &InMemoryAuthorStore{
-		Mu:      sync.RWMutex{},
-		Authors: make(map[int]Author),
-		NextID:  1,
-	}
-	customerStore := &InMemoryCustomerStore{
-		Mu:        sync.RWMutex{},
-		Customers: make(map[int]Customer),
-		NextID:    1,
-	}
-	orderStore := &InMemoryOrderStore{
-		Mu:     sync.RWMutex{},
-		Orders: make(map[int]Order),
-		NextID: 1,
-	}
+func InitializeRoutes(journal *Journal, drivers StorageDrivers) (http.Handler, *Stores, *SnapshotManager) {
+	stores := NewInMemoryStores(journal, drivers)
 
 	ctx := context.Background()
-	if err := bookStore.LoadBooks(ctx, "books.json"); err != nil {
-		log.Fatalf("Failed to load books: %v", err)
-	}
-	if err := authorStore.LoadAuthors(ctx, "authors.json"); err != nil {
-		log.Fatalf("Failed to load authors: %v", err)
-	}
-	if err := customerStore.LoadCustomers(ctx, "customers.json"); err != nil {
-		log.Fatalf("Failed to load customers: %v", err)
-	}
-	if err := orderStore.LoadOrders(ctx, "orders.json"); err != nil {
-		log.Fatalf("Failed to load orders: %v", err)
+	if err := stores.Load(ctx); err != nil {
+		log.Fatalf("Failed to load the stores: %v", err)
 	}
 
+	snapshots := NewSnapshotManager(journal, 10)
+
 	router := http.NewServeMux()
 
-	RegisterBookRoutes(router, bookStore, authorStore)
-	RegisterAuthorRoutes(router, authorStore, bookStore)
-	RegisterOrderRoutes(router, orderStore, customerStore, bookStore)
-	RegisterCustomerRoutes(router, customerStore, orderStore)
+	RegisterBookRoutes(router, stores.Books, stores.Inventory, stores.Idempotency)
+	RegisterAuthorRoutes(router, stores.Authors, stores.Idempotency)
+	RegisterOrderRoutes(router, stores.Orders, stores.Shipments, stores.Payments, stores.Idempotency)
+	RegisterCustomerRoutes(router, stores.Customers, stores.Carts, stores.Orders, stores.Idempotency)
+	RegisterCartRoutes(router, stores.Carts)
+	RegisterPromotionRoutes(router, stores.Promotions)
+	RegisterInventoryRoutes(router, stores.Inventory)
+	RegisterAlertRoutes(router, stores.Alerts)
+	RegisterSupplierRoutes(router, stores.Suppliers, stores.Idempotency)
+	RegisterPurchaseOrderRoutes(router, stores.PurchaseOrders, stores.Idempotency)
+	RegisterReturnRoutes(router, stores.Returns, stores.Idempotency)
+	RegisterAdminRoutes(router, snapshots, stores)
 
-	return router, bookStore, authorStore, customerStore, orderStore
+	// ?expand= works the same on every route
+	return WithExpansion(router, stores), stores, snapshots
 }
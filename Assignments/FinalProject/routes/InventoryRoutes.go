@@ -0,0 +1,29 @@
+package routes
+
+import (
+	. "FinalProject/controllers"
+	. "FinalProject/stores"
+	"net/http"
+)
+
+func RegisterInventoryRoutes(mux *http.ServeMux, inventoryStore InventoryStore) {
+	mux.HandleFunc("/reports/inventory-valuation", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			InventoryValuationHandler(w, r, inventoryStore)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// serveStockMovements handles /books/{id}/stock-movements, which shares the /books/ route with the books.
+func serveStockMovements(w http.ResponseWriter, r *http.Request, inventoryStore InventoryStore) {
+	switch r.Method {
+	case "GET":
+		GetStockMovementsHandler(w, r, inventoryStore)
+	case "POST":
+		RecordStockMovementHandler(w, r, inventoryStore)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
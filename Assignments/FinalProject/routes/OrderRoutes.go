@@ -4,13 +4,16 @@ import (
 	. "FinalProject/controllers"
 	. "FinalProject/stores"
 	"net/http"
+	"strings"
 )
 
-func RegisterOrderRoutes(mux *http.ServeMux, orderStore *InMemoryOrderStore, customerStore *InMemoryCustomerStore, bookStore *InMemoryBookStore) {
+func RegisterOrderRoutes(mux *http.ServeMux, orderStore OrderStore, shipmentStore ShipmentStore, paymentStore PaymentStore, idempotencyStore IdempotencyStore) {
 	mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "POST":
-			CreateOrderHandler(w, r, customerStore, bookStore, orderStore)
+			WithIdempotencyKey(w, r, idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
+				CreateOrderHandler(w, r, orderStore)
+			})
 		case "GET":
 			GetAllOrdersHandler(w, r, orderStore)
 		default:
@@ -19,13 +22,35 @@ func RegisterOrderRoutes(mux *http.ServeMux, orderStore *InMemoryOrderStore, cus
 	})
 
 	mux.HandleFunc("/orders/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) > 3 && parts[3] == "shipments" {
+			serveShipments(w, r, shipmentStore)
+			return
+		}
+		if len(parts) > 3 && parts[3] == "payments" {
+			if r.Method == "GET" {
+				ListPaymentsHandler(w, r, paymentStore)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
 		switch r.Method {
 		case "GET":
 			GetOrderHandler(w, r, orderStore)
 		case "PUT":
-			UpdateOrderHandler(w, r, orderStore, customerStore, bookStore)
+			UpdateOrderHandler(w, r, orderStore)
 		case "DELETE":
 			DeleteOrderHandler(w, r, orderStore)
+		case "POST":
+			// paying, cancelling and refunding go through the payment provider
+			if len(parts) > 3 && parts[3] == "pay" {
+				PayOrderHandler(w, r, paymentStore)
+			} else if len(parts) > 3 && (parts[3] == "cancel" || parts[3] == "refund") {
+				RefundOrderHandler(w, r, paymentStore)
+			} else {
+				TransitionOrderHandler(w, r, orderStore)
+			}
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
@@ -55,4 +80,12 @@ func RegisterOrderRoutes(mux *http.ServeMux, orderStore *InMemoryOrderStore, cus
 		}
 	})
 
+	mux.HandleFunc("/reports/pre-orders", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			PreOrderDemandHandler(w, r, orderStore)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
 }
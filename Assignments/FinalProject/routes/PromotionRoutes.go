@@ -0,0 +1,42 @@
+package routes
+
+import (
+	. "FinalProject/controllers"
+	. "FinalProject/stores"
+	"net/http"
+	"strings"
+)
+
+func RegisterPromotionRoutes(mux *http.ServeMux, promotionStore PromotionStore) {
+	mux.HandleFunc("/promotions", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			CreatePromotionHandler(w, r, promotionStore)
+		case "GET":
+			ListPromotionsHandler(w, r, promotionStore)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/promotions/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/redemptions") {
+			if r.Method == "GET" {
+				ListRedemptionsHandler(w, r, promotionStore)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+		switch r.Method {
+		case "GET":
+			GetPromotionHandler(w, r, promotionStore)
+		case "PUT":
+			UpdatePromotionHandler(w, r, promotionStore)
+		case "DELETE":
+			DeletePromotionHandler(w, r, promotionStore)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
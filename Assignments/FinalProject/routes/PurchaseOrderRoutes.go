@@ -0,0 +1,52 @@
+package routes
+
+import (
+	. "FinalProject/controllers"
+	. "FinalProject/stores"
+	"net/http"
+	"strings"
+)
+
+func RegisterPurchaseOrderRoutes(mux *http.ServeMux, purchaseOrderStore PurchaseOrderStore, idempotencyStore IdempotencyStore) {
+	mux.HandleFunc("/purchase-orders", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			WithIdempotencyKey(w, r, idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
+				CreatePurchaseOrderHandler(w, r, purchaseOrderStore)
+			})
+		case "GET":
+			ListPurchaseOrdersHandler(w, r, purchaseOrderStore)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/purchase-orders/", func(w http.ResponseWriter, r *http.Request) {
+		if parts := strings.Split(r.URL.Path, "/"); len(parts) > 3 {
+			if r.Method != "POST" {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			switch parts[3] {
+			case "receive":
+				// a retried delivery must not be put in stock twice
+				WithIdempotencyKey(w, r, idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
+					ReceivePurchaseOrderHandler(w, r, purchaseOrderStore)
+				})
+			case "cancel":
+				CancelPurchaseOrderHandler(w, r, purchaseOrderStore)
+			default:
+				http.NotFound(w, r)
+			}
+			return
+		}
+		switch r.Method {
+		case "GET":
+			GetPurchaseOrderHandler(w, r, purchaseOrderStore)
+		case "PUT":
+			UpdatePurchaseOrderHandler(w, r, purchaseOrderStore)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package routes
+
+import (
+	. "FinalProject/controllers"
+	. "FinalProject/stores"
+	"net/http"
+	"strings"
+)
+
+func RegisterReturnRoutes(mux *http.ServeMux, returnStore ReturnStore, idempotencyStore IdempotencyStore) {
+	mux.HandleFunc("/returns", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			WithIdempotencyKey(w, r, idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
+				RequestReturnHandler(w, r, returnStore)
+			})
+		case "GET":
+			ListReturnsHandler(w, r, returnStore)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/returns/", func(w http.ResponseWriter, r *http.Request) {
+		if parts := strings.Split(r.URL.Path, "/"); len(parts) > 3 {
+			if r.Method != "POST" {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			switch parts[3] {
+			case "approve":
+				ApproveReturnHandler(w, r, returnStore)
+			case "reject":
+				RejectReturnHandler(w, r, returnStore)
+			default:
+				http.NotFound(w, r)
+			}
+			return
+		}
+		if r.Method == "GET" {
+			GetReturnHandler(w, r, returnStore)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
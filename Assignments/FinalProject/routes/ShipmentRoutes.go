@@ -0,0 +1,39 @@
+package routes
+
+import (
+	. "FinalProject/controllers"
+	. "FinalProject/stores"
+	"net/http"
+	"strings"
+)
+
+// serveShipments handles /orders/{id}/shipments, /orders/{id}/shipments/{shipmentId} and
+// /orders/{id}/shipments/{shipmentId}/events, which share the /orders/ route with the orders.
+func serveShipments(w http.ResponseWriter, r *http.Request, shipmentStore ShipmentStore) {
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 4:
+		switch r.Method {
+		case "POST":
+			CreateShipmentHandler(w, r, shipmentStore)
+		case "GET":
+			ListShipmentsHandler(w, r, shipmentStore)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 5:
+		if r.Method == "GET" {
+			GetShipmentHandler(w, r, shipmentStore)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 6 && parts[5] == "events":
+		if r.Method == "POST" {
+			AddTrackingEventHandler(w, r, shipmentStore)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
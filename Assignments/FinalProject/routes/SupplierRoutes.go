@@ -0,0 +1,35 @@
+package routes
+
+import (
+	. "FinalProject/controllers"
+	. "FinalProject/stores"
+	"net/http"
+)
+
+func RegisterSupplierRoutes(mux *http.ServeMux, supplierStore SupplierStore, idempotencyStore IdempotencyStore) {
+	mux.HandleFunc("/suppliers", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			WithIdempotencyKey(w, r, idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
+				CreateSupplierHandler(w, r, supplierStore)
+			})
+		case "GET":
+			ListSuppliersHandler(w, r, supplierStore)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/suppliers/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			GetSupplierHandler(w, r, supplierStore)
+		case "PUT":
+			UpdateSupplierHandler(w, r, supplierStore)
+		case "DELETE":
+			DeleteSupplierHandler(w, r, supplierStore)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
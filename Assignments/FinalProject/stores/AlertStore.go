@@ -0,0 +1,166 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"log"
+	"math"
+	"time"
+)
+
+const DefaultReorderCoverDays = 30
+
+// ----------------------------------------------Definition of AlertMethods--------------------------------
+type InMemoryAlertStore struct {
+	repo  *Repository[StockAlert]
+	Books *InMemoryBookStore
+	// CoverDays is how many days of sales a suggested purchase should cover.
+	CoverDays int
+}
+
+type AlertStore interface {
+	CheckStockLevels(ctx context.Context, salesPerDay map[int]float64) (int, error)
+	ListStockAlerts(ctx context.Context, status AlertStatus) ([]StockAlert, error)
+	LoadStockAlerts(ctx context.Context) error
+	SaveStockAlerts(ctx context.Context) error
+}
+
+func NewInMemoryAlertStore(journal *Journal, driver StorageDriver[StockAlert]) *InMemoryAlertStore {
+	return &InMemoryAlertStore{
+		repo:      NewRepository[StockAlert](alertEntity, alertStoreRank, journal, driver),
+		CoverDays: DefaultReorderCoverDays,
+	}
+}
+
+// CheckStockLevels opens an alert for every book newly low on stock, refreshes the open ones and
+// resolves those whose stock came back. salesPerDay holds the recent sales velocity of the books.
+// It returns the number of alerts opened.
+func (s *InMemoryAlertStore) CheckStockLevels(ctx context.Context, salesPerDay map[int]float64) (int, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during the stock level check")
+		return 0, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Books.repo), WriteLock(s.repo))
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+		books := Table(tx, s.Books.repo)
+		alerts := Table(tx, s.repo)
+
+		openAlerts := make(map[int]StockAlert)
+		for _, alert := range alerts.All() {
+			if alert.Status == AlertOpen {
+				openAlerts[alert.BookID] = alert
+			}
+		}
+
+		now := time.Now()
+		opened, changed := 0, false
+		for _, book := range books.All() {
+			alert, isOpen := openAlerts[book.ID]
+			delete(openAlerts, book.ID)
+			if !lowOnStock(book) {
+				if isOpen {
+					alert.Status = AlertResolved
+					alert.Stock, alert.Available = book.Stock, book.Available()
+					alert.UpdatedAt = now
+					alert.ResolvedAt = &now
+					if err := alerts.Put(alert.ID, alert); err != nil {
+						return 0, err
+					}
+					log.Printf("Stock alert %d resolved, book %d has %d available again\n", alert.ID, book.ID, book.Available())
+					changed = true
+				}
+				continue
+			}
+
+			previous := alert
+			if !isOpen {
+				alert = StockAlert{BookID: book.ID, Status: AlertOpen, CreatedAt: now}
+				alert.ID, err = alerts.NextID()
+				if err != nil {
+					return 0, err
+				}
+				opened++
+				log.Printf("Stock alert %d opened, book %d has %d available\n", alert.ID, book.ID, book.Available())
+			}
+			alert.Title = book.Title
+			alert.Stock, alert.Available = book.Stock, book.Available()
+			alert.ReorderPoint, alert.ReorderQuantity = book.ReorderPoint, book.ReorderQuantity
+			alert.SalesPerDay = math.Round(salesPerDay[book.ID]*100) / 100
+			alert.SuggestedQuantity = s.suggestedQuantity(book, salesPerDay[book.ID])
+			// an alert that didn't change isn't written again
+			if isOpen && alert == previous {
+				continue
+			}
+			alert.UpdatedAt = now
+			if err := alerts.Put(alert.ID, alert); err != nil {
+				return 0, err
+			}
+			changed = true
+		}
+		// the books deleted since leave nothing to reorder
+		for _, alert := range openAlerts {
+			alert.Status = AlertResolved
+			alert.UpdatedAt = now
+			alert.ResolvedAt = &now
+			if err := alerts.Put(alert.ID, alert); err != nil {
+				return 0, err
+			}
+			changed = true
+		}
+		if !changed {
+			return 0, nil
+		}
+		return opened, tx.Commit()
+	}
+}
+
+// ListStockAlerts lists the alerts with the status, all of them when it is empty.
+func (s *InMemoryAlertStore) ListStockAlerts(ctx context.Context, status AlertStatus) ([]StockAlert, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during stock alerts retrieval")
+		return nil, ctx.Err()
+	default:
+		alerts := []StockAlert{}
+		for _, alert := range s.repo.List() {
+			if status == "" || alert.Status == status {
+				alerts = append(alerts, alert)
+			}
+		}
+		return alerts, nil
+	}
+}
+
+func (s *InMemoryAlertStore) LoadStockAlerts(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemoryAlertStore) SaveStockAlerts(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
+
+// lowOnStock tells whether a book fell to its reorder point, or ran out when it has none.
+func lowOnStock(book Book) bool {
+	if book.ReorderPoint > 0 {
+		return book.Available() <= book.ReorderPoint
+	}
+	return book.Available() == 0
+}
+
+// suggestedQuantity brings the available stock back to the reorder point plus the sales expected over
+// the cover days, never less than the reorder quantity of the book.
+func (s *InMemoryAlertStore) suggestedQuantity(book Book, salesPerDay float64) int {
+	target := book.ReorderPoint + int(math.Ceil(salesPerDay*float64(s.CoverDays)))
+	suggested := target - book.Available()
+	if suggested < book.ReorderQuantity {
+		suggested = book.ReorderQuantity
+	}
+	if suggested < 0 {
+		return 0
+	}
+	return suggested
+}
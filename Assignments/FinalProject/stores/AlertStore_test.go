@@ -0,0 +1,72 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"testing"
+)
+
+func TestSuggestedQuantity(t *testing.T) {
+	tests := []struct {
+		name        string
+		book        Book
+		salesPerDay float64
+		want        int
+	}{
+		{"no sales orders the reorder quantity", Book{Stock: 2, ReorderPoint: 3, ReorderQuantity: 10}, 0, 10},
+		{"sales cover the days to come", Book{Stock: 2, ReorderPoint: 3, ReorderQuantity: 10}, 1, 31},
+		{"slow sales are rounded up", Book{Stock: 0, ReorderPoint: 0}, 0.1, 3},
+		{"reserved copies are not available", Book{Stock: 5, Reserved: 5, ReorderPoint: 2}, 0, 2},
+		{"enough stock suggests nothing", Book{Stock: 50, ReorderPoint: 3}, 0, 0},
+	}
+	store := newTestStores(t).Alerts
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := store.suggestedQuantity(tt.book, tt.salesPerDay); got != tt.want {
+				t.Fatalf("suggestedQuantity = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckStockLevels(t *testing.T) {
+	ctx := context.Background()
+	stores, book, _ := newTestCatalog(t, 5)
+	book.ReorderPoint, book.ReorderQuantity = 3, 10
+	if _, err := stores.Books.UpdateBook(ctx, book.ID, book); err != nil {
+		t.Fatal(err)
+	}
+	sales := map[int]float64{book.ID: 0.5}
+
+	if opened, err := stores.Alerts.CheckStockLevels(ctx, sales); err != nil || opened != 0 {
+		t.Fatalf("opened %d alerts, %v, the book isn't low", opened, err)
+	}
+
+	book.Stock = 2
+	if _, err := stores.Books.UpdateBook(ctx, book.ID, book); err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := stores.Alerts.CheckStockLevels(ctx, sales); err != nil || opened != 1 {
+		t.Fatalf("opened %d alerts, %v, want 1", opened, err)
+	}
+	// a second check refreshes the alert instead of opening another one
+	if opened, _ := stores.Alerts.CheckStockLevels(ctx, sales); opened != 0 {
+		t.Fatalf("opened %d more alerts", opened)
+	}
+	alerts, _ := stores.Alerts.ListStockAlerts(ctx, AlertOpen)
+	if len(alerts) != 1 || alerts[0].Available != 2 || alerts[0].SuggestedQuantity != 16 {
+		t.Fatalf("open alerts %+v", alerts)
+	}
+
+	book.Stock = 20
+	if _, err := stores.Books.UpdateBook(ctx, book.ID, book); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Alerts.CheckStockLevels(ctx, sales); err != nil {
+		t.Fatal(err)
+	}
+	alerts, _ = stores.Alerts.ListStockAlerts(ctx, AlertResolved)
+	if len(alerts) != 1 || alerts[0].ResolvedAt == nil {
+		t.Fatalf("resolved alerts %+v", alerts)
+	}
+}
@@ -2,23 +2,24 @@ package stores
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 
 	. "FinalProject/models"
 )
 
 // ----------------------------------------------Definition of BookMethods--------------------------------
 type InMemoryBookStore struct {
-	Mu     sync.RWMutex
-	Books  map[int]Book
-	NextID int
+	repo           *Repository[Book]
+	Authors        *InMemoryAuthorStore
+	Orders         *InMemoryOrderStore
+	Inventory      *InMemoryInventoryStore
+	Carts          *InMemoryCartStore
+	PurchaseOrders *InMemoryPurchaseOrderStore
 }
 
 type BookStore interface {
@@ -27,107 +28,171 @@ type BookStore interface {
 	UpdateBook(ctx context.Context, id int, book Book) (Book, error)
 	DeleteBook(ctx context.Context, id int) error
 	SearchBooks(ctx context.Context, criteria SearchCriteria) ([]Book, error)
-	LoadBooks(ctx context.Context, filePath string) error
-	SaveBooks(ctx context.Context, filePath string) error
+	LoadBooks(ctx context.Context) error
+	SaveBooks(ctx context.Context) error
 }
 
-func (s *InMemoryBookStore) CreateBook(ctx context.Context, book Book, auths *InMemoryAuthorStore) (Book, error) {
+func NewInMemoryBookStore(journal *Journal, driver StorageDriver[Book]) *InMemoryBookStore {
+	return &InMemoryBookStore{repo: NewRepository[Book](bookEntity, bookStoreRank, journal, driver)}
+}
+
+func (s *InMemoryBookStore) CreateBook(ctx context.Context, book Book) (Book, error) {
 	select {
 	case <-ctx.Done():
 		log.Println("Request canceled during book creation")
 		return Book{}, ctx.Err()
 	default:
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
+		tx, err := BeginTransaction(ctx, ReadLock(s.Authors.repo), WriteLock(s.repo), WriteLock(s.Inventory.repo))
+		if err != nil {
+			return Book{}, err
+		}
+		defer tx.Rollback()
 
-		author, ok := auths.Authors[book.Author.ID]
-		if !ok {
-			return Book{}, errors.New("Author with ID " + strconv.Itoa(book.Author.ID) + " not found")
+		if book.Contributors, err = checkContributors(Table(tx, s.Authors.repo), book); err != nil {
+			return Book{}, err
 		}
-		book.Author = author
+		// only the orders hold books, and the first stock is received through the ledger
+		stock := book.Stock
+		book.Stock, book.Reserved = 0, 0
 
-		book.ID = s.NextID
-		s.NextID++
-		s.Books[book.ID] = book
+		inventory := inventoryTables{books: Table(tx, s.repo), movements: Table(tx, s.Inventory.repo)}
+		book.ID, err = inventory.books.NextID()
+		if err != nil {
+			return Book{}, err
+		}
+		if err := inventory.books.Put(book.ID, book); err != nil {
+			return Book{}, err
+		}
+		if stock != 0 {
+			// the cost given with the book is the cost of its first copies
+			movement, err := inventory.move(book, StockMovement{Kind: MovementReceipt, Quantity: stock, UnitCost: book.UnitCost.Float(), Reason: "initial stock", Actor: actorAPI})
+			if err != nil {
+				return Book{}, err
+			}
+			book.Stock = movement.StockAfter
+		}
+		if err := tx.Commit(); err != nil {
+			return Book{}, err
+		}
 
 		log.Printf("Book created successfully. ID: %d\n", book.ID)
-		return book, nil
+		return resolveContributors(book, s.Authors.repo.Find), nil
 	}
 }
 
-func (s *InMemoryBookStore) GetBook(ctx context.Context, bookId int, auths *InMemoryAuthorStore) (Book, error) {
+func (s *InMemoryBookStore) GetBook(ctx context.Context, bookId int) (Book, error) {
 	select {
 	case <-ctx.Done():
 		log.Println("Request canceled during book retrieval of book")
 		return Book{}, ctx.Err()
 	default:
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
-		book, ok := s.Books[bookId]
+		book, ok := s.repo.Find(bookId)
 		if !ok {
 			log.Println("Book with ID ", bookId, " not found")
 			return Book{}, errors.New("Book with ID " + strconv.Itoa(bookId) + " not found")
 		}
-		author, ok := auths.Authors[book.Author.ID]
-		if ok {
-			book.Author = author
-		} else {
-			log.Printf("Author with ID %d not found for book ID %d\n", book.Author.ID, book.ID)
-		}
-		return book, nil
+		return resolveContributors(book, s.Authors.repo.Find), nil
 	}
 }
 
-func (s *InMemoryBookStore) UpdateBook(ctx context.Context, bookId int, book Book, auths *InMemoryAuthorStore) (Book, error) {
+func (s *InMemoryBookStore) UpdateBook(ctx context.Context, bookId int, book Book) (Book, error) {
 	select {
 	case <-ctx.Done():
 		log.Println("Request canceled during book update")
 		return Book{}, ctx.Err()
 	default:
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
-		authors, _ := auths.ListAuthors(ctx)
-		foundAuthor := false
-		for _, a := range authors {
-			if a.FirstName == book.Author.FirstName && a.LastName == book.Author.LastName {
-				unchangedBook := s.Books[bookId]
-				book.ID = unchangedBook.ID
-				s.Books[bookId] = book
-				foundAuthor = true
-				return s.Books[bookId], nil
-			}
+		tx, err := BeginTransaction(ctx, ReadLock(s.Authors.repo), WriteLock(s.repo), WriteLock(s.Inventory.Orders.repo), WriteLock(s.Inventory.Reservations.repo), WriteLock(s.Inventory.repo))
+		if err != nil {
+			return Book{}, err
+		}
+		defer tx.Rollback()
+		inventory := s.Inventory.backorderTables(tx)
+
+		existing, ok := inventory.books.Get(bookId)
+		if !ok {
+			return Book{}, errors.New("Book with id " + strconv.Itoa(bookId) + "not found")
 		}
-		if !foundAuthor {
-			log.Println("Author with name", book.Author.FirstName, "and last name", book.Author.LastName, "not found")
-			_, err := auths.CreateAuthor(ctx, book.Author)
+		if book.Contributors, err = checkContributors(Table(tx, s.Authors.repo), book); err != nil {
+			return Book{}, err
+		}
+		// a new stock count is recorded as an adjustment of the ledger
+		stock := book.Stock
+		book.ID = bookId
+		book.Stock, book.Reserved = existing.Stock, existing.Reserved
+		// the cost only comes from the receipts
+		book.UnitCost = existing.UnitCost
+		updated, err := inventory.adjustStock(book, stock, "stock set by a book update")
+		if err != nil {
+			return Book{}, err
+		}
+		// the copies added go to the backorders first
+		if stock > existing.Stock {
+			allocated, err := inventory.allocateBackorders(bookId, time.Now(), s.Inventory.Reservations.TTL)
 			if err != nil {
-				log.Println("Error creating the author for the book you're trying to update")
-				return s.Books[bookId], err
+				return Book{}, err
+			}
+			if allocated > 0 {
+				updated, _ = inventory.books.Get(bookId)
 			}
-			log.Println("Author with name", book.Author.FirstName, "and last name", book.Author.LastName, "was created, in order to update book")
-			unchangedBook := s.Books[bookId]
-			book.ID = unchangedBook.ID
-			s.Books[bookId] = book
-			return s.Books[bookId], nil
 		}
-
-		return Book{}, errors.New("Book with id " + strconv.Itoa(bookId) + "not found")
+		if err := tx.Commit(); err != nil {
+			return Book{}, err
+		}
+		return resolveContributors(updated, s.Authors.repo.Find), nil
 	}
 }
 
+// DeleteBook refuses to delete a book that is still waited for: by an order that hasn't shipped it yet,
+// a hold on its stock, a purchase order still expecting it or an active cart. The orders already shipped
+// keep its ID and title.
 func (s *InMemoryBookStore) DeleteBook(ctx context.Context, bookId int) error {
 	select {
 	case <-ctx.Done():
 		log.Println("Request canceled during book deletion")
 		return ctx.Err()
 	default:
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
-		if _, ok := s.Books[bookId]; ok {
-			delete(s.Books, bookId)
-			return nil
+		tx, err := BeginTransaction(ctx, WriteLock(s.repo), ReadLock(s.Orders.repo), ReadLock(s.Carts.repo), ReadLock(s.PurchaseOrders.repo))
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		books := Table(tx, s.repo)
+
+		if _, ok := books.Get(bookId); !ok {
+			return errors.New("Book with id " + strconv.Itoa(bookId) + "not found")
+		}
+		// the order items point at their book
+		for _, order := range Table(tx, s.Orders.repo).All() {
+			for _, item := range order.Items {
+				if item.BookID == bookId {
+					return fmt.Errorf("%w: book %d was ordered in order %d", ErrBookInUse, bookId, order.ID)
+				}
+			}
+		}
+		for _, purchaseOrder := range Table(tx, s.PurchaseOrders.repo).All() {
+			if !purchaseOrder.IsOpen() {
+				continue
+			}
+			for _, line := range purchaseOrder.Lines {
+				if line.BookID == bookId {
+					return fmt.Errorf("%w: purchase order %d still expects book %d", ErrBookInUse, purchaseOrder.ID, bookId)
+				}
+			}
+		}
+		for _, cart := range Table(tx, s.Carts.repo).All() {
+			if cart.Status != CartActive {
+				continue
+			}
+			for _, item := range cart.Items {
+				if item.BookID == bookId {
+					return fmt.Errorf("%w: book %d is in the cart of customer %d", ErrBookInUse, bookId, cart.CustomerID)
+				}
+			}
+		}
+		if err := books.Delete(bookId); err != nil {
+			return err
 		}
-		return errors.New("Book with id " + strconv.Itoa(bookId) + "not found")
+		return tx.Commit()
 	}
 }
 
@@ -137,14 +202,29 @@ func (s *InMemoryBookStore) SearchBooks(ctx context.Context, criteria SearchCrit
 		log.Println("Request canceled during book search")
 		return nil, ctx.Err()
 	default:
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
+		tx, err := BeginTransaction(ctx, ReadLock(s.Authors.repo), ReadLock(s.repo))
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		authors := Table(tx, s.Authors.repo)
+
+		// only the criteria given count, without any every book matches
+		all := criteria.Title == "" && criteria.Author == "" && criteria.Genre == ""
 		var result []Book
-		for _, book := range s.Books {
-			if strings.Contains(book.Title, criteria.Title) ||
-				strings.Contains(book.Author.FirstName, criteria.Author) ||
-				strings.Contains(book.Author.LastName, criteria.Author) ||
-				strings.Contains(strings.Join(book.Genres, ","), criteria.Genre) {
+		for _, book := range Table(tx, s.repo).All() {
+			book = resolveContributors(book, authors.Get)
+			// any of the contributors matches the author searched for
+			byAuthor := false
+			for _, contributor := range book.Contributors {
+				if criteria.Author != "" && contributor.Author != nil && (strings.Contains(contributor.Author.FirstName, criteria.Author) ||
+					strings.Contains(contributor.Author.LastName, criteria.Author)) {
+					byAuthor = true
+					break
+				}
+			}
+			if all || byAuthor || (criteria.Title != "" && strings.Contains(book.Title, criteria.Title)) ||
+				(criteria.Genre != "" && strings.Contains(strings.Join(book.Genres, ","), criteria.Genre)) {
 				result = append(result, book)
 			}
 		}
@@ -155,84 +235,49 @@ func (s *InMemoryBookStore) SearchBooks(ctx context.Context, criteria SearchCrit
 	}
 }
 
-func (s *InMemoryBookStore) LoadBooks(ctx context.Context, filePath string) error {
-	select {
-	case <-ctx.Done():
-		log.Println("Context canceled during book loading")
-		return ctx.Err()
-	default:
-		dir := "database"
-		fullPath := filepath.Join(dir, filePath)
-
-		file, err := os.Open(fullPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				log.Printf("No existing book database found in %s, starting fresh.\n", fullPath)
-				s.NextID = 1
-				return nil
-			}
-			log.Printf("Failed to open file %s: %v\n", fullPath, err)
-			return err
-		}
-		defer file.Close()
-
-		var data struct {
-			Books  map[int]Book `json:"books"`
-			NextID int          `json:"next_id"`
-		}
-
-		if err := json.NewDecoder(file).Decode(&data); err != nil {
-			log.Printf("Failed to decode books from file %s: %v\n", fullPath, err)
-			return err
-		}
+func (s *InMemoryBookStore) LoadBooks(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
 
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
-		s.Books = data.Books
-		s.NextID = data.NextID
-		log.Printf("Books loaded successfully from %s\n", fullPath)
-		return nil
-	}
+func (s *InMemoryBookStore) SaveBooks(ctx context.Context) error {
+	return s.repo.Save(ctx)
 }
 
-func (s *InMemoryBookStore) SaveBooks(ctx context.Context, filePath string) error {
-	select {
-	case <-ctx.Done():
-		log.Println("Context canceled during book saving")
-		return ctx.Err()
-	default:
-		dir := "database"
-		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-			log.Printf("Failed to create directory %s: %v\n", dir, err)
-			return err
+// checkContributors puts the contributors of a book in order and checks that each of them is a known
+// author, with one of the roles and only once in that role.
+func checkContributors(authors *TxTable[Author], book Book) ([]Contributor, error) {
+	contributors := book.SortedContributors()
+	if len(contributors) == 0 {
+		return nil, fmt.Errorf("%w: a book needs at least one contributor", ErrInvalidContributors)
+	}
+	seen := make(map[Contributor]bool)
+	for _, contributor := range contributors {
+		if !contributor.Role.Valid() {
+			return nil, fmt.Errorf("%w: unknown role '%s', expected author, editor, translator or illustrator", ErrInvalidContributors, contributor.Role)
 		}
-
-		fullPath := filepath.Join(dir, filePath)
-
-		s.Mu.RLock()
-		defer s.Mu.RUnlock()
-
-		data := struct {
-			Books  map[int]Book `json:"books"`
-			NextID int          `json:"next_id"`
-		}{
-			Books:  s.Books,
-			NextID: s.NextID,
+		if _, ok := authors.Get(contributor.AuthorID); !ok {
+			return nil, fmt.Errorf("%w: author with ID %d not found", ErrInvalidContributors, contributor.AuthorID)
 		}
-
-		file, err := os.Create(fullPath)
-		if err != nil {
-			log.Printf("Failed to create file %s: %v\n", fullPath, err)
-			return err
+		key := Contributor{AuthorID: contributor.AuthorID, Role: contributor.Role}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: author %d is listed twice as %s", ErrInvalidContributors, contributor.AuthorID, contributor.Role)
 		}
-		defer file.Close()
+		seen[key] = true
+	}
+	return contributors, nil
+}
 
-		if err := json.NewEncoder(file).Encode(data); err != nil {
-			log.Printf("Failed to write books to file %s: %v\n", fullPath, err)
-			return err
+// resolveContributors fills in the authors of the contributors, on a copy so the stored book keeps its IDs only.
+func resolveContributors(book Book, find func(id int) (Author, bool)) Book {
+	contributors := make([]Contributor, len(book.Contributors))
+	for i, contributor := range book.Contributors {
+		if author, ok := find(contributor.AuthorID); ok {
+			contributor.Author = &author
+		} else {
+			log.Printf("Author with ID %d not found for book ID %d\n", contributor.AuthorID, book.ID)
 		}
-
-		log.Printf("Books saved successfully to %s\n", fullPath)
-		return nil
+		contributors[i] = contributor
 	}
+	book.Contributors = contributors
+	return book
 }
@@ -0,0 +1,97 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDeleteBookStillInUse(t *testing.T) {
+	tests := []struct {
+		name    string
+		use     func(t *testing.T, stores *Stores, book Book, customer Customer)
+		wantErr error
+	}{
+		{"unused book", func(t *testing.T, stores *Stores, book Book, customer Customer) {}, nil},
+		{"ordered book", func(t *testing.T, stores *Stores, book Book, customer Customer) {
+			order, err := stores.Orders.CreateOrder(context.Background(), Order{CustomerID: customer.ID, Items: []OrderItem{{BookID: book.ID, Quantity: 1}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// even once cancelled, the order still points at the book
+			if _, err := stores.Orders.TransitionOrder(context.Background(), order.ID, OrderCancelled); err != nil {
+				t.Fatal(err)
+			}
+		}, ErrBookInUse},
+		{"book in a cart", func(t *testing.T, stores *Stores, book Book, customer Customer) {
+			if _, err := stores.Carts.AddCartItem(context.Background(), customer.ID, CartItem{BookID: book.ID, Quantity: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}, ErrBookInUse},
+		{"book expected by a purchase order", func(t *testing.T, stores *Stores, book Book, customer Customer) {
+			supplier, err := stores.Suppliers.CreateSupplier(context.Background(), Supplier{Name: "Orbit Books"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			purchaseOrder := PurchaseOrder{SupplierID: supplier.ID, Lines: []PurchaseOrderLine{{BookID: book.ID, Quantity: 1}}}
+			if _, err := stores.PurchaseOrders.CreatePurchaseOrder(context.Background(), purchaseOrder); err != nil {
+				t.Fatal(err)
+			}
+		}, ErrBookInUse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, customer := newTestCatalog(t, 5)
+			tt.use(t, stores, book, customer)
+			if err := stores.Books.DeleteBook(ctx, book.ID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteBook error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBookContributors(t *testing.T) {
+	ctx := context.Background()
+	stores, book, _ := newTestCatalog(t, 5)
+	translator, err := stores.Authors.CreateAuthor(ctx, Author{FirstName: "Ken", LastName: "Liu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		contributors []Contributor
+		wantErr      error
+	}{
+		{"no contributor", nil, ErrInvalidContributors},
+		{"unknown author", []Contributor{{AuthorID: 42, Role: RoleAuthor}}, ErrInvalidContributors},
+		{"unknown role", []Contributor{{AuthorID: translator.ID, Role: "ghost writer"}}, ErrInvalidContributors},
+		{"author and translator", []Contributor{book.Contributors[0], {AuthorID: translator.ID, Role: RoleTranslator, Position: 2}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := stores.Books.CreateBook(ctx, Book{Title: "The Three-Body Problem", Contributors: tt.contributors, Price: NewAmount(10)})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateBook error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// the books show the author as it is now
+	translator.LastName = "Liu Yukun"
+	if _, err := stores.Authors.UpdateAuthor(ctx, translator.ID, translator); err != nil {
+		t.Fatal(err)
+	}
+	books, err := stores.Books.SearchBooks(ctx, SearchCriteria{Title: "Three-Body"})
+	if err != nil || len(books) != 1 {
+		t.Fatalf("found %d books, %v", len(books), err)
+	}
+	if author := books[0].Contributors[1].Author; author == nil || author.LastName != "Liu Yukun" {
+		t.Fatalf("translator %+v", books[0].Contributors[1].Author)
+	}
+	if err := stores.Authors.DeleteAuthor(ctx, translator.ID); err == nil {
+		t.Fatal("the translator of a book was deleted")
+	}
+}
@@ -0,0 +1,429 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DefaultCartTTL = 7 * 24 * time.Hour
+
+// ----------------------------------------------Definition of CartMethods--------------------------------
+// The carts are priced with the pricing engine of the orders every time they are read or changed, with
+// the books as they are now, so the customer sees what the checkout will charge. The changes and the
+// checkouts of carts are made one at a time, a cart can't get a book added while it becomes an order.
+type InMemoryCartStore struct {
+	repo      *Repository[Cart]
+	Customers *InMemoryCustomerStore
+	Books     *InMemoryBookStore
+	Orders    *InMemoryOrderStore
+	TTL       time.Duration
+	mu        sync.Mutex
+}
+
+type CartStore interface {
+	GetCart(ctx context.Context, customerId int) (Cart, error)
+	AddCartItem(ctx context.Context, customerId int, item CartItem) (Cart, error)
+	UpdateCartItem(ctx context.Context, customerId int, bookId int, quantity int) (Cart, error)
+	RemoveCartItem(ctx context.Context, customerId int, bookId int) (Cart, error)
+	UpdateCartOptions(ctx context.Context, customerId int, options Cart) (Cart, error)
+	ClearCart(ctx context.Context, customerId int) error
+	CheckoutCart(ctx context.Context, customerId int) (Order, error)
+	ExpireCarts(ctx context.Context) (int, error)
+	ListAbandonedCarts(ctx context.Context, idle time.Duration) ([]AbandonedCart, error)
+	LoadCarts(ctx context.Context) error
+	SaveCarts(ctx context.Context) error
+}
+
+func NewInMemoryCartStore(journal *Journal, driver StorageDriver[Cart]) *InMemoryCartStore {
+	return &InMemoryCartStore{repo: NewRepository[Cart](cartEntity, cartStoreRank, journal, driver), TTL: DefaultCartTTL}
+}
+
+func (s *InMemoryCartStore) GetCart(ctx context.Context, customerId int) (Cart, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during cart retrieval of customer", customerId)
+		return Cart{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Customers.repo), ReadLock(s.Books.repo), ReadLock(s.Orders.repo),
+			ReadLock(s.Orders.Promotions.repo), ReadLock(s.repo))
+		if err != nil {
+			return Cart{}, err
+		}
+		defer tx.Rollback()
+
+		customer, ok := Table(tx, s.Customers.repo).Get(customerId)
+		if !ok {
+			return Cart{}, fmt.Errorf("%w: customer with ID %d", ErrRecordNotFound, customerId)
+		}
+		cart, ok := activeCart(Table(tx, s.repo).All(), customerId, time.Now())
+		if !ok {
+			return Cart{}, ErrCartNotFound
+		}
+		s.priceCart(tx, customer, &cart)
+		return cart, nil
+	}
+}
+
+// AddCartItem puts a book in the cart of the customer, or adds to its quantity when it is already
+// there. The customer gets a new cart when they have no active one.
+func (s *InMemoryCartStore) AddCartItem(ctx context.Context, customerId int, item CartItem) (Cart, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled while adding to the cart of customer", customerId)
+		return Cart{}, ctx.Err()
+	default:
+		if item.Quantity <= 0 {
+			return Cart{}, errors.New("Invalid quantity " + strconv.Itoa(item.Quantity) + " for book " + strconv.Itoa(item.BookID))
+		}
+		return s.changeCart(ctx, customerId, true, func(cart *Cart, books *TxTable[Book]) error {
+			for i := range cart.Items {
+				if cart.Items[i].BookID == item.BookID {
+					return setCartQuantity(cart, i, cart.Items[i].Quantity+item.Quantity, books)
+				}
+			}
+			cart.Items = append(cart.Items, CartItem{BookID: item.BookID})
+			return setCartQuantity(cart, len(cart.Items)-1, item.Quantity, books)
+		})
+	}
+}
+
+// UpdateCartItem sets the quantity of a book of the cart, 0 takes it out.
+func (s *InMemoryCartStore) UpdateCartItem(ctx context.Context, customerId int, bookId int, quantity int) (Cart, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during cart update of customer", customerId)
+		return Cart{}, ctx.Err()
+	default:
+		if quantity < 0 {
+			return Cart{}, errors.New("Invalid quantity " + strconv.Itoa(quantity) + " for book " + strconv.Itoa(bookId))
+		}
+		return s.changeCart(ctx, customerId, false, func(cart *Cart, books *TxTable[Book]) error {
+			for i := range cart.Items {
+				if cart.Items[i].BookID != bookId {
+					continue
+				}
+				if quantity == 0 {
+					cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+					return nil
+				}
+				return setCartQuantity(cart, i, quantity, books)
+			}
+			return fmt.Errorf("%w: book with ID %d is not in the cart", ErrRecordNotFound, bookId)
+		})
+	}
+}
+
+func (s *InMemoryCartStore) RemoveCartItem(ctx context.Context, customerId int, bookId int) (Cart, error) {
+	return s.UpdateCartItem(ctx, customerId, bookId, 0)
+}
+
+// UpdateCartOptions sets the coupon codes, the currency and the shipping method the cart is priced and
+// checked out with. A coupon that can't be used shows in the problems of the cart.
+func (s *InMemoryCartStore) UpdateCartOptions(ctx context.Context, customerId int, options Cart) (Cart, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during cart options update of customer", customerId)
+		return Cart{}, ctx.Err()
+	default:
+		options.Currency = NormalizeCurrency(options.Currency)
+		if options.Currency != "" && !ValidCurrency(options.Currency) {
+			return Cart{}, errors.New("Invalid currency '" + options.Currency + "', expected an ISO 4217 code like EUR")
+		}
+		return s.changeCart(ctx, customerId, true, func(cart *Cart, books *TxTable[Book]) error {
+			cart.CouponCodes = options.CouponCodes
+			cart.Currency = options.Currency
+			cart.ShippingMethod = options.ShippingMethod
+			return nil
+		})
+	}
+}
+
+// ClearCart throws the active cart of the customer away.
+func (s *InMemoryCartStore) ClearCart(ctx context.Context, customerId int) error {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during cart deletion of customer", customerId)
+		return ctx.Err()
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		cart, ok := activeCart(s.repo.List(), customerId, time.Now())
+		if !ok {
+			return ErrCartNotFound
+		}
+		return s.repo.Delete(ctx, cart.ID)
+	}
+}
+
+// CheckoutCart turns the active cart into an order through CreateOrder, which checks the stock and
+// prices it again. The cart is kept, checked out, with the ID of its order.
+func (s *InMemoryCartStore) CheckoutCart(ctx context.Context, customerId int) (Order, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during checkout of customer", customerId)
+		return Order{}, ctx.Err()
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, ok := s.Customers.repo.Find(customerId); !ok {
+			return Order{}, fmt.Errorf("%w: customer with ID %d", ErrRecordNotFound, customerId)
+		}
+		cart, ok := activeCart(s.repo.List(), customerId, time.Now())
+		if !ok {
+			return Order{}, ErrCartNotFound
+		}
+		if len(cart.Items) == 0 {
+			return Order{}, errors.New("The cart of customer " + strconv.Itoa(customerId) + " is empty")
+		}
+
+		order := Order{
+			CustomerID:     customerId,
+			CouponCodes:    cart.CouponCodes,
+			Currency:       cart.Currency,
+			ShippingMethod: cart.ShippingMethod,
+		}
+		for _, item := range cart.Items {
+			order.Items = append(order.Items, OrderItem{BookID: item.BookID, Quantity: item.Quantity})
+		}
+		created, err := s.Orders.CreateOrder(ctx, order)
+		if err != nil {
+			log.Printf("Checkout of cart %d failed: %v\n", cart.ID, err)
+			return Order{}, err
+		}
+
+		// the order exists, the cart is closed even if the client went away meanwhile
+		_, err = s.repo.Update(context.WithoutCancel(ctx), cart.ID, func(existing Cart) (Cart, error) {
+			existing.Status = CartCheckedOut
+			existing.OrderID = created.ID
+			existing.UpdatedAt = created.CreatedAt
+			existing.Problems = nil
+			return existing, nil
+		})
+		if err != nil {
+			log.Printf("Order %d was created but cart %d could not be closed: %v\n", created.ID, cart.ID, err)
+		}
+		log.Printf("Cart %d checked out into order %d\n", cart.ID, created.ID)
+		return created, nil
+	}
+}
+
+// ExpireCarts marks the active carts left untouched past their expiry date.
+func (s *InMemoryCartStore) ExpireCarts(ctx context.Context) (int, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during cart expiry")
+		return 0, ctx.Err()
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		tx, err := BeginTransaction(ctx, WriteLock(s.repo))
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+		carts := Table(tx, s.repo)
+
+		now := time.Now()
+		expired := 0
+		for _, cart := range carts.All() {
+			if cart.Status != CartActive || !cart.Expired(now) {
+				continue
+			}
+			cart.Status = CartExpired
+			if err := carts.Put(cart.ID, cart); err != nil {
+				return 0, err
+			}
+			expired++
+		}
+		if expired == 0 {
+			return 0, nil
+		}
+		return expired, tx.Commit()
+	}
+}
+
+// ListAbandonedCarts returns the carts with books that were not checked out and haven't been touched
+// for idle, expired ones included, the most recently left first.
+func (s *InMemoryCartStore) ListAbandonedCarts(ctx context.Context, idle time.Duration) ([]AbandonedCart, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during abandoned carts retrieval")
+		return nil, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Customers.repo), ReadLock(s.repo))
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		customers := Table(tx, s.Customers.repo)
+
+		before := time.Now().Add(-idle)
+		abandoned := []AbandonedCart{}
+		for _, cart := range Table(tx, s.repo).All() {
+			if cart.Status == CartCheckedOut || len(cart.Items) == 0 || cart.UpdatedAt.After(before) {
+				continue
+			}
+			customer, ok := customers.Get(cart.CustomerID)
+			if !ok {
+				continue
+			}
+			abandoned = append(abandoned, AbandonedCart{Cart: cart, CustomerName: customer.Name, CustomerEmail: customer.Email})
+		}
+		sort.Slice(abandoned, func(i, j int) bool { return abandoned[i].Cart.UpdatedAt.After(abandoned[j].Cart.UpdatedAt) })
+		log.Printf("%d carts abandoned for more than %s\n", len(abandoned), idle)
+		return abandoned, nil
+	}
+}
+
+// changeCart applies change to the active cart of the customer in one transaction and prices it again.
+// With create, a customer without an active cart gets a new one, otherwise ErrCartNotFound is returned.
+func (s *InMemoryCartStore) changeCart(ctx context.Context, customerId int, create bool, change func(cart *Cart, books *TxTable[Book]) error) (Cart, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := BeginTransaction(ctx, ReadLock(s.Customers.repo), ReadLock(s.Books.repo), ReadLock(s.Orders.repo),
+		ReadLock(s.Orders.Promotions.repo), WriteLock(s.repo))
+	if err != nil {
+		return Cart{}, err
+	}
+	defer tx.Rollback()
+	carts := Table(tx, s.repo)
+
+	customer, ok := Table(tx, s.Customers.repo).Get(customerId)
+	if !ok {
+		return Cart{}, fmt.Errorf("%w: customer with ID %d", ErrRecordNotFound, customerId)
+	}
+	now := time.Now()
+	// a cart left past its expiry date is closed, the customer starts a new one
+	for _, expired := range carts.All() {
+		if expired.CustomerID == customerId && expired.Status == CartActive && expired.Expired(now) {
+			expired.Status = CartExpired
+			if err := carts.Put(expired.ID, expired); err != nil {
+				return Cart{}, err
+			}
+			log.Printf("Cart %d of customer %d expired\n", expired.ID, customerId)
+		}
+	}
+	cart, ok := activeCart(carts.All(), customerId, now)
+	if !ok {
+		if !create {
+			return Cart{}, ErrCartNotFound
+		}
+		id, err := carts.NextID()
+		if err != nil {
+			return Cart{}, err
+		}
+		cart = Cart{ID: id, CustomerID: customerId, Status: CartActive, Items: []CartItem{}, CreatedAt: now}
+		log.Printf("New cart %d for customer %d\n", id, customerId)
+	}
+	if err := change(&cart, Table(tx, s.Books.repo)); err != nil {
+		return Cart{}, err
+	}
+	cart.UpdatedAt = now
+	cart.ExpiresAt = now.Add(s.TTL)
+	s.priceCart(tx, customer, &cart)
+
+	if err := carts.Put(cart.ID, cart); err != nil {
+		return Cart{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Cart{}, err
+	}
+	return cart, nil
+}
+
+// activeCart finds the cart of the customer that is active and not past its expiry date. Its items are
+// copied, they can be changed without touching the stored cart.
+func activeCart(carts []Cart, customerId int, at time.Time) (Cart, bool) {
+	for _, cart := range carts {
+		if cart.CustomerID == customerId && cart.Status == CartActive && !cart.Expired(at) {
+			cart.Items = append([]CartItem{}, cart.Items...)
+			return cart, true
+		}
+	}
+	return Cart{}, false
+}
+
+// setCartQuantity checks the book exists and has the stock for the new quantity of the item.
+func setCartQuantity(cart *Cart, index int, quantity int, books *TxTable[Book]) error {
+	book, ok := books.Get(cart.Items[index].BookID)
+	if !ok {
+		return errors.New("Book with ID " + strconv.Itoa(cart.Items[index].BookID) + " not found")
+	}
+	// the books not released yet are pre-ordered, they don't need stock
+	if quantity > book.Available() && book.Released(time.Now()) {
+		return errors.New("Not enough stock for book " + book.Title + ", " + strconv.Itoa(book.Available()) + " left")
+	}
+	cart.Items[index].Quantity = quantity
+	return nil
+}
+
+// priceCart prices the cart like CreateOrder would price its order, and lists in the problems of the
+// cart whatever would make the checkout fail.
+func (s *InMemoryCartStore) priceCart(tx *Transaction, customer Customer, cart *Cart) {
+	books := Table(tx, s.Books.repo)
+	cart.Problems = nil
+	draft := Order{
+		CustomerID:      customer.ID,
+		ShippingAddress: customer.Address,
+		CouponCodes:     cart.CouponCodes,
+		Currency:        cart.Currency,
+		ShippingMethod:  cart.ShippingMethod,
+		CreatedAt:       time.Now(),
+	}
+	var priced []int
+	for i, item := range cart.Items {
+		book, ok := books.Get(item.BookID)
+		if !ok {
+			cart.Items[i].Available, cart.Items[i].UnitPrice, cart.Items[i].LineTotal = 0, 0, 0
+			cart.Problems = append(cart.Problems, "Book with ID "+strconv.Itoa(item.BookID)+" no longer exists")
+			continue
+		}
+		cart.Items[i].Title = book.Title
+		cart.Items[i].Available = book.Available()
+		cart.Items[i].UnitPrice, cart.Items[i].LineTotal = 0, 0
+		if item.Quantity > book.Available() && book.Released(draft.CreatedAt) {
+			cart.Problems = append(cart.Problems, "Not enough stock for book "+book.Title+", "+strconv.Itoa(book.Available())+" left")
+		}
+		draft.Items = append(draft.Items, OrderItem{BookID: book.ID, Book: &book, Title: book.Title, Quantity: item.Quantity})
+		priced = append(priced, i)
+	}
+
+	cart.Pricing = PriceBreakdown{}
+	cart.Promotions = nil
+	if len(draft.Items) == 0 {
+		return
+	}
+	// the books are priced before the discounts, a coupon that can't be used still shows the item prices
+	err := s.Orders.Pricing.PriceOrder(&draft, s.Orders.promotionDiscount(tx, draft, 0))
+	for j, i := range priced {
+		cart.Items[i].UnitPrice = draft.Items[j].UnitPrice
+		cart.Items[i].LineTotal = draft.Items[j].LineTotal
+	}
+	if err != nil {
+		cart.Problems = append(cart.Problems, err.Error())
+		return
+	}
+	cart.Currency = draft.Currency
+	cart.CouponCodes = draft.CouponCodes
+	cart.Promotions = draft.Promotions
+	cart.Pricing = draft.Pricing
+}
+
+func (s *InMemoryCartStore) LoadCarts(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemoryCartStore) SaveCarts(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
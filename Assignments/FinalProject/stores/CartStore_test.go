@@ -0,0 +1,133 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCartCheckout(t *testing.T) {
+	ctx := context.Background()
+	stores, book, customer := newTestCatalog(t, 5)
+
+	if _, err := stores.Carts.CheckoutCart(ctx, customer.ID); !errors.Is(err, ErrCartNotFound) {
+		t.Fatalf("checkout without a cart: %v", err)
+	}
+	if _, err := stores.Carts.AddCartItem(ctx, customer.ID, CartItem{BookID: book.ID, Quantity: 6}); err == nil {
+		t.Fatal("more copies than in stock were added")
+	}
+	if _, err := stores.Carts.AddCartItem(ctx, customer.ID, CartItem{BookID: book.ID, Quantity: 2}); err != nil {
+		t.Fatal(err)
+	}
+	cart, err := stores.Carts.AddCartItem(ctx, customer.ID, CartItem{BookID: book.ID, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart.Items) != 1 || cart.Items[0].Quantity != 3 || cart.Pricing.Total != NewAmount(30) {
+		t.Fatalf("cart %+v", cart)
+	}
+
+	order, err := stores.Carts.CheckoutCart(ctx, customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.TotalPrice != NewAmount(30) || order.Items[0].Quantity != 3 {
+		t.Fatalf("order %+v", order)
+	}
+	stored, _ := stores.Books.GetBook(ctx, book.ID)
+	if stored.Available() != 2 {
+		t.Fatalf("%d books available, want 2", stored.Available())
+	}
+	closed, _ := stores.Carts.repo.Find(cart.ID)
+	if closed.Status != CartCheckedOut || closed.OrderID != order.ID {
+		t.Fatalf("cart is %s with order %d", closed.Status, closed.OrderID)
+	}
+	if _, err := stores.Carts.CheckoutCart(ctx, customer.ID); !errors.Is(err, ErrCartNotFound) {
+		t.Fatalf("a checked out cart was checked out again: %v", err)
+	}
+}
+
+func TestCartShowsWhatWouldFailTheCheckout(t *testing.T) {
+	ctx := context.Background()
+	stores, book, customer := newTestCatalog(t, 5)
+	if _, err := stores.Carts.AddCartItem(ctx, customer.ID, CartItem{BookID: book.ID, Quantity: 4}); err != nil {
+		t.Fatal(err)
+	}
+	// someone else buys the books meanwhile
+	if _, err := stores.Orders.CreateOrder(ctx, Order{CustomerID: customer.ID, Items: []OrderItem{{BookID: book.ID, Quantity: 3}}}); err != nil {
+		t.Fatal(err)
+	}
+	cart, err := stores.Carts.GetCart(ctx, customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart.Problems) != 1 || cart.Items[0].Available != 2 {
+		t.Fatalf("cart problems %v, available %d", cart.Problems, cart.Items[0].Available)
+	}
+	if _, err := stores.Carts.CheckoutCart(ctx, customer.ID); err == nil {
+		t.Fatal("a cart without the stock was checked out")
+	}
+	if _, err := stores.Carts.GetCart(ctx, customer.ID); err != nil {
+		t.Fatalf("the cart was lost by the failed checkout: %v", err)
+	}
+}
+
+func TestCartExpiry(t *testing.T) {
+	ctx := context.Background()
+	stores, book, customer := newTestCatalog(t, 5)
+	stores.Carts.TTL = -time.Second
+	first, err := stores.Carts.AddCartItem(ctx, customer.ID, CartItem{BookID: book.ID, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Carts.GetCart(ctx, customer.ID); !errors.Is(err, ErrCartNotFound) {
+		t.Fatalf("an expired cart was returned: %v", err)
+	}
+
+	// the customer starts again with a new cart, the old one is kept as expired
+	stores.Carts.TTL = time.Hour
+	second, err := stores.Carts.AddCartItem(ctx, customer.ID, CartItem{BookID: book.ID, Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID == first.ID || second.Items[0].Quantity != 2 {
+		t.Fatalf("the expired cart was reused: %+v", second)
+	}
+	if old, _ := stores.Carts.repo.Find(first.ID); old.Status != CartExpired {
+		t.Fatalf("old cart is %s", old.Status)
+	}
+
+	abandoned, err := stores.Carts.ListAbandonedCarts(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(abandoned) != 2 || abandoned[0].CustomerEmail != customer.Email {
+		t.Fatalf("abandoned carts %+v", abandoned)
+	}
+}
+
+func TestExpireCarts(t *testing.T) {
+	ctx := context.Background()
+	stores, book, customer := newTestCatalog(t, 5)
+	other, err := stores.Customers.CreateCustomer(ctx, Customer{Name: "Eve", Email: "eve@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores.Carts.TTL = -time.Second
+	if _, err := stores.Carts.AddCartItem(ctx, customer.ID, CartItem{BookID: book.ID, Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	stores.Carts.TTL = time.Hour
+	if _, err := stores.Carts.AddCartItem(ctx, other.ID, CartItem{BookID: book.ID, Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	expired, err := stores.Carts.ExpireCarts(ctx)
+	if err != nil || expired != 1 {
+		t.Fatalf("expired %d carts, %v, want 1", expired, err)
+	}
+	if expired, _ := stores.Carts.ExpireCarts(ctx); expired != 0 {
+		t.Fatalf("expired %d carts again", expired)
+	}
+}
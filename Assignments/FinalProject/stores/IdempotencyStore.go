@@ -0,0 +1,135 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// ----------------------------------------------Definition of IdempotencyMethods--------------------------------
+// The responses are stored once the request is done. The keys of the requests still running are only
+// held in memory, a request cut by a restart left nothing behind and can simply be sent again.
+type InMemoryIdempotencyStore struct {
+	repo    *Repository[IdempotencyRecord]
+	TTL     time.Duration
+	mu      sync.Mutex
+	pending map[string]string
+}
+
+type IdempotencyStore interface {
+	BeginRequest(ctx context.Context, key string, method string, path string, fingerprint string) (IdempotencyRecord, bool, error)
+	FinishRequest(ctx context.Context, record IdempotencyRecord) error
+	PurgeExpiredKeys(ctx context.Context) (int, error)
+	LoadIdempotencyKeys(ctx context.Context) error
+	SaveIdempotencyKeys(ctx context.Context) error
+}
+
+func NewInMemoryIdempotencyStore(journal *Journal, driver StorageDriver[IdempotencyRecord]) *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		repo:    NewRepository[IdempotencyRecord](idempotencyEntity, idempotencyStoreRank, journal, driver),
+		TTL:     DefaultIdempotencyTTL,
+		pending: make(map[string]string),
+	}
+}
+
+// BeginRequest returns the stored response of the key with true when the request was already served.
+// Otherwise the key is held until FinishRequest, a second request with the same key gets ErrIdempotencyKeyInUse
+// meanwhile. A key already used for another request gets ErrIdempotencyKeyMismatch.
+func (s *InMemoryIdempotencyStore) BeginRequest(ctx context.Context, key string, method string, path string, fingerprint string) (IdempotencyRecord, bool, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during idempotency key lookup", key)
+		return IdempotencyRecord{}, false, ctx.Err()
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		now := time.Now()
+		for _, record := range s.repo.Filter(func(record IdempotencyRecord) bool { return record.Key == key }) {
+			if record.Expired(now) {
+				if err := s.repo.Delete(ctx, record.ID); err != nil {
+					return IdempotencyRecord{}, false, err
+				}
+				log.Printf("Idempotency key %s expired, it can be used again\n", key)
+				continue
+			}
+			if record.Fingerprint != fingerprint {
+				return IdempotencyRecord{}, false, ErrIdempotencyKeyMismatch
+			}
+			log.Printf("Replaying the response of idempotency key %s\n", key)
+			return record, true, nil
+		}
+
+		if held, ok := s.pending[key]; ok {
+			if held != fingerprint {
+				return IdempotencyRecord{}, false, ErrIdempotencyKeyMismatch
+			}
+			return IdempotencyRecord{}, false, ErrIdempotencyKeyInUse
+		}
+		s.pending[key] = fingerprint
+		return IdempotencyRecord{Key: key, Method: method, Path: path, Fingerprint: fingerprint, CreatedAt: now}, false, nil
+	}
+}
+
+// FinishRequest releases the key and stores the response for the TTL of the store. Server errors are not
+// stored, the request didn't go through and the client may retry it with the same key.
+func (s *InMemoryIdempotencyStore) FinishRequest(ctx context.Context, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, record.Key)
+
+	if record.Status == 0 || record.Status >= 500 {
+		log.Printf("Idempotency key %s released without a response to keep\n", record.Key)
+		return nil
+	}
+	record.ExpiresAt = record.CreatedAt.Add(s.TTL)
+	if _, err := s.repo.Insert(ctx, record); err != nil {
+		log.Printf("Failed to store the response of idempotency key %s: %v\n", record.Key, err)
+		return err
+	}
+	return nil
+}
+
+// PurgeExpiredKeys deletes the responses whose TTL is over in one transaction.
+func (s *InMemoryIdempotencyStore) PurgeExpiredKeys(ctx context.Context) (int, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during idempotency keys purge")
+		return 0, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, WriteLock(s.repo))
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+		records := Table(tx, s.repo)
+
+		now := time.Now()
+		purged := 0
+		for _, record := range records.All() {
+			if !record.Expired(now) {
+				continue
+			}
+			if err := records.Delete(record.ID); err != nil {
+				return 0, err
+			}
+			purged++
+		}
+		if purged == 0 {
+			return 0, nil
+		}
+		return purged, tx.Commit()
+	}
+}
+
+func (s *InMemoryIdempotencyStore) LoadIdempotencyKeys(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemoryIdempotencyStore) SaveIdempotencyKeys(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
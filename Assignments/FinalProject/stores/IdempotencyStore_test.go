@@ -0,0 +1,82 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		firstStatus int
+		// finished is false while the first request is still running
+		finished    bool
+		ttl         time.Duration
+		fingerprint string
+		wantErr     error
+		wantReplay  bool
+	}{
+		{"retry replays the response", 201, true, time.Hour, "same", nil, true},
+		{"same key with another body", 201, true, time.Hour, "other", ErrIdempotencyKeyMismatch, false},
+		{"retry while the first request runs", 0, false, time.Hour, "same", ErrIdempotencyKeyInUse, false},
+		{"another body while the first request runs", 0, false, time.Hour, "other", ErrIdempotencyKeyMismatch, false},
+		{"client errors are kept too", 400, true, time.Hour, "same", nil, true},
+		{"server errors aren't kept", 500, true, time.Hour, "same", nil, false},
+		{"expired key can be used again", 201, true, -time.Second, "other", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newTestStores(t).Idempotency
+			store.TTL = tt.ttl
+
+			record, replayed, err := store.BeginRequest(ctx, "key-1", "POST", "/orders", "same")
+			if err != nil || replayed {
+				t.Fatalf("first request: replayed %v, %v", replayed, err)
+			}
+			if tt.finished {
+				record.Status, record.Body = tt.firstStatus, `{"id": 1}`
+				if err := store.FinishRequest(ctx, record); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			again, replayed, err := store.BeginRequest(ctx, "key-1", "POST", "/orders", tt.fingerprint)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("retry error = %v, want %v", err, tt.wantErr)
+			}
+			if replayed != tt.wantReplay {
+				t.Fatalf("retry replayed = %v, want %v", replayed, tt.wantReplay)
+			}
+			if replayed && (again.Status != tt.firstStatus || again.Body != `{"id": 1}`) {
+				t.Fatalf("replayed %d %s", again.Status, again.Body)
+			}
+		})
+	}
+}
+
+func TestPurgeExpiredIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStores(t).Idempotency
+	for i, ttl := range []time.Duration{-time.Second, -time.Second, time.Hour} {
+		store.TTL = ttl
+		record, _, err := store.BeginRequest(ctx, "key-"+string(rune('a'+i)), "POST", "/books", "body")
+		if err != nil {
+			t.Fatal(err)
+		}
+		record.Status = 201
+		if err := store.FinishRequest(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	purged, err := store.PurgeExpiredKeys(ctx)
+	if err != nil || purged != 2 {
+		t.Fatalf("purged %d, %v, want 2", purged, err)
+	}
+	if _, replayed, _ := store.BeginRequest(ctx, "key-c", "POST", "/books", "body"); !replayed {
+		t.Fatal("a key still valid was purged")
+	}
+}
@@ -0,0 +1,345 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	. "FinalProject/pricing"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	actorSystem = "system"
+	actorAPI    = "api"
+)
+
+// ----------------------------------------------Definition of InventoryMethods--------------------------------
+// The ledger only grows. Every stock change goes through inventoryTables.move in the transaction that
+// makes it, so the book and its movements can't disagree unless the data was edited by hand.
+type InMemoryInventoryStore struct {
+	repo         *Repository[StockMovement]
+	Books        *InMemoryBookStore
+	Orders       *InMemoryOrderStore
+	Reservations *InMemoryReservationStore
+	// Currency of the costs and prices of the valuation, the base one.
+	Currency string
+}
+
+type InventoryStore interface {
+	GetStockLedger(ctx context.Context, bookId int) (StockLedger, error)
+	RecordStockMovement(ctx context.Context, bookId int, movement StockMovement) (StockMovement, error)
+	ValueInventory(ctx context.Context) (InventoryValuation, error)
+	OpenBalances(ctx context.Context) (int, error)
+	LoadStockMovements(ctx context.Context) error
+	SaveStockMovements(ctx context.Context) error
+}
+
+func NewInMemoryInventoryStore(journal *Journal, driver StorageDriver[StockMovement]) *InMemoryInventoryStore {
+	return &InMemoryInventoryStore{
+		repo:     NewRepository[StockMovement](movementEntity, inventoryStoreRank, journal, driver),
+		Currency: DefaultCurrency,
+	}
+}
+
+func (s *InMemoryInventoryStore) GetStockLedger(ctx context.Context, bookId int) (StockLedger, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during stock ledger retrieval of book", bookId)
+		return StockLedger{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Books.repo), ReadLock(s.repo))
+		if err != nil {
+			return StockLedger{}, err
+		}
+		defer tx.Rollback()
+
+		book, ok := Table(tx, s.Books.repo).Get(bookId)
+		if !ok {
+			return StockLedger{}, fmt.Errorf("%w: book with ID %d", ErrRecordNotFound, bookId)
+		}
+		ledger := StockLedger{BookID: book.ID, Title: book.Title, Stock: book.Stock, Reserved: book.Reserved, Movements: []StockMovement{}}
+		for _, movement := range Table(tx, s.repo).All() {
+			if movement.BookID != book.ID {
+				continue
+			}
+			ledger.LedgerStock += movement.Quantity
+			ledger.LedgerReserved += movement.Reserved
+			ledger.Movements = append(ledger.Movements, movement)
+		}
+		ledger.Reconciled = ledger.LedgerStock == book.Stock && ledger.LedgerReserved == book.Reserved
+		return ledger, nil
+	}
+}
+
+// RecordStockMovement records the receipts and the adjustments made by hand. The reservations, sales
+// and returns are only recorded by the orders. The copies added go to the backorders first.
+func (s *InMemoryInventoryStore) RecordStockMovement(ctx context.Context, bookId int, movement StockMovement) (StockMovement, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during stock movement of book", bookId)
+		return StockMovement{}, ctx.Err()
+	default:
+		switch movement.Kind {
+		case MovementReceipt:
+			if movement.Quantity <= 0 {
+				return StockMovement{}, fmt.Errorf("%w: a receipt needs a positive quantity", ErrInvalidMovement)
+			}
+			if movement.UnitCost < 0 {
+				return StockMovement{}, fmt.Errorf("%w: the unit cost can't be negative", ErrInvalidMovement)
+			}
+		case MovementAdjustment:
+			if movement.Quantity == 0 {
+				return StockMovement{}, fmt.Errorf("%w: an adjustment needs a quantity", ErrInvalidMovement)
+			}
+			if movement.Reason == "" {
+				return StockMovement{}, fmt.Errorf("%w: an adjustment needs a reason", ErrInvalidMovement)
+			}
+			movement.UnitCost = 0
+		default:
+			return StockMovement{}, fmt.Errorf("%w: only receipts and adjustments can be recorded, not %q", ErrInvalidMovement, movement.Kind)
+		}
+		movement.Reserved, movement.OrderID = 0, 0
+		movement.CreatedAt = time.Time{}
+		if movement.Actor == "" {
+			movement.Actor = actorAPI
+		}
+
+		tx, err := BeginTransaction(ctx, WriteLock(s.Books.repo), WriteLock(s.Orders.repo), WriteLock(s.Reservations.repo), WriteLock(s.repo))
+		if err != nil {
+			return StockMovement{}, err
+		}
+		defer tx.Rollback()
+		inventory := s.backorderTables(tx)
+
+		book, ok := inventory.books.Get(bookId)
+		if !ok {
+			return StockMovement{}, fmt.Errorf("%w: book with ID %d", ErrRecordNotFound, bookId)
+		}
+		if book.Stock+movement.Quantity < 0 {
+			return StockMovement{}, fmt.Errorf("%w: book %s only has %d copies in stock", ErrInvalidMovement, book.Title, book.Stock)
+		}
+		if book.Stock+movement.Quantity < book.Reserved {
+			return StockMovement{}, fmt.Errorf("%w: book %s has %d copies reserved by unpaid orders", ErrStockBelowReserved, book.Title, book.Reserved)
+		}
+		movement, err = inventory.move(book, movement)
+		if err != nil {
+			return StockMovement{}, err
+		}
+		if movement.Quantity > 0 {
+			if _, err := inventory.allocateBackorders(bookId, movement.CreatedAt, s.Reservations.TTL); err != nil {
+				return StockMovement{}, err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return StockMovement{}, err
+		}
+		log.Printf("Stock movement %d recorded, book %d %s %+d\n", movement.ID, bookId, movement.Kind, movement.Quantity)
+		return movement, nil
+	}
+}
+
+// ValueInventory values the stock on hand of every book at the moving average cost of its receipts.
+// The copies received without a cost, like the opening balances, are valued at the cost of the others.
+func (s *InMemoryInventoryStore) ValueInventory(ctx context.Context) (InventoryValuation, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during inventory valuation")
+		return InventoryValuation{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Books.repo), ReadLock(s.repo))
+		if err != nil {
+			return InventoryValuation{}, err
+		}
+		defer tx.Rollback()
+
+		type bookLedger struct {
+			stock, reserved int
+			unitCost        float64
+		}
+		ledgers := make(map[int]*bookLedger)
+		for _, movement := range Table(tx, s.repo).All() {
+			ledger, ok := ledgers[movement.BookID]
+			if !ok {
+				ledger = &bookLedger{}
+				ledgers[movement.BookID] = ledger
+			}
+			if movement.Kind == MovementReceipt && movement.UnitCost > 0 && movement.Quantity > 0 {
+				onHand := ledger.stock
+				if onHand < 0 || ledger.unitCost == 0 {
+					onHand = 0
+				}
+				ledger.unitCost = (float64(onHand)*ledger.unitCost + float64(movement.Quantity)*movement.UnitCost) / float64(onHand+movement.Quantity)
+			}
+			ledger.stock += movement.Quantity
+			ledger.reserved += movement.Reserved
+		}
+
+		valuation := InventoryValuation{Timestamp: time.Now(), Currency: s.Currency, Books: []BookValuation{}}
+		for _, book := range Table(tx, s.Books.repo).All() {
+			ledger, ok := ledgers[book.ID]
+			if !ok {
+				ledger = &bookLedger{}
+			}
+			line := BookValuation{
+				BookID:      book.ID,
+				Title:       book.Title,
+				Stock:       book.Stock,
+				Reserved:    book.Reserved,
+				UnitCost:    RoundPrice(ledger.unitCost),
+				CostValue:   RoundPrice(float64(book.Stock) * ledger.unitCost),
+				RetailValue: book.Price.Times(book.Stock).Float(),
+				Reconciled:  ledger.stock == book.Stock && ledger.reserved == book.Reserved,
+			}
+			if !line.Reconciled {
+				log.Printf("Book %d has %d in stock but its ledger adds up to %d\n", book.ID, book.Stock, ledger.stock)
+			}
+			valuation.TotalUnits += line.Stock
+			valuation.TotalCostValue += line.CostValue
+			valuation.TotalRetailValue += line.RetailValue
+			valuation.Books = append(valuation.Books, line)
+		}
+		valuation.TotalCostValue = RoundPrice(valuation.TotalCostValue)
+		valuation.TotalRetailValue = RoundPrice(valuation.TotalRetailValue)
+		return valuation, nil
+	}
+}
+
+// OpenBalances starts the ledger of the books that have none, with their stock and reservations as
+// they are. It returns the number of books opened.
+func (s *InMemoryInventoryStore) OpenBalances(ctx context.Context) (int, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during the opening of the stock ledger")
+		return 0, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, WriteLock(s.Books.repo), WriteLock(s.repo))
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+		inventory := inventoryTables{books: Table(tx, s.Books.repo), movements: Table(tx, s.repo)}
+
+		hasLedger := make(map[int]bool)
+		for _, movement := range inventory.movements.All() {
+			hasLedger[movement.BookID] = true
+		}
+		opened := 0
+		for _, book := range inventory.books.All() {
+			if hasLedger[book.ID] || (book.Stock == 0 && book.Reserved == 0) {
+				continue
+			}
+			quantity, reserved := book.Stock, book.Reserved
+			book.Stock, book.Reserved = 0, 0
+			if _, err := inventory.move(book, StockMovement{Kind: MovementAdjustment, Quantity: quantity, Reserved: reserved, Reason: "opening balance"}); err != nil {
+				return 0, err
+			}
+			opened++
+		}
+		if opened == 0 {
+			return 0, nil
+		}
+		return opened, tx.Commit()
+	}
+}
+
+// backorderTables are the tables of a receipt, which allocates the backorders of the books it brings in.
+// The transaction write locks the books, the orders, the reservations and the movements.
+func (s *InMemoryInventoryStore) backorderTables(tx *Transaction) inventoryTables {
+	return inventoryTables{books: Table(tx, s.Books.repo), movements: Table(tx, s.repo), reservations: Table(tx, s.Reservations.repo), orders: Table(tx, s.Orders.repo)}
+}
+
+func (s *InMemoryInventoryStore) LoadStockMovements(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemoryInventoryStore) SaveStockMovements(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
+
+// inventoryTables are the tables a stock change writes to in a transaction. The reservations are
+// only there for the order changes, the orders for the allocation of the backorders.
+type inventoryTables struct {
+	books        *TxTable[Book]
+	movements    *TxTable[StockMovement]
+	reservations *TxTable[StockReservation]
+	orders       *TxTable[Order]
+}
+
+// move applies the movement to the book and records it in the ledger.
+func (t inventoryTables) move(book Book, movement StockMovement) (StockMovement, error) {
+	// a release never takes more than what is reserved
+	if book.Reserved+movement.Reserved < 0 {
+		movement.Reserved = -book.Reserved
+	}
+	switch {
+	case movement.Kind == MovementReceipt && movement.UnitCost > 0 && movement.Quantity > 0:
+		// the same moving average as the valuation, the copies without a cost don't dilute it
+		onHand := book.Stock
+		if onHand < 0 || book.UnitCost == 0 {
+			onHand = 0
+		}
+		book.UnitCost = NewAmount((float64(onHand)*book.UnitCost.Float() + float64(movement.Quantity)*movement.UnitCost) / float64(onHand+movement.Quantity))
+	case movement.Kind == MovementSale:
+		movement.UnitCost = book.UnitCost.Float()
+	}
+	book.Stock += movement.Quantity
+	book.Reserved += movement.Reserved
+	if err := t.books.Put(book.ID, book); err != nil {
+		return StockMovement{}, err
+	}
+
+	id, err := t.movements.NextID()
+	if err != nil {
+		return StockMovement{}, err
+	}
+	movement.ID = id
+	movement.BookID = book.ID
+	movement.StockAfter = book.Stock
+	movement.ReservedAfter = book.Reserved
+	if movement.Actor == "" {
+		movement.Actor = actorSystem
+	}
+	if movement.CreatedAt.IsZero() {
+		movement.CreatedAt = time.Now()
+	}
+	return movement, t.movements.Put(id, movement)
+}
+
+// returnStock puts the books of an order back on the shelves, its backorders never left them.
+func (t inventoryTables) returnStock(order Order, reason string) error {
+	for _, item := range order.Items {
+		if item.Allocated() == 0 {
+			continue
+		}
+		book, ok := t.books.Get(item.BookID)
+		if !ok {
+			log.Printf("Book with ID %d no longer exists, its stock can't be given back\n", item.BookID)
+			continue
+		}
+		if _, err := t.move(book, StockMovement{Kind: MovementReturn, Quantity: item.Allocated(), OrderID: order.ID, Reason: reason}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adjustStock sets the stock of a book to a new count, the difference is recorded as an adjustment.
+func (t inventoryTables) adjustStock(book Book, stock int, reason string) (Book, error) {
+	if stock < 0 {
+		return Book{}, errors.New("The stock of book " + book.Title + " can't be negative")
+	}
+	if stock < book.Reserved {
+		return Book{}, fmt.Errorf("%w: book %s has %d copies reserved by unpaid orders", ErrStockBelowReserved, book.Title, book.Reserved)
+	}
+	if stock == book.Stock {
+		return book, t.books.Put(book.ID, book)
+	}
+	movement, err := t.move(book, StockMovement{Kind: MovementAdjustment, Quantity: stock - book.Stock, Reason: reason, Actor: actorAPI})
+	if err != nil {
+		return Book{}, err
+	}
+	book.Stock, book.Reserved = movement.StockAfter, movement.ReservedAfter
+	return book, nil
+}
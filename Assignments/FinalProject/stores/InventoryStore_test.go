@@ -0,0 +1,114 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLedgerReconcilesWithTheBook(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []OrderStatus
+		wantKinds []MovementKind
+	}{
+		{"pending order", nil, []MovementKind{MovementReceipt, MovementReservation}},
+		{"cancelled order", []OrderStatus{OrderCancelled}, []MovementKind{MovementReceipt, MovementReservation, MovementRelease}},
+		{"paid order", []OrderStatus{OrderPaid}, []MovementKind{MovementReceipt, MovementReservation, MovementSale}},
+		{"refund before shipping", []OrderStatus{OrderPaid, OrderRefunded}, []MovementKind{MovementReceipt, MovementReservation, MovementSale, MovementReturn}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, order := newTestOrder(t, 5, 2)
+			for _, status := range tt.steps {
+				if _, err := stores.Orders.TransitionOrder(ctx, order.ID, status); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ledger, err := stores.Inventory.GetStockLedger(ctx, book.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ledger.Reconciled || ledger.LedgerStock != ledger.Stock || ledger.LedgerReserved != ledger.Reserved {
+				t.Fatalf("ledger %d/%d, book %d/%d", ledger.LedgerStock, ledger.LedgerReserved, ledger.Stock, ledger.Reserved)
+			}
+			if len(ledger.Movements) != len(tt.wantKinds) {
+				t.Fatalf("%d movements, want %d", len(ledger.Movements), len(tt.wantKinds))
+			}
+			for i, kind := range tt.wantKinds {
+				if ledger.Movements[i].Kind != kind {
+					t.Fatalf("movement %d is a %s, want a %s", i, ledger.Movements[i].Kind, kind)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordStockMovement(t *testing.T) {
+	tests := []struct {
+		name      string
+		movement  StockMovement
+		wantErr   error
+		wantStock int
+	}{
+		{"receipt", StockMovement{Kind: MovementReceipt, Quantity: 3, UnitCost: 4}, nil, 8},
+		{"adjustment down", StockMovement{Kind: MovementAdjustment, Quantity: -1, Reason: "damaged"}, nil, 4},
+		{"receipt of nothing", StockMovement{Kind: MovementReceipt}, ErrInvalidMovement, 5},
+		{"adjustment without a reason", StockMovement{Kind: MovementAdjustment, Quantity: -1}, ErrInvalidMovement, 5},
+		{"sale by hand", StockMovement{Kind: MovementSale, Quantity: -1}, ErrInvalidMovement, 5},
+		{"below the reserved copies", StockMovement{Kind: MovementAdjustment, Quantity: -4, Reason: "lost"}, ErrStockBelowReserved, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, _ := newTestOrder(t, 5, 2)
+			if _, err := stores.Inventory.RecordStockMovement(ctx, book.ID, tt.movement); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RecordStockMovement error = %v, want %v", err, tt.wantErr)
+			}
+			ledger, _ := stores.Inventory.GetStockLedger(ctx, book.ID)
+			if ledger.Stock != tt.wantStock || !ledger.Reconciled {
+				t.Fatalf("stock %d, reconciled %v, want %d", ledger.Stock, ledger.Reconciled, tt.wantStock)
+			}
+		})
+	}
+}
+
+func TestValueInventoryAtTheAverageCost(t *testing.T) {
+	ctx := context.Background()
+	stores, book, _ := newTestCatalog(t, 0)
+	for _, receipt := range []StockMovement{{Kind: MovementReceipt, Quantity: 2, UnitCost: 4}, {Kind: MovementReceipt, Quantity: 2, UnitCost: 6}} {
+		if _, err := stores.Inventory.RecordStockMovement(ctx, book.ID, receipt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	valuation, err := stores.Inventory.ValueInventory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := valuation.Books[0]
+	if line.UnitCost != 5 || line.CostValue != 20 || line.RetailValue != 40 || valuation.TotalUnits != 4 || !line.Reconciled {
+		t.Fatalf("valuation %+v", valuation)
+	}
+}
+
+func TestOpenBalances(t *testing.T) {
+	ctx := context.Background()
+	stores, book, _ := newTestCatalog(t, 5)
+	if opened, err := stores.Inventory.OpenBalances(ctx); err != nil || opened != 0 {
+		t.Fatalf("opened %d ledgers, %v, the book already has one", opened, err)
+	}
+
+	// books stocked before the ledger existed have no movements
+	stores.Inventory = NewInMemoryInventoryStore(nil, MemoryStorageDrivers().StockMovements)
+	stores.Inventory.Books = stores.Books
+	if opened, err := stores.Inventory.OpenBalances(ctx); err != nil || opened != 1 {
+		t.Fatalf("opened %d ledgers, %v, want 1", opened, err)
+	}
+	ledger, _ := stores.Inventory.GetStockLedger(ctx, book.ID)
+	if !ledger.Reconciled || ledger.LedgerStock != 5 {
+		t.Fatalf("opened ledger adds up to %d", ledger.LedgerStock)
+	}
+}
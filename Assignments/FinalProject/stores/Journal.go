@@ -0,0 +1,298 @@
+package stores
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------Definition of the Journal--------------------------------
+// Every mutation of a store is appended to the journal before it is applied in memory. One line
+// holds one record, and a transaction writes all of its changes in a single record, so replaying
+// the journal never applies half of a transaction.
+const (
+	authorEntity    = "authors"
+	bookEntity      = "books"
+	customerEntity  = "customers"
+	orderEntity     = "orders"
+	promotionEntity = "promotions"
+	shipmentEntity  = "shipments"
+	paymentEntity   = "payments"
+
+	cartEntity        = "carts"
+	idempotencyEntity = "idempotency_keys"
+	reservationEntity = "reservations"
+	movementEntity    = "stock_movements"
+	alertEntity       = "stock_alerts"
+	supplierEntity    = "suppliers"
+	purchaseEntity    = "purchase_orders"
+	returnEntity      = "returns"
+
+	journalPut    = "put"
+	journalDelete = "delete"
+)
+
+type JournalChange struct {
+	Entity  string          `json:"entity"`
+	Op      string          `json:"op"`
+	ID      int             `json:"id"`
+	NextID  int             `json:"next_id"`
+	Version int             `json:"version,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type JournalRecord struct {
+	Seq     int64           `json:"seq"`
+	Time    time.Time       `json:"time"`
+	Changes []JournalChange `json:"changes,omitempty"`
+}
+
+type Journal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	seq  int64
+}
+
+func OpenJournal(filePath string) (*Journal, error) {
+	dir := "database"
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.Printf("Failed to create directory %s: %v\n", dir, err)
+		return nil, err
+	}
+	fullPath := filepath.Join(dir, filePath)
+
+	j := &Journal{path: fullPath}
+	validSize, err := j.scan(func(record JournalRecord) error {
+		j.seq = record.Seq
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read journal %s: %v\n", fullPath, err)
+		return nil, err
+	}
+
+	file, err := os.OpenFile(fullPath, os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		log.Printf("Failed to open journal %s: %v\n", fullPath, err)
+		return nil, err
+	}
+	// a crash in the middle of an append leaves a torn last line, it is cut off so new records start cleanly
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	j.file = file
+	log.Printf("Journal opened at %s, last sequence %d\n", fullPath, j.seq)
+	return j, nil
+}
+
+func (j *Journal) LastSeq() int64 {
+	if j == nil {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seq
+}
+
+func (j *Journal) Append(changes []JournalChange) (int64, error) {
+	if j == nil || len(changes) == 0 {
+		return 0, nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	record := JournalRecord{Seq: j.seq + 1, Time: time.Now(), Changes: changes}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return 0, err
+	}
+	line = append(line, '\n')
+	if _, err := j.file.Write(line); err != nil {
+		log.Printf("Failed to append to journal %s: %v\n", j.path, err)
+		return 0, err
+	}
+	if err := j.file.Sync(); err != nil {
+		log.Printf("Failed to sync journal %s: %v\n", j.path, err)
+		return 0, err
+	}
+	j.seq = record.Seq
+	return record.Seq, nil
+}
+
+func putChange(entity string, id int, nextID int, item interface{}) (JournalChange, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return JournalChange{}, err
+	}
+	return JournalChange{Entity: entity, Op: journalPut, ID: id, NextID: nextID, Version: CurrentSchemaVersion(entity), Data: data}, nil
+}
+
+func deleteChange(entity string, id int, nextID int) JournalChange {
+	return JournalChange{Entity: entity, Op: journalDelete, ID: id, NextID: nextID}
+}
+
+// Compact drops every record already covered by a snapshot. The first line of the new file
+// is an empty record carrying the current sequence, so numbering continues after a restart.
+func (j *Journal) Compact(upToSeq int64) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	if err := encoder.Encode(JournalRecord{Seq: j.seq, Time: time.Now()}); err != nil {
+		return err
+	}
+	kept := 0
+	if _, err := j.scan(func(record JournalRecord) error {
+		if record.Seq > upToSeq && len(record.Changes) > 0 {
+			kept++
+			return encoder.Encode(record)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := j.rewrite(buf.Bytes()); err != nil {
+		log.Printf("Failed to compact journal %s: %v\n", j.path, err)
+		return err
+	}
+	log.Printf("Journal compacted up to sequence %d, %d records kept\n", upToSeq, kept)
+	return nil
+}
+
+func (j *Journal) rewrite(content []byte) error {
+	tmpPath := j.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return err
+	}
+	// the rename only survives a crash once the directory is synced
+	if err := syncDir(filepath.Dir(j.path)); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	j.file.Close()
+	j.file = file
+	return nil
+}
+
+// syncDir makes the renames done in dir durable, a filesystem that can't sync a directory is only logged.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		log.Printf("Failed to sync directory %s: %v\n", dir, err)
+	}
+	return nil
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// Replay hands every change of the given entity written after afterSeq to apply, oldest first.
+func (j *Journal) Replay(entity string, afterSeq int64, apply func(seq int64, change JournalChange) error) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err := j.scan(func(record JournalRecord) error {
+		if record.Seq <= afterSeq {
+			return nil
+		}
+		for _, change := range record.Changes {
+			if change.Entity == entity {
+				if err := apply(record.Seq, change); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// scan reads the journal file record by record and returns the size of the valid part of the file.
+func (j *Journal) scan(fn func(record JournalRecord) error) (int64, error) {
+	file, err := os.Open(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				log.Printf("Ignoring incomplete record at the end of journal %s\n", j.path)
+			}
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+
+		var record JournalRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				log.Printf("Ignoring corrupted record at the end of journal %s\n", j.path)
+				return offset, nil
+			}
+			return offset, errors.New("journal " + j.path + " is corrupted: " + err.Error())
+		}
+		if err := fn(record); err != nil {
+			return offset, err
+		}
+		offset += int64(len(line))
+	}
+}
@@ -0,0 +1,196 @@
+package stores
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// inTempDir runs the test from an empty directory, the journal lives in its database directory.
+func inTempDir(t *testing.T) {
+	t.Helper()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+func openTestJournal(t *testing.T) *Journal {
+	t.Helper()
+	journal, err := OpenJournal("journal.log")
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	t.Cleanup(func() { journal.Close() })
+	return journal
+}
+
+func appendChanges(t *testing.T, journal *Journal, changes ...JournalChange) int64 {
+	t.Helper()
+	seq, err := journal.Append(changes)
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	return seq
+}
+
+func replayed(t *testing.T, journal *Journal, entity string, afterSeq int64) []int {
+	t.Helper()
+	var ids []int
+	if err := journal.Replay(entity, afterSeq, func(seq int64, change JournalChange) error {
+		ids = append(ids, change.ID)
+		return nil
+	}); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	return ids
+}
+
+func sameIDs(got, want []int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestJournalReplay(t *testing.T) {
+	inTempDir(t)
+	journal := openTestJournal(t)
+	appendChanges(t, journal, deleteChange(authorEntity, 1, 2))
+	appendChanges(t, journal, deleteChange(bookEntity, 7, 8), deleteChange(authorEntity, 2, 3))
+	appendChanges(t, journal, deleteChange(authorEntity, 3, 4))
+
+	tests := []struct {
+		name     string
+		entity   string
+		afterSeq int64
+		want     []int
+	}{
+		{"everything", authorEntity, 0, []int{1, 2, 3}},
+		{"after a sequence", authorEntity, 1, []int{2, 3}},
+		{"after the last sequence", authorEntity, 3, nil},
+		{"other entity of the same record", bookEntity, 0, []int{7}},
+		{"entity never written", orderEntity, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replayed(t, journal, tt.entity, tt.afterSeq); !sameIDs(got, tt.want) {
+				t.Fatalf("replayed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJournalReopenKeepsSequence(t *testing.T) {
+	inTempDir(t)
+	journal := openTestJournal(t)
+	appendChanges(t, journal, deleteChange(authorEntity, 1, 2))
+	appendChanges(t, journal, deleteChange(authorEntity, 2, 3))
+	journal.Close()
+
+	journal = openTestJournal(t)
+	if seq := journal.LastSeq(); seq != 2 {
+		t.Fatalf("LastSeq after reopen = %d, want 2", seq)
+	}
+	if seq := appendChanges(t, journal, deleteChange(authorEntity, 3, 4)); seq != 3 {
+		t.Fatalf("next sequence = %d, want 3", seq)
+	}
+}
+
+func TestJournalTruncatesTornRecord(t *testing.T) {
+	tests := []struct {
+		name string
+		tail string
+	}{
+		{"half written line", `{"seq":3,"changes":[{"entity":"authors","op":"del`},
+		{"corrupted last line", "not json at all\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inTempDir(t)
+			journal := openTestJournal(t)
+			appendChanges(t, journal, deleteChange(authorEntity, 1, 2))
+			appendChanges(t, journal, deleteChange(authorEntity, 2, 3))
+			journal.Close()
+
+			path := filepath.Join("database", "journal.log")
+			valid, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, append(append([]byte(nil), valid...), tt.tail...), 0666); err != nil {
+				t.Fatal(err)
+			}
+
+			journal = openTestJournal(t)
+			if got := replayed(t, journal, authorEntity, 0); !sameIDs(got, []int{1, 2}) {
+				t.Fatalf("replayed %v, want [1 2]", got)
+			}
+			if seq := appendChanges(t, journal, deleteChange(authorEntity, 3, 4)); seq != 3 {
+				t.Fatalf("next sequence = %d, want 3", seq)
+			}
+			if got := replayed(t, journal, authorEntity, 0); !sameIDs(got, []int{1, 2, 3}) {
+				t.Fatalf("replayed after the append %v, want [1 2 3]", got)
+			}
+		})
+	}
+}
+
+func TestJournalRejectsCorruptionBeforeTheEnd(t *testing.T) {
+	inTempDir(t)
+	journal := openTestJournal(t)
+	appendChanges(t, journal, deleteChange(authorEntity, 1, 2))
+	journal.Close()
+
+	path := filepath.Join("database", "journal.log")
+	valid, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := append([]byte("garbage\n"), valid...)
+	if err := os.WriteFile(path, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if journal, err := OpenJournal("journal.log"); err == nil {
+		journal.Close()
+		t.Fatal("a journal corrupted before its last record was opened")
+	}
+}
+
+func TestJournalCompact(t *testing.T) {
+	inTempDir(t)
+	journal := openTestJournal(t)
+	for id := 1; id <= 4; id++ {
+		appendChanges(t, journal, deleteChange(authorEntity, id, id+1))
+	}
+	if err := journal.Compact(2); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if got := replayed(t, journal, authorEntity, 0); !sameIDs(got, []int{3, 4}) {
+		t.Fatalf("replayed after compaction %v, want [3 4]", got)
+	}
+	if seq := appendChanges(t, journal, deleteChange(authorEntity, 5, 6)); seq != 5 {
+		t.Fatalf("sequence after compaction = %d, want 5", seq)
+	}
+
+	if err := journal.Compact(journal.LastSeq()); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	journal.Close()
+	journal = openTestJournal(t)
+	if seq := journal.LastSeq(); seq != 5 {
+		t.Fatalf("LastSeq of a compacted journal after reopen = %d, want 5", seq)
+	}
+	if got := replayed(t, journal, authorEntity, 0); got != nil {
+		t.Fatalf("replayed %v from a fully compacted journal", got)
+	}
+}
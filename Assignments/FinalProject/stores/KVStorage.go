@@ -0,0 +1,330 @@
+package stores
+
+import (
+	"FinalProject/kvstore"
+	. "FinalProject/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------Definition of the KV storage--------------------------------
+// Every entity lives under its own prefix of the KV store:
+//
+//	<entity>/meta                       format version, next id and journal sequence
+//	<entity>/r/<id>                     the JSON record
+//	<entity>/i/<index>/<value>/<id>     one empty key per secondary index entry
+//
+// Ids are zero padded so the keys sort in id order.
+const (
+	PrimaryIndex   = ""
+	CreatedAtIndex = "created_at"
+)
+
+type kvMeta struct {
+	Version    int      `json:"version"`
+	NextID     int      `json:"next_id"`
+	JournalSeq int64    `json:"journal_seq"`
+	Indexes    []string `json:"indexes"`
+}
+
+// IndexScanner is implemented by the drivers that keep the records in key order. ok is false when
+// the driver can't answer right now, the caller then falls back to the records in memory.
+type IndexScanner interface {
+	ScanIndex(ctx context.Context, index string, from string, to string) (ids []int, ok bool, err error)
+}
+
+func OpenKVStorageDrivers(dir string) (StorageDrivers, error) {
+	db, err := kvstore.Open(dir, kvstore.DefaultOptions)
+	if err != nil {
+		log.Printf("Failed to open the KV store %s: %v\n", dir, err)
+		return StorageDrivers{}, err
+	}
+	return StorageDrivers{
+		Authors:   NewKVDriver[Author](db, authorEntity, nil),
+		Books:     NewKVDriver[Book](db, bookEntity, nil),
+		Customers: NewKVDriver[Customer](db, customerEntity, nil),
+		Orders: NewKVDriver[Order](db, orderEntity, map[string]func(Order) string{
+			CreatedAtIndex: func(o Order) string { return IndexTime(o.CreatedAt) },
+		}),
+		Promotions:      NewKVDriver[Promotion](db, promotionEntity, nil),
+		Shipments:       NewKVDriver[Shipment](db, shipmentEntity, nil),
+		Payments:        NewKVDriver[PaymentAttempt](db, paymentEntity, nil),
+		Carts:           NewKVDriver[Cart](db, cartEntity, nil),
+		IdempotencyKeys: NewKVDriver[IdempotencyRecord](db, idempotencyEntity, nil),
+		Reservations:    NewKVDriver[StockReservation](db, reservationEntity, nil),
+		StockMovements:  NewKVDriver[StockMovement](db, movementEntity, nil),
+		StockAlerts:     NewKVDriver[StockAlert](db, alertEntity, nil),
+		Suppliers:       NewKVDriver[Supplier](db, supplierEntity, nil),
+		PurchaseOrders:  NewKVDriver[PurchaseOrder](db, purchaseEntity, nil),
+		Returns:         NewKVDriver[ReturnRequest](db, returnEntity, nil),
+		closer:          db,
+	}, nil
+}
+
+// IndexTime formats a time so that the index keys sort in chronological order.
+func IndexTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+type KVDriver[T any] struct {
+	mu      sync.Mutex
+	db      *kvstore.DB
+	entity  string
+	indexes map[string]func(T) string
+	dirty   bool
+}
+
+func NewKVDriver[T any](db *kvstore.DB, entity string, indexes map[string]func(T) string) *KVDriver[T] {
+	return &KVDriver[T]{db: db, entity: entity, indexes: indexes}
+}
+
+func (d *KVDriver[T]) metaKey() string {
+	return d.entity + "/meta"
+}
+
+func (d *KVDriver[T]) recordPrefix() string {
+	return d.entity + "/r/"
+}
+
+func (d *KVDriver[T]) recordKey(id int) string {
+	return fmt.Sprintf("%s%010d", d.recordPrefix(), id)
+}
+
+func (d *KVDriver[T]) indexPrefix(index string) string {
+	return d.entity + "/i/" + index + "/"
+}
+
+func (d *KVDriver[T]) indexNames() []string {
+	names := make([]string, 0, len(d.indexes))
+	for name := range d.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *KVDriver[T]) indexKeys(id int, item T) []string {
+	var keys []string
+	for _, name := range d.indexNames() {
+		keys = append(keys, fmt.Sprintf("%s%s/%010d", d.indexPrefix(name), d.indexes[name](item), id))
+	}
+	return keys
+}
+
+func (d *KVDriver[T]) readMeta() (kvMeta, error) {
+	meta := kvMeta{Version: CurrentSchemaVersion(d.entity), NextID: 1, Indexes: d.indexNames()}
+	raw, ok, err := d.db.Get(d.metaKey())
+	if err != nil || !ok {
+		return meta, err
+	}
+	err = json.Unmarshal(raw, &meta)
+	return meta, err
+}
+
+func (d *KVDriver[T]) putMeta(batch *kvstore.Batch, nextID int, journalSeq int64) error {
+	raw, err := json.Marshal(kvMeta{Version: CurrentSchemaVersion(d.entity), NextID: nextID, JournalSeq: journalSeq, Indexes: d.indexNames()})
+	if err != nil {
+		return err
+	}
+	batch.Put(d.metaKey(), raw)
+	return nil
+}
+
+func (d *KVDriver[T]) Load(ctx context.Context) (StoredState[T], error) {
+	select {
+	case <-ctx.Done():
+		log.Printf("Context canceled during %s loading\n", d.entity)
+		return StoredState[T]{}, ctx.Err()
+	default:
+		meta, err := d.readMeta()
+		if err != nil {
+			return StoredState[T]{}, err
+		}
+		steps, err := migrationPath(d.entity, meta.Version)
+		if err != nil {
+			return StoredState[T]{}, err
+		}
+
+		state := StoredState[T]{Records: make(map[int]T), NextID: meta.NextID, JournalSeq: meta.JournalSeq}
+		err = d.db.ScanPrefix(d.recordPrefix(), func(key string, value []byte) error {
+			id, err := strconv.Atoi(strings.TrimPrefix(key, d.recordPrefix()))
+			if err != nil {
+				return err
+			}
+			value, _, err = migrateRecord(steps, value)
+			if err != nil {
+				return err
+			}
+			var item T
+			if err := json.Unmarshal(value, &item); err != nil {
+				return err
+			}
+			state.Records[id] = item
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to load %s from the KV store: %v\n", d.entity, err)
+			return StoredState[T]{}, err
+		}
+
+		// records in an older format, or indexes added since they were written, are rewritten once
+		if len(steps) > 0 || !reflect.DeepEqual(meta.Indexes, d.indexNames()) {
+			log.Printf("Rewriting %s in the KV store to version %d\n", d.entity, CurrentSchemaVersion(d.entity))
+			if err := d.Replace(ctx, state); err != nil {
+				return StoredState[T]{}, err
+			}
+		}
+		log.Printf("%s loaded successfully from the KV store\n", d.entity)
+		return state, nil
+	}
+}
+
+func (d *KVDriver[T]) Persist(ctx context.Context, changes []RecordChange[T], nextID int, journalSeq int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dirty {
+		return nil
+	}
+
+	batch := &kvstore.Batch{}
+	for _, change := range changes {
+		// the index entries of the previous version are found from the stored record
+		if raw, ok, err := d.db.Get(d.recordKey(change.ID)); err != nil {
+			d.dirty = true
+			return err
+		} else if ok && len(d.indexes) > 0 {
+			var previous T
+			if err := json.Unmarshal(raw, &previous); err == nil {
+				for _, key := range d.indexKeys(change.ID, previous) {
+					batch.Delete(key)
+				}
+			}
+		}
+		if change.Item == nil {
+			batch.Delete(d.recordKey(change.ID))
+			continue
+		}
+		if err := d.putRecord(batch, change.ID, *change.Item); err != nil {
+			d.dirty = true
+			return err
+		}
+	}
+	if err := d.putMeta(batch, nextID, journalSeq); err != nil {
+		d.dirty = true
+		return err
+	}
+	if err := d.db.Write(batch); err != nil {
+		d.dirty = true
+		return err
+	}
+	return nil
+}
+
+func (d *KVDriver[T]) putRecord(batch *kvstore.Batch, id int, item T) error {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	batch.Put(d.recordKey(id), raw)
+	for _, key := range d.indexKeys(id, item) {
+		batch.Put(key, nil)
+	}
+	return nil
+}
+
+func (d *KVDriver[T]) Checkpoint(ctx context.Context, state StoredState[T]) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dirty {
+		log.Printf("Rewriting %s in the KV store, some changes could not be written earlier\n", d.entity)
+		return d.replace(ctx, state)
+	}
+	batch := &kvstore.Batch{}
+	if err := d.putMeta(batch, state.NextID, state.JournalSeq); err != nil {
+		return err
+	}
+	if err := d.db.Write(batch); err != nil {
+		return err
+	}
+	return d.db.MaybeCompact()
+}
+
+func (d *KVDriver[T]) Replace(ctx context.Context, state StoredState[T]) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.replace(ctx, state)
+}
+
+// replace writes the whole entity in one batch, so it is applied completely or not at all.
+func (d *KVDriver[T]) replace(ctx context.Context, state StoredState[T]) error {
+	batch := &kvstore.Batch{}
+	prefix := d.entity + "/"
+	err := d.db.ScanKeys(prefix, kvstore.PrefixEnd(prefix), func(key string) error {
+		batch.Delete(key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for id, item := range state.Records {
+		if err := d.putRecord(batch, id, item); err != nil {
+			return err
+		}
+	}
+	if err := d.putMeta(batch, state.NextID, state.JournalSeq); err != nil {
+		return err
+	}
+	if err := d.db.Write(batch); err != nil {
+		log.Printf("Failed to rewrite %s in the KV store: %v\n", d.entity, err)
+		return err
+	}
+	d.dirty = false
+	log.Printf("%s saved successfully to the KV store\n", d.entity)
+	return nil
+}
+
+// ScanIndex returns the ids in key order. PrimaryIndex scans the records themselves, from and to
+// are inclusive bounds on the index value and an empty bound is open.
+func (d *KVDriver[T]) ScanIndex(ctx context.Context, index string, from string, to string) ([]int, bool, error) {
+	d.mu.Lock()
+	dirty := d.dirty
+	d.mu.Unlock()
+	if dirty {
+		return nil, false, nil
+	}
+
+	prefix := d.recordPrefix()
+	if index != PrimaryIndex {
+		if _, ok := d.indexes[index]; !ok {
+			return nil, false, nil
+		}
+		prefix = d.indexPrefix(index)
+	}
+	start := prefix + from
+	end := kvstore.PrefixEnd(prefix)
+	if to != "" {
+		end = kvstore.PrefixEnd(prefix + to + "/")
+		if index == PrimaryIndex {
+			end = kvstore.PrefixEnd(prefix + to)
+		}
+	}
+
+	var ids []int
+	err := d.db.ScanKeys(start, end, func(key string) error {
+		id, err := strconv.Atoi(key[strings.LastIndex(key, "/")+1:])
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err == nil, err
+}
@@ -0,0 +1,413 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	. "FinalProject/pricing"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------Definition of Migrations--------------------------------
+// Files without a version header are version 1. Every migration upgrades the records of one entity
+// from FromVersion to FromVersion+1, so the current version of an entity is one more than the number
+// of migrations registered for it. Changing the JSON shape of a model means adding a migration here.
+type Migration struct {
+	Entity      string
+	FromVersion int
+	Description string
+	Migrate     func(record map[string]interface{}) error
+}
+
+var migrations = []Migration{
+	{Entity: authorEntity, FromVersion: 1, Description: "add format version header", Migrate: noRecordChange},
+	{Entity: bookEntity, FromVersion: 1, Description: "add format version header", Migrate: noRecordChange},
+	{Entity: customerEntity, FromVersion: 1, Description: "add format version header", Migrate: noRecordChange},
+	{Entity: orderEntity, FromVersion: 1, Description: "add format version header", Migrate: noRecordChange},
+	{Entity: orderEntity, FromVersion: 2, Description: "normalize order status and add status history", Migrate: migrateOrderStatus},
+	{Entity: orderEntity, FromVersion: 3, Description: "price the items on the server and add the price breakdown", Migrate: migrateOrderPricing},
+	{Entity: bookEntity, FromVersion: 2, Description: "replace the author copy with the contributors", Migrate: migrateBookContributors},
+	{Entity: orderEntity, FromVersion: 4, Description: "replace the author copy of the item books with the contributors", Migrate: migrateOrderItemContributors},
+	{Entity: orderEntity, FromVersion: 5, Description: "replace the customer and book copies with their IDs and snapshots", Migrate: migrateOrderReferences},
+}
+
+var entityFiles = map[string]string{
+	authorEntity:    "authors.json",
+	bookEntity:      "books.json",
+	customerEntity:  "customers.json",
+	orderEntity:     "orders.json",
+	promotionEntity: "promotions.json",
+	shipmentEntity:  "shipments.json",
+	paymentEntity:   "payments.json",
+
+	cartEntity:        "carts.json",
+	idempotencyEntity: "idempotency_keys.json",
+	reservationEntity: "reservations.json",
+	movementEntity:    "stock_movements.json",
+	alertEntity:       "stock_alerts.json",
+	supplierEntity:    "suppliers.json",
+	purchaseEntity:    "purchase_orders.json",
+	returnEntity:      "returns.json",
+}
+
+// storedEntities lists the entities in load order, the referenced ones first.
+var storedEntities = []string{authorEntity, bookEntity, customerEntity, orderEntity, promotionEntity, shipmentEntity, paymentEntity,
+	cartEntity, idempotencyEntity, reservationEntity, movementEntity, alertEntity, supplierEntity, purchaseEntity, returnEntity}
+
+type MigrationReport struct {
+	File           string   `json:"file"`
+	Entity         string   `json:"entity"`
+	FromVersion    int      `json:"from_version"`
+	ToVersion      int      `json:"to_version"`
+	Steps          []string `json:"steps"`
+	RecordsChanged int      `json:"records_changed"`
+}
+
+func noRecordChange(record map[string]interface{}) error {
+	return nil
+}
+
+// migrateOrderStatus turns the free-form status into one of the lifecycle states, anything unknown
+// becomes pending, and starts the history with the status the order had.
+func migrateOrderStatus(record map[string]interface{}) error {
+	raw, _ := record["status"].(string)
+	status := OrderStatus(strings.ToLower(strings.TrimSpace(raw)))
+	if !status.Valid() {
+		status = OrderPending
+	}
+	record["status"] = string(status)
+	if _, ok := record["status_history"]; !ok || record["status_history"] == nil {
+		record["status_history"] = []interface{}{
+			map[string]interface{}{"to": string(status), "at": record["created_at"]},
+		}
+	}
+	return nil
+}
+
+// migrateOrderPricing snapshots the unit price from the book copy kept in every item and replaces the
+// total sent by the client with the subtotal of the items, older orders had no discount, tax or shipping.
+func migrateOrderPricing(record map[string]interface{}) error {
+	items, _ := record["items"].([]interface{})
+	subtotal := 0.0
+	for _, raw := range items {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		book, _ := item["book"].(map[string]interface{})
+		unitPrice, err := jsonNumber(item["unit_price"])
+		if err != nil || item["unit_price"] == nil {
+			if unitPrice, err = jsonNumber(book["price"]); err != nil {
+				return err
+			}
+		}
+		quantity, err := jsonNumber(item["quantity"])
+		if err != nil {
+			return err
+		}
+		lineTotal := RoundPrice(unitPrice * quantity)
+		item["unit_price"] = RoundPrice(unitPrice)
+		item["line_total"] = lineTotal
+		subtotal += lineTotal
+	}
+	subtotal = RoundPrice(subtotal)
+	record["pricing"] = map[string]interface{}{"subtotal": subtotal, "discount": 0, "tax": 0, "shipping": 0, "total": subtotal}
+	record["total_price"] = subtotal
+	return nil
+}
+
+// migrateBookContributors turns the single author kept in a book into its first contributor, by ID only.
+// A book without an author is left without contributors.
+func migrateBookContributors(record map[string]interface{}) error {
+	author, _ := record["author"].(map[string]interface{})
+	delete(record, "author")
+	if _, ok := record["contributors"]; ok {
+		return nil
+	}
+	contributors := []interface{}{}
+	id, err := jsonNumber(author["id"])
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		contributors = append(contributors, map[string]interface{}{"author_id": int(id), "role": string(RoleAuthor), "position": 1})
+	}
+	record["contributors"] = contributors
+	return nil
+}
+
+// migrateOrderItemContributors does the same for the copy of the book kept in every item of an order.
+func migrateOrderItemContributors(record map[string]interface{}) error {
+	items, _ := record["items"].([]interface{})
+	for _, raw := range items {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if book, ok := item["book"].(map[string]interface{}); ok {
+			if err := migrateBookContributors(book); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migrateOrderReferences keeps the customer and the books of an order by ID. The address the order
+// was priced for and the title and cost of every book sold are taken from the copies they had.
+func migrateOrderReferences(record map[string]interface{}) error {
+	if customer, ok := record["customer"].(map[string]interface{}); ok {
+		if id, err := jsonNumber(record["customer_id"]); err != nil || id == 0 {
+			record["customer_id"] = customer["id"]
+		}
+		if _, ok := record["shipping_address"]; !ok && customer["address"] != nil {
+			record["shipping_address"] = customer["address"]
+		}
+	}
+	delete(record, "customer")
+
+	items, _ := record["items"].([]interface{})
+	for _, raw := range items {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if book, ok := item["book"].(map[string]interface{}); ok {
+			if id, err := jsonNumber(item["book_id"]); err != nil || id == 0 {
+				item["book_id"] = book["id"]
+			}
+			if title, _ := item["title"].(string); title == "" {
+				item["title"] = book["title"]
+			}
+			if _, ok := item["unit_cost"]; !ok && book["unit_cost"] != nil {
+				item["unit_cost"] = book["unit_cost"]
+			}
+		}
+		delete(item, "book")
+	}
+	return nil
+}
+
+func jsonNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	}
+	return 0, errors.New("expected a number")
+}
+
+func RegisterMigration(m Migration) {
+	migrations = append(migrations, m)
+}
+
+func CurrentSchemaVersion(entity string) int {
+	version := 1
+	for _, m := range migrations {
+		if m.Entity == entity && m.FromVersion >= version {
+			version = m.FromVersion + 1
+		}
+	}
+	return version
+}
+
+func migrationPath(entity string, fromVersion int) ([]Migration, error) {
+	current := CurrentSchemaVersion(entity)
+	if fromVersion > current {
+		return nil, errors.New(entity + " data has format version " + strconv.Itoa(fromVersion) + " but this server only knows up to version " + strconv.Itoa(current))
+	}
+	var steps []Migration
+	for version := fromVersion; version < current; version++ {
+		found := false
+		for _, m := range migrations {
+			if m.Entity == entity && m.FromVersion == version {
+				steps = append(steps, m)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("no migration registered for " + entity + " from version " + strconv.Itoa(version))
+		}
+	}
+	return steps, nil
+}
+
+func decodeGeneric(raw []byte, target interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
+
+// migrateRecord upgrades one record and reports whether any step changed it.
+func migrateRecord(steps []Migration, raw json.RawMessage) (json.RawMessage, bool, error) {
+	if len(steps) == 0 {
+		return raw, false, nil
+	}
+	var record map[string]interface{}
+	if err := decodeGeneric(raw, &record); err != nil {
+		return nil, false, err
+	}
+	before, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, step := range steps {
+		if err := step.Migrate(record); err != nil {
+			return nil, false, errors.New("migration '" + step.Description + "' failed: " + err.Error())
+		}
+	}
+	after, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+	return after, !bytes.Equal(before, after), nil
+}
+
+// migrateDocument upgrades a whole store file to the current format version of its entity.
+func migrateDocument(entity string, raw []byte) ([]byte, MigrationReport, error) {
+	report := MigrationReport{File: entityFiles[entity], Entity: entity, ToVersion: CurrentSchemaVersion(entity)}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, report, err
+	}
+	report.FromVersion = 1
+	if version, ok := doc["version"]; ok {
+		if err := json.Unmarshal(version, &report.FromVersion); err != nil {
+			return nil, report, err
+		}
+	}
+
+	steps, err := migrationPath(entity, report.FromVersion)
+	if err != nil {
+		return nil, report, err
+	}
+	if len(steps) == 0 {
+		return raw, report, nil
+	}
+	for _, step := range steps {
+		report.Steps = append(report.Steps, "v"+strconv.Itoa(step.FromVersion)+" -> v"+strconv.Itoa(step.FromVersion+1)+": "+step.Description)
+	}
+
+	var records map[string]json.RawMessage
+	if len(doc[entity]) > 0 && string(doc[entity]) != "null" {
+		if err := json.Unmarshal(doc[entity], &records); err != nil {
+			return nil, report, err
+		}
+	}
+	for id, record := range records {
+		migrated, changed, err := migrateRecord(steps, record)
+		if err != nil {
+			return nil, report, errors.New(entity + " record " + id + ": " + err.Error())
+		}
+		if changed {
+			report.RecordsChanged++
+		}
+		records[id] = migrated
+	}
+
+	if doc[entity], err = json.Marshal(records); err != nil {
+		return nil, report, err
+	}
+	if doc["version"], err = json.Marshal(report.ToVersion); err != nil {
+		return nil, report, err
+	}
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, report, err
+	}
+	return migrated, report, nil
+}
+
+// upgradeSnapshot is used by every store before decoding one of its files.
+func upgradeSnapshot(entity string, raw []byte) ([]byte, error) {
+	migrated, report, err := migrateDocument(entity, raw)
+	if err != nil {
+		log.Printf("Failed to migrate %s data: %v\n", entity, err)
+		return nil, err
+	}
+	if len(report.Steps) > 0 {
+		log.Printf("Migrated %s data from version %d to %d, %d records changed\n", entity, report.FromVersion, report.ToVersion, report.RecordsChanged)
+	}
+	return migrated, nil
+}
+
+func upgradeJournalChange(change JournalChange) (JournalChange, error) {
+	version := change.Version
+	if version == 0 {
+		version = 1
+	}
+	steps, err := migrationPath(change.Entity, version)
+	if err != nil || len(steps) == 0 || change.Op != journalPut {
+		return change, err
+	}
+	change.Data, _, err = migrateRecord(steps, change.Data)
+	change.Version = CurrentSchemaVersion(change.Entity)
+	return change, err
+}
+
+// DryRunMigrations reports what loading the database directory would change, without writing anything.
+func DryRunMigrations(ctx context.Context, journalPath string) ([]MigrationReport, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		// the journal is only scanned, opening it normally would cut off a torn last record
+		journal := &Journal{path: filepath.Join("database", journalPath)}
+		var reports []MigrationReport
+		for _, entity := range storedEntities {
+			fullPath := filepath.Join("database", entityFiles[entity])
+			raw, err := os.ReadFile(fullPath)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			_, report, err := migrateDocument(entity, raw)
+			if err != nil {
+				return nil, errors.New(fullPath + ": " + err.Error())
+			}
+			reports = append(reports, report)
+
+			journalReport := MigrationReport{File: "journal.log", Entity: entity, ToVersion: CurrentSchemaVersion(entity)}
+			err = journal.Replay(entity, 0, func(seq int64, change JournalChange) error {
+				version := change.Version
+				if version == 0 {
+					version = 1
+				}
+				if change.Op != journalPut || version == journalReport.ToVersion {
+					return nil
+				}
+				upgraded, err := upgradeJournalChange(change)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(upgraded.Data, change.Data) {
+					journalReport.RecordsChanged++
+				}
+				if journalReport.FromVersion == 0 || version < journalReport.FromVersion {
+					journalReport.FromVersion = version
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, errors.New("journal: " + err.Error())
+			}
+			if journalReport.FromVersion > 0 || journalReport.RecordsChanged > 0 {
+				reports = append(reports, journalReport)
+			}
+		}
+		return reports, nil
+	}
+}
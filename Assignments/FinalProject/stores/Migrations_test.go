@@ -0,0 +1,193 @@
+package stores
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+// canonicalJSON decodes and encodes again, so the key order and spacing of the fixtures don't matter.
+func canonicalJSON(t *testing.T, raw []byte) string {
+	t.Helper()
+	var value interface{}
+	if err := decodeGeneric(raw, &value); err != nil {
+		t.Fatalf("bad fixture %s: %v", raw, err)
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestMigrationSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		migrate func(map[string]interface{}) error
+		record  string
+		want    string
+	}{
+		{
+			name:    "version header only",
+			migrate: noRecordChange,
+			record:  `{"id": 1, "first_name": "Ann"}`,
+			want:    `{"id": 1, "first_name": "Ann"}`,
+		},
+		{
+			name:    "order status normalized",
+			migrate: migrateOrderStatus,
+			record:  `{"id": 1, "status": " Shipped ", "created_at": "2024-01-02T00:00:00Z"}`,
+			want:    `{"id": 1, "status": "shipped", "created_at": "2024-01-02T00:00:00Z", "status_history": [{"to": "shipped", "at": "2024-01-02T00:00:00Z"}]}`,
+		},
+		{
+			name:    "unknown order status",
+			migrate: migrateOrderStatus,
+			record:  `{"id": 1, "status": "lost in the mail", "created_at": "2024-01-02T00:00:00Z"}`,
+			want:    `{"id": 1, "status": "pending", "created_at": "2024-01-02T00:00:00Z", "status_history": [{"to": "pending", "at": "2024-01-02T00:00:00Z"}]}`,
+		},
+		{
+			name:    "order priced from the book copies",
+			migrate: migrateOrderPricing,
+			record:  `{"id": 1, "total_price": 99, "items": [{"quantity": 2, "book": {"price": 10.5}}, {"quantity": 1, "unit_price": 4, "book": {"price": 7}}]}`,
+			want: `{"id": 1, "total_price": 25, "pricing": {"subtotal": 25, "discount": 0, "tax": 0, "shipping": 0, "total": 25},
+				"items": [{"quantity": 2, "unit_price": 10.5, "line_total": 21, "book": {"price": 10.5}}, {"quantity": 1, "unit_price": 4, "line_total": 4, "book": {"price": 7}}]}`,
+		},
+		{
+			name:    "book author becomes a contributor",
+			migrate: migrateBookContributors,
+			record:  `{"id": 3, "author": {"id": 5, "first_name": "Ann"}}`,
+			want:    `{"id": 3, "contributors": [{"author_id": 5, "role": "author", "position": 1}]}`,
+		},
+		{
+			name:    "book without an author",
+			migrate: migrateBookContributors,
+			record:  `{"id": 3}`,
+			want:    `{"id": 3, "contributors": []}`,
+		},
+		{
+			name:    "order item books get contributors",
+			migrate: migrateOrderItemContributors,
+			record:  `{"id": 1, "items": [{"quantity": 1, "book": {"id": 3, "author": {"id": 5}}}]}`,
+			want:    `{"id": 1, "items": [{"quantity": 1, "book": {"id": 3, "contributors": [{"author_id": 5, "role": "author", "position": 1}]}}]}`,
+		},
+		{
+			name:    "order copies become references",
+			migrate: migrateOrderReferences,
+			record: `{"id": 1, "customer": {"id": 2, "address": {"city": "Oslo"}},
+				"items": [{"quantity": 1, "book": {"id": 3, "title": "Dune", "unit_cost": 4.5}}]}`,
+			want: `{"id": 1, "customer_id": 2, "shipping_address": {"city": "Oslo"},
+				"items": [{"quantity": 1, "book_id": 3, "title": "Dune", "unit_cost": 4.5}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var record map[string]interface{}
+			if err := decodeGeneric([]byte(tt.record), &record); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.migrate(record); err != nil {
+				t.Fatalf("migration failed: %v", err)
+			}
+			got, err := json.Marshal(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if canonicalJSON(t, got) != canonicalJSON(t, []byte(tt.want)) {
+				t.Fatalf("migrated to\n%s\nwant\n%s", canonicalJSON(t, got), canonicalJSON(t, []byte(tt.want)))
+			}
+		})
+	}
+}
+
+func TestEveryMigrationStepIsRegistered(t *testing.T) {
+	for entity := range entityFiles {
+		steps, err := migrationPath(entity, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", entity, err)
+		}
+		if len(steps) != CurrentSchemaVersion(entity)-1 {
+			t.Fatalf("%s has %d steps to version %d", entity, len(steps), CurrentSchemaVersion(entity))
+		}
+		for i, step := range steps {
+			if step.FromVersion != i+1 {
+				t.Fatalf("%s step %d starts from version %d", entity, i, step.FromVersion)
+			}
+		}
+	}
+	if _, err := migrationPath(orderEntity, CurrentSchemaVersion(orderEntity)+1); err == nil {
+		t.Fatal("a version newer than the server was accepted")
+	}
+}
+
+func TestMigrateRecordReportsChanges(t *testing.T) {
+	steps := []Migration{{Entity: "tests", FromVersion: 1, Description: "add a default", Migrate: func(record map[string]interface{}) error {
+		if _, ok := record["flag"]; !ok {
+			record["flag"] = true
+		}
+		return nil
+	}}}
+	tests := []struct {
+		name    string
+		record  string
+		changed bool
+	}{
+		{"record missing the field", `{"id": 1}`, true},
+		{"record that already has it", `{"id": 1, "flag": false}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, changed, err := migrateRecord(steps, json.RawMessage(tt.record))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tt.changed {
+				t.Fatalf("changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestMigrateDocument(t *testing.T) {
+	current := CurrentSchemaVersion(authorEntity)
+	tests := []struct {
+		name      string
+		raw       string
+		from      int
+		steps     int
+		wantErr   bool
+		unchanged bool
+	}{
+		{"file without a version header", `{"authors": {"1": {"id": 1}}, "next_id": 2}`, 1, current - 1, false, false},
+		{"file at the current version", `{"version": ` + strconv.Itoa(current) + `, "authors": {}, "next_id": 1}`, current, 0, false, true},
+		{"file from a newer server", `{"version": ` + strconv.Itoa(current+1) + `, "authors": {}}`, current + 1, 0, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrated, report, err := migrateDocument(authorEntity, []byte(tt.raw))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("the file was migrated")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.FromVersion != tt.from || report.ToVersion != current || len(report.Steps) != tt.steps {
+				t.Fatalf("report %+v, want version %d to %d in %d steps", report, tt.from, current, tt.steps)
+			}
+			if tt.unchanged && string(migrated) != tt.raw {
+				t.Fatalf("a current file was rewritten to %s", migrated)
+			}
+			var doc struct {
+				Version int `json:"version"`
+			}
+			if err := json.Unmarshal(migrated, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc.Version != current {
+				t.Fatalf("migrated file has version %d, want %d", doc.Version, current)
+			}
+		})
+	}
+}
@@ -2,70 +2,94 @@ package stores
 
 import (
 	. "FinalProject/models"
+	. "FinalProject/pricing"
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"strconv"
-	"sync"
 	"time"
 )
 
 type InMemoryOrderStore struct {
-	Mu     sync.RWMutex
-	Orders map[int]Order
-	NextID int
+	repo         *Repository[Order]
+	Customers    *InMemoryCustomerStore
+	Books        *InMemoryBookStore
+	Promotions   *InMemoryPromotionStore
+	Reservations *InMemoryReservationStore
+	Inventory    *InMemoryInventoryStore
+	Pricing      *PricingEngine
 }
 type OrderStore interface {
 	CreateOrder(ctx context.Context, order Order) (Order, error)
 	GetOrder(ctx context.Context, id int) (Order, error)
 	UpdateOrder(ctx context.Context, id int, order Order) (Order, error)
+	TransitionOrder(ctx context.Context, id int, status OrderStatus) (Order, error)
 	DeleteOrder(ctx context.Context, id int) error
 	ListOrders(ctx context.Context) ([]Order, error)
 	ViewOrderHistory(ctx context.Context) (map[int]time.Time, error)
 	FetchOrderWithinTimeLimit(ctx context.Context, startTime time.Time, endTime time.Time) ([]Order, error)
-	LoadOrders(ctx context.Context, filePath string) error
-	SaveOrders(ctx context.Context, filePath string) error
+	ReleasePreOrders(ctx context.Context) (int, error)
+	ListPreOrders(ctx context.Context, customerId int) ([]PreOrder, error)
+	PreOrderDemand(ctx context.Context) (PreOrderReport, error)
+	LoadOrders(ctx context.Context) error
+	SaveOrders(ctx context.Context) error
 }
 
-func (s *InMemoryOrderStore) CreateOrder(ctx context.Context, order Order, customerStore *InMemoryCustomerStore, bookStore *InMemoryBookStore) (Order, error) {
+func NewInMemoryOrderStore(journal *Journal, driver StorageDriver[Order]) *InMemoryOrderStore {
+	return &InMemoryOrderStore{repo: NewRepository[Order](orderEntity, orderStoreRank, journal, driver), Pricing: NewPricingEngine()}
+}
+
+func (s *InMemoryOrderStore) CreateOrder(ctx context.Context, order Order) (Order, error) {
 	select {
 	case <-ctx.Done():
 		log.Println("Request canceled during Order creation")
 		return Order{}, ctx.Err()
 	default:
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
+		tx, err := BeginTransaction(ctx, ReadLock(s.Customers.repo), WriteLock(s.Books.repo), WriteLock(s.repo), ReadLock(s.Promotions.repo),
+			WriteLock(s.Reservations.repo), WriteLock(s.Inventory.repo))
+		if err != nil {
+			return Order{}, err
+		}
+		defer tx.Rollback()
+		orders := Table(tx, s.repo)
 
-		customer, ok := customerStore.Customers[order.Customer.ID]
+		order = order.Detached()
+		customer, ok := Table(tx, s.Customers.repo).Get(order.CustomerID)
 		if !ok {
-			return Order{}, errors.New("Customer with ID " + strconv.Itoa(order.Customer.ID) + " not found")
+			return Order{}, errors.New("Customer with ID " + strconv.Itoa(order.CustomerID) + " not found")
 		}
-		order.Customer = customer
+		order.ShippingAddress = customer.Address
 
-		for i, item := range order.Items {
-			book, ok := bookStore.Books[item.Book.ID]
-			if !ok {
-				return Order{}, errors.New("Book with ID " + strconv.Itoa(item.Book.ID) + " not found")
-			}
-			if item.Quantity > book.Stock {
-				return Order{}, errors.New("Not enough stock for book " + book.Title)
-			}
-			book.Stock -= item.Quantity
-			bookStore.Books[item.Book.ID] = book
-
-			order.Items[i].Book = book
+		order.ID, err = orders.NextID()
+		if err != nil {
+			return Order{}, err
 		}
-
-		order.ID = s.NextID
-		s.NextID++
 		order.CreatedAt = time.Now()
-		s.Orders[order.ID] = order
+		// the books are only held until the order is paid
+		if err := s.inventoryTables(tx).holdStock(&order, order.CreatedAt.Add(s.Reservations.TTL)); err != nil {
+			return Order{}, err
+		}
+		if err := s.Pricing.PriceOrder(&order, s.promotionDiscount(tx, order, 0)); err != nil {
+			return Order{}, err
+		}
 
+		order.Status = OrderPending
+		order.StatusHistory = []StatusChange{{To: OrderPending, At: order.CreatedAt}}
+		// the books looked up for the pricing aren't kept, the items only keep what was sold
+		order = order.Detached()
+		if err := orders.Put(order.ID, order); err != nil {
+			return Order{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return Order{}, err
+		}
+
+		if backordered := order.Backordered(); backordered > 0 {
+			log.Printf("Order %d has %d copies on backorder\n", order.ID, backordered)
+		}
 		log.Printf("Order created successfully. ID: %d\n", order.ID)
-		return s.Orders[order.ID], nil
+		return order, nil
 	}
 }
 
@@ -75,9 +99,7 @@ func (s *InMemoryOrderStore) GetOrder(ctx context.Context, orderId int) (Order,
 		log.Println("Request canceled during Order retrieval of Order" + strconv.Itoa(orderId))
 		return Order{}, ctx.Err()
 	default:
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
-		order, ok := s.Orders[orderId]
+		order, ok := s.repo.Find(orderId)
 		if !ok {
 			log.Println("Order with ID ", orderId, " not found")
 			return Order{}, errors.New("Order with ID " + strconv.Itoa(orderId) + " not found")
@@ -86,51 +108,211 @@ func (s *InMemoryOrderStore) GetOrder(ctx context.Context, orderId int) (Order,
 	}
 }
 
-func (s *InMemoryOrderStore) UpdateOrder(ctx context.Context, orderId int, order Order, customerStore *InMemoryCustomerStore, bookStore *InMemoryBookStore) error {
+func (s *InMemoryOrderStore) UpdateOrder(ctx context.Context, orderId int, order Order) (Order, error) {
 	select {
 	case <-ctx.Done():
-		log.Println("Request canceled during Order %d update", orderId)
-		return ctx.Err()
+		log.Printf("Request canceled during Order %d update\n", orderId)
+		return Order{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Customers.repo), WriteLock(s.Books.repo), WriteLock(s.repo), ReadLock(s.Promotions.repo),
+			WriteLock(s.Reservations.repo), WriteLock(s.Inventory.repo))
+		if err != nil {
+			return Order{}, err
+		}
+		defer tx.Rollback()
+		orders := Table(tx, s.repo)
+		inventory := s.inventoryTables(tx)
+
+		unchangedOrder, ok := orders.Get(orderId)
+		if !ok {
+			return Order{}, errors.New("Order with ID " + strconv.Itoa(orderId) + " not found")
+		}
+		if _, ok := Table(tx, s.Customers.repo).Get(unchangedOrder.CustomerID); !ok {
+			return Order{}, errors.New("Customer with id " + strconv.Itoa(unchangedOrder.CustomerID) + " not found")
+		}
+		order = order.Detached()
+		order.ID = unchangedOrder.ID //logically these fields can't be open for update
+		order.CustomerID = unchangedOrder.CustomerID
+		order.ShippingAddress = unchangedOrder.ShippingAddress
+		order.CreatedAt = unchangedOrder.CreatedAt
+		// the status only moves through TransitionOrder, so every change is checked and timestamped
+		if order.Status != "" && order.Status != unchangedOrder.Status {
+			return Order{}, fmt.Errorf("%w: the status of an order is changed through its dedicated endpoints", ErrInvalidTransition)
+		}
+		order.Status = unchangedOrder.Status
+		order.StatusHistory = unchangedOrder.StatusHistory
+		order.ReservedUntil = unchangedOrder.ReservedUntil
+
+		if len(order.Items) == 0 {
+			order.Items = unchangedOrder.Items
+			order.AllowBackorder = unchangedOrder.AllowBackorder
+			order.CouponCodes = unchangedOrder.CouponCodes
+			order.Promotions = unchangedOrder.Promotions
+			order.TaxLines = unchangedOrder.TaxLines
+			order.Pricing = unchangedOrder.Pricing
+			order.TotalPrice = unchangedOrder.TotalPrice
+			order.Currency = unchangedOrder.Currency
+			order.ShippingMethod = unchangedOrder.ShippingMethod
+		} else {
+			if unchangedOrder.Status != OrderPending {
+				return Order{}, fmt.Errorf("%w: the items of a %s order can't be changed", ErrInvalidTransition, unchangedOrder.Status)
+			}
+			// the previous items give their stock back before the new ones are reserved,
+			// so an update that keeps the same quantity never fails for lack of stock
+			now := time.Now()
+			if err := inventory.releasePendingStock(unchangedOrder, "order updated", now); err != nil {
+				return Order{}, err
+			}
+			// the new holds keep the deadline of the order, changing it doesn't buy more time
+			expiresAt := now.Add(s.Reservations.TTL)
+			if unchangedOrder.ReservedUntil != nil {
+				expiresAt = *unchangedOrder.ReservedUntil
+			}
+			if err := inventory.holdStock(&order, expiresAt); err != nil {
+				return Order{}, err
+			}
+			if order.CouponCodes == nil {
+				order.CouponCodes = unchangedOrder.CouponCodes
+			}
+			if order.Currency == "" {
+				order.Currency = unchangedOrder.Currency
+			}
+			if order.ShippingMethod == "" {
+				order.ShippingMethod = unchangedOrder.ShippingMethod
+			}
+			if err := s.Pricing.PriceOrder(&order, s.promotionDiscount(tx, order, order.ID)); err != nil {
+				return Order{}, err
+			}
+			order = order.Detached()
+		}
+
+		if err := orders.Put(order.ID, order); err != nil {
+			return Order{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return Order{}, err
+		}
+		log.Printf("Order updated successfully. ID: %d\n", order.ID)
+		return order, nil
+	}
+}
+
+func (s *InMemoryOrderStore) TransitionOrder(ctx context.Context, orderId int, status OrderStatus) (Order, error) {
+	return s.transitionOrder(ctx, orderId, status, nil)
+}
+
+// transitionOrder runs check on the order in the same transaction, before it moves. A failed check leaves
+// the order as it was.
+func (s *InMemoryOrderStore) transitionOrder(ctx context.Context, orderId int, status OrderStatus, check func(order Order) error) (Order, error) {
+	select {
+	case <-ctx.Done():
+		log.Printf("Request canceled during Order %d status change\n", orderId)
+		return Order{}, ctx.Err()
 	default:
-		for _, item := range order.Items {
-			for _, book := range bookStore.Books {
+		tx, err := BeginTransaction(ctx, WriteLock(s.Books.repo), WriteLock(s.repo), WriteLock(s.Reservations.repo), WriteLock(s.Inventory.repo))
+		if err != nil {
+			return Order{}, err
+		}
+		defer tx.Rollback()
+		orders := Table(tx, s.repo)
+		inventory := s.inventoryTables(tx)
 
-				if book.ID != item.Book.ID || item.Quantity != book.Stock {
-					return errors.New("Not enough stock for book " + book.Title)
-				}
+		order, ok := orders.Get(orderId)
+		if !ok {
+			return Order{}, fmt.Errorf("%w: order with ID %d", ErrRecordNotFound, orderId)
+		}
+		if check != nil {
+			if err := check(order); err != nil {
+				return Order{}, err
+			}
+		}
+		previous := order.Status
+		now := time.Now()
+		if (status == OrderShipped || status == OrderDelivered) && order.Backordered() > 0 {
+			return Order{}, fmt.Errorf("%w: order %d still has %d copies on backorder", ErrInvalidTransition, orderId, order.Backordered())
+		}
+		if err := order.Transition(status, now); err != nil {
+			log.Printf("Order %d can't go from %s to %s\n", orderId, previous, status)
+			return Order{}, err
+		}
+		switch {
+		case status == OrderPaid:
+			// the payment makes the holds for good, the books leave the stock
+			if _, err := inventory.resolveHolds(order.ID, ReservationCommitted, "order paid", now); err != nil {
+				return Order{}, err
+			}
+		case previous == OrderPending && status == OrderCancelled:
+			if err := inventory.releasePendingStock(order, "order cancelled", now); err != nil {
+				return Order{}, err
 			}
-			for _, customer := range customerStore.Customers {
-				if customer.ID != order.Customer.ID {
-					return errors.New("Customer with id " + strconv.Itoa(order.Customer.ID) + "not found")
-				}
+		case previous.HoldsStock() && (status == OrderCancelled || status == OrderRefunded):
+			// books that never left the warehouse go back on the shelves
+			if err := inventory.returnStock(order, "order "+string(status)); err != nil {
+				return Order{}, err
 			}
 		}
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
-		unchangedOrder := s.Orders[order.ID]
-		if unchangedOrder.ID != orderId {
-			return errors.New("Order with ID " + strconv.Itoa(orderId) + " not found")
+		if previous == OrderPending {
+			order.ReservedUntil = nil
 		}
-		order.ID = unchangedOrder.ID //logically these two fields can't be open for update
-		order.Customer = unchangedOrder.Customer
-		s.Orders[order.ID] = order
-		return nil
+		order.RefundedAmount = order.RefundedTotal()
+
+		if err := orders.Put(order.ID, order); err != nil {
+			return Order{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return Order{}, err
+		}
+		log.Printf("Order %d moved from %s to %s\n", order.ID, previous, status)
+		return order, nil
 	}
 }
 
+// promotionDiscount prices the promotions with the usage counts seen by the transaction, so two orders
+// racing for the last use of a coupon can't both get it.
+func (s *InMemoryOrderStore) promotionDiscount(tx *Transaction, order Order, excludedOrderId int) PromotionDiscount {
+	return PromotionDiscount{
+		Promotions: Table(tx, s.Promotions.repo).All(),
+		Usage:      promotionUsage(Table(tx, s.repo).All(), order.CustomerID, excludedOrderId),
+		At:         order.CreatedAt,
+		Rates:      s.Pricing.Rates,
+	}
+}
+
+func (s *InMemoryOrderStore) inventoryTables(tx *Transaction) inventoryTables {
+	return inventoryTables{books: Table(tx, s.Books.repo), movements: Table(tx, s.Inventory.repo), reservations: Table(tx, s.Reservations.repo), orders: Table(tx, s.repo)}
+}
+
 func (s *InMemoryOrderStore) DeleteOrder(ctx context.Context, OrderId int) error {
 	select {
 	case <-ctx.Done():
-		log.Println("Request canceled during Order %d deletion", OrderId)
+		log.Printf("Request canceled during Order %d deletion\n", OrderId)
 		return ctx.Err()
 	default:
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
-		if _, ok := s.Orders[OrderId]; ok {
-			delete(s.Orders, OrderId)
-			return nil
+		tx, err := BeginTransaction(ctx, WriteLock(s.Books.repo), WriteLock(s.repo), WriteLock(s.Reservations.repo), WriteLock(s.Inventory.repo))
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		orders := Table(tx, s.repo)
+
+		order, ok := orders.Get(OrderId)
+		if !ok {
+			return errors.New("Order with id " + strconv.Itoa(OrderId) + "not found")
+		}
+		// the sales reports count the paid orders, they are returned or refunded instead
+		if order.WasPaid() {
+			return fmt.Errorf("%w: order %d was paid, it is returned or refunded instead of deleted", ErrOrderPaid, OrderId)
 		}
-		return errors.New("Order with id " + strconv.Itoa(OrderId) + "not found")
+		// an unpaid order doesn't keep its books once it is gone
+		if order.Status == OrderPending {
+			if err := s.inventoryTables(tx).releasePendingStock(order, "order deleted", time.Now()); err != nil {
+				return err
+			}
+		}
+		if err := orders.Delete(OrderId); err != nil {
+			return err
+		}
+		return tx.Commit()
 	}
 }
 
@@ -140,13 +322,10 @@ func (s *InMemoryOrderStore) ListOrders(ctx context.Context) ([]Order, error) {
 		log.Println("Request canceled during Orders list retrieval")
 		return nil, ctx.Err()
 	default:
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
-		var orders []Order
-		for _, order := range s.Orders {
-			orders = append(orders, order)
+		if orders, ok := s.repo.ScanIndex(ctx, PrimaryIndex, "", ""); ok {
+			return orders, nil
 		}
-		return orders, nil
+		return s.repo.List(), nil
 	}
 }
 
@@ -157,9 +336,7 @@ func (s *InMemoryOrderStore) ViewOrderHistory(ctx context.Context) (map[int]time
 		return nil, ctx.Err()
 	default:
 		history := make(map[int]time.Time)
-		for _, order := range s.Orders {
-			s.Mu.Lock()
-			defer s.Mu.Unlock()
+		for _, order := range s.repo.List() {
 			history[order.ID] = order.CreatedAt
 		}
 		if len(history) == 0 {
@@ -175,20 +352,21 @@ func (s *InMemoryOrderStore) FetchOrderWithinTimeLimit(ctx context.Context, star
 		log.Println("Request canceled during Order history retrieval")
 		return nil, ctx.Err()
 	default:
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
-
-		var orders []Order
 		log.Printf("Fetching orders created between %s and %s\n", startTime, endTime)
-		for _, order := range s.Orders {
+		// with an ordered index only the orders of the range are visited
+		if orders, ok := s.repo.ScanIndex(ctx, CreatedAtIndex, IndexTime(startTime), IndexTime(endTime)); ok {
+			log.Printf("%d orders found within the time range using the %s index.\n", len(orders), CreatedAtIndex)
+			return orders, nil
+		}
+		orders := s.repo.Filter(func(order Order) bool {
 			log.Printf("Checking order ID %d with CreatedAt %s\n", order.ID, order.CreatedAt)
 			if !order.CreatedAt.Before(startTime) && !order.CreatedAt.After(endTime) {
 				log.Printf("Order ID %d is within the time range (inclusive).\n", order.ID)
-				orders = append(orders, order)
-			} else {
-				log.Printf("Order ID %d is outside the time range.\n", order.ID)
+				return true
 			}
-		}
+			log.Printf("Order ID %d is outside the time range.\n", order.ID)
+			return false
+		})
 
 		if len(orders) == 0 {
 			log.Println("No orders found within the specified time range.")
@@ -198,84 +376,10 @@ func (s *InMemoryOrderStore) FetchOrderWithinTimeLimit(ctx context.Context, star
 	}
 }
 
-func (s *InMemoryOrderStore) LoadOrders(ctx context.Context, filePath string) error {
-	select {
-	case <-ctx.Done():
-		log.Println("Context canceled during order loading")
-		return ctx.Err()
-	default:
-		dir := "database"
-		fullPath := filepath.Join(dir, filePath)
-
-		file, err := os.Open(fullPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				log.Printf("No existing order database found in %s, starting fresh.\n", fullPath)
-				s.NextID = 1
-				return nil
-			}
-			log.Printf("Failed to open file %s: %v\n", fullPath, err)
-			return err
-		}
-		defer file.Close()
-
-		var data struct {
-			Orders map[int]Order `json:"orders"`
-			NextID int           `json:"next_id"`
-		}
-
-		if err := json.NewDecoder(file).Decode(&data); err != nil {
-			log.Printf("Failed to decode orders from file %s: %v\n", fullPath, err)
-			return err
-		}
-
-		s.Mu.Lock()
-		defer s.Mu.Unlock()
-		s.Orders = data.Orders
-		s.NextID = data.NextID
-		log.Printf("Orders loaded successfully from %s\n", fullPath)
-		return nil
-	}
+func (s *InMemoryOrderStore) LoadOrders(ctx context.Context) error {
+	return s.repo.Load(ctx)
 }
 
-func (s *InMemoryOrderStore) SaveOrders(ctx context.Context, filePath string) error {
-	select {
-	case <-ctx.Done():
-		log.Println("Context canceled during order saving")
-		return ctx.Err()
-	default:
-		dir := "database"
-		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-			log.Printf("Failed to create directory %s: %v\n", dir, err)
-			return err
-		}
-
-		fullPath := filepath.Join(dir, filePath)
-
-		s.Mu.RLock()
-		defer s.Mu.RUnlock()
-
-		data := struct {
-			Orders map[int]Order `json:"orders"`
-			NextID int           `json:"next_id"`
-		}{
-			Orders: s.Orders,
-			NextID: s.NextID,
-		}
-
-		file, err := os.Create(fullPath)
-		if err != nil {
-			log.Printf("Failed to create file %s: %v\n", fullPath, err)
-			return err
-		}
-		defer file.Close()
-
-		if err := json.NewEncoder(file).Encode(data); err != nil {
-			log.Printf("Failed to write orders to file %s: %v\n", fullPath, err)
-			return err
-		}
-
-		log.Printf("Orders saved successfully to %s\n", fullPath)
-		return nil
-	}
+func (s *InMemoryOrderStore) SaveOrders(ctx context.Context) error {
+	return s.repo.Save(ctx)
 }
@@ -0,0 +1,129 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"testing"
+)
+
+// newTestStores wires the stores on memory drivers without a journal.
+func newTestStores(t *testing.T) *Stores {
+	t.Helper()
+	return NewInMemoryStores(nil, MemoryStorageDrivers())
+}
+
+// newTestCatalog creates an author, a book with the given stock and a customer.
+func newTestCatalog(t *testing.T, stock int) (*Stores, Book, Customer) {
+	t.Helper()
+	ctx := context.Background()
+	stores := newTestStores(t)
+	author, err := stores.Authors.CreateAuthor(ctx, Author{FirstName: "Ann", LastName: "Leckie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, err := stores.Books.CreateBook(ctx, Book{Title: "Ancillary Justice", Contributors: []Contributor{{AuthorID: author.ID, Role: RoleAuthor, Position: 1}}, Price: NewAmount(10), Stock: stock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	customer, err := stores.Customers.CreateCustomer(ctx, Customer{Name: "Bob", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stores, book, customer
+}
+
+// newTestOrder also orders quantity copies of the book.
+func newTestOrder(t *testing.T, stock int, quantity int) (*Stores, Book, Order) {
+	t.Helper()
+	stores, book, customer := newTestCatalog(t, stock)
+	order, err := stores.Orders.CreateOrder(context.Background(), Order{CustomerID: customer.ID, Items: []OrderItem{{BookID: book.ID, Quantity: quantity}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stores, book, order
+}
+
+func TestOrderStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from OrderStatus
+		to   OrderStatus
+		ok   bool
+	}{
+		{OrderPending, OrderPaid, true},
+		{OrderPending, OrderCancelled, true},
+		{OrderPending, OrderShipped, false},
+		{OrderPaid, OrderPacked, true},
+		{OrderPaid, OrderRefunded, true},
+		{OrderPacked, OrderShipped, true},
+		{OrderPacked, OrderPaid, false},
+		{OrderShipped, OrderDelivered, true},
+		{OrderShipped, OrderCancelled, false},
+		{OrderDelivered, OrderRefunded, true},
+		{OrderCancelled, OrderPending, false},
+		{OrderRefunded, OrderPaid, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.ok {
+				t.Fatalf("CanTransitionTo = %v, want %v", got, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTransitionOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		steps        []OrderStatus
+		wantErr      error
+		wantStock    int
+		wantReserved int
+	}{
+		{"paid then packed takes the books out of the stock", []OrderStatus{OrderPaid, OrderPacked}, nil, 3, 0},
+		{"cancelled pending order releases the hold", []OrderStatus{OrderCancelled}, nil, 5, 0},
+		{"refund before shipping gives the stock back", []OrderStatus{OrderPaid, OrderRefunded}, nil, 5, 0},
+		{"refund after delivery keeps the stock", []OrderStatus{OrderPaid, OrderPacked, OrderShipped, OrderDelivered, OrderRefunded}, nil, 3, 0},
+		{"skipping a state is refused", []OrderStatus{OrderShipped}, ErrInvalidTransition, 5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, order := newTestOrder(t, 5, 2)
+
+			var err error
+			for _, status := range tt.steps {
+				if order, err = stores.Orders.TransitionOrder(ctx, order.ID, status); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TransitionOrder error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				if order.Status != tt.steps[len(tt.steps)-1] || len(order.StatusHistory) != len(tt.steps)+1 {
+					t.Fatalf("order ended as %s with history %+v", order.Status, order.StatusHistory)
+				}
+			}
+			stored, _ := stores.Books.GetBook(ctx, book.ID)
+			if stored.Stock != tt.wantStock || stored.Reserved != tt.wantReserved {
+				t.Fatalf("stock = %d, reserved = %d, want %d and %d", stored.Stock, stored.Reserved, tt.wantStock, tt.wantReserved)
+			}
+		})
+	}
+}
+
+func TestTransitionMissingOrder(t *testing.T) {
+	if _, err := newTestStores(t).Orders.TransitionOrder(context.Background(), 42, OrderPaid); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("TransitionOrder error = %v, want %v", err, ErrRecordNotFound)
+	}
+}
+
+func TestUpdateOrderKeepsTheStatus(t *testing.T) {
+	ctx := context.Background()
+	stores, _, order := newTestOrder(t, 5, 2)
+	order.Status = OrderShipped
+	if _, err := stores.Orders.UpdateOrder(ctx, order.ID, order); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("UpdateOrder error = %v, want %v", err, ErrInvalidTransition)
+	}
+}
@@ -0,0 +1,285 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	. "FinalProject/payments"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------Definition of PaymentMethods--------------------------------
+// The payment store charges and refunds orders through the provider and keeps every attempt. The calls
+// to the provider happen outside of the transactions, so payments are taken one at a time instead, and
+// an order can't be charged twice by two requests racing each other.
+type InMemoryPaymentStore struct {
+	repo     *Repository[PaymentAttempt]
+	Orders   *InMemoryOrderStore
+	Provider Provider
+	mu       sync.Mutex
+}
+
+type PaymentStore interface {
+	PayOrder(ctx context.Context, orderId int, card Card) (Order, error)
+	RefundOrder(ctx context.Context, orderId int, status OrderStatus) (Order, error)
+	RefundPayment(ctx context.Context, orderId int, reference string, amount Money) (string, error)
+	ListPayments(ctx context.Context, orderId int) ([]PaymentAttempt, error)
+	LoadPayments(ctx context.Context) error
+	SavePayments(ctx context.Context) error
+}
+
+func NewInMemoryPaymentStore(journal *Journal, driver StorageDriver[PaymentAttempt]) *InMemoryPaymentStore {
+	return &InMemoryPaymentStore{
+		repo:     NewRepository[PaymentAttempt](paymentEntity, paymentStoreRank, journal, driver),
+		Provider: NewFakeProvider(),
+	}
+}
+
+// PayOrder authorizes the total of a pending order on the card and captures it, then the order is paid.
+// An authorization that can't be captured is voided, and a capture for an order that stopped being
+// pending, or whose total changed, meanwhile is refunded.
+func (s *InMemoryPaymentStore) PayOrder(ctx context.Context, orderId int, card Card) (Order, error) {
+	select {
+	case <-ctx.Done():
+		log.Printf("Request canceled during payment of order %d\n", orderId)
+		return Order{}, ctx.Err()
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		order, ok := s.Orders.repo.Find(orderId)
+		if !ok {
+			return Order{}, errors.New("Order with ID " + strconv.Itoa(orderId) + " not found")
+		}
+		if !order.Status.CanTransitionTo(OrderPaid) {
+			return Order{}, fmt.Errorf("%w: a %s order can't be paid", ErrInvalidTransition, order.Status)
+		}
+		amount := s.orderAmount(order)
+		attempts := len(s.repo.Filter(func(attempt PaymentAttempt) bool { return attempt.OrderID == orderId }))
+		reference := "order_" + strconv.Itoa(orderId) + "_" + strconv.Itoa(attempts+1)
+
+		authorizationID, err := s.Provider.Authorize(ctx, reference, amount, card)
+		if err := s.record(ctx, PaymentAttempt{OrderID: orderId, Operation: PaymentAuthorize, Amount: amount, TransactionID: authorizationID,
+			CardBrand: card.Brand(), CardLast4: card.Last4()}, err); err != nil {
+			return Order{}, err
+		}
+		if err != nil {
+			log.Printf("Payment of order %d was not authorized: %v\n", orderId, err)
+			return Order{}, err
+		}
+
+		captureID, err := s.Provider.Capture(ctx, authorizationID, amount)
+		if err := s.record(ctx, PaymentAttempt{OrderID: orderId, Operation: PaymentCapture, Amount: amount, TransactionID: captureID,
+			ParentID: authorizationID, CardBrand: card.Brand(), CardLast4: card.Last4()}, err); err != nil {
+			return Order{}, err
+		}
+		if err != nil {
+			log.Printf("Payment of order %d was not captured, voiding the authorization: %v\n", orderId, err)
+			voidID, voidErr := s.Provider.Void(ctx, authorizationID)
+			if recordErr := s.record(ctx, PaymentAttempt{OrderID: orderId, Operation: PaymentVoid, Amount: amount, TransactionID: voidID,
+				ParentID: authorizationID}, voidErr); recordErr != nil {
+				return Order{}, recordErr
+			}
+			return Order{}, err
+		}
+
+		// the card is charged, the order is paid even if the client went away meanwhile, as long as its
+		// items weren't changed while the card was charged
+		paid, err := s.Orders.transitionOrder(context.WithoutCancel(ctx), orderId, OrderPaid, func(order Order) error {
+			if total := s.orderAmount(order); total != amount {
+				return fmt.Errorf("%w: the order now costs %s, %s was charged", ErrInvalidTransition, total, amount)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Order %d was charged but can't be paid anymore, refunding it: %v\n", orderId, err)
+			reference := s.refundReference(captureID)
+			refundID, refundErr := s.Provider.Refund(ctx, captureID, reference, amount)
+			if recordErr := s.record(ctx, PaymentAttempt{OrderID: orderId, Operation: PaymentRefund, Amount: amount, TransactionID: refundID,
+				ParentID: captureID, Reference: reference}, refundErr); recordErr != nil {
+				return Order{}, recordErr
+			}
+			return Order{}, err
+		}
+		log.Printf("Order %d paid %s with card %s\n", orderId, amount, card.Last4())
+		return paid, nil
+	}
+}
+
+// RefundOrder gives the money of a paid order back through the provider before it is cancelled or
+// refunded. An order that was never charged through the provider just changes status.
+func (s *InMemoryPaymentStore) RefundOrder(ctx context.Context, orderId int, status OrderStatus) (Order, error) {
+	select {
+	case <-ctx.Done():
+		log.Printf("Request canceled during refund of order %d\n", orderId)
+		return Order{}, ctx.Err()
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		order, ok := s.Orders.repo.Find(orderId)
+		if !ok {
+			return Order{}, errors.New("Order with ID " + strconv.Itoa(orderId) + " not found")
+		}
+		if !order.Status.CanTransitionTo(status) {
+			return Order{}, fmt.Errorf("%w: a %s order can't become %s", ErrInvalidTransition, order.Status, status)
+		}
+
+		capture, refunded, ok := s.capturedPayment(orderId)
+		if ok && refunded < capture.Amount.Amount {
+			amount := Money{Amount: capture.Amount.Amount - refunded, Currency: capture.Amount.Currency}
+			reference := s.refundReference(capture.TransactionID)
+			refundID, err := s.Provider.Refund(ctx, capture.TransactionID, reference, amount)
+			if err := s.record(ctx, PaymentAttempt{OrderID: orderId, Operation: PaymentRefund, Amount: amount, TransactionID: refundID,
+				ParentID: capture.TransactionID, Reference: reference, CardBrand: capture.CardBrand, CardLast4: capture.CardLast4}, err); err != nil {
+				return Order{}, err
+			}
+			if err != nil {
+				log.Printf("Refund of order %d failed, the order stays %s: %v\n", orderId, order.Status, err)
+				return Order{}, err
+			}
+			log.Printf("Order %d refunded %s\n", orderId, amount)
+		} else if !ok {
+			log.Printf("Order %d has no captured payment, nothing to refund\n", orderId)
+		}
+		return s.Orders.TransitionOrder(context.WithoutCancel(ctx), orderId, status)
+	}
+}
+
+// RefundPayment gives part of the captured payment of an order back, never more than what is left of it.
+// It returns the transaction id of the refund, empty when the order was never charged through the provider.
+// The reference names the refund for the caller: a retry with the reference of a refund that went through
+// gets that refund back instead of a second one, and one that failed is tried again under the same reference.
+func (s *InMemoryPaymentStore) RefundPayment(ctx context.Context, orderId int, reference string, amount Money) (string, error) {
+	select {
+	case <-ctx.Done():
+		log.Printf("Request canceled during partial refund of order %d\n", orderId)
+		return "", ctx.Err()
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		capture, refunded, ok := s.capturedPayment(orderId)
+		if !ok {
+			log.Printf("Order %d has no captured payment, nothing to refund\n", orderId)
+			return "", nil
+		}
+		for _, attempt := range s.repo.Filter(func(attempt PaymentAttempt) bool {
+			return attempt.Operation == PaymentRefund && attempt.ParentID == capture.TransactionID && attempt.Reference == reference
+		}) {
+			if attempt.Result == PaymentSucceeded {
+				log.Printf("Refund %s of order %d was already made\n", reference, orderId)
+				return attempt.TransactionID, nil
+			}
+		}
+		if NormalizeCurrency(amount.Currency) != NormalizeCurrency(capture.Amount.Currency) {
+			return "", errors.New("Order " + strconv.Itoa(orderId) + " was paid in " + capture.Amount.Currency + ", not " + amount.Currency)
+		}
+		if left := capture.Amount.Amount - refunded; amount.Amount > left {
+			amount.Amount = left
+		}
+		if amount.Amount <= 0 {
+			log.Printf("Order %d was already refunded in full\n", orderId)
+			return "", nil
+		}
+		refundID, err := s.Provider.Refund(ctx, capture.TransactionID, reference, amount)
+		if err := s.record(ctx, PaymentAttempt{OrderID: orderId, Operation: PaymentRefund, Amount: amount, TransactionID: refundID,
+			ParentID: capture.TransactionID, Reference: reference, CardBrand: capture.CardBrand, CardLast4: capture.CardLast4}, err); err != nil {
+			return "", err
+		}
+		if err != nil {
+			log.Printf("Partial refund of order %d failed: %v\n", orderId, err)
+			return "", err
+		}
+		log.Printf("Order %d partially refunded %s\n", orderId, amount)
+		return refundID, nil
+	}
+}
+
+func (s *InMemoryPaymentStore) ListPayments(ctx context.Context, orderId int) ([]PaymentAttempt, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during payments retrieval of order", orderId)
+		return nil, ctx.Err()
+	default:
+		if _, ok := s.Orders.repo.Find(orderId); !ok {
+			return nil, errors.New("Order with ID " + strconv.Itoa(orderId) + " not found")
+		}
+		attempts := s.repo.Filter(func(attempt PaymentAttempt) bool { return attempt.OrderID == orderId })
+		sort.Slice(attempts, func(i, j int) bool { return attempts[i].ID < attempts[j].ID })
+		return attempts, nil
+	}
+}
+
+// capturedPayment returns the last successful capture of the order and how much of it was refunded.
+func (s *InMemoryPaymentStore) capturedPayment(orderId int) (PaymentAttempt, int64, bool) {
+	attempts := s.repo.Filter(func(attempt PaymentAttempt) bool {
+		return attempt.OrderID == orderId && attempt.Result == PaymentSucceeded
+	})
+	sort.Slice(attempts, func(i, j int) bool { return attempts[i].ID < attempts[j].ID })
+	var capture PaymentAttempt
+	found := false
+	for _, attempt := range attempts {
+		if attempt.Operation == PaymentCapture {
+			capture, found = attempt, true
+		}
+	}
+	refunded := int64(0)
+	for _, attempt := range attempts {
+		if found && attempt.Operation == PaymentRefund && attempt.ParentID == capture.TransactionID {
+			refunded += attempt.Amount.Amount
+		}
+	}
+	return capture, refunded, found
+}
+
+// refundReference numbers the refunds of a capture from the attempts kept, failed ones included, so a
+// full refund never gets the reference of an earlier one, even after a restart.
+func (s *InMemoryPaymentStore) refundReference(captureID string) string {
+	refunds := s.repo.Filter(func(attempt PaymentAttempt) bool {
+		return attempt.Operation == PaymentRefund && attempt.ParentID == captureID
+	})
+	return "refund_" + strconv.Itoa(len(refunds)+1)
+}
+
+func (s *InMemoryPaymentStore) orderAmount(order Order) Money {
+	currency := order.Currency
+	if currency == "" {
+		currency = s.Orders.Pricing.Rates.BaseCurrency()
+	}
+	return order.TotalPrice.In(currency)
+}
+
+// record keeps the attempt with the result of the provider call, err is the error of that call.
+func (s *InMemoryPaymentStore) record(ctx context.Context, attempt PaymentAttempt, err error) error {
+	attempt.Provider = s.Provider.Name()
+	attempt.CreatedAt = time.Now()
+	switch {
+	case err == nil:
+		attempt.Result = PaymentSucceeded
+	case errors.Is(err, ErrDeclined):
+		attempt.Result = PaymentDeclined
+		attempt.Error = err.Error()
+	default:
+		attempt.Result = PaymentFailed
+		attempt.Error = err.Error()
+	}
+	if _, err := s.repo.Insert(context.WithoutCancel(ctx), attempt); err != nil {
+		log.Printf("Failed to record the %s attempt of order %d: %v\n", attempt.Operation, attempt.OrderID, err)
+		return err
+	}
+	return nil
+}
+
+func (s *InMemoryPaymentStore) LoadPayments(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemoryPaymentStore) SavePayments(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
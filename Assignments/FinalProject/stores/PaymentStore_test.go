@@ -0,0 +1,150 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	. "FinalProject/payments"
+	"context"
+	"errors"
+	"testing"
+)
+
+// racingProvider lets the test change the order while its payment is being captured.
+type racingProvider struct {
+	*FakeProvider
+	duringCapture func()
+}
+
+func (p racingProvider) Capture(ctx context.Context, authorizationID string, amount Money) (string, error) {
+	if p.duringCapture != nil {
+		p.duringCapture()
+	}
+	return p.FakeProvider.Capture(ctx, authorizationID, amount)
+}
+
+func TestPayOrder(t *testing.T) {
+	type attempt struct {
+		operation PaymentOperation
+		result    PaymentResult
+	}
+	tests := []struct {
+		name          string
+		card          string
+		duringCapture func(t *testing.T, stores *Stores, book Book, order Order)
+		wantErr       error
+		wantStatus    OrderStatus
+		wantAttempts  []attempt
+	}{
+		{
+			name:         "approved",
+			card:         "4242 4242 4242 4242",
+			wantStatus:   OrderPaid,
+			wantAttempts: []attempt{{PaymentAuthorize, PaymentSucceeded}, {PaymentCapture, PaymentSucceeded}},
+		},
+		{
+			name:         "declined",
+			card:         "4000 0000 0000 0002",
+			wantErr:      ErrDeclined,
+			wantStatus:   OrderPending,
+			wantAttempts: []attempt{{PaymentAuthorize, PaymentDeclined}},
+		},
+		{
+			name:         "timeout",
+			card:         "4000 0000 0000 0119",
+			wantErr:      ErrTimeout,
+			wantStatus:   OrderPending,
+			wantAttempts: []attempt{{PaymentAuthorize, PaymentFailed}},
+		},
+		{
+			name:       "failed capture voids the authorization",
+			card:       "4000 0000 0000 0341",
+			wantErr:    ErrDeclined,
+			wantStatus: OrderPending,
+			wantAttempts: []attempt{
+				{PaymentAuthorize, PaymentSucceeded}, {PaymentCapture, PaymentDeclined}, {PaymentVoid, PaymentSucceeded},
+			},
+		},
+		{
+			name: "items changed during the capture are refunded",
+			card: "4242 4242 4242 4242",
+			duringCapture: func(t *testing.T, stores *Stores, book Book, order Order) {
+				if _, err := stores.Orders.UpdateOrder(context.Background(), order.ID, Order{Items: []OrderItem{{BookID: book.ID, Quantity: 1}}}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr:    ErrInvalidTransition,
+			wantStatus: OrderPending,
+			wantAttempts: []attempt{
+				{PaymentAuthorize, PaymentSucceeded}, {PaymentCapture, PaymentSucceeded}, {PaymentRefund, PaymentSucceeded},
+			},
+		},
+		{
+			name: "order cancelled during the capture is refunded",
+			card: "4242 4242 4242 4242",
+			duringCapture: func(t *testing.T, stores *Stores, book Book, order Order) {
+				if _, err := stores.Orders.TransitionOrder(context.Background(), order.ID, OrderCancelled); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr:    ErrInvalidTransition,
+			wantStatus: OrderCancelled,
+			wantAttempts: []attempt{
+				{PaymentAuthorize, PaymentSucceeded}, {PaymentCapture, PaymentSucceeded}, {PaymentRefund, PaymentSucceeded},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, order := newTestOrder(t, 5, 2)
+			provider := racingProvider{FakeProvider: NewFakeProvider()}
+			if tt.duringCapture != nil {
+				provider.duringCapture = func() { tt.duringCapture(t, stores, book, order) }
+			}
+			stores.Payments.Provider = provider
+
+			_, err := stores.Payments.PayOrder(ctx, order.ID, Card{Number: tt.card, ExpMonth: 12, ExpYear: 2099})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PayOrder error = %v, want %v", err, tt.wantErr)
+			}
+			stored, _ := stores.Orders.GetOrder(ctx, order.ID)
+			if stored.Status != tt.wantStatus {
+				t.Fatalf("order is %s, want %s", stored.Status, tt.wantStatus)
+			}
+			attempts, err := stores.Payments.ListPayments(ctx, order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(attempts) != len(tt.wantAttempts) {
+				t.Fatalf("%d payment attempts, want %d: %+v", len(attempts), len(tt.wantAttempts), attempts)
+			}
+			for i, want := range tt.wantAttempts {
+				if attempts[i].Operation != want.operation || attempts[i].Result != want.result {
+					t.Fatalf("attempt %d is %s %s, want %s %s", i, attempts[i].Operation, attempts[i].Result, want.operation, want.result)
+				}
+			}
+			// what was charged is always given back in full when the order isn't paid
+			if tt.wantStatus != OrderPaid && len(attempts) == 3 && attempts[2].Amount != attempts[1].Amount {
+				t.Fatalf("refunded %s of the %s charged", attempts[2].Amount, attempts[1].Amount)
+			}
+		})
+	}
+}
+
+func TestRefundOrderGivesTheCaptureBack(t *testing.T) {
+	ctx := context.Background()
+	stores, _, order := newTestOrder(t, 5, 2)
+	if _, err := stores.Payments.PayOrder(ctx, order.ID, Card{Number: "4242 4242 4242 4242"}); err != nil {
+		t.Fatal(err)
+	}
+	refunded, err := stores.Payments.RefundOrder(ctx, order.ID, OrderRefunded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunded.Status != OrderRefunded {
+		t.Fatalf("order is %s", refunded.Status)
+	}
+	capture, refundedAmount, ok := stores.Payments.capturedPayment(order.ID)
+	if !ok || refundedAmount != capture.Amount.Amount {
+		t.Fatalf("refunded %d of %+v", refundedAmount, capture.Amount)
+	}
+}
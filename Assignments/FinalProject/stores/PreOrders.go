@@ -0,0 +1,205 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// ----------------------------------------------Definition of the Pre-orders--------------------------------
+// The pre-orders are items of the orders, they wait on backorder like the others and are served by
+// allocateBackorders in the same queue, once their book is released.
+
+// ReleasePreOrders allocates the pre-orders of the books released by now, in the order they were
+// placed. What the stock can't cover stays on backorder for the next receipts. It returns the number
+// of copies allocated.
+func (s *InMemoryOrderStore) ReleasePreOrders(ctx context.Context) (int, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during the release of the pre-orders")
+		return 0, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, WriteLock(s.Books.repo), WriteLock(s.repo), WriteLock(s.Reservations.repo), WriteLock(s.Inventory.repo))
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+		inventory := s.inventoryTables(tx)
+
+		now := time.Now()
+		var released []int
+		seen := make(map[int]bool)
+		for _, order := range inventory.orders.All() {
+			if !order.Status.Waiting() {
+				continue
+			}
+			for _, item := range order.Items {
+				if !item.PreOrder || item.Backordered == 0 || seen[item.BookID] {
+					continue
+				}
+				if book, ok := inventory.books.Get(item.BookID); ok && book.Released(now) {
+					seen[item.BookID] = true
+					released = append(released, item.BookID)
+				}
+			}
+		}
+		sort.Ints(released)
+
+		allocated := 0
+		for _, bookId := range released {
+			count, err := inventory.allocateBackorders(bookId, now, s.Reservations.TTL)
+			if err != nil {
+				return 0, err
+			}
+			if count > 0 {
+				log.Printf("Book %d is released, %d pre-ordered copies allocated\n", bookId, count)
+			}
+			allocated += count
+		}
+		if allocated == 0 {
+			return 0, nil
+		}
+		return allocated, tx.Commit()
+	}
+}
+
+// ListPreOrders gives the pre-orders of a customer with their place in the queue of their book.
+func (s *InMemoryOrderStore) ListPreOrders(ctx context.Context, customerId int) ([]PreOrder, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during pre-orders retrieval of customer", customerId)
+		return nil, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Books.repo), ReadLock(s.Customers.repo), ReadLock(s.repo))
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		books := Table(tx, s.Books.repo)
+
+		if _, ok := Table(tx, s.Customers.repo).Get(customerId); !ok {
+			return nil, fmt.Errorf("%w: customer with ID %d", ErrRecordNotFound, customerId)
+		}
+		orders := Table(tx, s.repo).All()
+		sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+
+		preOrders := []PreOrder{}
+		for _, order := range orders {
+			if order.CustomerID != customerId || order.Status == OrderCancelled || order.Status == OrderRefunded {
+				continue
+			}
+			for _, item := range order.Items {
+				if !item.PreOrder {
+					continue
+				}
+				preOrder := PreOrder{
+					OrderID:     order.ID,
+					OrderStatus: order.Status,
+					BookID:      item.BookID,
+					Title:       item.Title,
+					Quantity:    item.Quantity,
+					OrderedAt:   order.CreatedAt,
+				}
+				// the release date is the one of the book now, it can be postponed
+				if book, ok := books.Get(item.BookID); ok {
+					preOrder.PublishedAt = book.PublishedAt
+				}
+				if order.Status.Waiting() && item.Backordered > 0 {
+					preOrder.Waiting = item.Backordered
+					preOrder.Position, preOrder.CopiesAhead = queuePosition(orders, order.ID, item.BookID)
+				}
+				preOrders = append(preOrders, preOrder)
+			}
+		}
+		return preOrders, nil
+	}
+}
+
+// PreOrderDemand sums up what was pre-ordered of each book, the cancelled and refunded orders left out.
+func (s *InMemoryOrderStore) PreOrderDemand(ctx context.Context) (PreOrderReport, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during the pre-order demand report")
+		return PreOrderReport{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Books.repo), ReadLock(s.repo))
+		if err != nil {
+			return PreOrderReport{}, err
+		}
+		defer tx.Rollback()
+		books := Table(tx, s.Books.repo)
+
+		now := time.Now()
+		report := PreOrderReport{Timestamp: now, Books: []PreOrderDemand{}}
+		demand := make(map[int]*PreOrderDemand)
+		for _, order := range Table(tx, s.repo).All() {
+			if order.Status == OrderCancelled || order.Status == OrderRefunded {
+				continue
+			}
+			preOrdered := false
+			for _, item := range order.Items {
+				if !item.PreOrder {
+					continue
+				}
+				line, ok := demand[item.BookID]
+				if !ok {
+					line = &PreOrderDemand{BookID: item.BookID, Title: item.Title}
+					demand[item.BookID] = line
+				}
+				line.Orders++
+				line.Copies += item.Quantity
+				if order.Status.Waiting() {
+					line.Waiting += item.Backordered
+				}
+				preOrdered = true
+			}
+			if preOrdered {
+				report.TotalOrders++
+			}
+		}
+
+		for _, line := range demand {
+			if book, ok := books.Get(line.BookID); ok {
+				line.Title, line.PublishedAt, line.Available = book.Title, book.PublishedAt, book.Available()
+			}
+			line.Released = !line.PublishedAt.After(now)
+			if line.Waiting > line.Available {
+				line.Shortfall = line.Waiting - line.Available
+			}
+			report.TotalCopies += line.Copies
+			report.Books = append(report.Books, *line)
+		}
+		sort.Slice(report.Books, func(i, j int) bool {
+			if !report.Books[i].PublishedAt.Equal(report.Books[j].PublishedAt) {
+				return report.Books[i].PublishedAt.Before(report.Books[j].PublishedAt)
+			}
+			return report.Books[i].BookID < report.Books[j].BookID
+		})
+		return report, nil
+	}
+}
+
+// queuePosition is the place of an order in the queue of a book, the orders placed before it that
+// still wait for the book are served first. It returns the position and the copies they wait for.
+func queuePosition(orders []Order, orderId int, bookId int) (int, int) {
+	position, ahead := 1, 0
+	for _, order := range orders {
+		if order.ID >= orderId || !order.Status.Waiting() {
+			continue
+		}
+		waiting := 0
+		for _, item := range order.Items {
+			if item.BookID == bookId {
+				waiting += item.Backordered
+			}
+		}
+		if waiting > 0 {
+			position++
+			ahead += waiting
+		}
+	}
+	return position, ahead
+}
@@ -0,0 +1,95 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestPreOrders places an order per quantity for a book published tomorrow, with stock in the
+// warehouse already.
+func newTestPreOrders(t *testing.T, stock int, quantities ...int) (*Stores, Book, []Order) {
+	t.Helper()
+	ctx := context.Background()
+	stores, book, customer := newTestCatalog(t, stock)
+	book.PublishedAt = time.Now().Add(24 * time.Hour)
+	book, err := stores.Books.UpdateBook(ctx, book.ID, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var orders []Order
+	for _, quantity := range quantities {
+		order, err := stores.Orders.CreateOrder(ctx, Order{CustomerID: customer.ID, Items: []OrderItem{{BookID: book.ID, Quantity: quantity}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		orders = append(orders, order)
+	}
+	return stores, book, orders
+}
+
+func TestPreOrdersWaitForTheRelease(t *testing.T) {
+	ctx := context.Background()
+	stores, book, orders := newTestPreOrders(t, 5, 2, 4)
+	if item := orders[0].Items[0]; !item.PreOrder || item.Backordered != 2 {
+		t.Fatalf("item %+v is not pre-ordered", item)
+	}
+	stored, _ := stores.Books.GetBook(ctx, book.ID)
+	if stored.Reserved != 0 {
+		t.Fatalf("%d copies held before the release", stored.Reserved)
+	}
+	// the copies received before the release day stay in the warehouse
+	if _, err := stores.Inventory.RecordStockMovement(ctx, book.ID, StockMovement{Kind: MovementReceipt, Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if allocated, err := stores.Orders.ReleasePreOrders(ctx); err != nil || allocated != 0 {
+		t.Fatalf("allocated %d copies, %v, before the release", allocated, err)
+	}
+
+	preOrders, err := stores.Orders.ListPreOrders(ctx, orders[1].CustomerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preOrders) != 2 || preOrders[1].Position != 2 || preOrders[1].CopiesAhead != 2 || preOrders[1].Waiting != 4 {
+		t.Fatalf("pre-orders %+v", preOrders)
+	}
+}
+
+func TestReleasePreOrders(t *testing.T) {
+	tests := []struct {
+		name            string
+		stock           int
+		wantAllocated   int
+		wantBackordered []int
+	}{
+		{"enough stock for everyone", 6, 4, []int{0, 0}},
+		{"the first orders are served first", 3, 3, []int{0, 1}},
+		{"no stock keeps everyone waiting", 0, 0, []int{2, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, orders := newTestPreOrders(t, tt.stock, 2, 2)
+			book.PublishedAt = time.Now().Add(-time.Minute)
+			if _, err := stores.Books.UpdateBook(ctx, book.ID, book); err != nil {
+				t.Fatal(err)
+			}
+
+			allocated, err := stores.Orders.ReleasePreOrders(ctx)
+			if err != nil || allocated != tt.wantAllocated {
+				t.Fatalf("allocated %d copies, %v, want %d", allocated, err, tt.wantAllocated)
+			}
+			for i, want := range tt.wantBackordered {
+				order, _ := stores.Orders.GetOrder(ctx, orders[i].ID)
+				if order.Backordered() != want {
+					t.Fatalf("order %d has %d copies on backorder, want %d", order.ID, order.Backordered(), want)
+				}
+			}
+			ledger, _ := stores.Inventory.GetStockLedger(ctx, book.ID)
+			if !ledger.Reconciled || ledger.Reserved != tt.wantAllocated {
+				t.Fatalf("%d copies reserved, want %d, ledger reconciled %v", ledger.Reserved, tt.wantAllocated, ledger.Reconciled)
+			}
+		})
+	}
+}
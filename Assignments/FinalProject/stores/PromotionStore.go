@@ -0,0 +1,243 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	. "FinalProject/pricing"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type InMemoryPromotionStore struct {
+	repo   *Repository[Promotion]
+	Orders *InMemoryOrderStore
+}
+
+type PromotionStore interface {
+	CreatePromotion(ctx context.Context, promotion Promotion) (Promotion, error)
+	GetPromotion(ctx context.Context, id int) (Promotion, error)
+	UpdatePromotion(ctx context.Context, id int, promotion Promotion) (Promotion, error)
+	DeletePromotion(ctx context.Context, id int) error
+	ListPromotions(ctx context.Context) ([]Promotion, error)
+	ListRedemptions(ctx context.Context, id int) ([]Redemption, error)
+	LoadPromotions(ctx context.Context) error
+	SavePromotions(ctx context.Context) error
+}
+
+func NewInMemoryPromotionStore(journal *Journal, driver StorageDriver[Promotion]) *InMemoryPromotionStore {
+	return &InMemoryPromotionStore{repo: NewRepository[Promotion](promotionEntity, promotionStoreRank, journal, driver)}
+}
+
+func (s *InMemoryPromotionStore) CreatePromotion(ctx context.Context, promotion Promotion) (Promotion, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during promotion creation")
+		return Promotion{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, WriteLock(s.repo))
+		if err != nil {
+			return Promotion{}, err
+		}
+		defer tx.Rollback()
+		promotions := Table(tx, s.repo)
+
+		promotion.Code = NormalizeCouponCode(promotion.Code)
+		if err := validatePromotion(promotion, promotions.All()); err != nil {
+			return Promotion{}, err
+		}
+		promotion.ID, err = promotions.NextID()
+		if err != nil {
+			return Promotion{}, err
+		}
+		promotion.CreatedAt = time.Now()
+		if err := promotions.Put(promotion.ID, promotion); err != nil {
+			return Promotion{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return Promotion{}, err
+		}
+		log.Printf("Promotion created successfully. ID: %d\n", promotion.ID)
+		return promotion, nil
+	}
+}
+
+func (s *InMemoryPromotionStore) GetPromotion(ctx context.Context, promotionId int) (Promotion, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during promotion retrieval:", promotionId)
+		return Promotion{}, ctx.Err()
+	default:
+		promotion, ok := s.repo.Find(promotionId)
+		if !ok {
+			log.Printf("Promotion with ID %d not found", promotionId)
+			return Promotion{}, errors.New("Promotion with ID " + strconv.Itoa(promotionId) + " not found")
+		}
+		return promotion, nil
+	}
+}
+
+func (s *InMemoryPromotionStore) UpdatePromotion(ctx context.Context, promotionId int, promotion Promotion) (Promotion, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during promotion update:", promotionId)
+		return Promotion{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, WriteLock(s.repo))
+		if err != nil {
+			return Promotion{}, err
+		}
+		defer tx.Rollback()
+		promotions := Table(tx, s.repo)
+
+		unchangedPromotion, ok := promotions.Get(promotionId)
+		if !ok {
+			return Promotion{}, errors.New("Promotion with ID " + strconv.Itoa(promotionId) + " not found")
+		}
+		promotion.ID = unchangedPromotion.ID
+		promotion.CreatedAt = unchangedPromotion.CreatedAt
+		promotion.Code = NormalizeCouponCode(promotion.Code)
+
+		var others []Promotion
+		for _, other := range promotions.All() {
+			if other.ID != promotionId {
+				others = append(others, other)
+			}
+		}
+		if err := validatePromotion(promotion, others); err != nil {
+			return Promotion{}, err
+		}
+		if err := promotions.Put(promotion.ID, promotion); err != nil {
+			return Promotion{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return Promotion{}, err
+		}
+		log.Printf("Promotion updated successfully. ID: %d\n", promotion.ID)
+		return promotion, nil
+	}
+}
+
+// DeletePromotion keeps the redemptions, the orders still carry what the promotion gave them.
+func (s *InMemoryPromotionStore) DeletePromotion(ctx context.Context, promotionId int) error {
+	select {
+	case <-ctx.Done():
+		log.Printf("Request canceled during deletion of promotion ID %d", promotionId)
+		return ctx.Err()
+	default:
+		err := s.repo.Delete(ctx, promotionId)
+		if errors.Is(err, ErrRecordNotFound) {
+			return errors.New("Promotion with ID " + strconv.Itoa(promotionId) + " not found")
+		}
+		return err
+	}
+}
+
+func (s *InMemoryPromotionStore) ListPromotions(ctx context.Context) ([]Promotion, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during promotions list retrieval")
+		return nil, ctx.Err()
+	default:
+		return s.repo.List(), nil
+	}
+}
+
+func (s *InMemoryPromotionStore) ListRedemptions(ctx context.Context, promotionId int) ([]Redemption, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during redemptions retrieval of promotion", promotionId)
+		return nil, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Orders.repo), ReadLock(s.repo))
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if _, ok := Table(tx, s.repo).Get(promotionId); !ok {
+			return nil, errors.New("Promotion with ID " + strconv.Itoa(promotionId) + " not found")
+		}
+		redemptions := []Redemption{}
+		for _, order := range Table(tx, s.Orders.repo).All() {
+			for _, applied := range order.Promotions {
+				if applied.PromotionID == promotionId {
+					redemptions = append(redemptions, Redemption{
+						OrderID:     order.ID,
+						CustomerID:  order.CustomerID,
+						OrderStatus: order.Status,
+						Code:        applied.Code,
+						Discount:    applied.Discount,
+						Currency:    order.Currency,
+						RedeemedAt:  order.CreatedAt,
+					})
+				}
+			}
+		}
+		return redemptions, nil
+	}
+}
+
+// promotionUsage counts the orders using each promotion. Cancelled orders give their use back, and the
+// order being repriced doesn't count against itself.
+func promotionUsage(orders []Order, customerId int, excludedOrderId int) PromotionUsage {
+	usage := PromotionUsage{Total: make(map[int]int), ByCustomer: make(map[int]int)}
+	for _, order := range orders {
+		if order.ID == excludedOrderId || order.Status == OrderCancelled {
+			continue
+		}
+		for _, applied := range order.Promotions {
+			usage.Total[applied.PromotionID]++
+			if order.CustomerID == customerId {
+				usage.ByCustomer[applied.PromotionID]++
+			}
+		}
+	}
+	return usage
+}
+
+func validatePromotion(promotion Promotion, others []Promotion) error {
+	if strings.TrimSpace(promotion.Name) == "" {
+		return errors.New("A promotion needs a name")
+	}
+	switch promotion.Kind {
+	case PromotionPercentage:
+		if promotion.Percent <= 0 || promotion.Percent > 100 {
+			return errors.New("A percentage promotion needs a percent between 0 and 100")
+		}
+	case PromotionFixed:
+		if promotion.Amount <= 0 {
+			return errors.New("A fixed promotion needs a positive amount")
+		}
+	case PromotionBuyXGetY:
+		if promotion.BuyQuantity <= 0 || promotion.FreeQuantity <= 0 {
+			return errors.New("A buy_x_get_y promotion needs a positive buy_quantity and free_quantity")
+		}
+	default:
+		return errors.New("Unknown promotion kind '" + string(promotion.Kind) + "', expected percentage, fixed or buy_x_get_y")
+	}
+	if promotion.MinOrderValue < 0 || promotion.MaxUses < 0 || promotion.MaxUsesPerCustomer < 0 {
+		return errors.New("The minimum order value and the usage limits can't be negative")
+	}
+	if promotion.StartsAt != nil && promotion.EndsAt != nil && !promotion.EndsAt.After(*promotion.StartsAt) {
+		return errors.New("A promotion must end after it starts")
+	}
+	if promotion.Code != "" {
+		for _, other := range others {
+			if NormalizeCouponCode(other.Code) == promotion.Code {
+				return errors.New("Coupon code " + promotion.Code + " is already used by promotion " + strconv.Itoa(other.ID))
+			}
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryPromotionStore) LoadPromotions(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemoryPromotionStore) SavePromotions(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
@@ -0,0 +1,36 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"testing"
+)
+
+func TestPromotionUsage(t *testing.T) {
+	orders := []Order{
+		{ID: 1, CustomerID: 1, Status: OrderPaid, Promotions: []AppliedPromotion{{PromotionID: 5}}},
+		{ID: 2, CustomerID: 2, Status: OrderPending, Promotions: []AppliedPromotion{{PromotionID: 5}, {PromotionID: 6}}},
+		{ID: 3, CustomerID: 1, Status: OrderCancelled, Promotions: []AppliedPromotion{{PromotionID: 5}}},
+		{ID: 4, CustomerID: 1, Status: OrderPending, Promotions: []AppliedPromotion{{PromotionID: 6}}},
+	}
+	tests := []struct {
+		name         string
+		customerId   int
+		excluded     int
+		promotionId  int
+		wantTotal    int
+		wantCustomer int
+	}{
+		{"cancelled orders give their use back", 1, 0, 5, 2, 1},
+		{"the repriced order doesn't count", 1, 4, 6, 1, 0},
+		{"another customer", 2, 0, 6, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := promotionUsage(orders, tt.customerId, tt.excluded)
+			if usage.Total[tt.promotionId] != tt.wantTotal || usage.ByCustomer[tt.promotionId] != tt.wantCustomer {
+				t.Fatalf("usage %d total, %d by the customer, want %d and %d",
+					usage.Total[tt.promotionId], usage.ByCustomer[tt.promotionId], tt.wantTotal, tt.wantCustomer)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	. "FinalProject/pricing"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// ----------------------------------------------Definition of PurchaseOrderMethods--------------------------------
+// Purchase orders restock the books. Receiving one records a receipt in the ledger for every book that
+// came in, at its cost, so the stock grows and the average cost of the book follows what was paid.
+type InMemoryPurchaseOrderStore struct {
+	repo      *Repository[PurchaseOrder]
+	Suppliers *InMemorySupplierStore
+	Books     *InMemoryBookStore
+	Inventory *InMemoryInventoryStore
+}
+
+type PurchaseOrderStore interface {
+	CreatePurchaseOrder(ctx context.Context, purchaseOrder PurchaseOrder) (PurchaseOrder, error)
+	GetPurchaseOrder(ctx context.Context, id int) (PurchaseOrder, error)
+	ListPurchaseOrders(ctx context.Context, supplierId int, status PurchaseOrderStatus) ([]PurchaseOrder, error)
+	UpdatePurchaseOrder(ctx context.Context, id int, purchaseOrder PurchaseOrder) (PurchaseOrder, error)
+	ReceivePurchaseOrder(ctx context.Context, id int, receipt PurchaseReceipt) (PurchaseOrder, error)
+	CancelPurchaseOrder(ctx context.Context, id int) (PurchaseOrder, error)
+	LoadPurchaseOrders(ctx context.Context) error
+	SavePurchaseOrders(ctx context.Context) error
+}
+
+func NewInMemoryPurchaseOrderStore(journal *Journal, driver StorageDriver[PurchaseOrder]) *InMemoryPurchaseOrderStore {
+	return &InMemoryPurchaseOrderStore{repo: NewRepository[PurchaseOrder](purchaseEntity, purchaseOrderStoreRank, journal, driver)}
+}
+
+func (s *InMemoryPurchaseOrderStore) CreatePurchaseOrder(ctx context.Context, purchaseOrder PurchaseOrder) (PurchaseOrder, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during purchase order creation")
+		return PurchaseOrder{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Books.repo), ReadLock(s.Suppliers.repo), WriteLock(s.repo))
+		if err != nil {
+			return PurchaseOrder{}, err
+		}
+		defer tx.Rollback()
+		purchaseOrders := Table(tx, s.repo)
+
+		now := time.Now()
+		purchaseOrder.CreatedAt = now
+		if err := s.prepare(tx, &purchaseOrder, now); err != nil {
+			return PurchaseOrder{}, err
+		}
+		purchaseOrder.ID, err = purchaseOrders.NextID()
+		if err != nil {
+			return PurchaseOrder{}, err
+		}
+		if err := purchaseOrders.Put(purchaseOrder.ID, purchaseOrder); err != nil {
+			return PurchaseOrder{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return PurchaseOrder{}, err
+		}
+		log.Printf("Purchase order %d placed with supplier %d for %d copies\n", purchaseOrder.ID, purchaseOrder.SupplierID, purchaseOrder.Outstanding())
+		return purchaseOrder, nil
+	}
+}
+
+func (s *InMemoryPurchaseOrderStore) GetPurchaseOrder(ctx context.Context, purchaseOrderId int) (PurchaseOrder, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during purchase order retrieval:", purchaseOrderId)
+		return PurchaseOrder{}, ctx.Err()
+	default:
+		purchaseOrder, ok := s.repo.Find(purchaseOrderId)
+		if !ok {
+			log.Printf("Purchase order with ID %d not found", purchaseOrderId)
+			return PurchaseOrder{}, fmt.Errorf("%w: purchase order with ID %d", ErrRecordNotFound, purchaseOrderId)
+		}
+		return purchaseOrder, nil
+	}
+}
+
+// ListPurchaseOrders lists the purchase orders of a supplier, or of all of them when supplierId is 0,
+// with the status when one is given.
+func (s *InMemoryPurchaseOrderStore) ListPurchaseOrders(ctx context.Context, supplierId int, status PurchaseOrderStatus) ([]PurchaseOrder, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during purchase orders retrieval")
+		return nil, ctx.Err()
+	default:
+		purchaseOrders := []PurchaseOrder{}
+		for _, purchaseOrder := range s.repo.List() {
+			if (supplierId == 0 || purchaseOrder.SupplierID == supplierId) && (status == "" || purchaseOrder.Status == status) {
+				purchaseOrders = append(purchaseOrders, purchaseOrder)
+			}
+		}
+		return purchaseOrders, nil
+	}
+}
+
+// UpdatePurchaseOrder replaces the supplier, the lines, the expected date and the notes of an order,
+// as long as nothing was received for it.
+func (s *InMemoryPurchaseOrderStore) UpdatePurchaseOrder(ctx context.Context, purchaseOrderId int, purchaseOrder PurchaseOrder) (PurchaseOrder, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during purchase order update:", purchaseOrderId)
+		return PurchaseOrder{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Books.repo), ReadLock(s.Suppliers.repo), WriteLock(s.repo))
+		if err != nil {
+			return PurchaseOrder{}, err
+		}
+		defer tx.Rollback()
+		purchaseOrders := Table(tx, s.repo)
+
+		existing, ok := purchaseOrders.Get(purchaseOrderId)
+		if !ok {
+			return PurchaseOrder{}, fmt.Errorf("%w: purchase order with ID %d", ErrRecordNotFound, purchaseOrderId)
+		}
+		if existing.Status != PurchaseOrderOpen {
+			return PurchaseOrder{}, fmt.Errorf("%w: purchase order %d is %s, only an order with nothing received can be changed", ErrPurchaseOrderClosed, purchaseOrderId, existing.Status)
+		}
+		purchaseOrder.ID = purchaseOrderId
+		purchaseOrder.CreatedAt = existing.CreatedAt
+		if err := s.prepare(tx, &purchaseOrder, time.Now()); err != nil {
+			return PurchaseOrder{}, err
+		}
+		if err := purchaseOrders.Put(purchaseOrder.ID, purchaseOrder); err != nil {
+			return PurchaseOrder{}, err
+		}
+		return purchaseOrder, tx.Commit()
+	}
+}
+
+// ReceivePurchaseOrder puts a delivery in stock. A receipt without lines receives everything still
+// outstanding, the order stays partially received until all its lines came in. The copies received
+// go to the backorders first.
+func (s *InMemoryPurchaseOrderStore) ReceivePurchaseOrder(ctx context.Context, purchaseOrderId int, receipt PurchaseReceipt) (PurchaseOrder, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during receipt of purchase order", purchaseOrderId)
+		return PurchaseOrder{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, WriteLock(s.Books.repo), WriteLock(s.Inventory.Orders.repo), WriteLock(s.Inventory.Reservations.repo),
+			WriteLock(s.Inventory.repo), WriteLock(s.repo))
+		if err != nil {
+			return PurchaseOrder{}, err
+		}
+		defer tx.Rollback()
+		purchaseOrders := Table(tx, s.repo)
+		inventory := s.Inventory.backorderTables(tx)
+
+		purchaseOrder, ok := purchaseOrders.Get(purchaseOrderId)
+		if !ok {
+			return PurchaseOrder{}, fmt.Errorf("%w: purchase order with ID %d", ErrRecordNotFound, purchaseOrderId)
+		}
+		if !purchaseOrder.IsOpen() {
+			return PurchaseOrder{}, fmt.Errorf("%w: purchase order %d is already %s", ErrPurchaseOrderClosed, purchaseOrderId, purchaseOrder.Status)
+		}
+		if len(receipt.Lines) == 0 {
+			for _, line := range purchaseOrder.Lines {
+				if line.Outstanding() > 0 {
+					receipt.Lines = append(receipt.Lines, ReceiptLine{BookID: line.BookID, Quantity: line.Outstanding()})
+				}
+			}
+		}
+		if receipt.Actor == "" {
+			receipt.Actor = actorAPI
+		}
+		receipt.ReceivedAt = time.Now()
+
+		lines := make(map[int]int)
+		for i, line := range purchaseOrder.Lines {
+			lines[line.BookID] = i
+		}
+		for i, received := range receipt.Lines {
+			index, ok := lines[received.BookID]
+			if !ok {
+				return PurchaseOrder{}, errors.New("Book with ID " + strconv.Itoa(received.BookID) + " is not on purchase order " + strconv.Itoa(purchaseOrderId))
+			}
+			line := &purchaseOrder.Lines[index]
+			if received.Quantity <= 0 {
+				return PurchaseOrder{}, errors.New("Invalid quantity received for book " + line.Title)
+			}
+			if received.Quantity > line.Outstanding() {
+				return PurchaseOrder{}, errors.New("Only " + strconv.Itoa(line.Outstanding()) + " copies of book " + line.Title + " are still expected")
+			}
+			if received.UnitCost < 0 {
+				return PurchaseOrder{}, errors.New("The unit cost of book " + line.Title + " can't be negative")
+			}
+			if received.UnitCost == 0 {
+				receipt.Lines[i].UnitCost = line.UnitCost
+			}
+			book, ok := inventory.books.Get(received.BookID)
+			if !ok {
+				return PurchaseOrder{}, fmt.Errorf("%w: book with ID %d", ErrRecordNotFound, received.BookID)
+			}
+			movement := StockMovement{
+				Kind:            MovementReceipt,
+				Quantity:        received.Quantity,
+				UnitCost:        receipt.Lines[i].UnitCost,
+				Reason:          "purchase order " + strconv.Itoa(purchaseOrderId),
+				Actor:           receipt.Actor,
+				PurchaseOrderID: purchaseOrderId,
+				CreatedAt:       receipt.ReceivedAt,
+			}
+			if _, err := inventory.move(book, movement); err != nil {
+				return PurchaseOrder{}, err
+			}
+			if _, err := inventory.allocateBackorders(book.ID, receipt.ReceivedAt, s.Inventory.Reservations.TTL); err != nil {
+				return PurchaseOrder{}, err
+			}
+			line.Received += received.Quantity
+		}
+
+		purchaseOrder.Status = PurchaseOrderPartiallyReceived
+		if purchaseOrder.Outstanding() == 0 {
+			purchaseOrder.Status = PurchaseOrderReceived
+		}
+		purchaseOrder.Receipts = append(purchaseOrder.Receipts, receipt)
+		purchaseOrder.UpdatedAt = receipt.ReceivedAt
+		if err := purchaseOrders.Put(purchaseOrder.ID, purchaseOrder); err != nil {
+			return PurchaseOrder{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return PurchaseOrder{}, err
+		}
+		log.Printf("Purchase order %d received, %d copies still expected\n", purchaseOrderId, purchaseOrder.Outstanding())
+		return purchaseOrder, nil
+	}
+}
+
+// CancelPurchaseOrder gives up on what is still expected, the copies already received stay in stock.
+func (s *InMemoryPurchaseOrderStore) CancelPurchaseOrder(ctx context.Context, purchaseOrderId int) (PurchaseOrder, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during cancellation of purchase order", purchaseOrderId)
+		return PurchaseOrder{}, ctx.Err()
+	default:
+		cancelled, err := s.repo.Update(ctx, purchaseOrderId, func(purchaseOrder PurchaseOrder) (PurchaseOrder, error) {
+			if !purchaseOrder.IsOpen() {
+				return PurchaseOrder{}, fmt.Errorf("%w: purchase order %d is already %s", ErrPurchaseOrderClosed, purchaseOrderId, purchaseOrder.Status)
+			}
+			purchaseOrder.Status = PurchaseOrderCancelled
+			purchaseOrder.UpdatedAt = time.Now()
+			return purchaseOrder, nil
+		})
+		if errors.Is(err, ErrRecordNotFound) {
+			return PurchaseOrder{}, fmt.Errorf("%w: purchase order with ID %d", ErrRecordNotFound, purchaseOrderId)
+		}
+		if err != nil {
+			return PurchaseOrder{}, err
+		}
+		log.Printf("Purchase order %d cancelled\n", purchaseOrderId)
+		return cancelled, nil
+	}
+}
+
+func (s *InMemoryPurchaseOrderStore) LoadPurchaseOrders(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemoryPurchaseOrderStore) SavePurchaseOrders(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
+
+// prepare checks a new or changed order against its supplier and books, and fills in what the server
+// keeps: the titles, the total cost, the status and the expected date when none was given.
+func (s *InMemoryPurchaseOrderStore) prepare(tx *Transaction, purchaseOrder *PurchaseOrder, now time.Time) error {
+	supplier, ok := Table(tx, s.Suppliers.repo).Get(purchaseOrder.SupplierID)
+	if !ok {
+		return errors.New("Supplier with ID " + strconv.Itoa(purchaseOrder.SupplierID) + " not found")
+	}
+	if len(purchaseOrder.Lines) == 0 {
+		return errors.New("A purchase order needs at least one line")
+	}
+	books := Table(tx, s.Books.repo)
+	seen := make(map[int]bool)
+	totalCost := 0.0
+	for i, line := range purchaseOrder.Lines {
+		book, ok := books.Get(line.BookID)
+		if !ok {
+			return errors.New("Book with ID " + strconv.Itoa(line.BookID) + " not found")
+		}
+		if seen[book.ID] {
+			return errors.New("Book " + book.Title + " is on more than one line")
+		}
+		seen[book.ID] = true
+		if line.Quantity <= 0 {
+			return errors.New("Invalid quantity for book " + book.Title)
+		}
+		if line.UnitCost < 0 {
+			return errors.New("The unit cost of book " + book.Title + " can't be negative")
+		}
+		purchaseOrder.Lines[i].Title = book.Title
+		purchaseOrder.Lines[i].Received = 0
+		totalCost += float64(line.Quantity) * line.UnitCost
+	}
+
+	purchaseOrder.SupplierName = supplier.Name
+	purchaseOrder.Status = PurchaseOrderOpen
+	purchaseOrder.TotalCost = RoundPrice(totalCost)
+	purchaseOrder.Currency = s.Inventory.Currency
+	purchaseOrder.Receipts = nil
+	purchaseOrder.UpdatedAt = now
+	if purchaseOrder.ExpectedAt.IsZero() {
+		purchaseOrder.ExpectedAt = now.AddDate(0, 0, supplier.LeadTimeDays)
+	}
+	return nil
+}
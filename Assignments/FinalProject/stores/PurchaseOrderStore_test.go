@@ -0,0 +1,104 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestPurchaseOrder orders quantity copies of a book with no stock from a new supplier, at 4 each.
+func newTestPurchaseOrder(t *testing.T, quantity int) (*Stores, Book, PurchaseOrder) {
+	t.Helper()
+	ctx := context.Background()
+	stores, book, _ := newTestCatalog(t, 0)
+	supplier, err := stores.Suppliers.CreateSupplier(ctx, Supplier{Name: "Orbit Books", LeadTimeDays: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	purchaseOrder, err := stores.PurchaseOrders.CreatePurchaseOrder(ctx, PurchaseOrder{
+		SupplierID: supplier.ID,
+		Lines:      []PurchaseOrderLine{{BookID: book.ID, Quantity: quantity, UnitCost: 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stores, book, purchaseOrder
+}
+
+func TestCreatePurchaseOrder(t *testing.T) {
+	_, book, purchaseOrder := newTestPurchaseOrder(t, 10)
+	if purchaseOrder.Status != PurchaseOrderOpen || purchaseOrder.TotalCost != 40 || purchaseOrder.SupplierName != "Orbit Books" {
+		t.Fatalf("purchase order %+v", purchaseOrder)
+	}
+	if purchaseOrder.Lines[0].Title != book.Title {
+		t.Fatalf("line title %q", purchaseOrder.Lines[0].Title)
+	}
+	if days := purchaseOrder.ExpectedAt.Sub(time.Now()).Hours() / 24; days < 6.9 || days > 7 {
+		t.Fatalf("expected in %.1f days, want the supplier's 7", days)
+	}
+}
+
+func TestReceivePurchaseOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		receipts     []PurchaseReceipt
+		wantErr      bool
+		wantStatus   PurchaseOrderStatus
+		wantStock    int
+		wantUnitCost float64
+	}{
+		{"everything at once", []PurchaseReceipt{{}}, false, PurchaseOrderReceived, 10, 4},
+		{"partial receipt", []PurchaseReceipt{{Lines: []ReceiptLine{{BookID: 1, Quantity: 4}}}}, false, PurchaseOrderPartiallyReceived, 4, 4},
+		{"rest at another cost", []PurchaseReceipt{{Lines: []ReceiptLine{{BookID: 1, Quantity: 5}}}, {Lines: []ReceiptLine{{BookID: 1, Quantity: 5, UnitCost: 6}}}},
+			false, PurchaseOrderReceived, 10, 5},
+		{"more than ordered", []PurchaseReceipt{{Lines: []ReceiptLine{{BookID: 1, Quantity: 11}}}}, true, PurchaseOrderOpen, 0, 0},
+		{"book not on the order", []PurchaseReceipt{{Lines: []ReceiptLine{{BookID: 2, Quantity: 1}}}}, true, PurchaseOrderOpen, 0, 0},
+		{"nothing left to receive", []PurchaseReceipt{{}, {}}, true, PurchaseOrderReceived, 10, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, purchaseOrder := newTestPurchaseOrder(t, 10)
+
+			var err error
+			for _, receipt := range tt.receipts {
+				if _, err = stores.PurchaseOrders.ReceivePurchaseOrder(ctx, purchaseOrder.ID, receipt); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReceivePurchaseOrder error = %v, want an error %v", err, tt.wantErr)
+			}
+			purchaseOrder, _ = stores.PurchaseOrders.GetPurchaseOrder(ctx, purchaseOrder.ID)
+			if purchaseOrder.Status != tt.wantStatus {
+				t.Fatalf("purchase order is %s, want %s", purchaseOrder.Status, tt.wantStatus)
+			}
+			valuation, _ := stores.Inventory.ValueInventory(ctx)
+			line := valuation.Books[0]
+			if line.BookID != book.ID || line.Stock != tt.wantStock || line.UnitCost != tt.wantUnitCost || !line.Reconciled {
+				t.Fatalf("valuation %+v, want %d in stock at %v", line, tt.wantStock, tt.wantUnitCost)
+			}
+		})
+	}
+}
+
+func TestCancelPurchaseOrderKeepsWhatCameIn(t *testing.T) {
+	ctx := context.Background()
+	stores, book, purchaseOrder := newTestPurchaseOrder(t, 10)
+	if _, err := stores.PurchaseOrders.ReceivePurchaseOrder(ctx, purchaseOrder.ID, PurchaseReceipt{Lines: []ReceiptLine{{BookID: book.ID, Quantity: 3}}}); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := stores.PurchaseOrders.CancelPurchaseOrder(ctx, purchaseOrder.ID)
+	if err != nil || cancelled.Status != PurchaseOrderCancelled {
+		t.Fatalf("cancelled purchase order %+v, %v", cancelled, err)
+	}
+	if _, err := stores.PurchaseOrders.ReceivePurchaseOrder(ctx, purchaseOrder.ID, PurchaseReceipt{}); !errors.Is(err, ErrPurchaseOrderClosed) {
+		t.Fatalf("a cancelled purchase order was received: %v", err)
+	}
+	stored, _ := stores.Books.GetBook(ctx, book.ID)
+	if stored.Stock != 3 {
+		t.Fatalf("stock = %d, want 3", stored.Stock)
+	}
+}
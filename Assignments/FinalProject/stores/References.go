@@ -0,0 +1,74 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"encoding/json"
+)
+
+// ----------------------------------------------Definition of the reference expansion--------------------------------
+// The records only keep the IDs of the ones they point at. ExpandReferences resolves them in a JSON
+// response decoded as generic values, so every endpoint can be expanded without knowing its types:
+// author resolves the author_id of every contributor, customer every customer_id, and items.book the
+// book_id of every item. The records are looked up as they are now, a reference to a deleted one
+// stays an ID.
+func (s *Stores) ExpandReferences(value interface{}, expansion Expansion) {
+	s.expandValue(value, "", expansion)
+}
+
+// expandValue walks the value, key is the field the value comes from, the elements of an array
+// come from the field of the array.
+func (s *Stores) expandValue(value interface{}, key string, expansion Expansion) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, element := range v {
+			s.expandValue(element, key, expansion)
+		}
+	case map[string]interface{}:
+		if expansion.Customer {
+			if id := referenceID(v["customer_id"]); id != 0 {
+				if customer, ok := s.Customers.repo.Find(id); ok {
+					v["customer"] = jsonValue(customer)
+				}
+			}
+		}
+		if expansion.ItemsBook && key == "items" {
+			if id := referenceID(v["book_id"]); id != 0 {
+				if book, ok := s.Books.repo.Find(id); ok {
+					v["book"] = jsonValue(book)
+				}
+			}
+		}
+		if expansion.Author && key == "contributors" {
+			if id := referenceID(v["author_id"]); id != 0 {
+				if author, ok := s.Authors.repo.Find(id); ok {
+					v["author"] = jsonValue(author)
+				}
+			}
+		}
+		// the records just resolved are walked too, the books of the items get their authors
+		for field, child := range v {
+			s.expandValue(child, field, expansion)
+		}
+	}
+}
+
+func referenceID(value interface{}) int {
+	id, err := jsonNumber(value)
+	if err != nil {
+		return 0
+	}
+	return int(id)
+}
+
+// jsonValue gives a record in the generic shape of the response it is put in.
+func jsonValue(record interface{}) interface{} {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	var value interface{}
+	if err := decodeGeneric(raw, &value); err != nil {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,79 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestOrderKeepsWhatWasSold(t *testing.T) {
+	ctx := context.Background()
+	stores, book, order := newTestOrder(t, 5, 2)
+	if order.Items[0].Title != "Ancillary Justice" || order.Items[0].UnitPrice != NewAmount(10) || order.Items[0].Book != nil {
+		t.Fatalf("item is %+v, want the title and price of the book without the book", order.Items[0])
+	}
+
+	book.Title, book.Price = "Ancillary Sword", NewAmount(15)
+	if _, err := stores.Books.UpdateBook(ctx, book.ID, book); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := stores.Orders.GetOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Items[0].Title != "Ancillary Justice" || stored.Items[0].UnitPrice != NewAmount(10) {
+		t.Fatalf("item became %+v after the book was updated", stored.Items[0])
+	}
+}
+
+func TestExpandReferences(t *testing.T) {
+	tests := []struct {
+		name         string
+		expand       string
+		wantCustomer bool
+		wantBook     bool
+		wantAuthor   bool
+	}{
+		{"nothing", "", false, false, false},
+		{"customer", "customer", true, false, false},
+		{"books of the items", "items.book", false, true, false},
+		{"books with their authors", "items.book,author", false, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores, _, order := newTestOrder(t, 5, 1)
+			expansion, err := ParseExpansion(tt.expand)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := json.Marshal(order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var value map[string]interface{}
+			if err := decodeGeneric(raw, &value); err != nil {
+				t.Fatal(err)
+			}
+			stores.ExpandReferences(value, expansion)
+
+			customer, _ := value["customer"].(map[string]interface{})
+			if (customer != nil) != tt.wantCustomer || (customer != nil && customer["name"] != "Bob") {
+				t.Fatalf("customer = %v, want it resolved: %v", value["customer"], tt.wantCustomer)
+			}
+			item := value["items"].([]interface{})[0].(map[string]interface{})
+			book, _ := item["book"].(map[string]interface{})
+			if (book != nil) != tt.wantBook || (book != nil && book["title"] != "Ancillary Justice") {
+				t.Fatalf("book = %v, want it resolved: %v", item["book"], tt.wantBook)
+			}
+			if book == nil {
+				return
+			}
+			contributor := book["contributors"].([]interface{})[0].(map[string]interface{})
+			author, _ := contributor["author"].(map[string]interface{})
+			if (author != nil) != tt.wantAuthor || (author != nil && author["first_name"] != "Ann") {
+				t.Fatalf("author = %v, want it resolved: %v", contributor["author"], tt.wantAuthor)
+			}
+		})
+	}
+}
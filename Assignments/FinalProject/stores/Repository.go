@@ -0,0 +1,354 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+)
+
+// ----------------------------------------------Definition of the generic Repository--------------------------------
+// A Repository keeps every record of one entity in memory behind a RWMutex. Writes go through a
+// transaction, are appended to the journal, applied in memory and then handed to the storage driver.
+type Entity[T any] interface {
+	GetID() int
+	WithID(id int) T
+}
+
+var ErrRecordNotFound = errors.New("record not found")
+
+type StoredState[T any] struct {
+	Records    map[int]T
+	NextID     int
+	JournalSeq int64
+}
+
+type RecordChange[T any] struct {
+	ID   int
+	Item *T
+}
+
+// StorageDriver is where a repository keeps its records durably.
+// Load is called once at startup, Persist after every committed change, Checkpoint periodically
+// to make everything up to state.JournalSeq durable, and Replace when a snapshot is restored or imported.
+type StorageDriver[T any] interface {
+	Load(ctx context.Context) (StoredState[T], error)
+	Persist(ctx context.Context, changes []RecordChange[T], nextID int, journalSeq int64) error
+	Checkpoint(ctx context.Context, state StoredState[T]) error
+	Replace(ctx context.Context, state StoredState[T]) error
+}
+
+type Repository[T Entity[T]] struct {
+	mu      sync.RWMutex
+	entity  string
+	rank    int
+	records map[int]T
+	nextID  int
+	journal *Journal
+	driver  StorageDriver[T]
+}
+
+func NewRepository[T Entity[T]](entity string, rank int, journal *Journal, driver StorageDriver[T]) *Repository[T] {
+	return &Repository[T]{
+		entity:  entity,
+		rank:    rank,
+		records: make(map[int]T),
+		nextID:  1,
+		journal: journal,
+		driver:  driver,
+	}
+}
+
+func (r *Repository[T]) lockRank() int {
+	return r.rank
+}
+
+func (r *Repository[T]) mutex() *sync.RWMutex {
+	return &r.mu
+}
+
+func (r *Repository[T]) journalRef() *Journal {
+	return r.journal
+}
+
+func (r *Repository[T]) newTable(writable bool) txTable {
+	return &TxTable[T]{
+		repo:         r,
+		writable:     writable,
+		staged:       make(map[int]*T),
+		stagedNextID: r.nextID,
+	}
+}
+
+func (r *Repository[T]) Find(id int) (T, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	item, ok := r.records[id]
+	return item, ok
+}
+
+func (r *Repository[T]) List() []T {
+	return r.Filter(func(T) bool { return true })
+}
+
+// Filter returns the matching records ordered by ID.
+func (r *Repository[T]) Filter(match func(item T) bool) []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]int, 0, len(r.records))
+	for id := range r.records {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var items []T
+	for _, id := range ids {
+		if match(r.records[id]) {
+			items = append(items, r.records[id])
+		}
+	}
+	return items
+}
+
+// ScanIndex returns the records in the order of one of the driver's indexes. ok is false when the
+// driver keeps no such index, the caller then has to use Filter.
+func (r *Repository[T]) ScanIndex(ctx context.Context, index string, from string, to string) ([]T, bool) {
+	scanner, ok := r.driver.(IndexScanner)
+	if !ok {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids, ok, err := scanner.ScanIndex(ctx, index, from, to)
+	if err != nil {
+		log.Printf("Failed to scan the %s index of %s: %v\n", index, r.entity, err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	items := make([]T, 0, len(ids))
+	for _, id := range ids {
+		if item, ok := r.records[id]; ok {
+			items = append(items, item)
+		}
+	}
+	return items, true
+}
+
+func (r *Repository[T]) Insert(ctx context.Context, item T) (T, error) {
+	var zero T
+	tx, err := BeginTransaction(ctx, WriteLock(r))
+	if err != nil {
+		return zero, err
+	}
+	defer tx.Rollback()
+
+	table := Table(tx, r)
+	id, err := table.NextID()
+	if err != nil {
+		return zero, err
+	}
+	item = item.WithID(id)
+	if err := table.Put(id, item); err != nil {
+		return zero, err
+	}
+	return item, tx.Commit()
+}
+
+// Update replaces a record with what change returns, reading and writing under the same lock.
+func (r *Repository[T]) Update(ctx context.Context, id int, change func(existing T) (T, error)) (T, error) {
+	var zero T
+	tx, err := BeginTransaction(ctx, WriteLock(r))
+	if err != nil {
+		return zero, err
+	}
+	defer tx.Rollback()
+
+	table := Table(tx, r)
+	existing, ok := table.Get(id)
+	if !ok {
+		return zero, ErrRecordNotFound
+	}
+	updated, err := change(existing)
+	if err != nil {
+		return zero, err
+	}
+	updated = updated.WithID(id)
+	if err := table.Put(id, updated); err != nil {
+		return zero, err
+	}
+	return updated, tx.Commit()
+}
+
+func (r *Repository[T]) Delete(ctx context.Context, id int) error {
+	tx, err := BeginTransaction(ctx, WriteLock(r))
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	table := Table(tx, r)
+	if _, ok := table.Get(id); !ok {
+		return ErrRecordNotFound
+	}
+	if err := table.Delete(id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Load reads the records from the driver and replays the journal written after them.
+func (r *Repository[T]) Load(ctx context.Context) error {
+	state, err := r.driver.Load(ctx)
+	if err != nil {
+		log.Printf("Failed to load %s: %v\n", r.entity, err)
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = state.Records
+	if r.records == nil {
+		r.records = make(map[int]T)
+	}
+	r.nextID = state.NextID
+	if r.nextID < 1 {
+		r.nextID = 1
+	}
+
+	var replayed []RecordChange[T]
+	var lastSeq int64
+	err = r.journal.Replay(r.entity, state.JournalSeq, func(seq int64, change JournalChange) error {
+		change, err := upgradeJournalChange(change)
+		if err != nil {
+			return err
+		}
+		switch change.Op {
+		case journalPut:
+			var item T
+			if err := json.Unmarshal(change.Data, &item); err != nil {
+				return err
+			}
+			r.records[change.ID] = item
+			replayed = append(replayed, RecordChange[T]{ID: change.ID, Item: &item})
+		case journalDelete:
+			delete(r.records, change.ID)
+			replayed = append(replayed, RecordChange[T]{ID: change.ID})
+		}
+		if change.NextID > r.nextID {
+			r.nextID = change.NextID
+		}
+		lastSeq = seq
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to replay the journal for %s: %v\n", r.entity, err)
+		return err
+	}
+	if len(replayed) > 0 {
+		log.Printf("Replayed %d journal changes for %s\n", len(replayed), r.entity)
+		if err := r.driver.Persist(ctx, replayed, r.nextID, lastSeq); err != nil {
+			log.Printf("Failed to persist the replayed %s: %v\n", r.entity, err)
+			return err
+		}
+	}
+	log.Printf("%d %s loaded\n", len(r.records), r.entity)
+	return nil
+}
+
+func (r *Repository[T]) Save(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.driver.Checkpoint(ctx, r.state())
+}
+
+// state expects the caller to hold the lock.
+func (r *Repository[T]) state() StoredState[T] {
+	return StoredState[T]{Records: r.records, NextID: r.nextID, JournalSeq: r.journal.LastSeq()}
+}
+
+func (r *Repository[T]) persist(ctx context.Context, changes []RecordChange[T], journalSeq int64) {
+	if len(changes) == 0 {
+		return
+	}
+	if err := r.driver.Persist(ctx, changes, r.nextID, journalSeq); err != nil {
+		log.Printf("Failed to persist %d %s changes, they stay in the journal: %v\n", len(changes), r.entity, err)
+	}
+}
+
+func (r *Repository[T]) snapshotFileName() string {
+	return r.entity + ".json"
+}
+
+// encodeSnapshot and stageSnapshot expect the caller to hold the lock.
+func (r *Repository[T]) encodeSnapshot() ([]byte, error) {
+	return encodeEntityFile(r.entity, r.state())
+}
+
+// stageSnapshot decodes and migrates the file without touching the repository, the content is only
+// swapped by apply. undo puts back what apply replaced.
+func (r *Repository[T]) stageSnapshot(raw []byte) (stagedSnapshot, error) {
+	state, err := decodeEntityFile[T](r.entity, raw)
+	if err != nil {
+		return stagedSnapshot{}, err
+	}
+	previous := r.state()
+	return stagedSnapshot{
+		apply: func(ctx context.Context) error {
+			r.records, r.nextID = state.Records, state.NextID
+			return r.driver.Replace(ctx, r.state())
+		},
+		undo: func(ctx context.Context) error {
+			r.records, r.nextID = previous.Records, previous.NextID
+			return r.driver.Replace(ctx, r.state())
+		},
+	}, nil
+}
+
+// ----------------------------------------------Definition of the entity file format--------------------------------
+// The JSON layout shared by the JSON driver and the snapshot generations:
+// {"version": 2, "<entity>": {"<id>": {...}}, "next_id": 3, "journal_seq": 42}
+func encodeEntityFile[T any](entity string, state StoredState[T]) ([]byte, error) {
+	records := state.Records
+	if records == nil {
+		records = make(map[int]T)
+	}
+	return json.Marshal(map[string]interface{}{
+		"version":     CurrentSchemaVersion(entity),
+		entity:        records,
+		"next_id":     state.NextID,
+		"journal_seq": state.JournalSeq,
+	})
+}
+
+func decodeEntityFile[T any](entity string, raw []byte) (StoredState[T], error) {
+	state := StoredState[T]{Records: make(map[int]T), NextID: 1}
+	raw, err := upgradeSnapshot(entity, raw)
+	if err != nil {
+		return state, err
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return state, err
+	}
+	if records, ok := doc[entity]; ok && string(records) != "null" {
+		if err := json.Unmarshal(records, &state.Records); err != nil {
+			return state, err
+		}
+	}
+	if nextID, ok := doc["next_id"]; ok {
+		if err := json.Unmarshal(nextID, &state.NextID); err != nil {
+			return state, err
+		}
+	}
+	if journalSeq, ok := doc["journal_seq"]; ok {
+		if err := json.Unmarshal(journalSeq, &state.JournalSeq); err != nil {
+			return state, err
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,268 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const DefaultReservationTTL = 30 * time.Minute
+
+// ----------------------------------------------Definition of ReservationMethods--------------------------------
+// Creating an order only holds its books, they count in the Reserved of the book and no longer in what
+// is available, but they stay in the Stock until the order is paid. The holds not paid in time are
+// released by ReleaseExpiredReservations, which cancels their orders.
+type InMemoryReservationStore struct {
+	repo      *Repository[StockReservation]
+	Books     *InMemoryBookStore
+	Orders    *InMemoryOrderStore
+	Inventory *InMemoryInventoryStore
+	TTL       time.Duration
+}
+
+type ReservationStore interface {
+	ReleaseExpiredReservations(ctx context.Context) (int, error)
+	LoadReservations(ctx context.Context) error
+	SaveReservations(ctx context.Context) error
+}
+
+func NewInMemoryReservationStore(journal *Journal, driver StorageDriver[StockReservation]) *InMemoryReservationStore {
+	return &InMemoryReservationStore{
+		repo: NewRepository[StockReservation](reservationEntity, reservationStoreRank, journal, driver),
+		TTL:  DefaultReservationTTL,
+	}
+}
+
+// ReleaseExpiredReservations puts the books of the expired holds back on sale and cancels the unpaid
+// orders they belonged to. It returns the number of holds released.
+func (s *InMemoryReservationStore) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during reservations release")
+		return 0, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, WriteLock(s.Books.repo), WriteLock(s.Orders.repo), WriteLock(s.repo), WriteLock(s.Inventory.repo))
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+		orders := Table(tx, s.Orders.repo)
+		inventory := inventoryTables{books: Table(tx, s.Books.repo), movements: Table(tx, s.Inventory.repo), reservations: Table(tx, s.repo)}
+
+		now := time.Now()
+		var expiredOrders []int
+		seen := make(map[int]bool)
+		for _, reservation := range inventory.reservations.All() {
+			if reservation.Expired(now) && !seen[reservation.OrderID] {
+				seen[reservation.OrderID] = true
+				expiredOrders = append(expiredOrders, reservation.OrderID)
+			}
+		}
+		// an order that only has backorders holds nothing, its deadline still applies
+		for _, order := range orders.All() {
+			if order.Status == OrderPending && order.ReservedUntil != nil && !order.ReservedUntil.After(now) && !seen[order.ID] {
+				seen[order.ID] = true
+				expiredOrders = append(expiredOrders, order.ID)
+			}
+		}
+		if len(expiredOrders) == 0 {
+			return 0, nil
+		}
+
+		released := 0
+		for _, orderId := range expiredOrders {
+			count, err := inventory.resolveHolds(orderId, ReservationExpired, "reservation expired", now)
+			if err != nil {
+				return 0, err
+			}
+			released += count
+
+			order, ok := orders.Get(orderId)
+			if !ok || order.Status != OrderPending {
+				continue
+			}
+			if err := order.Transition(OrderCancelled, now); err != nil {
+				return 0, err
+			}
+			order.ReservedUntil = nil
+			if err := orders.Put(order.ID, order); err != nil {
+				return 0, err
+			}
+			log.Printf("Order %d cancelled, it wasn't paid before its reservation expired\n", order.ID)
+		}
+		return released, tx.Commit()
+	}
+}
+
+func (s *InMemoryReservationStore) LoadReservations(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemoryReservationStore) SaveReservations(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
+
+// holdStock reserves the books of the order until expiresAt, one hold per item. An order that allows
+// backorders takes what is available and puts the rest of the item on backorder, the books not released
+// yet are pre-ordered and go on backorder entirely.
+func (t inventoryTables) holdStock(order *Order, expiresAt time.Time) error {
+	now := time.Now()
+	for i, item := range order.Items {
+		book, ok := t.books.Get(item.BookID)
+		if !ok {
+			return errors.New("Book with ID " + strconv.Itoa(item.BookID) + " not found")
+		}
+		if item.Quantity <= 0 {
+			return errors.New("Invalid quantity for book " + book.Title)
+		}
+		held := item.Quantity
+		order.Items[i].PreOrder = !book.Released(now)
+		if order.Items[i].PreOrder {
+			// a book not released yet is queued whatever the stock, it is allocated on release day
+			held = 0
+		} else if held > book.Available() {
+			if !order.AllowBackorder {
+				return errors.New("Not enough stock for book " + book.Title)
+			}
+			held = book.Available()
+		}
+		order.Items[i].Backordered = item.Quantity - held
+		order.Items[i].Shipped = 0
+		order.Items[i].Title, order.Items[i].UnitCost = book.Title, book.UnitCost
+		// the book is kept on the item for the pricing, the order store drops it before saving
+		order.Items[i].Book = &book
+		if held == 0 {
+			log.Printf("%d copies of book %s are on backorder\n", item.Quantity, book.Title)
+			continue
+		}
+		book, err := t.hold(order.ID, book, held, "order placed", expiresAt)
+		if err != nil {
+			return err
+		}
+		order.Items[i].Book = &book
+	}
+	order.ReservedUntil = &expiresAt
+	return nil
+}
+
+// hold reserves quantity copies of the book for the order and returns the book with its new reservation.
+func (t inventoryTables) hold(orderId int, book Book, quantity int, reason string, expiresAt time.Time) (Book, error) {
+	movement, err := t.move(book, StockMovement{Kind: MovementReservation, Reserved: quantity, OrderID: orderId, Reason: reason})
+	if err != nil {
+		return Book{}, err
+	}
+	book.Reserved = movement.ReservedAfter
+
+	id, err := t.reservations.NextID()
+	if err != nil {
+		return Book{}, err
+	}
+	reservation := StockReservation{
+		ID:        id,
+		OrderID:   orderId,
+		BookID:    book.ID,
+		Quantity:  quantity,
+		Status:    ReservationHeld,
+		CreatedAt: movement.CreatedAt,
+		ExpiresAt: expiresAt,
+	}
+	return book, t.reservations.Put(id, reservation)
+}
+
+// allocateBackorders hands the available copies of a book to the orders waiting for it, the oldest
+// first. An unpaid order gets them held until its deadline, a paid one buys them right away. Nothing is
+// allocated before the book is released. An unpaid order without a deadline holds them for ttl, the
+// reservation TTL the server runs with. It returns the number of copies allocated.
+func (t inventoryTables) allocateBackorders(bookId int, at time.Time, ttl time.Duration) (int, error) {
+	if book, ok := t.books.Get(bookId); !ok || !book.Released(at) {
+		return 0, nil
+	}
+	orders := t.orders.All()
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	allocated := 0
+	for _, order := range orders {
+		if !order.Status.Waiting() {
+			continue
+		}
+		changed := false
+		for i, item := range order.Items {
+			if item.BookID != bookId || item.Backordered == 0 {
+				continue
+			}
+			book, ok := t.books.Get(bookId)
+			if !ok || book.Available() == 0 {
+				break
+			}
+			quantity := min(item.Backordered, book.Available())
+			reason := "backorder of order " + strconv.Itoa(order.ID) + " allocated"
+			if order.Status == OrderPending {
+				expiresAt := at.Add(ttl)
+				if order.ReservedUntil != nil {
+					expiresAt = *order.ReservedUntil
+				}
+				if _, err := t.hold(order.ID, book, quantity, reason, expiresAt); err != nil {
+					return 0, err
+				}
+			} else {
+				if _, err := t.move(book, StockMovement{Kind: MovementSale, Quantity: -quantity, OrderID: order.ID, Reason: reason, CreatedAt: at}); err != nil {
+					return 0, err
+				}
+			}
+			order.Items[i].Backordered -= quantity
+			allocated += quantity
+			changed = true
+			log.Printf("%d copies of book %d allocated to the backorder of order %d\n", quantity, bookId, order.ID)
+		}
+		if !changed {
+			continue
+		}
+		if err := t.orders.Put(order.ID, order); err != nil {
+			return 0, err
+		}
+	}
+	return allocated, nil
+}
+
+// resolveHolds ends the holds of an order. Committed holds are sales that take their books out of the
+// stock, the other statuses put them back on sale. It returns the number of holds ended.
+func (t inventoryTables) resolveHolds(orderId int, status ReservationStatus, reason string, at time.Time) (int, error) {
+	resolved := 0
+	for _, reservation := range t.reservations.All() {
+		if reservation.OrderID != orderId || reservation.Status != ReservationHeld {
+			continue
+		}
+		if book, ok := t.books.Get(reservation.BookID); ok {
+			movement := StockMovement{Kind: MovementRelease, Reserved: -reservation.Quantity, OrderID: orderId, Reason: reason, CreatedAt: at}
+			if status == ReservationCommitted {
+				movement.Kind = MovementSale
+				movement.Quantity = -reservation.Quantity
+			}
+			if _, err := t.move(book, movement); err != nil {
+				return 0, err
+			}
+		} else {
+			log.Printf("Book with ID %d no longer exists, its reservation is simply closed\n", reservation.BookID)
+		}
+		reservation.Status = status
+		reservation.ResolvedAt = &at
+		if err := t.reservations.Put(reservation.ID, reservation); err != nil {
+			return 0, err
+		}
+		resolved++
+	}
+	return resolved, nil
+}
+
+// releasePendingStock gives back the books of an unpaid order. Orders placed before the reservations
+// took their books out of the stock right away, they have no holds and give the stock back instead.
+func (t inventoryTables) releasePendingStock(order Order, reason string, at time.Time) error {
+	released, err := t.resolveHolds(order.ID, ReservationReleased, reason, at)
+	if err != nil || released > 0 {
+		return err
+	}
+	return t.returnStock(order, reason)
+}
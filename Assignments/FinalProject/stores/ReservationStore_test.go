@@ -0,0 +1,157 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOrderHoldsItsBooks(t *testing.T) {
+	ctx := context.Background()
+	stores, book, order := newTestOrder(t, 5, 2)
+	if order.ReservedUntil == nil || time.Until(*order.ReservedUntil) > stores.Reservations.TTL {
+		t.Fatalf("order reserved until %v", order.ReservedUntil)
+	}
+	stored, _ := stores.Books.GetBook(ctx, book.ID)
+	if stored.Stock != 5 || stored.Reserved != 2 || stored.Available() != 3 {
+		t.Fatalf("stock %d, reserved %d", stored.Stock, stored.Reserved)
+	}
+	if _, err := stores.Orders.CreateOrder(ctx, Order{CustomerID: order.CustomerID, Items: []OrderItem{{BookID: book.ID, Quantity: 4}}}); err == nil {
+		t.Fatal("the held books were ordered again")
+	}
+}
+
+func TestReleaseExpiredReservations(t *testing.T) {
+	tests := []struct {
+		name         string
+		ttl          time.Duration
+		pay          bool
+		wantReleased int
+		wantStatus   OrderStatus
+		wantStock    int
+		wantReserved int
+	}{
+		{"hold still running is kept", time.Hour, false, 0, OrderPending, 5, 2},
+		{"expired hold cancels the order", -time.Second, false, 1, OrderCancelled, 5, 0},
+		{"paid order is never released", -time.Second, true, 0, OrderPaid, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, customer := newTestCatalog(t, 5)
+			stores.Reservations.TTL = tt.ttl
+			order, err := stores.Orders.CreateOrder(ctx, Order{CustomerID: customer.ID, Items: []OrderItem{{BookID: book.ID, Quantity: 2}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.pay {
+				if _, err := stores.Orders.TransitionOrder(ctx, order.ID, OrderPaid); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			released, err := stores.Reservations.ReleaseExpiredReservations(ctx)
+			if err != nil || released != tt.wantReleased {
+				t.Fatalf("released %d holds, %v, want %d", released, err, tt.wantReleased)
+			}
+			order, _ = stores.Orders.GetOrder(ctx, order.ID)
+			if order.Status != tt.wantStatus {
+				t.Fatalf("order is %s, want %s", order.Status, tt.wantStatus)
+			}
+			stored, _ := stores.Books.GetBook(ctx, book.ID)
+			if stored.Stock != tt.wantStock || stored.Reserved != tt.wantReserved {
+				t.Fatalf("stock %d, reserved %d, want %d and %d", stored.Stock, stored.Reserved, tt.wantStock, tt.wantReserved)
+			}
+			// a second sweep finds nothing left to release
+			if released, _ := stores.Reservations.ReleaseExpiredReservations(ctx); released != 0 {
+				t.Fatalf("released %d holds twice", released)
+			}
+		})
+	}
+}
+
+func TestUpdateOrderKeepsTheHoldDeadline(t *testing.T) {
+	ctx := context.Background()
+	stores, book, order := newTestOrder(t, 5, 2)
+	order.Items = []OrderItem{{BookID: book.ID, Quantity: 5}}
+	updated, err := stores.Orders.UpdateOrder(ctx, order.ID, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.ReservedUntil.Equal(*order.ReservedUntil) {
+		t.Fatalf("deadline moved from %v to %v", order.ReservedUntil, updated.ReservedUntil)
+	}
+	stored, _ := stores.Books.GetBook(ctx, book.ID)
+	if stored.Reserved != 5 {
+		t.Fatalf("reserved = %d, want 5", stored.Reserved)
+	}
+}
+
+func TestBackorders(t *testing.T) {
+	tests := []struct {
+		name            string
+		allowBackorder  bool
+		wantErr         bool
+		wantBackordered int
+	}{
+		{"short order refused", false, true, 0},
+		{"short order on backorder", true, false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, customer := newTestCatalog(t, 2)
+			order, err := stores.Orders.CreateOrder(ctx, Order{CustomerID: customer.ID, Items: []OrderItem{{BookID: book.ID, Quantity: 5}}, AllowBackorder: tt.allowBackorder})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateOrder error = %v, want an error %v", err, tt.wantErr)
+			}
+			if err == nil && (order.Backordered() != tt.wantBackordered || order.Items[0].Backordered != tt.wantBackordered) {
+				t.Fatalf("%d copies on backorder, want %d", order.Backordered(), tt.wantBackordered)
+			}
+		})
+	}
+}
+
+func TestBackordersAreAllocatedOldestFirst(t *testing.T) {
+	ctx := context.Background()
+	stores, book, customer := newTestCatalog(t, 0)
+	var orders []Order
+	for _, quantity := range []int{2, 3, 1} {
+		order, err := stores.Orders.CreateOrder(ctx, Order{CustomerID: customer.ID, Items: []OrderItem{{BookID: book.ID, Quantity: quantity}}, AllowBackorder: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		orders = append(orders, order)
+	}
+	// the second order is paid, its copies are sold as soon as they come in
+	if _, err := stores.Orders.TransitionOrder(ctx, orders[1].ID, OrderPaid); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stores.Inventory.RecordStockMovement(ctx, book.ID, StockMovement{Kind: MovementReceipt, Quantity: 4}); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{0, 1, 1} {
+		order, _ := stores.Orders.GetOrder(ctx, orders[i].ID)
+		if order.Backordered() != want {
+			t.Fatalf("order %d has %d copies on backorder, want %d", order.ID, order.Backordered(), want)
+		}
+	}
+	stored, _ := stores.Books.GetBook(ctx, book.ID)
+	if stored.Stock != 2 || stored.Reserved != 2 || stored.Available() != 0 {
+		t.Fatalf("stock %d, reserved %d", stored.Stock, stored.Reserved)
+	}
+	ledger, _ := stores.Inventory.GetStockLedger(ctx, book.ID)
+	if !ledger.Reconciled {
+		t.Fatalf("ledger %d/%d, book %d/%d", ledger.LedgerStock, ledger.LedgerReserved, ledger.Stock, ledger.Reserved)
+	}
+
+	if _, err := stores.Orders.TransitionOrder(ctx, orders[1].ID, OrderPacked); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Orders.TransitionOrder(ctx, orders[1].ID, OrderShipped); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("an order with backorders was shipped: %v", err)
+	}
+}
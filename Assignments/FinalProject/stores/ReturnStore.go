@@ -0,0 +1,364 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------Definition of ReturnMethods--------------------------------
+// The refund of an approved return goes through the payment provider outside of the transactions, like
+// the payments, so the approvals are made one at a time and a return can't be refunded twice.
+type InMemoryReturnStore struct {
+	repo      *Repository[ReturnRequest]
+	Orders    *InMemoryOrderStore
+	Books     *InMemoryBookStore
+	Inventory *InMemoryInventoryStore
+	Payments  *InMemoryPaymentStore
+	mu        sync.Mutex
+}
+
+type ReturnStore interface {
+	RequestReturn(ctx context.Context, request ReturnRequest) (ReturnRequest, error)
+	GetReturn(ctx context.Context, id int) (ReturnRequest, error)
+	ListReturns(ctx context.Context, orderId int, status ReturnStatus) ([]ReturnRequest, error)
+	ApproveReturn(ctx context.Context, id int, decision ReturnDecision) (ReturnRequest, error)
+	RejectReturn(ctx context.Context, id int, decision ReturnDecision) (ReturnRequest, error)
+	LoadReturns(ctx context.Context) error
+	SaveReturns(ctx context.Context) error
+}
+
+func NewInMemoryReturnStore(journal *Journal, driver StorageDriver[ReturnRequest]) *InMemoryReturnStore {
+	return &InMemoryReturnStore{repo: NewRepository[ReturnRequest](returnEntity, returnStoreRank, journal, driver)}
+}
+
+// RequestReturn opens a return for books of a delivered order. A book can't be returned more times than
+// it was ordered, counting the returns still waiting and the approved ones.
+func (s *InMemoryReturnStore) RequestReturn(ctx context.Context, request ReturnRequest) (ReturnRequest, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during return request of order", request.OrderID)
+		return ReturnRequest{}, ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, ReadLock(s.Orders.repo), WriteLock(s.repo))
+		if err != nil {
+			return ReturnRequest{}, err
+		}
+		defer tx.Rollback()
+		returns := Table(tx, s.repo)
+
+		order, ok := Table(tx, s.Orders.repo).Get(request.OrderID)
+		if !ok {
+			return ReturnRequest{}, fmt.Errorf("%w: order with ID %d", ErrRecordNotFound, request.OrderID)
+		}
+		if order.Status != OrderDelivered {
+			return ReturnRequest{}, fmt.Errorf("%w: order %d is %s, only delivered orders can be returned", ErrReturnNotAllowed, order.ID, order.Status)
+		}
+		if len(request.Items) == 0 {
+			return ReturnRequest{}, errors.New("A return needs at least one book")
+		}
+
+		returned := make(map[int]int)
+		for _, existing := range returns.All() {
+			if existing.OrderID == order.ID && existing.Status != ReturnRejected {
+				for _, item := range existing.Items {
+					returned[item.BookID] += item.Quantity
+				}
+			}
+		}
+		// a book can be on several lines of the order, at different prices
+		ordered := make(map[int]*orderedBook)
+		for _, item := range order.Items {
+			book, ok := ordered[item.BookID]
+			if !ok {
+				book = &orderedBook{title: item.Title}
+				ordered[item.BookID] = book
+			}
+			book.quantity += item.Quantity
+			book.lines = append(book.lines, item)
+		}
+		for i, item := range request.Items {
+			book, ok := ordered[item.BookID]
+			if !ok {
+				return ReturnRequest{}, errors.New("Book with ID " + strconv.Itoa(item.BookID) + " is not in order " + strconv.Itoa(order.ID))
+			}
+			if item.Quantity <= 0 {
+				return ReturnRequest{}, errors.New("Invalid quantity for book " + book.title)
+			}
+			if strings.TrimSpace(item.Reason) == "" {
+				return ReturnRequest{}, errors.New("A reason is needed to return book " + book.title)
+			}
+			already := returned[item.BookID]
+			if already+item.Quantity > book.quantity {
+				return ReturnRequest{}, fmt.Errorf("%w: only %d copies of book %s were ordered and %d are already returned",
+					ErrReturnNotAllowed, book.quantity, book.title, already)
+			}
+			returned[item.BookID] += item.Quantity
+			request.Items[i].Title = book.title
+			request.Items[i].UnitPrice = NewAmount(book.value(already, item.Quantity).Float() / float64(item.Quantity))
+			request.Items[i].Disposition = ""
+		}
+
+		request.CustomerID = order.CustomerID
+		request.Status = ReturnRequested
+		request.Currency = s.orderCurrency(order)
+		request.RefundAmount = refundValue(order, request.Items, request.Currency)
+		request.RefundTransactionID, request.DecisionNote, request.DecidedAt = "", "", nil
+		request.CreatedAt = time.Now()
+		request.ID, err = returns.NextID()
+		if err != nil {
+			return ReturnRequest{}, err
+		}
+		if err := returns.Put(request.ID, request); err != nil {
+			return ReturnRequest{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return ReturnRequest{}, err
+		}
+		log.Printf("Return %d requested for order %d, %s to refund\n", request.ID, order.ID, request.RefundAmount.In(request.Currency))
+		return request, nil
+	}
+}
+
+func (s *InMemoryReturnStore) GetReturn(ctx context.Context, returnId int) (ReturnRequest, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during return retrieval:", returnId)
+		return ReturnRequest{}, ctx.Err()
+	default:
+		request, ok := s.repo.Find(returnId)
+		if !ok {
+			log.Printf("Return with ID %d not found", returnId)
+			return ReturnRequest{}, fmt.Errorf("%w: return with ID %d", ErrRecordNotFound, returnId)
+		}
+		return request, nil
+	}
+}
+
+// ListReturns lists the returns of an order, or of all of them when orderId is 0, with the status when
+// one is given.
+func (s *InMemoryReturnStore) ListReturns(ctx context.Context, orderId int, status ReturnStatus) ([]ReturnRequest, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during returns retrieval")
+		return nil, ctx.Err()
+	default:
+		returns := []ReturnRequest{}
+		for _, request := range s.repo.List() {
+			if (orderId == 0 || request.OrderID == orderId) && (status == "" || request.Status == status) {
+				returns = append(returns, request)
+			}
+		}
+		return returns, nil
+	}
+}
+
+// ApproveReturn refunds the books on the payment of the order, then restocks them or writes them off.
+// A refund refused by the provider leaves the return waiting.
+func (s *InMemoryReturnStore) ApproveReturn(ctx context.Context, returnId int, decision ReturnDecision) (ReturnRequest, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during approval of return", returnId)
+		return ReturnRequest{}, ctx.Err()
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		request, ok := s.repo.Find(returnId)
+		if !ok {
+			return ReturnRequest{}, fmt.Errorf("%w: return with ID %d", ErrRecordNotFound, returnId)
+		}
+		if request.Status != ReturnRequested {
+			return ReturnRequest{}, fmt.Errorf("%w: return %d is already %s", ErrReturnDecided, returnId, request.Status)
+		}
+		dispositions := make(map[int]ReturnDisposition)
+		for _, item := range decision.Items {
+			switch item.Disposition {
+			case DispositionRestock, DispositionWriteOff:
+				dispositions[item.BookID] = item.Disposition
+			default:
+				return ReturnRequest{}, errors.New("Invalid disposition '" + string(item.Disposition) + "', expected restock or write_off")
+			}
+		}
+		for i, item := range request.Items {
+			request.Items[i].Disposition = DispositionRestock
+			if disposition, ok := dispositions[item.BookID]; ok {
+				request.Items[i].Disposition = disposition
+				delete(dispositions, item.BookID)
+			}
+		}
+		for bookId := range dispositions {
+			return ReturnRequest{}, errors.New("Book with ID " + strconv.Itoa(bookId) + " is not in return " + strconv.Itoa(returnId))
+		}
+
+		order, ok := s.Orders.repo.Find(request.OrderID)
+		if !ok {
+			return ReturnRequest{}, fmt.Errorf("%w: order with ID %d", ErrRecordNotFound, request.OrderID)
+		}
+		if order.Status != OrderDelivered {
+			return ReturnRequest{}, fmt.Errorf("%w: order %d is %s, only delivered orders can be returned", ErrReturnNotAllowed, order.ID, order.Status)
+		}
+		// never more than what is left of the order
+		if left := order.TotalPrice - order.RefundedAmount; request.RefundAmount > left {
+			request.RefundAmount = left
+		}
+		if request.RefundAmount > 0 {
+			// the reference is the return's, an approval retried after its refund went through doesn't refund twice
+			refundID, err := s.Payments.RefundPayment(ctx, order.ID, "return_"+strconv.Itoa(request.ID), request.RefundAmount.In(request.Currency))
+			if err != nil {
+				log.Printf("Return %d can't be refunded, it stays requested: %v\n", returnId, err)
+				return ReturnRequest{}, err
+			}
+			request.RefundTransactionID = refundID
+		}
+
+		// the money is given back, the return is recorded even if the client went away meanwhile
+		approved, err := s.applyApproval(context.WithoutCancel(ctx), request, decision.Note)
+		if err != nil {
+			return ReturnRequest{}, err
+		}
+		log.Printf("Return %d approved, %s refunded for order %d\n", approved.ID, approved.RefundAmount.In(approved.Currency), approved.OrderID)
+		return approved, nil
+	}
+}
+
+func (s *InMemoryReturnStore) RejectReturn(ctx context.Context, returnId int, decision ReturnDecision) (ReturnRequest, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during rejection of return", returnId)
+		return ReturnRequest{}, ctx.Err()
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		rejected, err := s.repo.Update(ctx, returnId, func(request ReturnRequest) (ReturnRequest, error) {
+			if request.Status != ReturnRequested {
+				return ReturnRequest{}, fmt.Errorf("%w: return %d is already %s", ErrReturnDecided, returnId, request.Status)
+			}
+			now := time.Now()
+			request.Status = ReturnRejected
+			request.DecisionNote = decision.Note
+			request.DecidedAt = &now
+			return request, nil
+		})
+		if errors.Is(err, ErrRecordNotFound) {
+			return ReturnRequest{}, fmt.Errorf("%w: return with ID %d", ErrRecordNotFound, returnId)
+		}
+		if err != nil {
+			return ReturnRequest{}, err
+		}
+		log.Printf("Return %d rejected\n", returnId)
+		return rejected, nil
+	}
+}
+
+func (s *InMemoryReturnStore) LoadReturns(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemoryReturnStore) SaveReturns(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
+
+// applyApproval puts the restocked books back in stock, where the backorders get them first, and records
+// the refund on the order.
+func (s *InMemoryReturnStore) applyApproval(ctx context.Context, request ReturnRequest, note string) (ReturnRequest, error) {
+	tx, err := BeginTransaction(ctx, WriteLock(s.Books.repo), WriteLock(s.Orders.repo), WriteLock(s.Inventory.Reservations.repo),
+		WriteLock(s.Inventory.repo), WriteLock(s.repo))
+	if err != nil {
+		return ReturnRequest{}, err
+	}
+	defer tx.Rollback()
+	orders := Table(tx, s.Orders.repo)
+	inventory := s.Inventory.backorderTables(tx)
+
+	now := time.Now()
+	for _, item := range request.Items {
+		if item.Disposition != DispositionRestock {
+			log.Printf("Return %d writes off %d copies of book %d\n", request.ID, item.Quantity, item.BookID)
+			continue
+		}
+		book, ok := inventory.books.Get(item.BookID)
+		if !ok {
+			log.Printf("Book with ID %d no longer exists, its return can't be restocked\n", item.BookID)
+			continue
+		}
+		movement := StockMovement{Kind: MovementReturn, Quantity: item.Quantity, OrderID: request.OrderID,
+			Reason: "return " + strconv.Itoa(request.ID) + ": " + item.Reason, Actor: actorAPI, CreatedAt: now}
+		if _, err := inventory.move(book, movement); err != nil {
+			return ReturnRequest{}, err
+		}
+		if _, err := inventory.allocateBackorders(book.ID, now, s.Inventory.Reservations.TTL); err != nil {
+			return ReturnRequest{}, err
+		}
+	}
+
+	if order, ok := orders.Get(request.OrderID); ok {
+		order.RefundedAmount = min(order.RefundedAmount+request.RefundAmount, order.TotalPrice)
+		if err := orders.Put(order.ID, order); err != nil {
+			return ReturnRequest{}, err
+		}
+	}
+
+	request.Status = ReturnApproved
+	request.DecisionNote = note
+	request.DecidedAt = &now
+	if err := Table(tx, s.repo).Put(request.ID, request); err != nil {
+		return ReturnRequest{}, err
+	}
+	return request, tx.Commit()
+}
+
+func (s *InMemoryReturnStore) orderCurrency(order Order) string {
+	if order.Currency == "" {
+		return s.Orders.Pricing.Rates.BaseCurrency()
+	}
+	return order.Currency
+}
+
+// orderedBook gathers the lines of an order with the same book.
+type orderedBook struct {
+	title    string
+	quantity int
+	lines    []OrderItem
+}
+
+// value is what count copies of the book were sold for. The copies are taken from the lines in order,
+// after the skip ones already returned.
+func (b *orderedBook) value(skip int, count int) Amount {
+	var value Amount
+	for _, line := range b.lines {
+		take := line.Quantity
+		if skip >= take {
+			skip -= take
+			continue
+		}
+		take = min(take-skip, count)
+		skip = 0
+		value += line.UnitPrice.Times(take)
+		if count -= take; count == 0 {
+			break
+		}
+	}
+	return value
+}
+
+// refundValue is what the books were paid in the order: their price with the share of the discount and
+// of the tax of the order. The shipping isn't refunded.
+func refundValue(order Order, items []ReturnItem, currency string) Amount {
+	var value Amount
+	for _, item := range items {
+		value += item.UnitPrice.Times(item.Quantity)
+	}
+	if order.Pricing.Subtotal <= 0 {
+		return value
+	}
+	share := (order.Pricing.Total - order.Pricing.Shipping).Float() / order.Pricing.Subtotal.Float()
+	return AmountOf(NewMoney(value.Float()*share, currency))
+}
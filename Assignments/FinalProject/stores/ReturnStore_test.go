@@ -0,0 +1,135 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	. "FinalProject/payments"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// newTestDeliveredOrder pays an order of quantity copies of a 10 book with the fake provider and
+// delivers it.
+func newTestDeliveredOrder(t *testing.T, quantity int) (*Stores, Book, Order) {
+	t.Helper()
+	ctx := context.Background()
+	stores, book, order := newTestOrder(t, 5, quantity)
+	if _, err := stores.Payments.PayOrder(ctx, order.ID, Card{Number: "4242 4242 4242 4242", ExpMonth: 12, ExpYear: 2099}); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []OrderStatus{OrderPacked, OrderShipped, OrderDelivered} {
+		var err error
+		if order, err = stores.Orders.TransitionOrder(ctx, order.ID, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return stores, book, order
+}
+
+func TestRequestReturn(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      []ReturnItem
+		wantErr    error
+		wantRefund float64
+	}{
+		{"one of two copies", []ReturnItem{{Quantity: 1, Reason: "damaged"}}, nil, 10},
+		{"both copies", []ReturnItem{{Quantity: 2, Reason: "damaged"}}, nil, 20},
+		{"more copies than ordered", []ReturnItem{{Quantity: 3, Reason: "damaged"}}, ErrReturnNotAllowed, 0},
+		{"the same book twice", []ReturnItem{{Quantity: 1, Reason: "damaged"}, {Quantity: 2, Reason: "late"}}, ErrReturnNotAllowed, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores, book, order := newTestDeliveredOrder(t, 2)
+			for i := range tt.items {
+				tt.items[i].BookID = book.ID
+			}
+			request, err := stores.Returns.RequestReturn(context.Background(), ReturnRequest{OrderID: order.ID, Items: tt.items})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RequestReturn error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (request.Status != ReturnRequested || request.RefundAmount != NewAmount(tt.wantRefund)) {
+				t.Fatalf("return %s to refund %v, want %v", request.Status, request.RefundAmount, tt.wantRefund)
+			}
+		})
+	}
+}
+
+func TestRequestReturnNeedsADeliveredOrder(t *testing.T) {
+	stores, book, order := newTestOrder(t, 5, 2)
+	_, err := stores.Returns.RequestReturn(context.Background(), ReturnRequest{OrderID: order.ID, Items: []ReturnItem{{BookID: book.ID, Quantity: 1, Reason: "damaged"}}})
+	if !errors.Is(err, ErrReturnNotAllowed) {
+		t.Fatalf("RequestReturn error = %v, want %v", err, ErrReturnNotAllowed)
+	}
+}
+
+func TestApproveReturn(t *testing.T) {
+	tests := []struct {
+		name        string
+		disposition ReturnDisposition
+		wantStock   int
+	}{
+		{"restocked", DispositionRestock, 4},
+		{"written off", DispositionWriteOff, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, order := newTestDeliveredOrder(t, 2)
+			request, err := stores.Returns.RequestReturn(ctx, ReturnRequest{OrderID: order.ID, Items: []ReturnItem{{BookID: book.ID, Quantity: 1, Reason: "damaged"}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			approved, err := stores.Returns.ApproveReturn(ctx, request.ID, ReturnDecision{Items: []ReturnItem{{BookID: book.ID, Disposition: tt.disposition}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approved.Status != ReturnApproved || approved.RefundTransactionID == "" {
+				t.Fatalf("return %+v", approved)
+			}
+			if _, err := stores.Returns.ApproveReturn(ctx, request.ID, ReturnDecision{}); !errors.Is(err, ErrReturnDecided) {
+				t.Fatalf("the return was approved twice: %v", err)
+			}
+
+			stored, _ := stores.Books.GetBook(ctx, book.ID)
+			if stored.Stock != tt.wantStock {
+				t.Fatalf("stock = %d, want %d", stored.Stock, tt.wantStock)
+			}
+			order, _ = stores.Orders.GetOrder(ctx, order.ID)
+			if order.RefundedAmount != NewAmount(10) {
+				t.Fatalf("refunded amount = %v, want 10", order.RefundedAmount)
+			}
+			capture, refunded, _ := stores.Payments.capturedPayment(order.ID)
+			if refunded != capture.Amount.Amount/2 {
+				t.Fatalf("refunded %d of %d", refunded, capture.Amount.Amount)
+			}
+		})
+	}
+}
+
+func TestApproveReturnRetryDoesNotRefundTwice(t *testing.T) {
+	ctx := context.Background()
+	stores, book, order := newTestDeliveredOrder(t, 2)
+	request, err := stores.Returns.RequestReturn(ctx, ReturnRequest{OrderID: order.ID, Items: []ReturnItem{{BookID: book.ID, Quantity: 1, Reason: "damaged"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an earlier approval refunded the return but stopped before recording it
+	refundID, err := stores.Payments.RefundPayment(ctx, order.ID, "return_"+strconv.Itoa(request.ID), request.RefundAmount.In(request.Currency))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	approved, err := stores.Returns.ApproveReturn(ctx, request.ID, ReturnDecision{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.RefundTransactionID != refundID {
+		t.Fatalf("refund %s, want the earlier %s", approved.RefundTransactionID, refundID)
+	}
+	capture, refunded, _ := stores.Payments.capturedPayment(order.ID)
+	if refunded != capture.Amount.Amount/2 {
+		t.Fatalf("refunded %d of %d", refunded, capture.Amount.Amount)
+	}
+}
@@ -0,0 +1,472 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+// ----------------------------------------------Definition of the SQL storage--------------------------------
+// The SQL backend keeps every entity in its own table. Relations are foreign keys
+// (book_contributors.author_id, orders.customer_id, order_items.book_id) instead of embedded copies,
+// they are resolved again when the records are loaded. The keys RESTRICT deletes the way the stores
+// do, an author, a customer or a book something still points at can't be deleted, and books.author_id
+// still holds the primary author of every book. Fields without a column of their own are kept in the
+// JSON details column, so adding a field to a model doesn't need a new column.
+const sqlSchema = `
+CREATE TABLE IF NOT EXISTS store_meta (
+	entity      TEXT PRIMARY KEY,
+	version     INTEGER NOT NULL,
+	next_id     INTEGER NOT NULL,
+	journal_seq INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS authors (
+	id         INTEGER PRIMARY KEY,
+	first_name TEXT NOT NULL,
+	last_name  TEXT NOT NULL,
+	bio        TEXT NOT NULL DEFAULT '',
+	details    TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS books (
+	id           INTEGER PRIMARY KEY,
+	title        TEXT NOT NULL,
+	author_id    INTEGER REFERENCES authors(id) ON DELETE RESTRICT,
+	published_at TEXT NOT NULL,
+	price        REAL NOT NULL,
+	stock        INTEGER NOT NULL,
+	details      TEXT NOT NULL DEFAULT '{}'
+);
+CREATE INDEX IF NOT EXISTS books_author_id ON books(author_id);
+CREATE TABLE IF NOT EXISTS book_contributors (
+	book_id   INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+	position  INTEGER NOT NULL,
+	author_id INTEGER REFERENCES authors(id) ON DELETE RESTRICT,
+	role      TEXT NOT NULL,
+	PRIMARY KEY (book_id, position)
+);
+CREATE INDEX IF NOT EXISTS book_contributors_author_id ON book_contributors(author_id);
+CREATE TABLE IF NOT EXISTS customers (
+	id          INTEGER PRIMARY KEY,
+	name        TEXT NOT NULL,
+	email       TEXT NOT NULL,
+	street      TEXT NOT NULL DEFAULT '',
+	city        TEXT NOT NULL DEFAULT '',
+	state       TEXT NOT NULL DEFAULT '',
+	postal_code TEXT NOT NULL DEFAULT '',
+	country     TEXT NOT NULL DEFAULT '',
+	created_at  TEXT NOT NULL,
+	details     TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS orders (
+	id          INTEGER PRIMARY KEY,
+	customer_id INTEGER REFERENCES customers(id) ON DELETE RESTRICT,
+	total_price REAL NOT NULL,
+	status      TEXT NOT NULL DEFAULT '',
+	created_at  TEXT NOT NULL,
+	details     TEXT NOT NULL DEFAULT '{}'
+);
+CREATE INDEX IF NOT EXISTS orders_customer_id ON orders(customer_id);
+CREATE INDEX IF NOT EXISTS orders_created_at ON orders(created_at);
+CREATE TABLE IF NOT EXISTS order_items (
+	order_id   INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	position   INTEGER NOT NULL,
+	book_id    INTEGER REFERENCES books(id) ON DELETE RESTRICT,
+	book_title TEXT NOT NULL DEFAULT '',
+	unit_price REAL NOT NULL DEFAULT 0,
+	quantity   INTEGER NOT NULL,
+	details    TEXT NOT NULL DEFAULT '{}',
+	PRIMARY KEY (order_id, position)
+);
+CREATE INDEX IF NOT EXISTS order_items_book_id ON order_items(book_id);
+CREATE TABLE IF NOT EXISTS promotions (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS shipments (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS payments (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS carts (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS reservations (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS stock_movements (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS stock_alerts (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS suppliers (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS purchase_orders (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS returns (
+	id      INTEGER PRIMARY KEY,
+	details TEXT NOT NULL DEFAULT '{}'
+);
+`
+
+type SQLDatabase struct {
+	DB   *sql.DB
+	path string
+}
+
+// OpenSQLDatabase opens (or creates) the SQLite database file in the database directory.
+// An empty file name opens a private in-memory database.
+func OpenSQLDatabase(filePath string) (*SQLDatabase, error) {
+	dsn := "file::memory:"
+	fullPath := ":memory:"
+	if filePath != "" {
+		dir := "database"
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			log.Printf("Failed to create directory %s: %v\n", dir, err)
+			return nil, err
+		}
+		fullPath = filepath.Join(dir, filePath)
+		dsn = "file:" + fullPath
+	}
+	dsn += "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		log.Printf("Failed to open SQL database %s: %v\n", fullPath, err)
+		return nil, err
+	}
+	// one connection serializes the writers, and keeps the in-memory database alive between queries
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqlSchema); err != nil {
+		db.Close()
+		log.Printf("Failed to create the SQL schema in %s: %v\n", fullPath, err)
+		return nil, err
+	}
+	log.Printf("SQL database opened at %s\n", fullPath)
+	return &SQLDatabase{DB: db, path: fullPath}, nil
+}
+
+func (d *SQLDatabase) Close() error {
+	return d.DB.Close()
+}
+
+func SQLStorageDrivers(db *SQLDatabase) StorageDrivers {
+	return StorageDrivers{
+		Authors:         &SQLDriver[Author]{db: db, table: sqlAuthors{}},
+		Books:           &SQLDriver[Book]{db: db, table: sqlBooks{}},
+		Customers:       &SQLDriver[Customer]{db: db, table: sqlCustomers{}},
+		Orders:          &SQLDriver[Order]{db: db, table: sqlOrders{}},
+		Promotions:      &SQLDriver[Promotion]{db: db, table: sqlDocuments[Promotion]{entity: promotionEntity}},
+		Shipments:       &SQLDriver[Shipment]{db: db, table: sqlDocuments[Shipment]{entity: shipmentEntity}},
+		Payments:        &SQLDriver[PaymentAttempt]{db: db, table: sqlDocuments[PaymentAttempt]{entity: paymentEntity}},
+		Carts:           &SQLDriver[Cart]{db: db, table: sqlDocuments[Cart]{entity: cartEntity}},
+		IdempotencyKeys: &SQLDriver[IdempotencyRecord]{db: db, table: sqlDocuments[IdempotencyRecord]{entity: idempotencyEntity}},
+		Reservations:    &SQLDriver[StockReservation]{db: db, table: sqlDocuments[StockReservation]{entity: reservationEntity}},
+		StockMovements:  &SQLDriver[StockMovement]{db: db, table: sqlDocuments[StockMovement]{entity: movementEntity}},
+		StockAlerts:     &SQLDriver[StockAlert]{db: db, table: sqlDocuments[StockAlert]{entity: alertEntity}},
+		Suppliers:       &SQLDriver[Supplier]{db: db, table: sqlDocuments[Supplier]{entity: supplierEntity}},
+		PurchaseOrders:  &SQLDriver[PurchaseOrder]{db: db, table: sqlDocuments[PurchaseOrder]{entity: purchaseEntity}},
+		Returns:         &SQLDriver[ReturnRequest]{db: db, table: sqlDocuments[ReturnRequest]{entity: returnEntity}},
+		closer:          db,
+	}
+}
+
+type sqlMeta struct {
+	Version    int
+	NextID     int
+	JournalSeq int64
+}
+
+func (d *SQLDatabase) readMeta(ctx context.Context, entity string) (sqlMeta, error) {
+	meta := sqlMeta{Version: CurrentSchemaVersion(entity), NextID: 1}
+	err := d.DB.QueryRowContext(ctx, "SELECT version, next_id, journal_seq FROM store_meta WHERE entity = ?", entity).
+		Scan(&meta.Version, &meta.NextID, &meta.JournalSeq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return meta, nil
+	}
+	return meta, err
+}
+
+func writeMeta(ctx context.Context, tx *sql.Tx, entity string, nextID int, journalSeq int64) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO store_meta (entity, version, next_id, journal_seq) VALUES (?, ?, ?, ?)
+		ON CONFLICT(entity) DO UPDATE SET version = excluded.version, next_id = excluded.next_id, journal_seq = excluded.journal_seq`,
+		entity, CurrentSchemaVersion(entity), nextID, journalSeq)
+	return err
+}
+
+// sqlTable maps one entity to its table(s).
+type sqlTable[T any] interface {
+	name() string
+	load(ctx context.Context, db *SQLDatabase, steps []Migration) (map[int]T, error)
+	upsert(ctx context.Context, tx *sql.Tx, item T) error
+}
+
+// loadSQLTable reads a whole table, upgrading its records from the version recorded in store_meta.
+func loadSQLTable[T any](ctx context.Context, db *SQLDatabase, table sqlTable[T]) (map[int]T, error) {
+	meta, err := db.readMeta(ctx, table.name())
+	if err != nil {
+		return nil, err
+	}
+	steps, err := migrationPath(table.name(), meta.Version)
+	if err != nil {
+		return nil, err
+	}
+	return table.load(ctx, db, steps)
+}
+
+// SQLDriver writes every committed change to the database right away, so a checkpoint only has
+// to move the journal sequence forward. If a write fails the journal still holds the change, the
+// driver then stops writing and the next checkpoint rewrites the whole table.
+type SQLDriver[T any] struct {
+	mu    sync.Mutex
+	db    *SQLDatabase
+	table sqlTable[T]
+	dirty bool
+}
+
+func (d *SQLDriver[T]) Load(ctx context.Context) (StoredState[T], error) {
+	select {
+	case <-ctx.Done():
+		log.Printf("Context canceled during %s loading\n", d.table.name())
+		return StoredState[T]{}, ctx.Err()
+	default:
+		meta, err := d.db.readMeta(ctx, d.table.name())
+		if err != nil {
+			return StoredState[T]{}, err
+		}
+		steps, err := migrationPath(d.table.name(), meta.Version)
+		if err != nil {
+			return StoredState[T]{}, err
+		}
+		records, err := d.table.load(ctx, d.db, steps)
+		if err != nil {
+			log.Printf("Failed to load %s from %s: %v\n", d.table.name(), d.db.path, err)
+			return StoredState[T]{}, err
+		}
+		state := StoredState[T]{Records: records, NextID: meta.NextID, JournalSeq: meta.JournalSeq}
+		if len(steps) > 0 {
+			log.Printf("Migrating the %s table from version %d to %d\n", d.table.name(), meta.Version, CurrentSchemaVersion(d.table.name()))
+			if err := d.Replace(ctx, state); err != nil {
+				return StoredState[T]{}, err
+			}
+		}
+		log.Printf("%s loaded successfully from %s\n", d.table.name(), d.db.path)
+		return state, nil
+	}
+}
+
+func (d *SQLDriver[T]) Persist(ctx context.Context, changes []RecordChange[T], nextID int, journalSeq int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dirty {
+		return nil
+	}
+	err := d.inTx(ctx, func(tx *sql.Tx) error {
+		for _, change := range changes {
+			if change.Item == nil {
+				if err := deleteSQLRecord(ctx, tx, d.table.name(), change.ID); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.table.upsert(ctx, tx, *change.Item); err != nil {
+				return err
+			}
+		}
+		return writeMeta(ctx, tx, d.table.name(), nextID, journalSeq)
+	})
+	if err != nil {
+		d.dirty = true
+	}
+	return err
+}
+
+func (d *SQLDriver[T]) Checkpoint(ctx context.Context, state StoredState[T]) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dirty {
+		log.Printf("Rewriting the %s table, some changes could not be written earlier\n", d.table.name())
+		return d.replace(ctx, state)
+	}
+	return d.inTx(ctx, func(tx *sql.Tx) error {
+		return writeMeta(ctx, tx, d.table.name(), state.NextID, state.JournalSeq)
+	})
+}
+
+func (d *SQLDriver[T]) Replace(ctx context.Context, state StoredState[T]) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.replace(ctx, state)
+}
+
+// replace rewrites the whole table with the foreign keys off. A restore or an import rewrites the
+// tables one after the other, a row may point at one the next table only brings, or be pointed at by
+// one it takes away. The stores already checked the references of what they hold.
+func (d *SQLDriver[T]) replace(ctx context.Context, state StoredState[T]) error {
+	err := d.inTxWithoutKeys(ctx, func(tx *sql.Tx) error {
+		existing, err := tableIDs(ctx, tx, d.table.name())
+		if err != nil {
+			return err
+		}
+		for id, item := range state.Records {
+			if err := d.table.upsert(ctx, tx, item); err != nil {
+				return errors.New(d.table.name() + " " + strconv.Itoa(id) + ": " + err.Error())
+			}
+		}
+		for _, id := range existing {
+			if _, ok := state.Records[id]; !ok {
+				if err := deleteSQLRecord(ctx, tx, d.table.name(), id); err != nil {
+					return err
+				}
+			}
+		}
+		return writeMeta(ctx, tx, d.table.name(), state.NextID, state.JournalSeq)
+	})
+	if err != nil {
+		log.Printf("Failed to rewrite the %s table: %v\n", d.table.name(), err)
+		return err
+	}
+	d.dirty = false
+	log.Printf("%s saved successfully to %s\n", d.table.name(), d.db.path)
+	return nil
+}
+
+func (d *SQLDriver[T]) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := d.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *SQLDriver[T]) inTxWithoutKeys(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	conn, err := d.db.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// the pragma is ignored inside a transaction, it is set on the connection before
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlOwnedRows deletes the rows that belong to a record of the table, they go with it. The keys would
+// cascade, but not while a whole table is rewritten with them off.
+var sqlOwnedRows = map[string]string{
+	bookEntity:  "DELETE FROM book_contributors WHERE book_id = ?",
+	orderEntity: "DELETE FROM order_items WHERE order_id = ?",
+}
+
+func deleteSQLRecord(ctx context.Context, tx *sql.Tx, table string, id int) error {
+	if owned, ok := sqlOwnedRows[table]; ok {
+		if _, err := tx.ExecContext(ctx, owned, id); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE id = ?", id)
+	return err
+}
+
+func tableIDs(ctx context.Context, tx *sql.Tx, table string) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM "+table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ----------------------------------------------Definition of the details column--------------------------------
+// sqlDetails keeps the JSON fields of item that have no column of their own.
+func sqlDetails(item interface{}, columns ...string) (string, error) {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+	var record map[string]interface{}
+	if err := decodeGeneric(raw, &record); err != nil {
+		return "", err
+	}
+	for _, column := range columns {
+		delete(record, column)
+	}
+	details, err := json.Marshal(record)
+	return string(details), err
+}
+
+// sqlRecord rebuilds the JSON record of a row from its details and its columns.
+func sqlRecord(details string, columns map[string]interface{}) (map[string]interface{}, error) {
+	record := make(map[string]interface{})
+	if strings.TrimSpace(details) != "" {
+		if err := decodeGeneric([]byte(details), &record); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range columns {
+		record[key] = value
+	}
+	return record, nil
+}
+
+func decodeSQLRecord[T any](steps []Migration, record map[string]interface{}) (T, error) {
+	var item T
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return item, err
+	}
+	raw, _, err = migrateRecord(steps, raw)
+	if err != nil {
+		return item, err
+	}
+	err = json.Unmarshal(raw, &item)
+	return item, err
+}
@@ -0,0 +1,407 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ----------------------------------------------Definition of the SQL tables--------------------------------
+// A record that points at nothing (ID 0) keeps NULL in the column, a reference to a missing row
+// fails the write.
+func sqlReference(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+type sqlAuthors struct{}
+
+func (sqlAuthors) name() string {
+	return authorEntity
+}
+
+func (sqlAuthors) load(ctx context.Context, db *SQLDatabase, steps []Migration) (map[int]Author, error) {
+	rows, err := db.DB.QueryContext(ctx, "SELECT id, first_name, last_name, bio, details FROM authors")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	authors := make(map[int]Author)
+	for rows.Next() {
+		var id int
+		var firstName, lastName, bio, details string
+		if err := rows.Scan(&id, &firstName, &lastName, &bio, &details); err != nil {
+			return nil, err
+		}
+		record, err := sqlRecord(details, map[string]interface{}{
+			"id": id, "first_name": firstName, "last_name": lastName, "bio": bio,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if authors[id], err = decodeSQLRecord[Author](steps, record); err != nil {
+			return nil, err
+		}
+	}
+	return authors, rows.Err()
+}
+
+func (sqlAuthors) upsert(ctx context.Context, tx *sql.Tx, author Author) error {
+	details, err := sqlDetails(author, "id", "first_name", "last_name", "bio")
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO authors (id, first_name, last_name, bio, details) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET first_name = excluded.first_name, last_name = excluded.last_name,
+		bio = excluded.bio, details = excluded.details`,
+		author.ID, author.FirstName, author.LastName, author.Bio, details)
+	return err
+}
+
+type sqlBooks struct{}
+
+func (sqlBooks) name() string {
+	return bookEntity
+}
+
+func (sqlBooks) load(ctx context.Context, db *SQLDatabase, steps []Migration) (map[int]Book, error) {
+	rows, err := db.DB.QueryContext(ctx, "SELECT id, title, author_id, published_at, price, stock, details FROM books")
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[int]map[string]interface{})
+	for rows.Next() {
+		var id, stock int
+		var authorID sql.NullInt64
+		var title, publishedAt, details string
+		var price float64
+		if err := rows.Scan(&id, &title, &authorID, &publishedAt, &price, &stock, &details); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		columns := map[string]interface{}{
+			"id": id, "title": title, "published_at": publishedAt, "price": price, "stock": stock,
+		}
+		// tables written before the contributors only have the author column, the migration turns it into one
+		if authorID.Valid {
+			columns["author"] = map[string]interface{}{"id": authorID.Int64}
+		}
+		record, err := sqlRecord(details, columns)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		records[id] = record
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.QueryContext(ctx, `SELECT book_id, position, author_id, role FROM book_contributors
+		WHERE author_id IS NOT NULL ORDER BY book_id, position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bookID, position, authorID int
+		var role string
+		if err := rows.Scan(&bookID, &position, &authorID, &role); err != nil {
+			return nil, err
+		}
+		record, ok := records[bookID]
+		if !ok {
+			continue
+		}
+		contributors, _ := record["contributors"].([]interface{})
+		record["contributors"] = append(contributors, map[string]interface{}{"author_id": authorID, "role": role, "position": position})
+		delete(record, "author")
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	books := make(map[int]Book)
+	for id, record := range records {
+		book, err := decodeSQLRecord[Book](steps, record)
+		if err != nil {
+			return nil, err
+		}
+		books[id] = book
+	}
+	return books, nil
+}
+
+func (sqlBooks) upsert(ctx context.Context, tx *sql.Tx, book Book) error {
+	details, err := sqlDetails(book, "id", "title", "contributors", "published_at", "price", "stock")
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO books (id, title, author_id, published_at, price, stock, details)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET title = excluded.title, author_id = excluded.author_id,
+		published_at = excluded.published_at, price = excluded.price, stock = excluded.stock, details = excluded.details`,
+		book.ID, book.Title, sqlReference(book.PrimaryAuthorID()), book.PublishedAt.Format(time.RFC3339Nano), book.Price.Float(), book.Stock, details)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM book_contributors WHERE book_id = ?", book.ID); err != nil {
+		return err
+	}
+	for _, contributor := range book.Contributors {
+		_, err = tx.ExecContext(ctx, `INSERT INTO book_contributors (book_id, position, author_id, role)
+			VALUES (?, ?, ?, ?)`,
+			book.ID, contributor.Position, sqlReference(contributor.AuthorID), contributor.Role)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sqlCustomers struct{}
+
+func (sqlCustomers) name() string {
+	return customerEntity
+}
+
+func (sqlCustomers) load(ctx context.Context, db *SQLDatabase, steps []Migration) (map[int]Customer, error) {
+	rows, err := db.DB.QueryContext(ctx, `SELECT id, name, email, street, city, state, postal_code, country, created_at, details
+		FROM customers`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	customers := make(map[int]Customer)
+	for rows.Next() {
+		var id int
+		var name, email, street, city, state, postalCode, country, createdAt, details string
+		if err := rows.Scan(&id, &name, &email, &street, &city, &state, &postalCode, &country, &createdAt, &details); err != nil {
+			return nil, err
+		}
+		record, err := sqlRecord(details, map[string]interface{}{
+			"id": id, "name": name, "email": email, "created_at": createdAt,
+			"address": map[string]interface{}{
+				"street": street, "city": city, "state": state, "postal_code": postalCode, "country": country,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if customers[id], err = decodeSQLRecord[Customer](steps, record); err != nil {
+			return nil, err
+		}
+	}
+	return customers, rows.Err()
+}
+
+func (sqlCustomers) upsert(ctx context.Context, tx *sql.Tx, customer Customer) error {
+	details, err := sqlDetails(customer, "id", "name", "email", "address", "created_at")
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO customers (id, name, email, street, city, state, postal_code, country, created_at, details)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, email = excluded.email, street = excluded.street,
+		city = excluded.city, state = excluded.state, postal_code = excluded.postal_code, country = excluded.country,
+		created_at = excluded.created_at, details = excluded.details`,
+		customer.ID, customer.Name, customer.Email, customer.Address.Street, customer.Address.City, customer.Address.State,
+		customer.Address.PostalCode, customer.Address.Country, customer.CreatedAt.Format(time.RFC3339Nano), details)
+	return err
+}
+
+// Order items keep the title and price of their book as it was sold.
+type sqlOrders struct{}
+
+func (sqlOrders) name() string {
+	return orderEntity
+}
+
+func (sqlOrders) load(ctx context.Context, db *SQLDatabase, steps []Migration) (map[int]Order, error) {
+	// tables written before the orders kept references had copies of the customer and the books,
+	// their rows are read in that shape for the migrations
+	legacy := len(steps) > 0
+	rows, err := db.DB.QueryContext(ctx, "SELECT id, customer_id, total_price, status, created_at, details FROM orders")
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[int]map[string]interface{})
+	for rows.Next() {
+		var id int
+		var customerID sql.NullInt64
+		var totalPrice float64
+		var status, createdAt, details string
+		if err := rows.Scan(&id, &customerID, &totalPrice, &status, &createdAt, &details); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		columns := map[string]interface{}{
+			"id": id, "customer_id": customerID.Int64, "total_price": totalPrice, "status": status, "created_at": createdAt,
+			"items": []interface{}{},
+		}
+		if legacy {
+			columns["customer"] = map[string]interface{}{"id": customerID.Int64}
+		}
+		record, err := sqlRecord(details, columns)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		records[id] = record
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.QueryContext(ctx, `SELECT order_id, book_id, book_title, unit_price, quantity, details
+		FROM order_items ORDER BY order_id, position`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var orderID, quantity int
+		var bookID sql.NullInt64
+		var bookTitle, details string
+		var unitPrice float64
+		if err := rows.Scan(&orderID, &bookID, &bookTitle, &unitPrice, &quantity, &details); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		record, ok := records[orderID]
+		if !ok {
+			continue
+		}
+		columns := map[string]interface{}{"book_id": bookID.Int64, "title": bookTitle, "quantity": quantity, "unit_price": unitPrice}
+		if legacy {
+			columns["book"] = map[string]interface{}{"id": bookID.Int64, "title": bookTitle, "price": unitPrice}
+		}
+		itemRecord, err := sqlRecord(details, columns)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		record["items"] = append(record["items"].([]interface{}), itemRecord)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if legacy {
+		// the copies were the customer and the books as they are now, the migration takes the address
+		// and the cost it keeps from them
+		customers, err := loadSQLTable[Customer](ctx, db, sqlCustomers{})
+		if err != nil {
+			return nil, err
+		}
+		books, err := loadSQLTable[Book](ctx, db, sqlBooks{})
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if customer, ok := customers[int(record["customer_id"].(int64))]; ok {
+				record["customer"] = map[string]interface{}{"id": customer.ID, "address": customer.Address}
+			}
+			for _, item := range record["items"].([]interface{}) {
+				item := item.(map[string]interface{})
+				if book, ok := books[int(item["book_id"].(int64))]; ok {
+					item["book"].(map[string]interface{})["unit_cost"] = book.UnitCost
+				}
+			}
+		}
+	}
+
+	orders := make(map[int]Order)
+	for id, record := range records {
+		order, err := decodeSQLRecord[Order](steps, record)
+		if err != nil {
+			return nil, err
+		}
+		orders[id] = order
+	}
+	return orders, nil
+}
+
+func (sqlOrders) upsert(ctx context.Context, tx *sql.Tx, order Order) error {
+	details, err := sqlDetails(order, "id", "customer_id", "items", "total_price", "status", "created_at")
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO orders (id, customer_id, total_price, status, created_at, details)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET customer_id = excluded.customer_id, total_price = excluded.total_price,
+		status = excluded.status, created_at = excluded.created_at, details = excluded.details`,
+		order.ID, sqlReference(order.CustomerID), order.TotalPrice.Float(), order.Status, order.CreatedAt.Format(time.RFC3339Nano), details)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM order_items WHERE order_id = ?", order.ID); err != nil {
+		return err
+	}
+	for position, item := range order.Items {
+		itemDetails, err := sqlDetails(item, "book_id", "title", "quantity", "unit_price")
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO order_items (order_id, position, book_id, book_title, unit_price, quantity, details)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			order.ID, position, sqlReference(item.BookID), item.Title, item.UnitPrice.Float(), item.Quantity, itemDetails)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlDocuments keeps an entity without relations of its own as an id and its JSON details.
+type sqlDocuments[T Entity[T]] struct {
+	entity string
+}
+
+func (t sqlDocuments[T]) name() string {
+	return t.entity
+}
+
+func (t sqlDocuments[T]) load(ctx context.Context, db *SQLDatabase, steps []Migration) (map[int]T, error) {
+	rows, err := db.DB.QueryContext(ctx, "SELECT id, details FROM "+t.entity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[int]T)
+	for rows.Next() {
+		var id int
+		var details string
+		if err := rows.Scan(&id, &details); err != nil {
+			return nil, err
+		}
+		record, err := sqlRecord(details, map[string]interface{}{"id": id})
+		if err != nil {
+			return nil, err
+		}
+		if items[id], err = decodeSQLRecord[T](steps, record); err != nil {
+			return nil, err
+		}
+	}
+	return items, rows.Err()
+}
+
+func (t sqlDocuments[T]) upsert(ctx context.Context, tx *sql.Tx, item T) error {
+	details, err := sqlDetails(item, "id")
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO "+t.entity+` (id, details) VALUES (?, ?)
+		ON CONFLICT(id) DO UPDATE SET details = excluded.details`, item.GetID(), details)
+	return err
+}
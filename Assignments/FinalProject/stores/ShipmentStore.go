@@ -0,0 +1,297 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type InMemoryShipmentStore struct {
+	repo   *Repository[Shipment]
+	Orders *InMemoryOrderStore
+}
+
+type ShipmentStore interface {
+	CreateShipment(ctx context.Context, orderId int, shipment Shipment) (Shipment, error)
+	GetShipment(ctx context.Context, orderId int, id int) (Shipment, error)
+	ListShipments(ctx context.Context, orderId int) ([]Shipment, error)
+	AddTrackingEvent(ctx context.Context, orderId int, id int, event TrackingEvent) (Shipment, error)
+	LoadShipments(ctx context.Context) error
+	SaveShipments(ctx context.Context) error
+}
+
+func NewInMemoryShipmentStore(journal *Journal, driver StorageDriver[Shipment]) *InMemoryShipmentStore {
+	return &InMemoryShipmentStore{repo: NewRepository[Shipment](shipmentEntity, shipmentStoreRank, journal, driver)}
+}
+
+// CreateShipment hands a paid order to a carrier. The label is created with the shipment, which marks
+// the order as packed. A shipment without items carries everything allocated that isn't shipped yet,
+// the backorders go in a later parcel.
+func (s *InMemoryShipmentStore) CreateShipment(ctx context.Context, orderId int, shipment Shipment) (Shipment, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during shipment creation for order", orderId)
+		return Shipment{}, ctx.Err()
+	default:
+		shipment.Carrier = strings.TrimSpace(shipment.Carrier)
+		shipment.TrackingNumber = strings.TrimSpace(shipment.TrackingNumber)
+		if shipment.Carrier == "" || shipment.TrackingNumber == "" {
+			return Shipment{}, errors.New("A shipment needs a carrier and a tracking number")
+		}
+
+		tx, err := BeginTransaction(ctx, WriteLock(s.Orders.repo), WriteLock(s.repo))
+		if err != nil {
+			return Shipment{}, err
+		}
+		defer tx.Rollback()
+		orders := Table(tx, s.Orders.repo)
+		shipments := Table(tx, s.repo)
+
+		order, ok := orders.Get(orderId)
+		if !ok {
+			return Shipment{}, errors.New("Order with ID " + strconv.Itoa(orderId) + " not found")
+		}
+		if order.Status != OrderPaid && order.Status != OrderPacked && order.Status != OrderPartiallyShipped && order.Status != OrderShipped {
+			return Shipment{}, fmt.Errorf("%w: a %s order can't be shipped", ErrInvalidTransition, order.Status)
+		}
+		for _, other := range shipments.All() {
+			if strings.EqualFold(other.Carrier, shipment.Carrier) && other.TrackingNumber == shipment.TrackingNumber {
+				return Shipment{}, errors.New("Tracking number " + shipment.TrackingNumber + " is already used by shipment " + strconv.Itoa(other.ID))
+			}
+		}
+
+		if err := packItems(&order, &shipment); err != nil {
+			return Shipment{}, err
+		}
+		if err := orders.Put(order.ID, order); err != nil {
+			return Shipment{}, err
+		}
+
+		now := time.Now()
+		shipment.OrderID = orderId
+		if shipment.Method == "" {
+			shipment.Method = order.ShippingMethod
+		}
+		shipment.Status = ShipmentLabelCreated
+		shipment.Events = []TrackingEvent{{Status: ShipmentLabelCreated, Description: "Label created", At: now}}
+		shipment.CreatedAt = now
+		shipment.ID, err = shipments.NextID()
+		if err != nil {
+			return Shipment{}, err
+		}
+		if err := shipments.Put(shipment.ID, shipment); err != nil {
+			return Shipment{}, err
+		}
+		if err := advanceOrder(orders, shipments, order, now); err != nil {
+			return Shipment{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return Shipment{}, err
+		}
+		log.Printf("Shipment %d created for order %d, %s tracking number %s\n", shipment.ID, orderId, shipment.Carrier, shipment.TrackingNumber)
+		return shipment, nil
+	}
+}
+
+func (s *InMemoryShipmentStore) GetShipment(ctx context.Context, orderId int, shipmentId int) (Shipment, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during shipment retrieval:", shipmentId)
+		return Shipment{}, ctx.Err()
+	default:
+		shipment, ok := s.repo.Find(shipmentId)
+		if !ok || shipment.OrderID != orderId {
+			log.Printf("Shipment with ID %d not found for order %d", shipmentId, orderId)
+			return Shipment{}, errors.New("Shipment with ID " + strconv.Itoa(shipmentId) + " not found for order " + strconv.Itoa(orderId))
+		}
+		return shipment, nil
+	}
+}
+
+func (s *InMemoryShipmentStore) ListShipments(ctx context.Context, orderId int) ([]Shipment, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during shipments retrieval of order", orderId)
+		return nil, ctx.Err()
+	default:
+		if _, ok := s.Orders.repo.Find(orderId); !ok {
+			return nil, errors.New("Order with ID " + strconv.Itoa(orderId) + " not found")
+		}
+		shipments := s.repo.Filter(func(shipment Shipment) bool { return shipment.OrderID == orderId })
+		sort.Slice(shipments, func(i, j int) bool { return shipments[i].ID < shipments[j].ID })
+		return shipments, nil
+	}
+}
+
+// AddTrackingEvent records what the carrier reports about a parcel and moves the order forward with it:
+// shipped once a parcel is on its way, delivered once all of them are.
+func (s *InMemoryShipmentStore) AddTrackingEvent(ctx context.Context, orderId int, shipmentId int, event TrackingEvent) (Shipment, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during tracking update of shipment", shipmentId)
+		return Shipment{}, ctx.Err()
+	default:
+		if !event.Status.Valid() {
+			return Shipment{}, errors.New("Unknown shipment status '" + string(event.Status) + "'")
+		}
+		if event.At.IsZero() {
+			event.At = time.Now()
+		}
+
+		tx, err := BeginTransaction(ctx, WriteLock(s.Orders.repo), WriteLock(s.repo))
+		if err != nil {
+			return Shipment{}, err
+		}
+		defer tx.Rollback()
+		orders := Table(tx, s.Orders.repo)
+		shipments := Table(tx, s.repo)
+
+		shipment, ok := shipments.Get(shipmentId)
+		if !ok || shipment.OrderID != orderId {
+			return Shipment{}, errors.New("Shipment with ID " + strconv.Itoa(shipmentId) + " not found for order " + strconv.Itoa(orderId))
+		}
+		for _, known := range shipment.Events {
+			if known.Status == event.Status && known.At.Equal(event.At) {
+				log.Printf("Tracking event %s of shipment %d was already recorded\n", event.Status, shipmentId)
+				return shipment, nil
+			}
+		}
+		// carriers don't always report in order, the latest event gives the status
+		shipment.Events = append(shipment.Events, event)
+		sort.SliceStable(shipment.Events, func(i, j int) bool { return shipment.Events[i].At.Before(shipment.Events[j].At) })
+		shipment.Status = shipment.Events[len(shipment.Events)-1].Status
+		if err := shipments.Put(shipment.ID, shipment); err != nil {
+			return Shipment{}, err
+		}
+
+		order, ok := orders.Get(orderId)
+		if !ok {
+			return Shipment{}, errors.New("Order with ID " + strconv.Itoa(orderId) + " not found")
+		}
+		if err := advanceOrder(orders, shipments, order, event.At); err != nil {
+			return Shipment{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return Shipment{}, err
+		}
+		log.Printf("Shipment %d of order %d is now %s\n", shipment.ID, orderId, shipment.Status)
+		return shipment, nil
+	}
+}
+
+// packItems checks what the shipment carries against what the order has left to ship, and counts it
+// as shipped on the items of the order.
+func packItems(order *Order, shipment *Shipment) error {
+	if len(shipment.Items) == 0 {
+		for _, item := range order.Items {
+			if item.ToShip() > 0 {
+				shipment.Items = append(shipment.Items, ShipmentItem{BookID: item.BookID, Quantity: item.ToShip()})
+			}
+		}
+		if len(shipment.Items) == 0 {
+			return fmt.Errorf("%w: order %d has nothing left to ship", ErrInvalidTransition, order.ID)
+		}
+	}
+	// a book can be on several lines of the order, the copies shipped fill them in order
+	for _, packed := range shipment.Items {
+		found, ready, backordered, title := false, 0, 0, ""
+		for _, item := range order.Items {
+			if item.BookID == packed.BookID {
+				found, title = true, item.Title
+				ready += item.ToShip()
+				backordered += item.Backordered
+			}
+		}
+		if !found {
+			return errors.New("Book with ID " + strconv.Itoa(packed.BookID) + " is not in order " + strconv.Itoa(order.ID))
+		}
+		if packed.Quantity <= 0 {
+			return errors.New("Invalid quantity shipped for book " + title)
+		}
+		if packed.Quantity > ready {
+			return errors.New("Only " + strconv.Itoa(ready) + " copies of book " + title + " are ready to ship, " +
+				strconv.Itoa(backordered) + " are on backorder")
+		}
+		left := packed.Quantity
+		for i, item := range order.Items {
+			if item.BookID != packed.BookID || left == 0 {
+				continue
+			}
+			quantity := min(item.ToShip(), left)
+			order.Items[i].Shipped += quantity
+			left -= quantity
+		}
+	}
+	return nil
+}
+
+// advanceOrder moves the order as far as its shipments allow: packed once a label exists, shipped once
+// a parcel is on its way, delivered once every parcel is. While books are left to ship, the order is
+// partially shipped at most. An order that was cancelled or refunded meanwhile keeps its status, the
+// tracking is still recorded.
+func advanceOrder(orders *TxTable[Order], shipments *TxTable[Shipment], order Order, at time.Time) error {
+	left := 0
+	for _, item := range order.Items {
+		left += item.Quantity - item.Shipped
+	}
+	moving, delivered, onTheWay := 0, 0, 0
+	for _, shipment := range shipments.All() {
+		if shipment.OrderID != order.ID {
+			continue
+		}
+		// the parcels made before the orders could be split carried all of it
+		if len(shipment.Items) == 0 {
+			left = 0
+		}
+		status, ok := shipment.Status.OrderStatus()
+		if !ok {
+			continue
+		}
+		moving++
+		if status == OrderDelivered {
+			delivered++
+		}
+		if status == OrderShipped || status == OrderDelivered {
+			onTheWay++
+		}
+	}
+	var target OrderStatus
+	switch {
+	case moving == 0:
+		return nil
+	case left > 0 && onTheWay > 0:
+		target = OrderPartiallyShipped
+	case left > 0:
+		target = OrderPacked
+	case delivered == moving:
+		target = OrderDelivered
+	case onTheWay > 0:
+		target = OrderShipped
+	default:
+		target = OrderPacked
+	}
+	previous := order.Status
+	if err := order.AdvanceTo(target, at); err != nil {
+		log.Printf("Order %d stays %s: %v\n", order.ID, order.Status, err)
+		return nil
+	}
+	if order.Status == previous {
+		return nil
+	}
+	log.Printf("Order %d moved from %s to %s by its shipments\n", order.ID, previous, order.Status)
+	return orders.Put(order.ID, order)
+}
+
+func (s *InMemoryShipmentStore) LoadShipments(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemoryShipmentStore) SaveShipments(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
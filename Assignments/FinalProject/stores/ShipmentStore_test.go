@@ -0,0 +1,81 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShipmentsMoveTheOrder(t *testing.T) {
+	// the label is created now, the carrier events come after it
+	start := time.Now()
+	type event struct {
+		parcel int
+		status ShipmentStatus
+		at     time.Duration
+	}
+	tests := []struct {
+		name    string
+		parcels int
+		events  []event
+		want    OrderStatus
+	}{
+		{"label only", 1, nil, OrderPacked},
+		{"parcel on its way", 1, []event{{0, ShipmentInTransit, time.Hour}}, OrderShipped},
+		{"parcel delivered", 1, []event{{0, ShipmentInTransit, time.Hour}, {0, ShipmentDelivered, 2 * time.Hour}}, OrderDelivered},
+		{"late in transit event after the delivery", 1, []event{{0, ShipmentDelivered, 2 * time.Hour}, {0, ShipmentInTransit, time.Hour}}, OrderDelivered},
+		{"one of two parcels delivered", 2, []event{{0, ShipmentDelivered, time.Hour}}, OrderShipped},
+		{"both parcels delivered", 2, []event{{0, ShipmentDelivered, time.Hour}, {1, ShipmentDelivered, time.Hour}}, OrderDelivered},
+		{"exception doesn't move the order", 1, []event{{0, ShipmentException, time.Hour}}, OrderPacked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores, book, order := newTestOrder(t, 5, 2)
+			if _, err := stores.Orders.TransitionOrder(ctx, order.ID, OrderPaid); err != nil {
+				t.Fatal(err)
+			}
+			var parcels []Shipment
+			for i := 0; i < tt.parcels; i++ {
+				// the copies are split over the parcels
+				items := []ShipmentItem{{BookID: book.ID, Quantity: 2 / tt.parcels}}
+				shipment, err := stores.Shipments.CreateShipment(ctx, order.ID, Shipment{Carrier: "UPS", TrackingNumber: "1Z" + string(rune('A'+i)), Items: items})
+				if err != nil {
+					t.Fatal(err)
+				}
+				parcels = append(parcels, shipment)
+			}
+			for _, e := range tt.events {
+				if _, err := stores.Shipments.AddTrackingEvent(ctx, order.ID, parcels[e.parcel].ID, TrackingEvent{Status: e.status, At: start.Add(e.at)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, _ := stores.Orders.GetOrder(ctx, order.ID)
+			if got.Status != tt.want {
+				t.Fatalf("order is %s, want %s", got.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateShipmentChecks(t *testing.T) {
+	ctx := context.Background()
+	stores, _, order := newTestOrder(t, 5, 2)
+	if _, err := stores.Shipments.CreateShipment(ctx, order.ID, Shipment{Carrier: "UPS", TrackingNumber: "1Z"}); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("shipping an unpaid order: %v", err)
+	}
+	if _, err := stores.Orders.TransitionOrder(ctx, order.ID, OrderPaid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Shipments.CreateShipment(ctx, order.ID, Shipment{Carrier: "UPS"}); err == nil {
+		t.Fatal("a shipment without tracking number was created")
+	}
+	if _, err := stores.Shipments.CreateShipment(ctx, order.ID, Shipment{Carrier: "UPS", TrackingNumber: "1Z"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Shipments.CreateShipment(ctx, order.ID, Shipment{Carrier: "ups", TrackingNumber: "1Z"}); err == nil {
+		t.Fatal("a tracking number was used twice")
+	}
+}
@@ -0,0 +1,317 @@
+package stores
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------Definition of Snapshots--------------------------------
+// A generation is a consistent copy of the store files taken under one set of locks.
+// Generations live in database/snapshots/<generation>/ and the manifest ties their files together.
+const (
+	snapshotsDir         = "snapshots"
+	snapshotManifestFile = "manifest.json"
+	generationFormat     = "20060102T150405.000Z"
+)
+
+type snapshotStore interface {
+	txParticipant
+	snapshotFileName() string
+	encodeSnapshot() ([]byte, error)
+	stageSnapshot(raw []byte) (stagedSnapshot, error)
+}
+
+// stagedSnapshot is the content of a store read from a generation, ready to be swapped in.
+type stagedSnapshot struct {
+	apply func(ctx context.Context) error
+	undo  func(ctx context.Context) error
+}
+
+type SnapshotFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type SnapshotGeneration struct {
+	Generation string                  `json:"generation"`
+	CreatedAt  time.Time               `json:"created_at"`
+	JournalSeq int64                   `json:"journal_seq"`
+	Files      map[string]SnapshotFile `json:"files"`
+}
+
+type SnapshotManifest struct {
+	Generations []SnapshotGeneration `json:"generations"`
+}
+
+type SnapshotManager struct {
+	mu      sync.Mutex
+	Retain  int
+	Journal *Journal
+}
+
+func NewSnapshotManager(journal *Journal, retain int) *SnapshotManager {
+	return &SnapshotManager{Retain: retain, Journal: journal}
+}
+
+// writeFileAtomic never leaves a half written file behind: the data goes to a temporary file
+// that is synced and then renamed over the destination.
+func writeFileAtomic(fullPath string, data []byte) error {
+	dir := filepath.Dir(fullPath)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.Printf("Failed to create directory %s: %v\n", dir, err)
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *SnapshotManager) manifestPath() string {
+	return filepath.Join("database", snapshotsDir, snapshotManifestFile)
+}
+
+func (m *SnapshotManager) generationDir(generation string) string {
+	return filepath.Join("database", snapshotsDir, generation)
+}
+
+func (m *SnapshotManager) readManifest() (SnapshotManifest, error) {
+	var manifest SnapshotManifest
+	raw, err := os.ReadFile(m.manifestPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return manifest, err
+	}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return manifest, err
+	}
+	return manifest, nil
+}
+
+func (m *SnapshotManager) writeManifest(manifest SnapshotManifest) error {
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.manifestPath(), raw)
+}
+
+func (m *SnapshotManager) ListSnapshots(ctx context.Context) ([]SnapshotGeneration, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during snapshot listing")
+		return nil, ctx.Err()
+	default:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		manifest, err := m.readManifest()
+		if err != nil {
+			log.Printf("Failed to read snapshot manifest: %v\n", err)
+			return nil, err
+		}
+		return manifest.Generations, nil
+	}
+}
+
+func (m *SnapshotManager) CreateSnapshot(ctx context.Context, all *Stores) (SnapshotGeneration, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during snapshot creation")
+		return SnapshotGeneration{}, ctx.Err()
+	default:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		stores := all.snapshotStores()
+		locks := make([]TxLock, 0, len(stores))
+		for _, s := range stores {
+			locks = append(locks, ReadLock(s))
+		}
+		tx, err := BeginTransaction(ctx, locks...)
+		if err != nil {
+			return SnapshotGeneration{}, err
+		}
+		contents := make(map[string][]byte)
+		for _, s := range stores {
+			data, err := s.encodeSnapshot()
+			if err != nil {
+				tx.Rollback()
+				return SnapshotGeneration{}, err
+			}
+			contents[s.snapshotFileName()] = data
+		}
+		now := time.Now().UTC()
+		generation := SnapshotGeneration{
+			Generation: now.Format(generationFormat),
+			CreatedAt:  now,
+			JournalSeq: m.Journal.LastSeq(),
+			Files:      make(map[string]SnapshotFile),
+		}
+		tx.Rollback()
+
+		dir := m.generationDir(generation.Generation)
+		if _, err := os.Stat(dir); err == nil {
+			return SnapshotGeneration{}, errors.New("snapshot generation " + generation.Generation + " already exists")
+		}
+		for name, data := range contents {
+			if err := writeFileAtomic(filepath.Join(dir, name), data); err != nil {
+				log.Printf("Failed to write snapshot file %s: %v\n", name, err)
+				os.RemoveAll(dir)
+				return SnapshotGeneration{}, err
+			}
+			generation.Files[name] = SnapshotFile{Name: name, Size: int64(len(data)), SHA256: checksum(data)}
+		}
+
+		manifest, err := m.readManifest()
+		if err != nil {
+			log.Printf("Failed to read snapshot manifest: %v\n", err)
+			return SnapshotGeneration{}, err
+		}
+		manifest.Generations = append(manifest.Generations, generation)
+		sort.Slice(manifest.Generations, func(i, j int) bool {
+			return manifest.Generations[i].CreatedAt.Before(manifest.Generations[j].CreatedAt)
+		})
+		var expired []SnapshotGeneration
+		if m.Retain > 0 && len(manifest.Generations) > m.Retain {
+			expired = manifest.Generations[:len(manifest.Generations)-m.Retain]
+			manifest.Generations = manifest.Generations[len(manifest.Generations)-m.Retain:]
+		}
+		if err := m.writeManifest(manifest); err != nil {
+			log.Printf("Failed to write snapshot manifest: %v\n", err)
+			return SnapshotGeneration{}, err
+		}
+		for _, old := range expired {
+			if err := os.RemoveAll(m.generationDir(old.Generation)); err != nil {
+				log.Printf("Failed to remove expired snapshot %s: %v\n", old.Generation, err)
+			}
+		}
+
+		log.Printf("Snapshot generation %s created, %d generations kept\n", generation.Generation, len(manifest.Generations))
+		return generation, nil
+	}
+}
+
+// RestoreSnapshot replaces the content of every store with the given generation. The storage drivers
+// are rewritten first with the current journal sequence, so journal records written before the restore
+// are never replayed over the restored data, even if the server stops before the journal is compacted.
+// Every file is read before the stores are swapped, a restore that fails leaves them as they were.
+func (m *SnapshotManager) RestoreSnapshot(ctx context.Context, generation string, all *Stores) error {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during snapshot restore")
+		return ctx.Err()
+	default:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		manifest, err := m.readManifest()
+		if err != nil {
+			log.Printf("Failed to read snapshot manifest: %v\n", err)
+			return err
+		}
+		var found *SnapshotGeneration
+		for i := range manifest.Generations {
+			if manifest.Generations[i].Generation == generation {
+				found = &manifest.Generations[i]
+			}
+		}
+		if found == nil {
+			return errors.New("snapshot generation " + generation + " not found")
+		}
+
+		stores := all.snapshotStores()
+		contents := make(map[string][]byte)
+		for _, s := range stores {
+			file, ok := found.Files[s.snapshotFileName()]
+			if !ok {
+				// the store didn't exist yet when the generation was taken, it is restored empty
+				log.Printf("Snapshot generation %s has no %s, it is restored empty\n", generation, s.snapshotFileName())
+				contents[s.snapshotFileName()] = []byte("{}")
+				continue
+			}
+			raw, err := os.ReadFile(filepath.Join(m.generationDir(generation), file.Name))
+			if err != nil {
+				return err
+			}
+			if checksum(raw) != file.SHA256 {
+				return errors.New("snapshot file " + file.Name + " of generation " + generation + " is corrupted")
+			}
+			contents[s.snapshotFileName()] = raw
+		}
+
+		// the transaction is only used to hold every store lock while their content is swapped
+		locks := make([]TxLock, 0, len(stores))
+		for _, s := range stores {
+			locks = append(locks, WriteLock(s))
+		}
+		tx, err := BeginTransaction(ctx, locks...)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// every file is decoded and migrated before any store is touched, a bad file changes nothing
+		staged := make([]stagedSnapshot, 0, len(stores))
+		for _, s := range stores {
+			snapshot, err := s.stageSnapshot(contents[s.snapshotFileName()])
+			if err != nil {
+				log.Printf("Restore of generation %s aborted, %s could not be read: %v\n", generation, s.snapshotFileName(), err)
+				return err
+			}
+			staged = append(staged, snapshot)
+		}
+		for i, snapshot := range staged {
+			if err := snapshot.apply(ctx); err != nil {
+				log.Printf("Restore of generation %s aborted, %s could not be written: %v\n", generation, stores[i].snapshotFileName(), err)
+				for j := i; j >= 0; j-- {
+					if err := staged[j].undo(ctx); err != nil {
+						log.Printf("Failed to put back %s after the aborted restore: %v\n", stores[j].snapshotFileName(), err)
+					}
+				}
+				return err
+			}
+		}
+		if err := m.Journal.Compact(m.Journal.LastSeq()); err != nil {
+			return err
+		}
+
+		log.Printf("Snapshot generation %s restored\n", generation)
+		return nil
+	}
+}
@@ -0,0 +1,247 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// ----------------------------------------------Definition of Storage Drivers--------------------------------
+type StorageDrivers struct {
+	Authors         StorageDriver[Author]
+	Books           StorageDriver[Book]
+	Customers       StorageDriver[Customer]
+	Orders          StorageDriver[Order]
+	Promotions      StorageDriver[Promotion]
+	Shipments       StorageDriver[Shipment]
+	Payments        StorageDriver[PaymentAttempt]
+	Carts           StorageDriver[Cart]
+	IdempotencyKeys StorageDriver[IdempotencyRecord]
+	Reservations    StorageDriver[StockReservation]
+	StockMovements  StorageDriver[StockMovement]
+	StockAlerts     StorageDriver[StockAlert]
+	Suppliers       StorageDriver[Supplier]
+	PurchaseOrders  StorageDriver[PurchaseOrder]
+	Returns         StorageDriver[ReturnRequest]
+	closer          io.Closer
+}
+
+// Close releases what the drivers hold open, like the SQL database.
+func (d StorageDrivers) Close() error {
+	if d.closer == nil {
+		return nil
+	}
+	return d.closer.Close()
+}
+
+func JSONStorageDrivers() StorageDrivers {
+	return StorageDrivers{
+		Authors:         NewJSONFileDriver[Author](authorEntity),
+		Books:           NewJSONFileDriver[Book](bookEntity),
+		Customers:       NewJSONFileDriver[Customer](customerEntity),
+		Orders:          NewJSONFileDriver[Order](orderEntity),
+		Promotions:      NewJSONFileDriver[Promotion](promotionEntity),
+		Shipments:       NewJSONFileDriver[Shipment](shipmentEntity),
+		Payments:        NewJSONFileDriver[PaymentAttempt](paymentEntity),
+		Carts:           NewJSONFileDriver[Cart](cartEntity),
+		IdempotencyKeys: NewJSONFileDriver[IdempotencyRecord](idempotencyEntity),
+		Reservations:    NewJSONFileDriver[StockReservation](reservationEntity),
+		StockMovements:  NewJSONFileDriver[StockMovement](movementEntity),
+		StockAlerts:     NewJSONFileDriver[StockAlert](alertEntity),
+		Suppliers:       NewJSONFileDriver[Supplier](supplierEntity),
+		PurchaseOrders:  NewJSONFileDriver[PurchaseOrder](purchaseEntity),
+		Returns:         NewJSONFileDriver[ReturnRequest](returnEntity),
+	}
+}
+
+// MemoryStorageDrivers keep nothing on disk, they are meant for tests and throwaway servers.
+func MemoryStorageDrivers() StorageDrivers {
+	return StorageDrivers{
+		Authors:         &MemoryDriver[Author]{},
+		Books:           &MemoryDriver[Book]{},
+		Customers:       &MemoryDriver[Customer]{},
+		Orders:          &MemoryDriver[Order]{},
+		Promotions:      &MemoryDriver[Promotion]{},
+		Shipments:       &MemoryDriver[Shipment]{},
+		Payments:        &MemoryDriver[PaymentAttempt]{},
+		Carts:           &MemoryDriver[Cart]{},
+		IdempotencyKeys: &MemoryDriver[IdempotencyRecord]{},
+		Reservations:    &MemoryDriver[StockReservation]{},
+		StockMovements:  &MemoryDriver[StockMovement]{},
+		StockAlerts:     &MemoryDriver[StockAlert]{},
+		Suppliers:       &MemoryDriver[Supplier]{},
+		PurchaseOrders:  &MemoryDriver[PurchaseOrder]{},
+		Returns:         &MemoryDriver[ReturnRequest]{},
+	}
+}
+
+// JSONFileDriver keeps all the records of one entity in database/<entity>.json. It relies on the
+// journal between checkpoints, so Persist has nothing to do.
+type JSONFileDriver[T any] struct {
+	entity   string
+	fullPath string
+}
+
+func NewJSONFileDriver[T any](entity string) *JSONFileDriver[T] {
+	return &JSONFileDriver[T]{entity: entity, fullPath: filepath.Join("database", entity+".json")}
+}
+
+func (d *JSONFileDriver[T]) Load(ctx context.Context) (StoredState[T], error) {
+	select {
+	case <-ctx.Done():
+		log.Printf("Context canceled during %s loading\n", d.entity)
+		return StoredState[T]{}, ctx.Err()
+	default:
+		raw, err := os.ReadFile(d.fullPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				log.Printf("No existing %s database found in %s, starting fresh.\n", d.entity, d.fullPath)
+				return StoredState[T]{Records: make(map[int]T), NextID: 1}, nil
+			}
+			log.Printf("Failed to open file %s: %v\n", d.fullPath, err)
+			return StoredState[T]{}, err
+		}
+		state, err := decodeEntityFile[T](d.entity, raw)
+		if err != nil {
+			log.Printf("Failed to decode %s from file %s: %v\n", d.entity, d.fullPath, err)
+			return StoredState[T]{}, err
+		}
+		log.Printf("%s loaded successfully from %s\n", d.entity, d.fullPath)
+		return state, nil
+	}
+}
+
+func (d *JSONFileDriver[T]) Persist(ctx context.Context, changes []RecordChange[T], nextID int, journalSeq int64) error {
+	return nil
+}
+
+func (d *JSONFileDriver[T]) Checkpoint(ctx context.Context, state StoredState[T]) error {
+	select {
+	case <-ctx.Done():
+		log.Printf("Context canceled during %s saving\n", d.entity)
+		return ctx.Err()
+	default:
+		data, err := encodeEntityFile(d.entity, state)
+		if err != nil {
+			log.Printf("Failed to encode %s: %v\n", d.entity, err)
+			return err
+		}
+		if err := writeFileAtomic(d.fullPath, data); err != nil {
+			log.Printf("Failed to write %s to file %s: %v\n", d.entity, d.fullPath, err)
+			return err
+		}
+		log.Printf("%s saved successfully to %s\n", d.entity, d.fullPath)
+		return nil
+	}
+}
+
+func (d *JSONFileDriver[T]) Replace(ctx context.Context, state StoredState[T]) error {
+	return d.Checkpoint(ctx, state)
+}
+
+type MemoryDriver[T any] struct{}
+
+func (d *MemoryDriver[T]) Load(ctx context.Context) (StoredState[T], error) {
+	return StoredState[T]{Records: make(map[int]T), NextID: 1}, nil
+}
+
+func (d *MemoryDriver[T]) Persist(ctx context.Context, changes []RecordChange[T], nextID int, journalSeq int64) error {
+	return nil
+}
+
+func (d *MemoryDriver[T]) Checkpoint(ctx context.Context, state StoredState[T]) error {
+	return nil
+}
+
+func (d *MemoryDriver[T]) Replace(ctx context.Context, state StoredState[T]) error {
+	return nil
+}
+
+// OpenStorageDrivers picks the drivers named by the -storage flag: json, sql, kv or memory.
+func OpenStorageDrivers(kind string) (StorageDrivers, error) {
+	switch kind {
+	case "json", "":
+		return JSONStorageDrivers(), nil
+	case "sql":
+		db, err := OpenSQLDatabase("bookstore.db")
+		if err != nil {
+			return StorageDrivers{}, err
+		}
+		return SQLStorageDrivers(db), nil
+	case "kv":
+		return OpenKVStorageDrivers(filepath.Join("database", "kv"))
+	case "memory":
+		return MemoryStorageDrivers(), nil
+	default:
+		return StorageDrivers{}, errors.New("unknown storage " + kind + ", expected json, sql, kv or memory")
+	}
+}
+
+// ImportJSONDatabase loads the database/*.json files, with the journal replayed on top of them,
+// and writes every record to the target drivers. Existing records of the target are replaced.
+func ImportJSONDatabase(ctx context.Context, journal *Journal, target StorageDrivers) error {
+	stores := NewInMemoryStores(journal, JSONStorageDrivers())
+	if err := stores.Load(ctx); err != nil {
+		return err
+	}
+
+	// referenced records are written before the ones pointing at them
+	if err := importRepository(ctx, stores.Authors.repo, target.Authors); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Books.repo, target.Books); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Customers.repo, target.Customers); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Orders.repo, target.Orders); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Promotions.repo, target.Promotions); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Shipments.repo, target.Shipments); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Payments.repo, target.Payments); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Carts.repo, target.Carts); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Idempotency.repo, target.IdempotencyKeys); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Reservations.repo, target.Reservations); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Inventory.repo, target.StockMovements); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Alerts.repo, target.StockAlerts); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.Suppliers.repo, target.Suppliers); err != nil {
+		return err
+	}
+	if err := importRepository(ctx, stores.PurchaseOrders.repo, target.PurchaseOrders); err != nil {
+		return err
+	}
+	return importRepository(ctx, stores.Returns.repo, target.Returns)
+}
+
+func importRepository[T Entity[T]](ctx context.Context, repo *Repository[T], target StorageDriver[T]) error {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	if err := target.Replace(ctx, repo.state()); err != nil {
+		log.Printf("Failed to import %s: %v\n", repo.entity, err)
+		return err
+	}
+	log.Printf("Imported %d %s\n", len(repo.records), repo.entity)
+	return nil
+}
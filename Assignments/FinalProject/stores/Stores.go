@@ -0,0 +1,230 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// ----------------------------------------------Definition of Stores--------------------------------
+// Stores groups every store of the server. They share one journal and are linked to the ones they
+// need, so the snapshots, the saving and the routes get them all in one value.
+type Stores struct {
+	Authors        *InMemoryAuthorStore
+	Books          *InMemoryBookStore
+	Customers      *InMemoryCustomerStore
+	Orders         *InMemoryOrderStore
+	Promotions     *InMemoryPromotionStore
+	Shipments      *InMemoryShipmentStore
+	Payments       *InMemoryPaymentStore
+	Carts          *InMemoryCartStore
+	Idempotency    *InMemoryIdempotencyStore
+	Reservations   *InMemoryReservationStore
+	Inventory      *InMemoryInventoryStore
+	Alerts         *InMemoryAlertStore
+	Suppliers      *InMemorySupplierStore
+	PurchaseOrders *InMemoryPurchaseOrderStore
+	Returns        *InMemoryReturnStore
+}
+
+func NewInMemoryStores(journal *Journal, drivers StorageDrivers) *Stores {
+	authorStore := NewInMemoryAuthorStore(journal, drivers.Authors)
+	bookStore := NewInMemoryBookStore(journal, drivers.Books)
+	customerStore := NewInMemoryCustomerStore(journal, drivers.Customers)
+	orderStore := NewInMemoryOrderStore(journal, drivers.Orders)
+	promotionStore := NewInMemoryPromotionStore(journal, drivers.Promotions)
+	shipmentStore := NewInMemoryShipmentStore(journal, drivers.Shipments)
+	paymentStore := NewInMemoryPaymentStore(journal, drivers.Payments)
+	cartStore := NewInMemoryCartStore(journal, drivers.Carts)
+	idempotencyStore := NewInMemoryIdempotencyStore(journal, drivers.IdempotencyKeys)
+	reservationStore := NewInMemoryReservationStore(journal, drivers.Reservations)
+	inventoryStore := NewInMemoryInventoryStore(journal, drivers.StockMovements)
+	alertStore := NewInMemoryAlertStore(journal, drivers.StockAlerts)
+	supplierStore := NewInMemorySupplierStore(journal, drivers.Suppliers)
+	purchaseOrderStore := NewInMemoryPurchaseOrderStore(journal, drivers.PurchaseOrders)
+	returnStore := NewInMemoryReturnStore(journal, drivers.Returns)
+
+	authorStore.Books = bookStore
+	bookStore.Authors = authorStore
+	bookStore.Orders = orderStore
+	customerStore.Orders = orderStore
+	orderStore.Customers = customerStore
+	orderStore.Books = bookStore
+	orderStore.Promotions = promotionStore
+	promotionStore.Orders = orderStore
+	shipmentStore.Orders = orderStore
+	paymentStore.Orders = orderStore
+	customerStore.Carts = cartStore
+	cartStore.Customers = customerStore
+	cartStore.Books = bookStore
+	cartStore.Orders = orderStore
+	orderStore.Reservations = reservationStore
+	reservationStore.Books = bookStore
+	reservationStore.Orders = orderStore
+	bookStore.Inventory = inventoryStore
+	bookStore.Carts = cartStore
+	bookStore.PurchaseOrders = purchaseOrderStore
+	orderStore.Inventory = inventoryStore
+	reservationStore.Inventory = inventoryStore
+	inventoryStore.Books = bookStore
+	inventoryStore.Orders = orderStore
+	inventoryStore.Reservations = reservationStore
+	alertStore.Books = bookStore
+	supplierStore.PurchaseOrders = purchaseOrderStore
+	purchaseOrderStore.Suppliers = supplierStore
+	purchaseOrderStore.Books = bookStore
+	purchaseOrderStore.Inventory = inventoryStore
+	returnStore.Orders = orderStore
+	returnStore.Books = bookStore
+	returnStore.Inventory = inventoryStore
+	returnStore.Payments = paymentStore
+	return &Stores{Authors: authorStore, Books: bookStore, Customers: customerStore, Orders: orderStore, Promotions: promotionStore,
+		Shipments: shipmentStore, Payments: paymentStore, Carts: cartStore, Idempotency: idempotencyStore,
+		Reservations: reservationStore, Inventory: inventoryStore,
+		Alerts: alertStore, Suppliers: supplierStore, PurchaseOrders: purchaseOrderStore,
+		Returns: returnStore}
+}
+
+// Load reads every store, the referenced records first so the ones pointing at them resolve.
+func (s *Stores) Load(ctx context.Context) error {
+	if err := s.Authors.LoadAuthors(ctx); err != nil {
+		log.Printf("Failed to load authors: %v\n", err)
+		return err
+	}
+	if err := s.Books.LoadBooks(ctx); err != nil {
+		log.Printf("Failed to load books: %v\n", err)
+		return err
+	}
+	if err := s.Customers.LoadCustomers(ctx); err != nil {
+		log.Printf("Failed to load customers: %v\n", err)
+		return err
+	}
+	if err := s.Orders.LoadOrders(ctx); err != nil {
+		log.Printf("Failed to load orders: %v\n", err)
+		return err
+	}
+	if err := s.Promotions.LoadPromotions(ctx); err != nil {
+		log.Printf("Failed to load promotions: %v\n", err)
+		return err
+	}
+	if err := s.Shipments.LoadShipments(ctx); err != nil {
+		log.Printf("Failed to load shipments: %v\n", err)
+		return err
+	}
+	if err := s.Payments.LoadPayments(ctx); err != nil {
+		log.Printf("Failed to load payments: %v\n", err)
+		return err
+	}
+	if err := s.Carts.LoadCarts(ctx); err != nil {
+		log.Printf("Failed to load carts: %v\n", err)
+		return err
+	}
+	if err := s.Idempotency.LoadIdempotencyKeys(ctx); err != nil {
+		log.Printf("Failed to load idempotency keys: %v\n", err)
+		return err
+	}
+	if err := s.Reservations.LoadReservations(ctx); err != nil {
+		log.Printf("Failed to load reservations: %v\n", err)
+		return err
+	}
+	if err := s.Inventory.LoadStockMovements(ctx); err != nil {
+		log.Printf("Failed to load stock movements: %v\n", err)
+		return err
+	}
+	if err := s.Alerts.LoadStockAlerts(ctx); err != nil {
+		log.Printf("Failed to load stock alerts: %v\n", err)
+		return err
+	}
+	if err := s.Suppliers.LoadSuppliers(ctx); err != nil {
+		log.Printf("Failed to load suppliers: %v\n", err)
+		return err
+	}
+	if err := s.PurchaseOrders.LoadPurchaseOrders(ctx); err != nil {
+		log.Printf("Failed to load purchase orders: %v\n", err)
+		return err
+	}
+	if err := s.Returns.LoadReturns(ctx); err != nil {
+		log.Printf("Failed to load returns: %v\n", err)
+		return err
+	}
+	// books kept before the ledger existed start it with their current stock
+	if opened, err := s.Inventory.OpenBalances(ctx); err != nil {
+		log.Printf("Failed to open the stock ledger: %v\n", err)
+		return err
+	} else if opened > 0 {
+		log.Printf("Opening balances recorded for %d books\n", opened)
+	}
+	return nil
+}
+
+// Save checkpoints every store, a failing one doesn't stop the others from being saved.
+func (s *Stores) Save(ctx context.Context) error {
+	var errs []error
+	if err := s.Books.SaveBooks(ctx); err != nil {
+		log.Printf("Failed to save books: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Authors.SaveAuthors(ctx); err != nil {
+		log.Printf("Failed to save authors: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Customers.SaveCustomers(ctx); err != nil {
+		log.Printf("Failed to save customers: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Orders.SaveOrders(ctx); err != nil {
+		log.Printf("Failed to save orders: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Promotions.SavePromotions(ctx); err != nil {
+		log.Printf("Failed to save promotions: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Shipments.SaveShipments(ctx); err != nil {
+		log.Printf("Failed to save shipments: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Payments.SavePayments(ctx); err != nil {
+		log.Printf("Failed to save payments: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Carts.SaveCarts(ctx); err != nil {
+		log.Printf("Failed to save carts: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Idempotency.SaveIdempotencyKeys(ctx); err != nil {
+		log.Printf("Failed to save idempotency keys: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Reservations.SaveReservations(ctx); err != nil {
+		log.Printf("Failed to save reservations: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Inventory.SaveStockMovements(ctx); err != nil {
+		log.Printf("Failed to save stock movements: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Alerts.SaveStockAlerts(ctx); err != nil {
+		log.Printf("Failed to save stock alerts: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Suppliers.SaveSuppliers(ctx); err != nil {
+		log.Printf("Failed to save suppliers: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.PurchaseOrders.SavePurchaseOrders(ctx); err != nil {
+		log.Printf("Failed to save purchase orders: %v", err)
+		errs = append(errs, err)
+	}
+	if err := s.Returns.SaveReturns(ctx); err != nil {
+		log.Printf("Failed to save returns: %v", err)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Stores) snapshotStores() []snapshotStore {
+	return []snapshotStore{s.Authors.repo, s.Books.repo, s.Customers.repo, s.Orders.repo, s.Promotions.repo, s.Shipments.repo, s.Payments.repo,
+		s.Carts.repo, s.Idempotency.repo, s.Reservations.repo, s.Inventory.repo, s.Alerts.repo,
+		s.Suppliers.repo, s.PurchaseOrders.repo, s.Returns.repo}
+}
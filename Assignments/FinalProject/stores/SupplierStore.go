@@ -0,0 +1,140 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------Definition of SupplierMethods--------------------------------
+type InMemorySupplierStore struct {
+	repo           *Repository[Supplier]
+	PurchaseOrders *InMemoryPurchaseOrderStore
+}
+
+type SupplierStore interface {
+	CreateSupplier(ctx context.Context, supplier Supplier) (Supplier, error)
+	GetSupplier(ctx context.Context, id int) (Supplier, error)
+	UpdateSupplier(ctx context.Context, id int, supplier Supplier) (Supplier, error)
+	DeleteSupplier(ctx context.Context, id int) error
+	ListSuppliers(ctx context.Context) ([]Supplier, error)
+	LoadSuppliers(ctx context.Context) error
+	SaveSuppliers(ctx context.Context) error
+}
+
+func NewInMemorySupplierStore(journal *Journal, driver StorageDriver[Supplier]) *InMemorySupplierStore {
+	return &InMemorySupplierStore{repo: NewRepository[Supplier](supplierEntity, supplierStoreRank, journal, driver)}
+}
+
+func (s *InMemorySupplierStore) CreateSupplier(ctx context.Context, supplier Supplier) (Supplier, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during supplier creation")
+		return Supplier{}, ctx.Err()
+	default:
+		if err := validateSupplier(supplier); err != nil {
+			return Supplier{}, err
+		}
+		supplier.CreatedAt = time.Now()
+		return s.repo.Insert(ctx, supplier)
+	}
+}
+
+func (s *InMemorySupplierStore) GetSupplier(ctx context.Context, supplierId int) (Supplier, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during supplier retrieval:", supplierId)
+		return Supplier{}, ctx.Err()
+	default:
+		supplier, ok := s.repo.Find(supplierId)
+		if !ok {
+			log.Printf("Supplier with ID %d not found", supplierId)
+			return Supplier{}, errors.New("supplier with ID " + strconv.Itoa(supplierId) + " not found")
+		}
+		return supplier, nil
+	}
+}
+
+// UpdateSupplier replaces the supplier, its purchase orders keep the name they were placed under.
+func (s *InMemorySupplierStore) UpdateSupplier(ctx context.Context, supplierId int, supplier Supplier) (Supplier, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during supplier update:", supplierId)
+		return Supplier{}, ctx.Err()
+	default:
+		if err := validateSupplier(supplier); err != nil {
+			return Supplier{}, err
+		}
+		updated, err := s.repo.Update(ctx, supplierId, func(unchangedSupplier Supplier) (Supplier, error) {
+			supplier.CreatedAt = unchangedSupplier.CreatedAt
+			return supplier, nil
+		})
+		if errors.Is(err, ErrRecordNotFound) {
+			log.Printf("Supplier with ID %d not found", supplierId)
+			return Supplier{}, errors.New("supplier with ID " + strconv.Itoa(supplierId) + " not found")
+		}
+		return updated, err
+	}
+}
+
+func (s *InMemorySupplierStore) DeleteSupplier(ctx context.Context, supplierId int) error {
+	select {
+	case <-ctx.Done():
+		log.Printf("Request canceled during deletion of supplier ID %d", supplierId)
+		return ctx.Err()
+	default:
+		tx, err := BeginTransaction(ctx, WriteLock(s.repo), ReadLock(s.PurchaseOrders.repo))
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		suppliers := Table(tx, s.repo)
+
+		if _, ok := suppliers.Get(supplierId); !ok {
+			return errors.New("supplier with ID " + strconv.Itoa(supplierId) + " not found")
+		}
+		for _, purchaseOrder := range Table(tx, s.PurchaseOrders.repo).All() {
+			if purchaseOrder.SupplierID == supplierId {
+				errMsg := "Cannot delete supplier with ID " + strconv.Itoa(supplierId) + ", it has a purchase order with ID " + strconv.Itoa(purchaseOrder.ID)
+				log.Println(errMsg)
+				return errors.New(errMsg)
+			}
+		}
+		if err := suppliers.Delete(supplierId); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+}
+
+func (s *InMemorySupplierStore) ListSuppliers(ctx context.Context) ([]Supplier, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled during suppliers list retrieval")
+		return nil, ctx.Err()
+	default:
+		return s.repo.List(), nil
+	}
+}
+
+func (s *InMemorySupplierStore) LoadSuppliers(ctx context.Context) error {
+	return s.repo.Load(ctx)
+}
+
+func (s *InMemorySupplierStore) SaveSuppliers(ctx context.Context) error {
+	return s.repo.Save(ctx)
+}
+
+func validateSupplier(supplier Supplier) error {
+	if strings.TrimSpace(supplier.Name) == "" {
+		return errors.New("A supplier needs a name")
+	}
+	if supplier.LeadTimeDays < 0 {
+		return errors.New("The lead time of a supplier can't be negative")
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+)
+
+// ----------------------------------------------Definition of Transactions--------------------------------
+// Repositories are always locked in the order of their rank (authors, books, customers, orders, promotions,
+// shipments, payments, carts, idempotency keys, reservations, stock movements, stock alerts,
+// suppliers, purchase orders, returns) so two transactions touching the same repositories can never deadlock each other.
+const (
+	authorStoreRank = iota + 1
+	bookStoreRank
+	customerStoreRank
+	orderStoreRank
+	promotionStoreRank
+	shipmentStoreRank
+	paymentStoreRank
+	cartStoreRank
+	idempotencyStoreRank
+	reservationStoreRank
+	inventoryStoreRank
+	alertStoreRank
+	supplierStoreRank
+	purchaseOrderStoreRank
+	returnStoreRank
+)
+
+type txParticipant interface {
+	lockRank() int
+	mutex() *sync.RWMutex
+	journalRef() *Journal
+	newTable(writable bool) txTable
+}
+
+type txTable interface {
+	changes() ([]JournalChange, error)
+	apply()
+	persist(ctx context.Context, journalSeq int64)
+}
+
+type TxLock struct {
+	store txParticipant
+	write bool
+}
+
+func ReadLock(store txParticipant) TxLock {
+	return TxLock{store: store, write: false}
+}
+
+func WriteLock(store txParticipant) TxLock {
+	return TxLock{store: store, write: true}
+}
+
+// TxTable stages the changes made to one repository during a transaction. Reads see the staged
+// values first, and nothing reaches the repository until the transaction commits.
+type TxTable[T Entity[T]] struct {
+	repo         *Repository[T]
+	writable     bool
+	staged       map[int]*T
+	stagedNextID int
+}
+
+func (t *TxTable[T]) Get(id int) (T, bool) {
+	if item, ok := t.staged[id]; ok {
+		if item == nil {
+			var zero T
+			return zero, false
+		}
+		return *item, true
+	}
+	item, ok := t.repo.records[id]
+	return item, ok
+}
+
+func (t *TxTable[T]) All() []T {
+	ids := make([]int, 0, len(t.repo.records)+len(t.staged))
+	for id := range t.repo.records {
+		if _, ok := t.staged[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	for id, item := range t.staged {
+		if item != nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	items := make([]T, 0, len(ids))
+	for _, id := range ids {
+		item, _ := t.Get(id)
+		items = append(items, item)
+	}
+	return items
+}
+
+func (t *TxTable[T]) Put(id int, item T) error {
+	if !t.writable {
+		return errors.New("store is locked for reading only in this transaction")
+	}
+	t.staged[id] = &item
+	return nil
+}
+
+func (t *TxTable[T]) Delete(id int) error {
+	if !t.writable {
+		return errors.New("store is locked for reading only in this transaction")
+	}
+	t.staged[id] = nil
+	return nil
+}
+
+func (t *TxTable[T]) NextID() (int, error) {
+	if !t.writable {
+		return 0, errors.New("store is locked for reading only in this transaction")
+	}
+	id := t.stagedNextID
+	t.stagedNextID++
+	return id, nil
+}
+
+func (t *TxTable[T]) stagedIDs() []int {
+	ids := make([]int, 0, len(t.staged))
+	for id := range t.staged {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (t *TxTable[T]) changes() ([]JournalChange, error) {
+	var changes []JournalChange
+	for _, id := range t.stagedIDs() {
+		if t.staged[id] == nil {
+			changes = append(changes, deleteChange(t.repo.entity, id, t.stagedNextID))
+			continue
+		}
+		change, err := putChange(t.repo.entity, id, t.stagedNextID, *t.staged[id])
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (t *TxTable[T]) apply() {
+	for id, item := range t.staged {
+		if item == nil {
+			delete(t.repo.records, id)
+		} else {
+			t.repo.records[id] = *item
+		}
+	}
+	t.repo.nextID = t.stagedNextID
+}
+
+func (t *TxTable[T]) persist(ctx context.Context, journalSeq int64) {
+	changes := make([]RecordChange[T], 0, len(t.staged))
+	for _, id := range t.stagedIDs() {
+		changes = append(changes, RecordChange[T]{ID: id, Item: t.staged[id]})
+	}
+	t.repo.persist(ctx, changes, journalSeq)
+}
+
+type Transaction struct {
+	ctx     context.Context
+	locks   []TxLock
+	journal *Journal
+	tables  map[txParticipant]txTable
+	closed  bool
+}
+
+func BeginTransaction(ctx context.Context, locks ...TxLock) (*Transaction, error) {
+	select {
+	case <-ctx.Done():
+		log.Println("Request canceled before the transaction started")
+		return nil, ctx.Err()
+	default:
+	}
+
+	byRank := make(map[int]TxLock)
+	for _, l := range locks {
+		if existing, ok := byRank[l.store.lockRank()]; ok {
+			l.write = l.write || existing.write
+		}
+		byRank[l.store.lockRank()] = l
+	}
+	ordered := make([]TxLock, 0, len(byRank))
+	for _, l := range byRank {
+		ordered = append(ordered, l)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].store.lockRank() < ordered[j].store.lockRank()
+	})
+
+	tx := &Transaction{ctx: ctx, locks: ordered, tables: make(map[txParticipant]txTable)}
+	for _, l := range ordered {
+		if l.write {
+			l.store.mutex().Lock()
+		} else {
+			l.store.mutex().RLock()
+		}
+		tx.tables[l.store] = l.store.newTable(l.write)
+		if tx.journal == nil {
+			tx.journal = l.store.journalRef()
+		}
+	}
+	return tx, nil
+}
+
+// Table gives access to a repository locked by the transaction.
+func Table[T Entity[T]](tx *Transaction, repo *Repository[T]) *TxTable[T] {
+	table, ok := tx.tables[repo]
+	if !ok {
+		panic("repository " + repo.entity + " is not locked by this transaction")
+	}
+	return table.(*TxTable[T])
+}
+
+func (tx *Transaction) Commit() error {
+	if tx.closed {
+		return errors.New("transaction already closed")
+	}
+	defer tx.release()
+
+	var changes []JournalChange
+	for _, l := range tx.locks {
+		tableChanges, err := tx.tables[l.store].changes()
+		if err != nil {
+			return err
+		}
+		changes = append(changes, tableChanges...)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	seq, err := tx.journal.Append(changes)
+	if err != nil {
+		log.Printf("Transaction rolled back, its changes could not be journaled: %v\n", err)
+		return err
+	}
+
+	// the change is durable once journaled, a failing driver can't undo the commit anymore
+	persistCtx := context.WithoutCancel(tx.ctx)
+	for _, l := range tx.locks {
+		if l.write {
+			tx.tables[l.store].apply()
+			tx.tables[l.store].persist(persistCtx, seq)
+		}
+	}
+	return nil
+}
+
+// Rollback discards every staged change. It is safe to defer right after BeginTransaction,
+// it does nothing once the transaction has been committed.
+func (tx *Transaction) Rollback() {
+	if tx.closed {
+		return
+	}
+	tx.release()
+}
+
+func (tx *Transaction) release() {
+	tx.closed = true
+	for i := len(tx.locks) - 1; i >= 0; i-- {
+		if tx.locks[i].write {
+			tx.locks[i].store.mutex().Unlock()
+		} else {
+			tx.locks[i].store.mutex().RUnlock()
+		}
+	}
+}
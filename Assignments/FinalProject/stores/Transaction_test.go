@@ -0,0 +1,160 @@
+package stores
+
+import (
+	. "FinalProject/models"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rankedStore only records when its lock is taken, it has nothing to stage.
+type rankedStore struct {
+	rank   int
+	mu     sync.RWMutex
+	locked *[]int
+}
+
+func (s *rankedStore) lockRank() int         { return s.rank }
+func (s *rankedStore) journalRef() *Journal  { return nil }
+func (s *rankedStore) newTable(bool) txTable { return noTable{} }
+func (s *rankedStore) mutex() *sync.RWMutex {
+	*s.locked = append(*s.locked, s.rank)
+	return &s.mu
+}
+
+type noTable struct{}
+
+func (noTable) changes() ([]JournalChange, error) { return nil, nil }
+func (noTable) apply()                            {}
+func (noTable) persist(context.Context, int64)    {}
+
+func TestBeginTransactionLockOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		ranks []int
+		want  []int
+	}{
+		{"already ordered", []int{1, 2, 3}, []int{1, 2, 3}},
+		{"reversed", []int{3, 2, 1}, []int{1, 2, 3}},
+		{"shuffled", []int{4, 1, 3, 2}, []int{1, 2, 3, 4}},
+		{"same store twice", []int{2, 1, 2}, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var locked []int
+			stores := make(map[int]*rankedStore)
+			var locks []TxLock
+			for _, rank := range tt.ranks {
+				if stores[rank] == nil {
+					stores[rank] = &rankedStore{rank: rank, locked: &locked}
+				}
+				locks = append(locks, ReadLock(stores[rank]))
+			}
+
+			tx, err := BeginTransaction(context.Background(), locks...)
+			if err != nil {
+				t.Fatalf("BeginTransaction: %v", err)
+			}
+			got := append([]int(nil), locked...)
+			tx.Rollback()
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("locked %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("locked %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBeginTransactionWriteWinsOverRead(t *testing.T) {
+	var locked []int
+	store := &rankedStore{rank: 1, locked: &locked}
+
+	tx, err := BeginTransaction(context.Background(), ReadLock(store), WriteLock(store))
+	if err != nil {
+		t.Fatalf("BeginTransaction: %v", err)
+	}
+	if store.mu.TryRLock() {
+		store.mu.RUnlock()
+		t.Fatal("the store is only read locked, the write lock was lost")
+	}
+	tx.Rollback()
+	if !store.mu.TryLock() {
+		t.Fatal("the store is still locked after the rollback")
+	}
+	store.mu.Unlock()
+}
+
+func TestTransactionsInOppositeOrderDontDeadlock(t *testing.T) {
+	authors := NewRepository[Author](authorEntity, authorStoreRank, nil, &MemoryDriver[Author]{})
+	books := NewRepository[Book](bookEntity, bookStoreRank, nil, &MemoryDriver[Book]{})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, locks := range [][]TxLock{
+		{WriteLock(authors), WriteLock(books)},
+		{WriteLock(books), WriteLock(authors)},
+	} {
+		wg.Add(1)
+		go func(locks []TxLock) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				tx, err := BeginTransaction(context.Background(), locks...)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				tx.Rollback()
+			}
+		}(locks)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the transactions deadlocked")
+	}
+}
+
+func TestTransactionCommitAndRollback(t *testing.T) {
+	authors := NewRepository[Author](authorEntity, authorStoreRank, nil, &MemoryDriver[Author]{})
+	ctx := context.Background()
+
+	tx, err := BeginTransaction(ctx, WriteLock(authors))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := Table(tx, authors)
+	id, _ := table.NextID()
+	table.Put(id, Author{ID: id, FirstName: "Ann"})
+	if _, ok := table.Get(id); !ok {
+		t.Fatal("the transaction doesn't see its own change")
+	}
+	tx.Rollback()
+	if _, ok := authors.Find(id); ok {
+		t.Fatal("a rolled back change reached the repository")
+	}
+
+	tx, _ = BeginTransaction(ctx, WriteLock(authors))
+	table = Table(tx, authors)
+	id, _ = table.NextID()
+	table.Put(id, Author{ID: id, FirstName: "Bob"})
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if author, ok := authors.Find(id); !ok || author.FirstName != "Bob" {
+		t.Fatalf("committed author = %+v, %v", author, ok)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("a closed transaction committed again")
+	}
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	. "FinalProject/stores"
+)
+
+// StartCartExpiryBackgroundJob closes the carts left untouched past their expiry date, they then show
+// as expired in the abandoned carts.
+func StartCartExpiryBackgroundJob(ctx context.Context, cartStore CartStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Starting periodic cart expiry background job...")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping cart expiry background job.")
+			return
+		case <-ticker.C:
+			expired, err := cartStore.ExpireCarts(ctx)
+			if err != nil {
+				log.Printf("Error expiring carts: %v\n", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("%d carts expired\n", expired)
+			}
+		}
+	}
+}
@@ -18,3 +18,11 @@ func ExtractPathParamInt(r *http.Request) (int, error) {
 	}
 	return ID, nil
 }
+
+func ExtractPathParam(r *http.Request, position int) (string, error) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) <= position || parts[position] == "" {
+		return "", errors.New("Url parameter not provided")
+	}
+	return parts[position], nil
+}
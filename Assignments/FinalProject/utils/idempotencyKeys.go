@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	. "FinalProject/stores"
+)
+
+// StartIdempotencyKeyCleanupBackgroundJob deletes the stored responses whose TTL is over, so the
+// idempotency keys don't pile up between two restarts.
+func StartIdempotencyKeyCleanupBackgroundJob(ctx context.Context, idempotencyStore IdempotencyStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Starting periodic idempotency key cleanup background job...")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping idempotency key cleanup background job.")
+			return
+		case <-ticker.C:
+			purged, err := idempotencyStore.PurgeExpiredKeys(ctx)
+			if err != nil {
+				log.Printf("Error purging expired idempotency keys: %v\n", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d expired idempotency keys\n", purged)
+			}
+		}
+	}
+}
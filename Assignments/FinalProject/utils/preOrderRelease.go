@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	. "FinalProject/stores"
+)
+
+// StartPreOrderReleaseBackgroundJob allocates the pre-orders of the books released since the last run,
+// in the order they were placed.
+func StartPreOrderReleaseBackgroundJob(ctx context.Context, orderStore OrderStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Starting periodic pre-order release background job...")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping pre-order release background job.")
+			return
+		case <-ticker.C:
+			allocated, err := orderStore.ReleasePreOrders(ctx)
+			if err != nil {
+				log.Printf("Error releasing pre-orders: %v\n", err)
+				continue
+			}
+			if allocated > 0 {
+				log.Printf("%d pre-ordered copies allocated\n", allocated)
+			}
+		}
+	}
+}
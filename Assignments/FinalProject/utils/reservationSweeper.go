@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	. "FinalProject/stores"
+)
+
+// StartReservationSweeperBackgroundJob releases the books held by the orders not paid in time, the
+// orders are cancelled and the books can be ordered again.
+func StartReservationSweeperBackgroundJob(ctx context.Context, reservationStore ReservationStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Starting periodic reservation sweeper background job...")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping reservation sweeper background job.")
+			return
+		case <-ticker.C:
+			released, err := reservationStore.ReleaseExpiredReservations(ctx)
+			if err != nil {
+				log.Printf("Error releasing expired reservations: %v\n", err)
+				continue
+			}
+			if released > 0 {
+				log.Printf("%d expired reservations released\n", released)
+			}
+		}
+	}
+}
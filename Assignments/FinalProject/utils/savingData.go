@@ -3,28 +3,50 @@ package utils
 import (
 	"context"
 	"log"
+	"time"
 
 	. "FinalProject/stores"
 )
 
-func SaveAllData(ctx context.Context, bookStore *InMemoryBookStore, authorStore *InMemoryAuthorStore, customerStore *InMemoryCustomerStore, orderStore *InMemoryOrderStore) {
+func SaveAllData(ctx context.Context, stores *Stores) error {
 	log.Println("Saving data to files...")
+	err := stores.Save(ctx)
+	log.Println("Data saving completed.")
+	return err
+}
 
-	if err := bookStore.SaveBooks(ctx, "books.json"); err != nil {
-		log.Printf("Failed to save books: %v", err)
-	}
-
-	if err := authorStore.SaveAuthors(ctx, "authors.json"); err != nil {
-		log.Printf("Failed to save authors: %v", err)
+// CompactJournal writes fresh snapshots of every store, keeps a copy of them as a new snapshot
+// generation and then drops the journal records they cover. The journal is only compacted when
+// all the snapshots and the generation were written.
+func CompactJournal(ctx context.Context, snapshots *SnapshotManager, stores *Stores) error {
+	coveredSeq := snapshots.Journal.LastSeq()
+	if err := SaveAllData(ctx, stores); err != nil {
+		log.Printf("Journal not compacted, snapshots could not be saved: %v\n", err)
+		return err
 	}
-
-	if err := customerStore.SaveCustomers(ctx, "customers.json"); err != nil {
-		log.Printf("Failed to save customers: %v", err)
+	if _, err := snapshots.CreateSnapshot(ctx, stores); err != nil {
+		log.Printf("Journal not compacted, snapshot generation could not be created: %v\n", err)
+		return err
 	}
+	return snapshots.Journal.Compact(coveredSeq)
+}
 
-	if err := orderStore.SaveOrders(ctx, "orders.json"); err != nil {
-		log.Printf("Failed to save orders: %v", err)
+func StartJournalCompactionBackgroundJob(ctx context.Context, snapshots *SnapshotManager, stores *Stores, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Println("Starting periodic journal compaction background job...")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping journal compaction background job.")
+			return
+		case <-ticker.C:
+			log.Println("Triggering journal compaction...")
+			if err := CompactJournal(ctx, snapshots, stores); err != nil {
+				log.Printf("Error compacting journal: %v\n", err)
+			}
+		}
 	}
-
-	log.Println("Data saving completed.")
 }